@@ -0,0 +1,418 @@
+// Package client provides a typed client for the Motion HTTP API.
+//
+// Client implements blob.Store, along with blob.Lister, blob.Remover and
+// blob.HealthChecker, so that it can be used wherever a store is expected,
+// including as the store of another Motion instance.
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/filecoin-project/motion/api"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/ipfs/go-cid"
+)
+
+var (
+	_ blob.Store         = (*Client)(nil)
+	_ blob.Lister        = (*Client)(nil)
+	_ blob.Remover       = (*Client)(nil)
+	_ blob.HealthChecker = (*Client)(nil)
+)
+
+// Client is a client of the Motion HTTP API.
+type Client struct {
+	*options
+	endpoint *url.URL
+}
+
+// NewClient instantiates a new client of the Motion HTTP API at the given
+// endpoint, e.g. "http://localhost:40080".
+// See Option.
+func NewClient(endpoint string, o ...Option) (*Client, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Motion API endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Motion API endpoint: unsupported scheme %q", u.Scheme)
+	}
+	return &Client{
+		options:  opts,
+		endpoint: u,
+	}, nil
+}
+
+// Put stores the content read from the given reader as a blob. The metadata
+// and expected size and digests in the given options are sent along with the
+// content, and verified by the server. The content length is sent if known,
+// either from blob.WithExpectedSize or because the reader is an io.Seeker, in
+// which case blob.ErrSizeMismatch is returned without sending the content if
+// the two disagree.
+//
+// The returned descriptor carries the size of the content sent, and the time
+// at which the server responded as the modification time.
+func (c *Client) Put(ctx context.Context, r io.Reader, o ...blob.PutOption) (*blob.Descriptor, error) {
+	opts := blob.NewPutOptions(o...)
+	size := int64(-1)
+	if opts.ExpectedSize != nil {
+		size = int64(*opts.ExpectedSize)
+	}
+	// Seekable content can be rewound to retry the upload.
+	seeker, seekable := r.(io.Seeker)
+	var start int64
+	if seekable {
+		var err error
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		} else {
+			end, err := seeker.Seek(0, io.SeekEnd)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			if size >= 0 && size != end-start {
+				return nil, fmt.Errorf("%w: expected %d bytes but content has %d", blob.ErrSizeMismatch, size, end-start)
+			}
+			size = end - start
+		}
+	}
+
+	var body *countingReader
+	newRequest := func(attempt int) (*http.Request, error) {
+		if attempt > 0 {
+			if !seekable {
+				return nil, errNotReplayable
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		body = &countingReader{r: r}
+		req, err := c.authenticate(http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint.JoinPath("v0", "blob").String(), io.NopCloser(body)))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		setMetadataHeader(req.Header, opts.Metadata)
+		if opts.ExpectedSHA256 != nil {
+			req.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(opts.ExpectedSHA256)+":")
+		}
+		if opts.ExpectedMD5 != nil {
+			req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(opts.ExpectedMD5))
+		}
+		return req, nil
+	}
+	var response api.PostBlobResponse
+	if err := c.doJson(ctx, newRequest, http.StatusCreated, &response); err != nil {
+		return nil, err
+	}
+	var id blob.ID
+	if err := id.Decode(response.ID); err != nil {
+		return nil, fmt.Errorf("invalid blob ID in response: %w", err)
+	}
+	sha256, err := hex.DecodeString(response.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHA-256 digest in response: %w", err)
+	}
+	desc := &blob.Descriptor{
+		ID:               id,
+		Size:             body.n,
+		ModificationTime: time.Now(),
+		Metadata:         opts.Metadata,
+	}
+	if len(sha256) != 0 {
+		desc.SHA256 = sha256
+	}
+	return desc, nil
+}
+
+// Status gets the status of the blob with the given ID as returned by the
+// Motion API. blob.ErrBlobNotFound is returned if there is no such blob.
+func (c *Client) Status(ctx context.Context, id blob.ID) (*api.GetStatusResponse, error) {
+	var response api.GetStatusResponse
+	if err := c.doJson(ctx, c.newRequest(ctx, http.MethodGet, "v0", "blob", id.String(), "status"), http.StatusOK, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Describe gets the status of the blob with the given ID, including its
+// replicas. blob.ErrBlobNotFound is returned if there is no such blob.
+func (c *Client) Describe(ctx context.Context, id blob.ID) (*blob.Descriptor, error) {
+	response, err := c.Status(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	desc := &blob.Descriptor{
+		ID:               id,
+		Size:             response.Size,
+		ModificationTime: response.CreatedAt,
+	}
+	if response.SHA256 != "" {
+		sha256, err := hex.DecodeString(response.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SHA-256 digest in response: %w", err)
+		}
+		desc.SHA256 = sha256
+	}
+	if response.RootCID != "" {
+		root, err := cid.Decode(response.RootCID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root CID in response: %w", err)
+		}
+		desc.RootCID = root
+	}
+	if response.Metadata != nil {
+		desc.Metadata = blob.Metadata{
+			ContentType: response.Metadata.ContentType,
+			Filename:    response.Metadata.Filename,
+			Tags:        response.Metadata.Tags,
+			RetainUntil: response.Metadata.RetainUntil,
+			Policy:      response.Metadata.Policy,
+		}
+	}
+	if response.Replication != nil {
+		desc.TargetReplicas = response.Replication.Target
+		desc.ActiveReplicas = response.Replication.Achieved
+	}
+	for _, replica := range response.Replicas {
+		pieces := make([]blob.Piece, 0, len(replica.Pieces))
+		for _, piece := range replica.Pieces {
+			pieces = append(pieces, blob.Piece{
+				Expiration:  piece.Expiration,
+				LastUpdated: piece.LastVerified,
+				PieceCID:    piece.PieceCID,
+				Status:      piece.Status,
+				PieceSize:   piece.PieceSize,
+				PayloadCID:  piece.PayloadCID,
+				DealID:      piece.DealID,
+				ProposalID:  piece.ProposalID,
+				Client:      piece.Client,
+				Verified:    piece.Verified,
+				Price:       piece.Price,
+				StartEpoch:  piece.StartEpoch,
+				EndEpoch:    piece.EndEpoch,
+			})
+		}
+		desc.Replicas = append(desc.Replicas, blob.Replica{
+			Provider: replica.Provider,
+			Pieces:   pieces,
+		})
+	}
+	for _, pack := range response.Packs {
+		desc.Packs = append(desc.Packs, blob.Pack(pack))
+	}
+	for _, repair := range response.Repairs {
+		desc.Repairs = append(desc.Repairs, blob.Repair(repair))
+	}
+	return desc, nil
+}
+
+// Get retrieves the content of the blob with the given ID. The blob is first
+// described to find its size, and its content is then fetched lazily, using
+// Range requests to seek and to resume reading after failure.
+// blob.ErrBlobNotFound is returned if there is no such blob.
+func (c *Client) Get(ctx context.Context, id blob.ID) (io.ReadSeekCloser, error) {
+	desc, err := c.Describe(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{
+		ctx:    ctx,
+		client: c,
+		id:     id,
+		size:   int64(desc.Size),
+	}, nil
+}
+
+// GetCAR retrieves the blob with the given ID as a CARv1 that contains the
+// UnixFS DAG representing its content, rooted at the root CID reported by
+// Describe. blob.ErrBlobNotFound is returned if there is no such blob.
+func (c *Client) GetCAR(ctx context.Context, id blob.ID) (io.ReadCloser, error) {
+	newRequest := func(attempt int) (*http.Request, error) {
+		req, err := c.newRequest(ctx, http.MethodGet, "v0", "blob", id.String())(attempt)
+		if err != nil {
+			return nil, err
+		}
+		req.URL.RawQuery = url.Values{"format": {"car"}}.Encode()
+		return req, nil
+	}
+	resp, err := c.do(ctx, newRequest)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newError(resp)
+	}
+	return resp.Body, nil
+}
+
+// ListBlobs lists a page of the blobs stored by the Motion API.
+// blob.ErrInvalidCursor is returned if the cursor is invalid.
+func (c *Client) ListBlobs(ctx context.Context, options blob.ListOptions) (*blob.ListResult, error) {
+	query := url.Values{}
+	if options.Cursor != "" {
+		query.Set("cursor", options.Cursor)
+	}
+	if options.Limit != 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if !options.CreatedAfter.IsZero() {
+		query.Set("createdAfter", options.CreatedAfter.Format(time.RFC3339))
+	}
+	if !options.CreatedBefore.IsZero() {
+		query.Set("createdBefore", options.CreatedBefore.Format(time.RFC3339))
+	}
+	if options.MinSize != 0 {
+		query.Set("minSize", strconv.FormatUint(options.MinSize, 10))
+	}
+	if options.MaxSize != 0 {
+		query.Set("maxSize", strconv.FormatUint(options.MaxSize, 10))
+	}
+	newRequest := func(int) (*http.Request, error) {
+		u := c.endpoint.JoinPath("v0", "blob")
+		u.RawQuery = query.Encode()
+		return c.authenticate(http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil))
+	}
+	var response api.ListBlobsResponse
+	if err := c.doJson(ctx, newRequest, http.StatusOK, &response); err != nil {
+		return nil, err
+	}
+	result := &blob.ListResult{
+		Descriptors: make([]*blob.Descriptor, 0, len(response.Blobs)),
+		NextCursor:  response.NextCursor,
+	}
+	for _, listed := range response.Blobs {
+		var id blob.ID
+		if err := id.Decode(listed.ID); err != nil {
+			return nil, fmt.Errorf("invalid blob ID in response: %w", err)
+		}
+		result.Descriptors = append(result.Descriptors, &blob.Descriptor{
+			ID:               id,
+			Size:             listed.Size,
+			ModificationTime: listed.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// Remove removes the blob with the given ID. blob.ErrBlobNotFound is returned if
+// there is no such blob.
+func (c *Client) Remove(ctx context.Context, id blob.ID) error {
+	resp, err := c.do(ctx, c.newRequest(ctx, http.MethodDelete, "v0", "blob", id.String()))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return newError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// CheckHealth checks the readiness of the Motion API. The first check,
+// "motion_api", fails if the readiness endpoint cannot be reached. It is
+// followed by the checks performed by the Motion API, each prefixed with
+// "remote_".
+func (c *Client) CheckHealth(ctx context.Context) []blob.HealthCheck {
+	checks := []blob.HealthCheck{{Name: "motion_api"}}
+	resp, err := c.do(ctx, c.newRequest(ctx, http.MethodGet, "readyz"))
+	if err != nil {
+		checks[0].Err = err
+		return checks
+	}
+	defer resp.Body.Close()
+	var response api.HealthResponse
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		checks[0].Err = newError(resp)
+		return checks
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		checks[0].Err = fmt.Errorf("failed to decode readiness response: %w", err)
+		return checks
+	}
+	if resp.StatusCode != http.StatusOK && len(response.Checks) == 0 {
+		checks[0].Err = fmt.Errorf("motion API is %s", response.Status)
+	}
+	for _, check := range response.Checks {
+		remote := blob.HealthCheck{Name: "remote_" + check.Name}
+		if check.Status != "ok" {
+			remote.Err = errors.New(check.Error)
+		}
+		checks = append(checks, remote)
+	}
+	return checks
+}
+
+// newRequest returns a function that instantiates authenticated requests with
+// the given method and path segments, and no body.
+func (c *Client) newRequest(ctx context.Context, method string, path ...string) func(int) (*http.Request, error) {
+	return func(int) (*http.Request, error) {
+		return c.authenticate(http.NewRequestWithContext(ctx, method, c.endpoint.JoinPath(path...).String(), nil))
+	}
+}
+
+func (c *Client) authenticate(req *http.Request, err error) (*http.Request, error) {
+	if err != nil {
+		return nil, err
+	}
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+	return req, nil
+}
+
+// setMetadataHeader sets the request headers via which the Motion API accepts
+// blob metadata.
+func setMetadataHeader(header http.Header, metadata blob.Metadata) {
+	if metadata.ContentType != "" {
+		header.Set("Content-Type", metadata.ContentType)
+	} else {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	if metadata.Filename != "" {
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": metadata.Filename}))
+	}
+	for tag, value := range metadata.Tags {
+		header.Set("X-Motion-Meta-"+tag, value)
+	}
+	if metadata.RetainUntil != nil {
+		header.Set("X-Motion-Retain-Until", metadata.RetainUntil.Format(time.RFC3339))
+	}
+	if metadata.Policy != "" {
+		header.Set("X-Motion-Policy", metadata.Policy)
+	}
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += uint64(n)
+	return n, err
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/motion/api/server"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, o ...server.Option) (*Client, *blob.LocalStore) {
+	store := blob.NewLocalStore(t.TempDir())
+	motion, err := server.NewHttpServer(store, append(o, server.WithUploadDir(t.TempDir()))...)
+	require.NoError(t, err)
+	api := httptest.NewServer(motion.ServeMux())
+	t.Cleanup(api.Close)
+	subject, err := NewClient(api.URL, WithRetryBackoff(time.Millisecond, time.Millisecond))
+	require.NoError(t, err)
+	return subject, store
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	subject, _ := newTestClient(t)
+	ctx := context.Background()
+	const content = "fish and lobster"
+	digest := sha256.Sum256([]byte(content))
+	metadata := blob.Metadata{ContentType: "text/plain", Filename: "catch.txt", Tags: map[string]string{"sea": "north"}}
+
+	desc, err := subject.Put(ctx, strings.NewReader(content), blob.WithMetadata(metadata), blob.WithExpectedSHA256(digest[:]))
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(content)), desc.Size)
+	require.Equal(t, digest[:], desc.SHA256)
+
+	described, err := subject.Describe(ctx, desc.ID)
+	require.NoError(t, err)
+	require.Equal(t, desc.Size, described.Size)
+	require.Equal(t, desc.SHA256, described.SHA256)
+	require.Equal(t, metadata, described.Metadata)
+	require.False(t, described.ModificationTime.IsZero())
+
+	reader, err := subject.Get(ctx, desc.ID)
+	require.NoError(t, err)
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, content, string(got))
+	offset, err := reader.Seek(-7, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)-7), offset)
+	got, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, "lobster", string(got))
+	_, err = reader.Seek(5, io.SeekStart)
+	require.NoError(t, err)
+	got = make([]byte, 3)
+	_, err = io.ReadFull(reader, got)
+	require.NoError(t, err)
+	require.Equal(t, "and", string(got))
+	require.NoError(t, reader.Close())
+
+	root, err := blob.RootOf(strings.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, root, described.RootCID)
+	car, err := subject.GetCAR(ctx, desc.ID)
+	require.NoError(t, err)
+	var wantCAR bytes.Buffer
+	_, err = blob.WriteCAR(ctx, &wantCAR, strings.NewReader(content), root)
+	require.NoError(t, err)
+	got, err = io.ReadAll(car)
+	require.NoError(t, err)
+	require.Equal(t, wantCAR.Bytes(), got)
+	require.NoError(t, car.Close())
+
+	listed, err := subject.ListBlobs(ctx, blob.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, listed.Descriptors, 1)
+	require.Equal(t, desc.ID, listed.Descriptors[0].ID)
+
+	require.NoError(t, subject.Remove(ctx, desc.ID))
+	require.ErrorIs(t, subject.Remove(ctx, desc.ID), blob.ErrBlobNotFound)
+	_, err = subject.Describe(ctx, desc.ID)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	_, err = subject.Get(ctx, desc.ID)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	_, err = subject.GetCAR(ctx, desc.ID)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+}
+
+func TestClientMapsErrors(t *testing.T) {
+	subject, _ := newTestClient(t, server.WithMaxBlobLength(4))
+	ctx := context.Background()
+
+	_, err := subject.Put(ctx, strings.NewReader("lobster"))
+	require.ErrorIs(t, err, blob.ErrBlobTooLarge)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+
+	wrong := sha256.Sum256([]byte("lobster"))
+	_, err = subject.Put(ctx, strings.NewReader("fish"), blob.WithExpectedSHA256(wrong[:]))
+	require.ErrorIs(t, err, blob.ErrDigestMismatch)
+	_, err = subject.Put(ctx, strings.NewReader("fish"), blob.WithExpectedSize(3))
+	require.ErrorIs(t, err, blob.ErrSizeMismatch)
+
+	_, err = subject.ListBlobs(ctx, blob.ListOptions{Cursor: "not-a-cursor"})
+	require.ErrorIs(t, err, blob.ErrInvalidCursor)
+}
+
+func TestClientRetries(t *testing.T) {
+	store := blob.NewLocalStore(t.TempDir())
+	motion, err := server.NewHttpServer(store, server.WithUploadDir(t.TempDir()))
+	require.NoError(t, err)
+	var requests atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail every other request as unavailable.
+		if requests.Add(1)%2 == 1 {
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		motion.ServeMux().ServeHTTP(w, r)
+	}))
+	defer api.Close()
+
+	subject, err := NewClient(api.URL, WithRetryBackoff(time.Millisecond, time.Millisecond))
+	require.NoError(t, err)
+	ctx := context.Background()
+	desc, err := subject.Put(ctx, strings.NewReader("fish"))
+	require.NoError(t, err)
+	_, err = subject.Describe(ctx, desc.ID)
+	require.NoError(t, err)
+	require.Equal(t, int32(4), requests.Load())
+
+	// Streamed content cannot be replayed, therefore is not retried.
+	_, err = subject.Put(ctx, io.MultiReader(strings.NewReader("fish")))
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+
+	subject, err = NewClient(api.URL, WithMaxRetries(0))
+	require.NoError(t, err)
+	requests.Store(0)
+	_, err = subject.Describe(ctx, desc.ID)
+	require.ErrorAs(t, err, &apiErr)
+}
+
+func TestClientAuthentication(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s3cr3t blob:read blob:write\n"), 0600))
+	store := blob.NewLocalStore(t.TempDir())
+	motion, err := server.NewHttpServer(store, server.WithUploadDir(t.TempDir()), server.WithAuthTokenFile(tokenFile))
+	require.NoError(t, err)
+	api := httptest.NewServer(motion.ServeMux())
+	defer api.Close()
+	ctx := context.Background()
+
+	subject, err := NewClient(api.URL)
+	require.NoError(t, err)
+	_, err = subject.Put(ctx, strings.NewReader("fish"))
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	subject, err = NewClient(api.URL, WithBearerToken("s3cr3t"))
+	require.NoError(t, err)
+	_, err = subject.Put(ctx, strings.NewReader("fish"))
+	require.NoError(t, err)
+}
+
+func TestClientCheckHealth(t *testing.T) {
+	subject, _ := newTestClient(t)
+	checks := subject.CheckHealth(context.Background())
+	require.Equal(t, []blob.HealthCheck{
+		{Name: "motion_api"},
+		{Name: "remote_local_store_writable"},
+		{Name: "remote_local_store_free_space"},
+	}, checks)
+
+	unreachable, err := NewClient("http://127.0.0.1:1", WithMaxRetries(0))
+	require.NoError(t, err)
+	checks = unreachable.CheckHealth(context.Background())
+	require.Len(t, checks, 1)
+	require.Error(t, checks[0].Err)
+}
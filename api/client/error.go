@@ -0,0 +1,68 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/motion/api"
+	"github.com/filecoin-project/motion/blob"
+)
+
+// Error is an error response returned by the Motion API.
+// Responses that correspond to a blob package error unwrap to that error, so
+// that they can be checked via errors.Is, e.g. blob.ErrBlobNotFound.
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Message is the error message in the response body, if any.
+	Message string
+	cause   error
+}
+
+// errorMessagePrefixes maps the prefixes of error messages returned by the
+// Motion API to the blob package error they correspond to.
+var errorMessagePrefixes = []struct {
+	prefix string
+	err    error
+}{
+	{prefix: "No blob is found for the given ID", err: blob.ErrBlobNotFound},
+	{prefix: "Blob length exceeds the maximum", err: blob.ErrBlobTooLarge},
+	{prefix: "Content-Length exceeds the maximum", err: blob.ErrBlobTooLarge},
+	{prefix: "Blob content does not match the declared digest", err: blob.ErrDigestMismatch},
+	{prefix: "Blob content does not match the declared length", err: blob.ErrSizeMismatch},
+	{prefix: "Invalid list cursor", err: blob.ErrInvalidCursor},
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("motion API responded with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("motion API responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// newError instantiates an Error from the given response, and closes its body.
+func newError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	e := &Error{StatusCode: resp.StatusCode}
+	var body api.ErrorResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body); err == nil {
+		e.Message = body.Error
+	}
+	for _, mapping := range errorMessagePrefixes {
+		if strings.HasPrefix(e.Message, mapping.prefix) {
+			e.cause = mapping.err
+			return e
+		}
+	}
+	if strings.Contains(e.Message, blob.ErrNotEnoughSpace.Error()) {
+		e.cause = blob.ErrNotEnoughSpace
+	}
+	return e
+}
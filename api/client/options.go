@@ -0,0 +1,82 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+type (
+	// Option is a configurable parameter in Client.
+	Option  func(*options) error
+	options struct {
+		httpClient      *http.Client
+		bearerToken     string
+		maxRetries      int
+		minRetryBackoff time.Duration
+		maxRetryBackoff time.Duration
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		httpClient:      http.DefaultClient,
+		maxRetries:      3,
+		minRetryBackoff: 250 * time.Millisecond,
+		maxRetryBackoff: 5 * time.Second,
+	}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithHttpClient sets the client with which requests are sent to the Motion API.
+// Defaults to http.DefaultClient.
+func WithHttpClient(c *http.Client) Option {
+	return func(o *options) error {
+		o.httpClient = c
+		return nil
+	}
+}
+
+// WithBearerToken sets the token with which requests are authenticated, either
+// a static token or a JWT that grants the scopes required by the calls made.
+// Defaults to no authentication.
+func WithBearerToken(token string) Option {
+	return func(o *options) error {
+		o.bearerToken = token
+		return nil
+	}
+}
+
+// WithMaxRetries sets the maximum number of times a request is retried after
+// failing with a network error, or with 429, 502, 503 or 504 status code.
+// Uploads are only retried if their content is an io.Seeker. Zero disables
+// retries.
+// Defaults to 3.
+func WithMaxRetries(n int) Option {
+	return func(o *options) error {
+		if n < 0 {
+			return errors.New("max retries must not be negative")
+		}
+		o.maxRetries = n
+		return nil
+	}
+}
+
+// WithRetryBackoff sets the minimum and maximum delay between retries. The
+// delay doubles after each retry, starting from min and capped at max.
+// Defaults to 250 milliseconds and 5 seconds respectively.
+func WithRetryBackoff(min, max time.Duration) Option {
+	return func(o *options) error {
+		if min <= 0 || max < min {
+			return errors.New("retry backoff must be positive with max at least min")
+		}
+		o.minRetryBackoff = min
+		o.maxRetryBackoff = max
+		return nil
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/filecoin-project/motion/blob"
+)
+
+var (
+	_ io.ReadSeekCloser = (*reader)(nil)
+
+	errNegativeOffset = errors.New("seek to negative offset")
+	errReaderClosed   = errors.New("reader is closed")
+)
+
+// reader reads blob content via the Motion API. Content is requested from the
+// current offset on first read after opening or seeking, and is requested again
+// from the offset at which reading failed, up to the maximum number of retries.
+type reader struct {
+	ctx    context.Context
+	client *Client
+	id     blob.ID
+	size   int64
+
+	offset  int64
+	body    io.ReadCloser
+	retries int
+	closed  bool
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, errReaderClosed
+	}
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	switch {
+	case err == nil:
+		r.retries = 0
+	case errors.Is(err, io.EOF):
+		r.closeBody()
+		if r.offset < r.size {
+			err = io.ErrUnexpectedEOF
+		}
+	default:
+		r.closeBody()
+		if r.retries < r.client.maxRetries && r.ctx.Err() == nil {
+			// Resume from the current offset at next read.
+			r.retries++
+			err = nil
+		}
+	}
+	return n, err
+}
+
+// open requests the content from the current offset.
+func (r *reader) open() error {
+	newRequest := func(attempt int) (*http.Request, error) {
+		req, err := r.client.newRequest(r.ctx, http.MethodGet, "v0", "blob", r.id.String())(attempt)
+		if err != nil {
+			return nil, err
+		}
+		if r.offset > 0 {
+			req.Header.Set("Range", "bytes="+strconv.FormatInt(r.offset, 10)+"-")
+		}
+		return req, nil
+	}
+	resp, err := r.client.do(r.ctx, newRequest)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && r.offset == 0:
+	case resp.StatusCode == http.StatusOK:
+		resp.Body.Close()
+		return fmt.Errorf("motion API ignored range request from offset %d", r.offset)
+	default:
+		return newError(resp)
+	}
+	r.body = resp.Body
+	return nil
+}
+
+func (r *reader) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, errReaderClosed
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if abs < 0 {
+		return 0, errNegativeOffset
+	}
+	if abs != r.offset {
+		r.closeBody()
+		r.offset = abs
+	}
+	return abs, nil
+}
+
+func (r *reader) Close() error {
+	r.closed = true
+	return r.closeBody()
+}
+
+func (r *reader) closeBody() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// errNotReplayable signals that a failed request cannot be retried because its
+// body cannot be read again.
+var errNotReplayable = errors.New("request body is not replayable")
+
+// requestFunc instantiates the request to send at the given attempt, starting
+// from zero.
+type requestFunc func(attempt int) (*http.Request, error)
+
+// do sends the request instantiated by newRequest, retrying with exponential
+// backoff on network errors and on responses with a status code that signals a
+// transient failure. The response of the last attempt is returned.
+func (c *Client) do(ctx context.Context, newRequest requestFunc) (*http.Response, error) {
+	backoff := c.minRetryBackoff
+	var lastResp *http.Response
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest(attempt)
+		if err != nil {
+			if errors.Is(err, errNotReplayable) {
+				return lastResp, lastErr
+			}
+			return nil, err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err == nil && !isTransientStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= c.maxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+		if lastResp != nil {
+			lastResp.Body.Close()
+		}
+		lastResp, lastErr = resp, err
+		if resp != nil {
+			// Drain the body so that the connection may be reused, while
+			// keeping the response in case the request cannot be retried.
+			lastResp = drained(resp)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if lastResp != nil {
+				lastResp.Body.Close()
+			}
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > c.maxRetryBackoff {
+			backoff = c.maxRetryBackoff
+		}
+	}
+}
+
+// doJson sends the request instantiated by newRequest, and decodes the response
+// body into v if the response status code is as expected. Otherwise, the error
+// response is returned as Error.
+func (c *Client) doJson(ctx context.Context, newRequest requestFunc, expectedStatus int, v any) error {
+	resp, err := c.do(ctx, newRequest)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != expectedStatus {
+		return newError(resp)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// drained reads the body of the given response into memory, up to a limit, and
+// closes it.
+func drained(resp *http.Response) *http.Response {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp
+}
@@ -7,15 +7,194 @@ type (
 	PostBlobResponse struct {
 		// ID is the unique identifier for the uploaded blob.
 		ID string `json:"id"`
+		// SHA256 is the hex encoded SHA-256 digest of the blob content, as
+		// computed by the server.
+		SHA256 string `json:"sha256,omitempty"`
+	}
+	// UploadResponse represents the state of a resumable upload session.
+	UploadResponse struct {
+		// ID is the unique identifier for the upload session.
+		ID string `json:"id"`
+		// Offset is the number of bytes uploaded so far, i.e. the offset at
+		// which the next chunk of data must be uploaded.
+		Offset uint64 `json:"offset"`
+		// Length is the total length of the upload, if known.
+		Length *uint64 `json:"length,omitempty"`
+		// ExpiresAt is the time after which the session is removed if no
+		// further data is uploaded.
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	// HealthResponse represents the liveness or readiness of Motion.
+	HealthResponse struct {
+		// Status is "ok" if Motion is live or ready, and "unavailable" otherwise.
+		Status string `json:"status"`
+		// Checks are the results of the individual checks performed, if any.
+		Checks []HealthCheck `json:"checks,omitempty"`
+	}
+	// HealthCheck is the result of a single readiness check.
+	HealthCheck struct {
+		// Name identifies the check.
+		Name string `json:"name"`
+		// Status is "ok" if the check passed, and "fail" otherwise.
+		Status string `json:"status"`
+		// Error is the reason for which the check failed, if any.
+		Error string `json:"error,omitempty"`
+	}
+	// PostWebhookRequest represents the request to subscribe an endpoint to
+	// blob lifecycle events.
+	PostWebhookRequest struct {
+		// URL is the absolute HTTP(S) URL to which events are delivered.
+		URL string `json:"url"`
+		// Events are the types of event to deliver. All events are delivered
+		// if empty.
+		Events []string `json:"events,omitempty"`
+		// Secret is the key with which deliveries are signed. A random secret
+		// is generated if empty.
+		Secret string `json:"secret,omitempty"`
+	}
+	// Webhook represents an endpoint subscribed to blob lifecycle events.
+	Webhook struct {
+		ID        string    `json:"id"`
+		URL       string    `json:"url"`
+		Events    []string  `json:"events,omitempty"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+	// PostWebhookResponse represents the response to a successful POST request
+	// to subscribe an endpoint.
+	PostWebhookResponse struct {
+		Webhook
+		// Secret is the key with which deliveries are signed. It is only ever
+		// returned on subscription.
+		Secret string `json:"secret"`
+	}
+	// ListWebhooksResponse represents the endpoints subscribed via the API.
+	ListWebhooksResponse struct {
+		Webhooks []Webhook `json:"webhooks"`
+	}
+	// WalletResponse represents the funds and DataCap of the wallet with which
+	// deals are made, as of its last check. Amounts are decimal strings, in
+	// attoFIL for funds and bytes for DataCap.
+	WalletResponse struct {
+		Address         string `json:"address"`
+		Balance         string `json:"balance"`
+		MarketEscrow    string `json:"marketEscrow"`
+		MarketLocked    string `json:"marketLocked"`
+		MarketAvailable string `json:"marketAvailable"`
+		DataCap         string `json:"dataCap"`
+		// FundsLow is whether the balance or the available market escrow is
+		// below the configured minimum, in which case new deals are held.
+		FundsLow bool `json:"fundsLow"`
+		// DataCapLow is whether the DataCap is below the configured minimum, in
+		// which case new verified deals are held.
+		DataCapLow bool `json:"dataCapLow"`
+		// CheckedAt is the time of the last successful check.
+		CheckedAt time.Time `json:"checkedAt"`
+		// Error is the reason for which the last check failed, if it did.
+		Error string `json:"error,omitempty"`
+	}
+	// EstimateRequest represents the request to estimate the cost of storing
+	// data on Filecoin.
+	EstimateRequest struct {
+		// Size is the size of the data in bytes.
+		Size uint64 `json:"size"`
+		// Policy is the name of the storage policy with which the data would be
+		// stored, as set by X-Motion-Policy. The default deal settings are used
+		// if empty.
+		Policy string `json:"policy,omitempty"`
+	}
+	// EstimateResponse represents the expected cost of storing data on
+	// Filecoin. Costs are decimal strings, in attoFIL.
+	EstimateResponse struct {
+		Size   uint64 `json:"size"`
+		Policy string `json:"policy,omitempty"`
+		// MaxCARSize is the maximum size in bytes of the CARs into which the
+		// data is packed.
+		MaxCARSize uint64 `json:"maxCarSize"`
+		// PieceSize is the padded size in bytes of the piece made from each CAR.
+		PieceSize uint64 `json:"pieceSize"`
+		// Pieces is the number of pieces made from the data.
+		Pieces uint64 `json:"pieces"`
+		// PaddedSize is the total padded size of the pieces in bytes.
+		PaddedSize uint64 `json:"paddedSize"`
+		// DealDuration is the duration of each deal in seconds.
+		DealDuration int64 `json:"dealDuration"`
+		// Deals is the number of deals across all storage providers.
+		Deals uint64 `json:"deals"`
+		// PricePerEpoch is the price of each deal per epoch.
+		PricePerEpoch string `json:"pricePerEpoch"`
+		// PricePerDeal is the price of each deal over its duration.
+		PricePerDeal string `json:"pricePerDeal"`
+		// TotalCost is the price of all the deals over their duration.
+		TotalCost string `json:"totalCost"`
+		// Providers break down the deals and their cost by storage provider.
+		Providers []ProviderEstimate `json:"providers"`
+	}
+	ProviderEstimate struct {
+		Provider string `json:"provider"`
+		Deals    uint64 `json:"deals"`
+		Cost     string `json:"cost"`
 	}
 	// ErrorResponse represents the response that signal an error has occurred.
 	ErrorResponse struct {
 		// Error is the description of the error.
 		Error string `json:"error"`
 	}
+	// ListBlobsResponse represents a single page of blobs listed by GET request.
+	ListBlobsResponse struct {
+		// Blobs are the listed blobs in ascending order of ID.
+		Blobs []Blob `json:"blobs"`
+		// NextCursor is the cursor from which to list the next page of blobs.
+		// Absent if there are no more blobs to list.
+		NextCursor string `json:"nextCursor,omitempty"`
+	}
+	// Blob summarises a stored blob.
+	Blob struct {
+		ID        string    `json:"id"`
+		Size      uint64    `json:"size"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
 	GetStatusResponse struct {
-		ID       string    `json:"id"`
-		Replicas []Replica `json:"replicas,omitempty"`
+		ID string `json:"id"`
+		// Size is the size of the blob in bytes.
+		Size uint64 `json:"size"`
+		// CreatedAt is the time at which the blob was stored.
+		CreatedAt time.Time `json:"createdAt"`
+		// SHA256 is the hex encoded SHA-256 digest of the blob content. Absent
+		// if the digest is unknown.
+		SHA256 string `json:"sha256,omitempty"`
+		// RootCID is the CID of the root of the UnixFS DAG that represents the
+		// blob content, i.e. the root of the CAR exported via the "car" format.
+		// Absent if the root CID is unknown.
+		RootCID  string    `json:"rootCid,omitempty"`
+		Metadata *Metadata `json:"metadata,omitempty"`
+		// Replication is the target and achieved number of replicas of the
+		// blob. Absent if the store does not replicate blobs.
+		Replication *Replication `json:"replication,omitempty"`
+		Replicas    []Replica    `json:"replicas,omitempty"`
+		// Packs are the CARs into which the blob was packed. Absent if the
+		// blob is not packed yet.
+		Packs []Pack `json:"packs,omitempty"`
+		// Repairs are the repairs made after replicas of the blob were lost,
+		// most recent first.
+		Repairs []Repair `json:"repairs,omitempty"`
+	}
+	// Metadata is the client-supplied information stored along with a blob.
+	Metadata struct {
+		ContentType string            `json:"contentType,omitempty"`
+		Filename    string            `json:"filename,omitempty"`
+		Tags        map[string]string `json:"tags,omitempty"`
+		RetainUntil *time.Time        `json:"retainUntil,omitempty"`
+		Policy      string            `json:"policy,omitempty"`
+	}
+	Replication struct {
+		// Target is the number of storage providers that should hold active
+		// deals for the blob.
+		Target uint `json:"target"`
+		// Achieved is the number of storage providers that hold active deals
+		// for the blob.
+		Achieved uint `json:"achieved"`
+		// Safe is whether the achieved number of replicas reached the target.
+		Safe bool `json:"safe"`
 	}
 	Replica struct {
 		Provider string  `json:"provider"`
@@ -26,5 +205,31 @@ type (
 		LastVerified time.Time `json:"lastVerified"`
 		PieceCID     string    `json:"pieceCid"`
 		Status       string    `json:"status"`
+		PieceSize    uint64    `json:"pieceSize,omitempty"`
+		PayloadCID   string    `json:"payloadCid,omitempty"`
+		DealID       uint64    `json:"dealId,omitempty"`
+		ProposalID   string    `json:"proposalId,omitempty"`
+		Client       string    `json:"client,omitempty"`
+		Verified     bool      `json:"verified"`
+		Price        string    `json:"price,omitempty"`
+		StartEpoch   int64     `json:"startEpoch,omitempty"`
+		EndEpoch     int64     `json:"endEpoch,omitempty"`
+	}
+	Pack struct {
+		JobID      int64  `json:"jobId"`
+		Offset     uint64 `json:"offset"`
+		Length     uint64 `json:"length"`
+		PieceCID   string `json:"pieceCid,omitempty"`
+		PieceSize  uint64 `json:"pieceSize,omitempty"`
+		PayloadCID string `json:"payloadCid,omitempty"`
+		CARSize    uint64 `json:"carSize,omitempty"`
+	}
+	Repair struct {
+		PieceCID     string    `json:"pieceCid"`
+		Lost         []string  `json:"lost"`
+		Replacements []string  `json:"replacements,omitempty"`
+		Restored     bool      `json:"restored"`
+		Time         time.Time `json:"time"`
+		Error        string    `json:"error,omitempty"`
 	}
 )
@@ -0,0 +1,54 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// s3Error is an S3 error code along with the HTTP status with which it is
+// returned.
+// See: https://docs.aws.amazon.com/AmazonS3/latest/API/ErrorResponses.html#ErrorCodeList
+type s3Error struct {
+	status  int
+	code    string
+	message string
+}
+
+var (
+	errResponseAccessDenied               = s3Error{http.StatusForbidden, "AccessDenied", "Access Denied"}
+	errResponseSignatureDoesNotMatch      = s3Error{http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided."}
+	errResponseInvalidAccessKeyID         = s3Error{http.StatusForbidden, "InvalidAccessKeyId", "The access key ID you provided does not exist in our records."}
+	errResponseRequestTimeTooSkewed       = s3Error{http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large."}
+	errResponseAuthorizationHeaderMalform = s3Error{http.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization header is malformed."}
+	errResponseKeyTooLong                 = s3Error{http.StatusBadRequest, "KeyTooLongError", "Your key is too long."}
+	errResponseInvalidBucketName          = s3Error{http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid."}
+	errResponseInvalidArgument            = s3Error{http.StatusBadRequest, "InvalidArgument", "Invalid Argument"}
+	errResponseInvalidRequest             = s3Error{http.StatusBadRequest, "InvalidRequest", "Invalid Request"}
+	errResponseInvalidDigest              = s3Error{http.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified is not valid."}
+	errResponseBadDigest                  = s3Error{http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received."}
+	errResponseContentSHA256Mismatch      = s3Error{http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed."}
+	errResponseIncompleteBody             = s3Error{http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header."}
+	errResponseEntityTooSmall             = s3Error{http.StatusBadRequest, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size."}
+	errResponseEntityTooLarge             = s3Error{http.StatusBadRequest, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size."}
+	errResponseMalformedXML               = s3Error{http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema."}
+	errResponseInvalidPart                = s3Error{http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found or the specified entity tag might not have matched the part's entity tag."}
+	errResponseInvalidPartOrder           = s3Error{http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order."}
+	errResponseNoSuchBucket               = s3Error{http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist."}
+	errResponseNoSuchKey                  = s3Error{http.StatusNotFound, "NoSuchKey", "The specified key does not exist."}
+	errResponseNoSuchUpload               = s3Error{http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist."}
+	errResponseMethodNotAllowed           = s3Error{http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource."}
+	errResponseBucketAlreadyOwnedByYou    = s3Error{http.StatusConflict, "BucketAlreadyOwnedByYou", "The bucket you tried to create already exists, and you own it."}
+	errResponseBucketNotEmpty             = s3Error{http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty."}
+	errResponseOperationAborted           = s3Error{http.StatusConflict, "OperationAborted", "A conflicting conditional operation is currently in progress against this resource."}
+	errResponseNotImplemented             = s3Error{http.StatusNotImplemented, "NotImplemented", "A header or query you provided implies functionality that is not implemented."}
+	errResponseServiceUnavailable         = s3Error{http.StatusServiceUnavailable, "ServiceUnavailable", "Please reduce your request rate."}
+)
+
+func errResponseInternalError(err error) s3Error {
+	return s3Error{http.StatusInternalServerError, "InternalError", fmt.Sprintf("We encountered an internal error: %s", err)}
+}
+
+func (e s3Error) withMessage(message string) s3Error {
+	e.message = message
+	return e
+}
@@ -0,0 +1,92 @@
+// Package s3 provides a gateway that exposes a subset of the Amazon S3 API in
+// front of any blob.Store.
+//
+// Objects are addressed using path-style requests, i.e. /<bucket>/<key>, and are
+// mapped to the blobs that hold their content via a persistent key index.
+// Supported operations are: ListBuckets, CreateBucket, HeadBucket, DeleteBucket,
+// GetBucketLocation, ListObjectsV2, PutObject, GetObject (including range
+// requests), HeadObject, DeleteObject, CreateMultipartUpload, UploadPart,
+// CompleteMultipartUpload and AbortMultipartUpload.
+package s3
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+
+	"github.com/filecoin-project/motion/blob"
+	"github.com/ipfs/go-log/v2"
+)
+
+var logger = log.Logger("motion/api/s3")
+
+// Gateway is an HTTP server that serves a subset of the S3 API, backed by a
+// blob.Store.
+type Gateway struct {
+	*options
+	httpServer *http.Server
+	store      blob.Store
+	index      *keyIndex
+	multipart  *multipartUploads
+}
+
+// NewGateway instantiates a new S3 gateway that stores and retrieves objects via
+// the given store.
+// See Option.
+func NewGateway(store blob.Store, o ...Option) (*Gateway, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	index, err := openKeyIndex(opts.dir)
+	if err != nil {
+		return nil, err
+	}
+	multipart, err := newMultipartUploads(filepath.Join(opts.dir, "multipart"))
+	if err != nil {
+		_ = index.close()
+		return nil, err
+	}
+	if len(opts.credentials) == 0 {
+		logger.Warn("S3 gateway authentication is disabled; all requests are served unauthenticated.")
+	}
+	gateway := &Gateway{
+		options:   opts,
+		store:     store,
+		index:     index,
+		multipart: multipart,
+	}
+	gateway.httpServer = &http.Server{
+		Handler: gateway,
+	}
+	return gateway, nil
+}
+
+// Start starts the S3 gateway HTTP server.
+// See Shutdown.
+func (g *Gateway) Start(_ context.Context) error {
+	listener, err := net.Listen("tcp", g.listenAddr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := g.httpServer.Serve(listener); errors.Is(err, http.ErrServerClosed) {
+			logger.Info("S3 gateway stopped successfully.")
+		} else {
+			logger.Errorw("S3 gateway stopped erroneously.", "err", err)
+		}
+	}()
+	logger.Infow("S3 gateway started successfully.", "address", listener.Addr())
+	return nil
+}
+
+// Shutdown shuts down the S3 gateway HTTP server and closes its key index.
+func (g *Gateway) Shutdown(ctx context.Context) error {
+	err := g.httpServer.Shutdown(ctx)
+	if closeErr := g.index.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,306 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+)
+
+type testGateway struct {
+	t   *testing.T
+	url string
+}
+
+func newTestGateway(t *testing.T, store *blob.LocalStore, dir string) *testGateway {
+	subject, err := NewGateway(store, WithDir(dir), WithMaxObjectLength(16<<20))
+	require.NoError(t, err)
+	server := httptest.NewServer(subject)
+	t.Cleanup(func() {
+		server.Close()
+		require.NoError(t, subject.Shutdown(context.Background()))
+	})
+	return &testGateway{t: t, url: server.URL}
+}
+
+func (tg *testGateway) do(method, path string, body []byte, header map[string]string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, tg.url+path, bytes.NewReader(body))
+	require.NoError(tg.t, err)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(tg.t, err)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(tg.t, err)
+	return resp, respBody
+}
+
+func requireErrorCode(t *testing.T, wantCode string, body []byte) {
+	var resp errorResponse
+	require.NoError(t, xml.Unmarshal(body, &resp), string(body))
+	require.Equal(t, wantCode, resp.Code)
+}
+
+func TestGateway_Objects(t *testing.T) {
+	storeDir := t.TempDir()
+	gatewayDir := filepath.Join(t.TempDir(), "s3")
+	store := blob.NewLocalStore(storeDir)
+	subject := newTestGateway(t, store, gatewayDir)
+
+	resp, body := subject.do(http.MethodPut, "/fish/key", []byte("lobster"), nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	requireErrorCode(t, "NoSuchBucket", body)
+
+	resp, body = subject.do(http.MethodPut, "/Invalid_Bucket", nil, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	requireErrorCode(t, "InvalidBucketName", body)
+
+	resp, _ = subject.do(http.MethodPut, "/fish", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, body = subject.do(http.MethodPut, "/fish", nil, nil)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	requireErrorCode(t, "BucketAlreadyOwnedByYou", body)
+	resp, _ = subject.do(http.MethodHead, "/fish", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	content := []byte("lobster and chips")
+	digest := md5.Sum(content)
+	resp, _ = subject.do(http.MethodPut, "/fish/dir/chips.txt", content, map[string]string{
+		"Content-Type":      "text/plain",
+		"Content-MD5":       base64.StdEncoding.EncodeToString(digest[:]),
+		"X-Amz-Meta-Source": "sea",
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	wantETag := fmt.Sprintf(`"%x"`, digest)
+	require.Equal(t, wantETag, resp.Header.Get("ETag"))
+
+	resp, body = subject.do(http.MethodPut, "/fish/bad-digest", content, map[string]string{
+		"Content-MD5": base64.StdEncoding.EncodeToString(make([]byte, md5.Size)),
+	})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	requireErrorCode(t, "BadDigest", body)
+
+	resp, body = subject.do(http.MethodGet, "/fish/dir/chips.txt", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, content, body)
+	require.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	require.Equal(t, "sea", resp.Header.Get("X-Amz-Meta-Source"))
+	require.Equal(t, wantETag, resp.Header.Get("ETag"))
+
+	resp, body = subject.do(http.MethodGet, "/fish/dir/chips.txt", nil, map[string]string{"Range": "bytes=4-6"})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "ter", string(body))
+
+	resp, _ = subject.do(http.MethodGet, "/fish/dir/chips.txt", nil, map[string]string{"If-None-Match": wantETag})
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, body = subject.do(http.MethodHead, "/fish/dir/chips.txt", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, body)
+	require.Equal(t, fmt.Sprint(len(content)), resp.Header.Get("Content-Length"))
+
+	resp, body = subject.do(http.MethodGet, "/fish/missing", nil, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	requireErrorCode(t, "NoSuchKey", body)
+
+	// Overwriting a key removes the blob it replaces.
+	blobsBefore, err := store.List(context.Background())
+	require.NoError(t, err)
+	resp, _ = subject.do(http.MethodPut, "/fish/dir/chips.txt", []byte("cod"), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	blobsAfter, err := store.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, blobsAfter, len(blobsBefore))
+
+	resp, body = subject.do(http.MethodDelete, "/fish", nil, nil)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	requireErrorCode(t, "BucketNotEmpty", body)
+
+	resp, _ = subject.do(http.MethodDelete, "/fish/dir/chips.txt", nil, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = subject.do(http.MethodDelete, "/fish/dir/chips.txt", nil, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	blobsAfter, err = store.List(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, blobsAfter)
+
+	resp, _ = subject.do(http.MethodDelete, "/fish", nil, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestGateway_ListObjectsV2(t *testing.T) {
+	gatewayDir := filepath.Join(t.TempDir(), "s3")
+	store := blob.NewLocalStore(t.TempDir())
+	subject := newTestGateway(t, store, gatewayDir)
+
+	resp, _ := subject.do(http.MethodPut, "/fish", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	for _, key := range []string{"a", "b/1", "b/2", "c/1", "d"} {
+		resp, _ := subject.do(http.MethodPut, "/fish/"+key, []byte(key), nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	list := func(query string) listBucketResult {
+		resp, body := subject.do(http.MethodGet, "/fish?list-type=2&"+query, nil, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		var result listBucketResult
+		require.NoError(t, xml.Unmarshal(body, &result))
+		return result
+	}
+	keysAndPrefixes := func(result listBucketResult) []string {
+		var got []string
+		for _, c := range result.Contents {
+			got = append(got, c.Key)
+		}
+		for _, p := range result.CommonPrefixes {
+			got = append(got, p.Prefix)
+		}
+		return got
+	}
+
+	result := list("")
+	require.Equal(t, []string{"a", "b/1", "b/2", "c/1", "d"}, keysAndPrefixes(result))
+	require.False(t, result.IsTruncated)
+
+	result = list("prefix=b/")
+	require.Equal(t, []string{"b/1", "b/2"}, keysAndPrefixes(result))
+
+	// Paginate with delimiter, where common prefixes count towards max-keys.
+	var pages [][]string
+	var token string
+	for {
+		result := list("delimiter=/&max-keys=2&continuation-token=" + token)
+		pages = append(pages, keysAndPrefixes(result))
+		if !result.IsTruncated {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	require.Equal(t, [][]string{{"a", "b/"}, {"d", "c/"}}, pages)
+
+	result = list("start-after=b/2")
+	require.Equal(t, []string{"c/1", "d"}, keysAndPrefixes(result))
+
+	resp, body := subject.do(http.MethodGet, "/fish?list-type=2&continuation-token=!", nil, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	requireErrorCode(t, "InvalidArgument", body)
+
+	resp, body = subject.do(http.MethodGet, "/shark?list-type=2", nil, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	requireErrorCode(t, "NoSuchBucket", body)
+}
+
+func TestGateway_MultipartUpload(t *testing.T) {
+	gatewayDir := filepath.Join(t.TempDir(), "s3")
+	store := blob.NewLocalStore(t.TempDir())
+	subject := newTestGateway(t, store, gatewayDir)
+
+	resp, _ := subject.do(http.MethodPut, "/fish", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	initiate := func() string {
+		resp, body := subject.do(http.MethodPost, "/fish/big?uploads", nil, map[string]string{"Content-Type": "application/x-fish"})
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		var result initiateMultipartUploadResult
+		require.NoError(t, xml.Unmarshal(body, &result))
+		require.NotEmpty(t, result.UploadID)
+		return result.UploadID
+	}
+	uploadPart := func(uploadID string, number int, content []byte) string {
+		resp, body := subject.do(http.MethodPut, fmt.Sprintf("/fish/big?partNumber=%d&uploadId=%s", number, uploadID), content, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		return resp.Header.Get("ETag")
+	}
+	complete := func(uploadID string, parts ...completedPart) (*http.Response, []byte) {
+		request, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+		require.NoError(t, err)
+		return subject.do(http.MethodPost, "/fish/big?uploadId="+uploadID, request, nil)
+	}
+
+	part1 := bytes.Repeat([]byte("a"), minPartSize)
+	part2 := []byte("tail")
+	uploadID := initiate()
+	etag2 := uploadPart(uploadID, 2, part2)
+	etag1 := uploadPart(uploadID, 1, []byte("replaced"))
+	etag1 = uploadPart(uploadID, 1, part1)
+
+	resp, body := complete(uploadID, completedPart{2, etag2}, completedPart{1, etag1})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	requireErrorCode(t, "InvalidPartOrder", body)
+	resp, body = complete(uploadID, completedPart{1, `"00000000000000000000000000000000"`}, completedPart{2, etag2})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	requireErrorCode(t, "InvalidPart", body)
+	resp, body = complete(uploadID, completedPart{1, etag1}, completedPart{3, etag2})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	requireErrorCode(t, "InvalidPart", body)
+
+	resp, body = complete(uploadID, completedPart{1, etag1}, completedPart{2, etag2})
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var result completeMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(body, &result))
+	require.True(t, strings.HasSuffix(result.ETag, `-2"`), result.ETag)
+
+	resp, body = subject.do(http.MethodGet, "/fish/big", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, append(part1, part2...), body)
+	require.Equal(t, "application/x-fish", resp.Header.Get("Content-Type"))
+	require.Equal(t, result.ETag, resp.Header.Get("ETag"))
+
+	resp, body = complete(uploadID, completedPart{1, etag1})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	requireErrorCode(t, "NoSuchUpload", body)
+
+	// Parts other than the last must be at least 5 MiB.
+	uploadID = initiate()
+	etag1 = uploadPart(uploadID, 1, part2)
+	etag2 = uploadPart(uploadID, 2, part2)
+	resp, body = complete(uploadID, completedPart{1, etag1}, completedPart{2, etag2})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	requireErrorCode(t, "EntityTooSmall", body)
+
+	resp, _ = subject.do(http.MethodDelete, "/fish/big?uploadId="+uploadID, nil, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, body = subject.do(http.MethodPut, "/fish/big?partNumber=1&uploadId="+uploadID, part2, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	requireErrorCode(t, "NoSuchUpload", body)
+}
+
+func TestGateway_IndexIsPersistent(t *testing.T) {
+	gatewayDir := filepath.Join(t.TempDir(), "s3")
+	store := blob.NewLocalStore(t.TempDir())
+
+	first, err := NewGateway(store, WithDir(gatewayDir))
+	require.NoError(t, err)
+	firstServer := httptest.NewServer(first)
+	tg := &testGateway{t: t, url: firstServer.URL}
+	resp, _ := tg.do(http.MethodPut, "/fish", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = tg.do(http.MethodPut, "/fish/kept", []byte("kept"), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = tg.do(http.MethodPut, "/fish/deleted", []byte("deleted"), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = tg.do(http.MethodDelete, "/fish/deleted", nil, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	firstServer.Close()
+	require.NoError(t, first.Shutdown(context.Background()))
+
+	second := newTestGateway(t, store, gatewayDir)
+	resp, body := second.do(http.MethodGet, "/fish/kept", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "kept", string(body))
+	resp, _ = second.do(http.MethodGet, "/fish/deleted", nil, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
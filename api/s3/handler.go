@@ -0,0 +1,636 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/motion/blob"
+)
+
+const (
+	// defaultMaxKeys is the default and maximum number of keys listed per page.
+	defaultMaxKeys = 1000
+	// maxKeyLength is the maximum length of object keys in bytes.
+	maxKeyLength = 1024
+	// maxCompleteMultipartUploadLength bounds the length of
+	// CompleteMultipartUpload request bodies.
+	maxCompleteMultipartUploadLength = 1 << 20 // 1 MiB
+	// defaultContentType is the content type of objects stored without one.
+	defaultContentType = "binary/octet-stream"
+)
+
+// ServeHTTP serves the S3 API request.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(headerAmzRequestID, newRequestID())
+	sig, errResp := g.authenticate(r)
+	if errResp != nil {
+		respondWithError(w, r, *errResp)
+		return
+	}
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case bucketName == "":
+		g.handleService(w, r)
+	case !isValidBucketName(bucketName):
+		respondWithError(w, r, errResponseInvalidBucketName)
+	case key == "":
+		g.handleBucket(w, r, sig, bucketName)
+	case len(key) > maxKeyLength:
+		respondWithError(w, r, errResponseKeyTooLong)
+	default:
+		g.handleObject(w, r, sig, bucketName, key)
+	}
+}
+
+func (g *Gateway) handleService(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, r, errResponseMethodNotAllowed)
+		return
+	}
+	result := listAllMyBucketsResult{
+		Xmlns: s3XmlNamespace,
+		Owner: owner{ID: "motion", DisplayName: "motion"},
+	}
+	for _, name := range g.index.listBuckets() {
+		createdAt, err := g.index.bucketCreatedAt(name)
+		if err != nil {
+			// Deleted since listed.
+			continue
+		}
+		result.Buckets = append(result.Buckets, bucketInfo{Name: name, CreationDate: createdAt})
+	}
+	respondWithXml(w, result, http.StatusOK)
+}
+
+func (g *Gateway) handleBucket(w http.ResponseWriter, r *http.Request, sig *sigV4, bucketName string) {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		switch {
+		case query.Get("list-type") == "2":
+			g.handleListObjectsV2(w, r, bucketName)
+		case query.Has("location"):
+			g.handleGetBucketLocation(w, r, bucketName)
+		default:
+			respondWithError(w, r, errResponseNotImplemented.withMessage("Only ListObjectsV2 and GetBucketLocation are supported on buckets."))
+		}
+	case http.MethodHead:
+		if _, err := g.index.bucketCreatedAt(bucketName); err != nil {
+			respondWithIndexError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		if len(query) != 0 {
+			respondWithError(w, r, errResponseNotImplemented)
+			return
+		}
+		// Discard the optional bucket configuration; buckets are not bound to
+		// any particular location.
+		if body, errResp := requestBody(r, sig); errResp != nil {
+			respondWithError(w, r, *errResp)
+			return
+		} else if _, err := io.Copy(io.Discard, io.LimitReader(body, maxCompleteMultipartUploadLength)); err != nil {
+			respondWithBodyError(w, r, err)
+			return
+		}
+		if err := g.index.createBucket(bucketName); err != nil {
+			respondWithIndexError(w, r, err)
+			return
+		}
+		logger.Infow("Created bucket", "bucket", bucketName)
+		w.Header().Set("Location", "/"+bucketName)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		if len(query) != 0 {
+			respondWithError(w, r, errResponseNotImplemented)
+			return
+		}
+		if err := g.index.deleteBucket(bucketName); err != nil {
+			respondWithIndexError(w, r, err)
+			return
+		}
+		logger.Infow("Deleted bucket", "bucket", bucketName)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPost:
+		respondWithError(w, r, errResponseNotImplemented)
+	default:
+		respondWithError(w, r, errResponseMethodNotAllowed)
+	}
+}
+
+func (g *Gateway) handleGetBucketLocation(w http.ResponseWriter, r *http.Request, bucketName string) {
+	if _, err := g.index.bucketCreatedAt(bucketName); err != nil {
+		respondWithIndexError(w, r, err)
+		return
+	}
+	location := locationConstraint{Xmlns: s3XmlNamespace}
+	// Buckets in us-east-1 have an empty location constraint.
+	if g.region != "us-east-1" {
+		location.Region = g.region
+	}
+	respondWithXml(w, location, http.StatusOK)
+}
+
+func (g *Gateway) handleListObjectsV2(w http.ResponseWriter, r *http.Request, bucketName string) {
+	query := r.URL.Query()
+	result := listBucketResult{
+		Xmlns:             s3XmlNamespace,
+		Name:              bucketName,
+		Prefix:            query.Get("prefix"),
+		Delimiter:         query.Get("delimiter"),
+		StartAfter:        query.Get("start-after"),
+		ContinuationToken: query.Get("continuation-token"),
+		EncodingType:      query.Get("encoding-type"),
+		MaxKeys:           defaultMaxKeys,
+	}
+	if value := query.Get("max-keys"); value != "" {
+		maxKeys, err := strconv.Atoi(value)
+		if err != nil || maxKeys < 0 {
+			respondWithError(w, r, errResponseInvalidArgument.withMessage("Invalid max-keys."))
+			return
+		}
+		result.MaxKeys = min(maxKeys, defaultMaxKeys)
+	}
+	if result.EncodingType != "" && result.EncodingType != "url" {
+		respondWithError(w, r, errResponseInvalidArgument.withMessage("Invalid Encoding Method specified in Request."))
+		return
+	}
+	after := result.StartAfter
+	if result.ContinuationToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(result.ContinuationToken)
+		if err != nil {
+			respondWithError(w, r, errResponseInvalidArgument.withMessage("The continuation token provided is incorrect."))
+			return
+		}
+		after = string(decoded)
+	}
+
+	listed, err := g.index.listObjects(bucketName, result.Prefix, after)
+	if err != nil {
+		respondWithIndexError(w, r, err)
+		return
+	}
+	encode := func(s string) string { return s }
+	if result.EncodingType == "url" {
+		encode = url.QueryEscape
+	}
+	// next is the position after which the next page starts, i.e. the last key
+	// listed or past all keys under the last common prefix listed.
+	var next, lastCommonPrefix string
+	for _, o := range listed {
+		if result.Delimiter != "" {
+			if i := strings.Index(o.Key[len(result.Prefix):], result.Delimiter); i >= 0 {
+				commonPrefix := o.Key[:len(result.Prefix)+i+len(result.Delimiter)]
+				if commonPrefix == lastCommonPrefix {
+					continue
+				}
+				if result.KeyCount == result.MaxKeys {
+					result.IsTruncated = true
+					break
+				}
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefixInfo{Prefix: encode(commonPrefix)})
+				result.KeyCount++
+				lastCommonPrefix = commonPrefix
+				// No valid UTF-8 key sorts after the common prefix followed by 0xFF.
+				next = commonPrefix + "\xff"
+				continue
+			}
+		}
+		if result.KeyCount == result.MaxKeys {
+			result.IsTruncated = true
+			break
+		}
+		result.Contents = append(result.Contents, objectInfo{
+			Key:          encode(o.Key),
+			LastModified: o.LastModified,
+			ETag:         o.ETag,
+			Size:         o.Size,
+			StorageClass: "STANDARD",
+		})
+		result.KeyCount++
+		next = o.Key
+	}
+	if result.IsTruncated && next != "" {
+		result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(next))
+	} else {
+		result.IsTruncated = false
+	}
+	result.Prefix = encode(result.Prefix)
+	result.Delimiter = encode(result.Delimiter)
+	result.StartAfter = encode(result.StartAfter)
+	respondWithXml(w, result, http.StatusOK)
+}
+
+func (g *Gateway) handleObject(w http.ResponseWriter, r *http.Request, sig *sigV4, bucketName, key string) {
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if uploadID != "" || query.Has("partNumber") || query.Has("versionId") || query.Has("acl") || query.Has("tagging") {
+			respondWithError(w, r, errResponseNotImplemented)
+			return
+		}
+		g.handleGetObject(w, r, bucketName, key)
+	case http.MethodPut:
+		switch {
+		case uploadID != "":
+			g.handleUploadPart(w, r, sig, bucketName, key, uploadID)
+		case r.Header.Get("X-Amz-Copy-Source") != "", len(query) != 0:
+			respondWithError(w, r, errResponseNotImplemented)
+		default:
+			g.handlePutObject(w, r, sig, bucketName, key)
+		}
+	case http.MethodDelete:
+		switch {
+		case uploadID != "":
+			g.handleAbortMultipartUpload(w, r, bucketName, key, uploadID)
+		case len(query) != 0:
+			respondWithError(w, r, errResponseNotImplemented)
+		default:
+			g.handleDeleteObject(w, r, bucketName, key)
+		}
+	case http.MethodPost:
+		switch {
+		case query.Has("uploads"):
+			g.handleCreateMultipartUpload(w, r, bucketName, key)
+		case uploadID != "":
+			g.handleCompleteMultipartUpload(w, r, sig, bucketName, key, uploadID)
+		default:
+			respondWithError(w, r, errResponseNotImplemented)
+		}
+	default:
+		respondWithError(w, r, errResponseMethodNotAllowed)
+	}
+}
+
+func (g *Gateway) handlePutObject(w http.ResponseWriter, r *http.Request, sig *sigV4, bucketName, key string) {
+	if _, err := g.index.bucketCreatedAt(bucketName); err != nil {
+		respondWithIndexError(w, r, err)
+		return
+	}
+	body, length, expectedMD5, errResp := g.objectBody(r, sig)
+	if errResp != nil {
+		respondWithError(w, r, *errResp)
+		return
+	}
+	obj := &object{
+		ContentType:  r.Header.Get("Content-Type"),
+		UserMetadata: userMetadataFromHeader(r.Header),
+	}
+	content := newObjectReader(body, g.maxObjectLength, length, expectedMD5)
+	if errResp := g.putBlob(r.Context(), key, obj, content); errResp != nil {
+		respondWithError(w, r, *errResp)
+		return
+	}
+	obj.ETag = strconv.Quote(hex.EncodeToString(content.md5.Sum(nil)))
+	if err := g.commitObject(r.Context(), bucketName, key, obj); err != nil {
+		respondWithIndexError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", obj.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *Gateway) handleGetObject(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	obj, err := g.index.getObject(bucketName, key)
+	if err != nil {
+		respondWithIndexError(w, r, err)
+		return
+	}
+	var id blob.ID
+	if err := id.Decode(obj.BlobID); err != nil {
+		logger.Errorw("Invalid blob ID in key index", "bucket", bucketName, "key", key, "id", obj.BlobID)
+		respondWithError(w, r, errResponseInternalError(err))
+		return
+	}
+	content, err := g.store.Get(r.Context(), id)
+	switch err {
+	case nil:
+	case blob.ErrBlobNotFound:
+		logger.Warnw("Key index refers to a blob that no longer exists", "bucket", bucketName, "key", key, "id", obj.BlobID)
+		respondWithError(w, r, errResponseNoSuchKey)
+		return
+	default:
+		logger.Errorw("Failed to get object content", "bucket", bucketName, "key", key, "id", obj.BlobID, "err", err)
+		respondWithError(w, r, errResponseInternalError(err))
+		return
+	}
+	defer content.Close()
+
+	header := w.Header()
+	header.Set("ETag", obj.ETag)
+	header.Set("Accept-Ranges", "bytes")
+	if obj.ContentType != "" {
+		header.Set("Content-Type", obj.ContentType)
+	} else {
+		header.Set("Content-Type", defaultContentType)
+	}
+	for name, value := range obj.UserMetadata {
+		header.Set(headerAmzMetaPrefix+name, value)
+	}
+	// Override response headers as requested via query parameters.
+	// See: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html
+	query := r.URL.Query()
+	for param, name := range responseHeaderOverrides {
+		if value := query.Get(param); value != "" {
+			header.Set(name, value)
+		}
+	}
+	http.ServeContent(w, r, "", obj.LastModified, content)
+}
+
+func (g *Gateway) handleDeleteObject(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	obj, err := g.index.deleteObject(bucketName, key)
+	switch err {
+	case nil:
+		logger.Infow("Deleted object", "bucket", bucketName, "key", key, "id", obj.BlobID)
+		g.removeBlob(r.Context(), obj.BlobID)
+	case errNoSuchKey:
+		// Deleting a non-existing key succeeds.
+	default:
+		respondWithIndexError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *Gateway) handleCreateMultipartUpload(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	if _, err := g.index.bucketCreatedAt(bucketName); err != nil {
+		respondWithIndexError(w, r, err)
+		return
+	}
+	upload := &multipartUpload{
+		Bucket:       bucketName,
+		Key:          key,
+		ContentType:  r.Header.Get("Content-Type"),
+		UserMetadata: userMetadataFromHeader(r.Header),
+	}
+	if err := g.multipart.create(upload); err != nil {
+		logger.Errorw("Failed to create multipart upload", "bucket", bucketName, "key", key, "err", err)
+		respondWithError(w, r, errResponseInternalError(err))
+		return
+	}
+	logger.Infow("Created multipart upload", "bucket", bucketName, "key", key, "uploadID", upload.ID)
+	respondWithXml(w, initiateMultipartUploadResult{
+		Xmlns:    s3XmlNamespace,
+		Bucket:   bucketName,
+		Key:      key,
+		UploadID: upload.ID,
+	}, http.StatusOK)
+}
+
+func (g *Gateway) handleUploadPart(w http.ResponseWriter, r *http.Request, sig *sigV4, bucketName, key, uploadID string) {
+	number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || number < 1 || number > maxPartNumber {
+		respondWithError(w, r, errResponseInvalidArgument.withMessage(fmt.Sprintf("Part number must be an integer between 1 and %d, inclusive.", maxPartNumber)))
+		return
+	}
+	upload, err := g.multipart.get(uploadID, bucketName, key)
+	if err != nil {
+		respondWithMultipartError(w, r, err)
+		return
+	}
+	body, length, expectedMD5, errResp := g.objectBody(r, sig)
+	if errResp != nil {
+		respondWithError(w, r, *errResp)
+		return
+	}
+	part, err := g.multipart.putPart(upload, number, newObjectReader(body, g.maxObjectLength, length, expectedMD5))
+	if err != nil {
+		respondWithMultipartError(w, r, err)
+		return
+	}
+	logger.Debugw("Uploaded part", "uploadID", uploadID, "partNumber", number, "size", part.Size)
+	w.Header().Set("ETag", strconv.Quote(part.MD5))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *Gateway) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Request, sig *sigV4, bucketName, key, uploadID string) {
+	upload, err := g.multipart.get(uploadID, bucketName, key)
+	if err != nil {
+		respondWithMultipartError(w, r, err)
+		return
+	}
+	release, err := g.multipart.acquire(uploadID)
+	if err != nil {
+		respondWithMultipartError(w, r, err)
+		return
+	}
+	defer release()
+
+	body, errResp := requestBody(r, sig)
+	if errResp != nil {
+		respondWithError(w, r, *errResp)
+		return
+	}
+	var request completeMultipartUpload
+	if err := xml.NewDecoder(io.LimitReader(body, maxCompleteMultipartUploadLength)).Decode(&request); err != nil {
+		var bodyErr bodyError
+		if errors.As(err, &bodyErr) {
+			respondWithError(w, r, bodyErr.s3Error)
+		} else {
+			respondWithError(w, r, errResponseMalformedXML)
+		}
+		return
+	}
+	if len(request.Parts) == 0 {
+		respondWithError(w, r, errResponseMalformedXML.withMessage("You must specify at least one part."))
+		return
+	}
+
+	uploaded, err := g.multipart.parts(upload)
+	if err != nil {
+		respondWithMultipartError(w, r, err)
+		return
+	}
+	partsByNumber := make(map[int]*uploadedPart, len(uploaded))
+	for _, part := range uploaded {
+		partsByNumber[part.Number] = part
+	}
+	var totalSize uint64
+	var parts []*uploadedPart
+	digests := md5.New()
+	for i := 1; i < len(request.Parts); i++ {
+		if request.Parts[i].PartNumber <= request.Parts[i-1].PartNumber {
+			respondWithError(w, r, errResponseInvalidPartOrder)
+			return
+		}
+	}
+	for i, requested := range request.Parts {
+		part, ok := partsByNumber[requested.PartNumber]
+		if !ok || strings.ToLower(strings.Trim(requested.ETag, `"`)) != part.MD5 {
+			respondWithError(w, r, errResponseInvalidPart)
+			return
+		}
+		if i < len(request.Parts)-1 && part.Size < minPartSize {
+			respondWithError(w, r, errResponseEntityTooSmall)
+			return
+		}
+		digest, err := hex.DecodeString(part.MD5)
+		if err != nil {
+			respondWithError(w, r, errResponseInvalidPart)
+			return
+		}
+		digests.Write(digest)
+		totalSize += uint64(part.Size)
+		parts = append(parts, part)
+	}
+	if totalSize > g.maxObjectLength {
+		respondWithError(w, r, errResponseEntityTooLarge)
+		return
+	}
+
+	obj := &object{
+		ContentType:  upload.ContentType,
+		UserMetadata: upload.UserMetadata,
+		ETag:         strconv.Quote(fmt.Sprintf("%x-%d", digests.Sum(nil), len(request.Parts))),
+	}
+	partsReader := g.multipart.concat(parts)
+	defer partsReader.Close()
+	content := newObjectReader(partsReader, g.maxObjectLength, int64(totalSize), nil)
+	if errResp := g.putBlob(r.Context(), key, obj, content); errResp != nil {
+		respondWithError(w, r, *errResp)
+		return
+	}
+	if err := g.commitObject(r.Context(), bucketName, key, obj); err != nil {
+		respondWithIndexError(w, r, err)
+		return
+	}
+	if err := g.multipart.remove(uploadID); err != nil {
+		logger.Warnw("Failed to remove completed multipart upload", "uploadID", uploadID, "err", err)
+	}
+	logger.Infow("Completed multipart upload", "bucket", bucketName, "key", key, "uploadID", uploadID, "parts", len(request.Parts), "size", obj.Size)
+	respondWithXml(w, completeMultipartUploadResult{
+		Xmlns:    s3XmlNamespace,
+		Location: "/" + bucketName + "/" + key,
+		Bucket:   bucketName,
+		Key:      key,
+		ETag:     obj.ETag,
+	}, http.StatusOK)
+}
+
+func (g *Gateway) handleAbortMultipartUpload(w http.ResponseWriter, r *http.Request, bucketName, key, uploadID string) {
+	if _, err := g.multipart.get(uploadID, bucketName, key); err != nil {
+		respondWithMultipartError(w, r, err)
+		return
+	}
+	release, err := g.multipart.acquire(uploadID)
+	if err != nil {
+		respondWithMultipartError(w, r, err)
+		return
+	}
+	defer release()
+	if err := g.multipart.remove(uploadID); err != nil {
+		logger.Errorw("Failed to remove aborted multipart upload", "uploadID", uploadID, "err", err)
+		respondWithError(w, r, errResponseInternalError(err))
+		return
+	}
+	logger.Infow("Aborted multipart upload", "bucket", bucketName, "key", key, "uploadID", uploadID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// objectBody returns the request body along with its expected length, or -1 if
+// unknown, and its expected MD5 digest, if specified via Content-MD5 header.
+func (g *Gateway) objectBody(r *http.Request, sig *sigV4) (io.Reader, int64, []byte, *s3Error) {
+	body, errResp := requestBody(r, sig)
+	if errResp != nil {
+		return nil, 0, nil, errResp
+	}
+	length, errResp := decodedContentLength(r)
+	if errResp != nil {
+		return nil, 0, nil, errResp
+	}
+	if length > 0 && uint64(length) > g.maxObjectLength {
+		return nil, 0, nil, &errResponseEntityTooLarge
+	}
+	var expectedMD5 []byte
+	if value := r.Header.Get("Content-MD5"); value != "" {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(decoded) != md5.Size {
+			return nil, 0, nil, &errResponseInvalidDigest
+		}
+		expectedMD5 = decoded
+	}
+	return body, length, expectedMD5, nil
+}
+
+// putBlob stores the given object content as a blob, and populates the blob ID
+// and size of the given object.
+func (g *Gateway) putBlob(ctx context.Context, key string, obj *object, content *objectReader) *s3Error {
+	metadata := blob.Metadata{
+		ContentType: obj.ContentType,
+		Filename:    path.Base(key),
+		Tags:        obj.UserMetadata,
+	}
+	desc, err := g.store.Put(ctx, content, blob.WithMetadata(metadata))
+	if err != nil {
+		var bodyErr bodyError
+		switch {
+		case errors.As(err, &bodyErr):
+			return &bodyErr.s3Error
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return &errResponseIncompleteBody
+		case errors.Is(err, blob.ErrBlobTooLarge):
+			return &errResponseEntityTooLarge
+		case errors.Is(err, blob.ErrNotEnoughSpace):
+			errResp := errResponseServiceUnavailable.withMessage("Insufficient storage space is available.")
+			return &errResp
+		default:
+			logger.Errorw("Failed to store object", "key", key, "err", err)
+			errResp := errResponseInternalError(err)
+			return &errResp
+		}
+	}
+	obj.BlobID = desc.ID.String()
+	obj.Size = desc.Size
+	obj.LastModified = time.Now().UTC()
+	return nil
+}
+
+// commitObject maps the given key to the given object in the key index. The
+// blob of any object replaced by it is removed, if the store supports removal.
+func (g *Gateway) commitObject(ctx context.Context, bucketName, key string, obj *object) error {
+	replaced, err := g.index.putObject(bucketName, key, obj)
+	if err != nil {
+		logger.Errorw("Failed to index object", "bucket", bucketName, "key", key, "id", obj.BlobID, "err", err)
+		g.removeBlob(ctx, obj.BlobID)
+		return err
+	}
+	logger.Infow("Stored object", "bucket", bucketName, "key", key, "id", obj.BlobID, "size", obj.Size)
+	if replaced != nil {
+		g.removeBlob(ctx, replaced.BlobID)
+	}
+	return nil
+}
+
+// removeBlob removes the blob with the given ID if the store supports removal.
+// Failure to remove is logged and otherwise ignored, since the blob is no
+// longer reachable via the gateway.
+func (g *Gateway) removeBlob(ctx context.Context, blobID string) {
+	remover, ok := g.store.(blob.Remover)
+	if !ok {
+		logger.Debugw("Store does not support removal; unreachable blob remains stored", "id", blobID)
+		return
+	}
+	var id blob.ID
+	if err := id.Decode(blobID); err != nil {
+		logger.Warnw("Cannot remove blob with invalid ID", "id", blobID, "err", err)
+		return
+	}
+	if err := remover.Remove(ctx, id); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+		logger.Warnw("Failed to remove unreachable blob", "id", blobID, "err", err)
+	}
+}
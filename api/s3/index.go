@@ -0,0 +1,341 @@
+package s3
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errNoSuchBucket    = errors.New("no such bucket")
+	errNoSuchKey       = errors.New("no such key")
+	errBucketNotEmpty  = errors.New("bucket is not empty")
+	errBucketExists    = errors.New("bucket already exists")
+	errIndexCorruption = errors.New("key index is corrupt")
+)
+
+const (
+	indexFileName = "index.log"
+
+	indexOpCreateBucket = "createBucket"
+	indexOpDeleteBucket = "deleteBucket"
+	indexOpPutObject    = "putObject"
+	indexOpDeleteObject = "deleteObject"
+)
+
+type (
+	// object describes an S3 object and the blob that holds its content.
+	object struct {
+		// BlobID is the ID of the blob holding the object content.
+		BlobID string `json:"blobId"`
+		// Size is the size of the object in bytes.
+		Size uint64 `json:"size"`
+		// ETag is the quoted entity tag of the object.
+		ETag string `json:"etag"`
+		// LastModified is the time at which the object was stored.
+		LastModified time.Time `json:"lastModified"`
+		// ContentType is the media type of the object, if specified on upload.
+		ContentType string `json:"contentType,omitempty"`
+		// UserMetadata holds the user-defined "x-amz-meta-*" metadata, keyed by
+		// lower-case name without the prefix.
+		UserMetadata map[string]string `json:"userMetadata,omitempty"`
+	}
+	bucket struct {
+		CreatedAt time.Time
+		objects   map[string]*object
+	}
+	listedObject struct {
+		Key string
+		*object
+	}
+	indexRecord struct {
+		Op        string    `json:"op"`
+		Bucket    string    `json:"bucket"`
+		Key       string    `json:"key,omitempty"`
+		Object    *object   `json:"object,omitempty"`
+		CreatedAt time.Time `json:"createdAt,omitempty"`
+	}
+
+	// keyIndex maps bucket names and object keys to the blobs that hold object
+	// content. The index is kept in memory and persisted as an append-only log of
+	// changes, which is compacted every time the index is opened.
+	keyIndex struct {
+		path string
+
+		mu      sync.RWMutex
+		log     *os.File
+		buckets map[string]*bucket
+	}
+)
+
+func openKeyIndex(dir string) (*keyIndex, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create key index directory: %w", err)
+	}
+	idx := &keyIndex{
+		path:    filepath.Join(dir, indexFileName),
+		buckets: make(map[string]*bucket),
+	}
+	if err := idx.replay(); err != nil {
+		return nil, err
+	}
+	if err := idx.compact(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *keyIndex) replay() error {
+	file, err := os.Open(idx.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to open key index: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	var line int
+	for scanner.Scan() {
+		line++
+		var record indexRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A partially written last record is the result of an interrupted
+			// append; the change it records was never acknowledged.
+			logger.Warnw("Ignoring malformed key index record", "line", line, "err", err)
+			continue
+		}
+		if err := idx.apply(&record); err != nil {
+			return fmt.Errorf("%w: line %d: %v", errIndexCorruption, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read key index: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the log such that it only contains the current state of the
+// index, and opens it for appending further changes.
+func (idx *keyIndex) compact() error {
+	temp, err := os.CreateTemp(filepath.Dir(idx.path), indexFileName+".*.temp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	writer := bufio.NewWriter(temp)
+	encoder := json.NewEncoder(writer)
+	for name, b := range idx.buckets {
+		if err := encoder.Encode(&indexRecord{Op: indexOpCreateBucket, Bucket: name, CreatedAt: b.CreatedAt}); err != nil {
+			return err
+		}
+		for key, obj := range b.objects {
+			if err := encoder.Encode(&indexRecord{Op: indexOpPutObject, Bucket: name, Key: key, Object: obj}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), idx.path); err != nil {
+		return fmt.Errorf("failed to replace key index: %w", err)
+	}
+	idx.log, err = os.OpenFile(idx.path, os.O_WRONLY|os.O_APPEND, 0)
+	return err
+}
+
+// apply applies the change recorded by the given record to the in-memory index.
+func (idx *keyIndex) apply(record *indexRecord) error {
+	switch record.Op {
+	case indexOpCreateBucket:
+		if _, exists := idx.buckets[record.Bucket]; exists {
+			return errBucketExists
+		}
+		idx.buckets[record.Bucket] = &bucket{
+			CreatedAt: record.CreatedAt,
+			objects:   make(map[string]*object),
+		}
+	case indexOpDeleteBucket:
+		b, exists := idx.buckets[record.Bucket]
+		if !exists {
+			return errNoSuchBucket
+		}
+		if len(b.objects) != 0 {
+			return errBucketNotEmpty
+		}
+		delete(idx.buckets, record.Bucket)
+	case indexOpPutObject:
+		b, exists := idx.buckets[record.Bucket]
+		if !exists {
+			return errNoSuchBucket
+		}
+		if record.Object == nil {
+			return fmt.Errorf("missing object for key %s", record.Key)
+		}
+		b.objects[record.Key] = record.Object
+	case indexOpDeleteObject:
+		b, exists := idx.buckets[record.Bucket]
+		if !exists {
+			return errNoSuchBucket
+		}
+		if _, exists := b.objects[record.Key]; !exists {
+			return errNoSuchKey
+		}
+		delete(b.objects, record.Key)
+	default:
+		return fmt.Errorf("unknown operation: %s", record.Op)
+	}
+	return nil
+}
+
+// append durably records the given change, and applies it to the in-memory
+// index. The caller must hold the write lock.
+func (idx *keyIndex) append(record *indexRecord) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := idx.log.Write(append(encoded, '\n')); err != nil {
+		return fmt.Errorf("failed to write key index: %w", err)
+	}
+	if err := idx.log.Sync(); err != nil {
+		return fmt.Errorf("failed to sync key index: %w", err)
+	}
+	return idx.apply(record)
+}
+
+func (idx *keyIndex) createBucket(name string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, exists := idx.buckets[name]; exists {
+		return errBucketExists
+	}
+	return idx.append(&indexRecord{Op: indexOpCreateBucket, Bucket: name, CreatedAt: time.Now().UTC()})
+}
+
+func (idx *keyIndex) deleteBucket(name string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	b, exists := idx.buckets[name]
+	if !exists {
+		return errNoSuchBucket
+	}
+	if len(b.objects) != 0 {
+		return errBucketNotEmpty
+	}
+	return idx.append(&indexRecord{Op: indexOpDeleteBucket, Bucket: name})
+}
+
+// bucketCreatedAt returns the creation time of the bucket, or errNoSuchBucket if
+// no such bucket exists.
+func (idx *keyIndex) bucketCreatedAt(name string) (time.Time, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	b, exists := idx.buckets[name]
+	if !exists {
+		return time.Time{}, errNoSuchBucket
+	}
+	return b.CreatedAt, nil
+}
+
+// listBuckets returns the names of all buckets in ascending order.
+func (idx *keyIndex) listBuckets() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	names := make([]string, 0, len(idx.buckets))
+	for name := range idx.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (idx *keyIndex) getObject(bucketName, key string) (*object, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	b, exists := idx.buckets[bucketName]
+	if !exists {
+		return nil, errNoSuchBucket
+	}
+	obj, exists := b.objects[key]
+	if !exists {
+		return nil, errNoSuchKey
+	}
+	return obj, nil
+}
+
+// putObject maps the given key to the given object, returning the object it
+// replaces if any.
+func (idx *keyIndex) putObject(bucketName, key string, obj *object) (*object, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	b, exists := idx.buckets[bucketName]
+	if !exists {
+		return nil, errNoSuchBucket
+	}
+	replaced := b.objects[key]
+	if err := idx.append(&indexRecord{Op: indexOpPutObject, Bucket: bucketName, Key: key, Object: obj}); err != nil {
+		return nil, err
+	}
+	return replaced, nil
+}
+
+// deleteObject removes the given key, returning the object it mapped to.
+func (idx *keyIndex) deleteObject(bucketName, key string) (*object, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	b, exists := idx.buckets[bucketName]
+	if !exists {
+		return nil, errNoSuchBucket
+	}
+	obj, exists := b.objects[key]
+	if !exists {
+		return nil, errNoSuchKey
+	}
+	if err := idx.append(&indexRecord{Op: indexOpDeleteObject, Bucket: bucketName, Key: key}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// listObjects returns the objects in the given bucket whose key has the given
+// prefix and sorts after the given key, in ascending order of key.
+func (idx *keyIndex) listObjects(bucketName, prefix, after string) ([]listedObject, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	b, exists := idx.buckets[bucketName]
+	if !exists {
+		return nil, errNoSuchBucket
+	}
+	var listed []listedObject
+	for key, obj := range b.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			listed = append(listed, listedObject{Key: key, object: obj})
+		}
+	}
+	sort.Slice(listed, func(i, j int) bool { return listed[i].Key < listed[j].Key })
+	return listed, nil
+}
+
+func (idx *keyIndex) close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.log.Close()
+}
@@ -0,0 +1,88 @@
+package s3
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const s3XmlNamespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+type (
+	// errorResponse is the body of S3 error responses.
+	// See: https://docs.aws.amazon.com/AmazonS3/latest/API/ErrorResponses.html
+	errorResponse struct {
+		XMLName   xml.Name `xml:"Error"`
+		Code      string   `xml:"Code"`
+		Message   string   `xml:"Message"`
+		Resource  string   `xml:"Resource,omitempty"`
+		RequestID string   `xml:"RequestId,omitempty"`
+	}
+	owner struct {
+		ID          string `xml:"ID"`
+		DisplayName string `xml:"DisplayName"`
+	}
+	listAllMyBucketsResult struct {
+		XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+		Xmlns   string       `xml:"xmlns,attr"`
+		Owner   owner        `xml:"Owner"`
+		Buckets []bucketInfo `xml:"Buckets>Bucket"`
+	}
+	bucketInfo struct {
+		Name         string    `xml:"Name"`
+		CreationDate time.Time `xml:"CreationDate"`
+	}
+	listBucketResult struct {
+		XMLName               xml.Name           `xml:"ListBucketResult"`
+		Xmlns                 string             `xml:"xmlns,attr"`
+		Name                  string             `xml:"Name"`
+		Prefix                string             `xml:"Prefix"`
+		Delimiter             string             `xml:"Delimiter,omitempty"`
+		StartAfter            string             `xml:"StartAfter,omitempty"`
+		ContinuationToken     string             `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string             `xml:"NextContinuationToken,omitempty"`
+		EncodingType          string             `xml:"EncodingType,omitempty"`
+		KeyCount              int                `xml:"KeyCount"`
+		MaxKeys               int                `xml:"MaxKeys"`
+		IsTruncated           bool               `xml:"IsTruncated"`
+		Contents              []objectInfo       `xml:"Contents"`
+		CommonPrefixes        []commonPrefixInfo `xml:"CommonPrefixes"`
+	}
+	objectInfo struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         uint64    `xml:"Size"`
+		StorageClass string    `xml:"StorageClass"`
+	}
+	commonPrefixInfo struct {
+		Prefix string `xml:"Prefix"`
+	}
+	locationConstraint struct {
+		XMLName xml.Name `xml:"LocationConstraint"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Region  string   `xml:",chardata"`
+	}
+	initiateMultipartUploadResult struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}
+	completeMultipartUpload struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}
+	completedPart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	completeMultipartUploadResult struct {
+		XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Location string   `xml:"Location"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		ETag     string   `xml:"ETag"`
+	}
+)
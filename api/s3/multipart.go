@@ -0,0 +1,276 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/motion/blob"
+)
+
+var (
+	errUploadNotFound = errors.New("no multipart upload is found with given ID")
+	errUploadInUse    = errors.New("multipart upload is in use")
+)
+
+const (
+	// minPartSize is the minimum size of all parts but the last of a multipart
+	// upload.
+	minPartSize = 5 << 20 // 5 MiB
+	// maxPartNumber is the maximum number of parts in a multipart upload.
+	maxPartNumber = 10000
+
+	multipartInfoFileName   = "info.json"
+	multipartPartFilePrefix = "part-"
+)
+
+type (
+	// multipartUploads manages multipart uploads staged on local disk. Each
+	// upload is stored as a directory, named by the upload ID, containing the
+	// upload info and the uploaded parts. Part files are named by their part
+	// number and MD5 digest, i.e. their entity tag.
+	multipartUploads struct {
+		dir string
+
+		mu    sync.Mutex
+		inUse map[string]struct{}
+	}
+	multipartUpload struct {
+		ID           string            `json:"-"`
+		Bucket       string            `json:"bucket"`
+		Key          string            `json:"key"`
+		ContentType  string            `json:"contentType,omitempty"`
+		UserMetadata map[string]string `json:"userMetadata,omitempty"`
+		CreatedAt    time.Time         `json:"createdAt"`
+	}
+	uploadedPart struct {
+		Number int
+		// MD5 is the hex encoded MD5 digest of the part content.
+		MD5  string
+		Size int64
+		path string
+	}
+)
+
+func newMultipartUploads(dir string) (*multipartUploads, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload directory: %w", err)
+	}
+	return &multipartUploads{
+		dir:   dir,
+		inUse: make(map[string]struct{}),
+	}, nil
+}
+
+func (mp *multipartUploads) uploadDir(id string) string {
+	return filepath.Join(mp.dir, id)
+}
+
+func (mp *multipartUploads) create(upload *multipartUpload) error {
+	id, err := blob.NewID()
+	if err != nil {
+		return err
+	}
+	upload.ID = id.String()
+	upload.CreatedAt = time.Now().UTC()
+	if err := os.Mkdir(mp.uploadDir(upload.ID), 0750); err != nil {
+		return fmt.Errorf("failed to create multipart upload directory: %w", err)
+	}
+	infoJson, err := json.Marshal(upload)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(mp.uploadDir(upload.ID), multipartInfoFileName), infoJson, 0640)
+	}
+	if err != nil {
+		_ = os.RemoveAll(mp.uploadDir(upload.ID))
+		return fmt.Errorf("failed to initialise multipart upload: %w", err)
+	}
+	return nil
+}
+
+// get returns the multipart upload with the given ID that targets the given
+// bucket and key, or errUploadNotFound if no such upload exists.
+func (mp *multipartUploads) get(id, bucketName, key string) (*multipartUpload, error) {
+	var decoded blob.ID
+	if err := decoded.Decode(id); err != nil {
+		return nil, errUploadNotFound
+	}
+	infoJson, err := os.ReadFile(filepath.Join(mp.uploadDir(id), multipartInfoFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errUploadNotFound
+		}
+		return nil, err
+	}
+	upload := &multipartUpload{ID: id}
+	if err := json.Unmarshal(infoJson, upload); err != nil {
+		return nil, fmt.Errorf("failed to decode multipart upload info: %w", err)
+	}
+	if upload.Bucket != bucketName || upload.Key != key {
+		return nil, errUploadNotFound
+	}
+	return upload, nil
+}
+
+// acquire marks the upload as in use, returning a function that releases it.
+// Returns errUploadInUse if the upload is already in use. This prevents
+// completing or aborting an upload while it is being completed.
+func (mp *multipartUploads) acquire(id string) (func(), error) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if _, ok := mp.inUse[id]; ok {
+		return nil, errUploadInUse
+	}
+	mp.inUse[id] = struct{}{}
+	return func() {
+		mp.mu.Lock()
+		delete(mp.inUse, id)
+		mp.mu.Unlock()
+	}, nil
+}
+
+// putPart stores the part with the given number, replacing any previously
+// uploaded part with the same number.
+func (mp *multipartUploads) putPart(upload *multipartUpload, number int, r io.Reader) (*uploadedPart, error) {
+	temp, err := os.CreateTemp(mp.uploadDir(upload.ID), "part.*.temp")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errUploadNotFound
+		}
+		return nil, err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	digest := md5.New()
+	written, err := io.Copy(io.MultiWriter(temp, digest), r)
+	if err != nil {
+		return nil, err
+	}
+	if err := temp.Close(); err != nil {
+		return nil, err
+	}
+	part := &uploadedPart{
+		Number: number,
+		MD5:    hex.EncodeToString(digest.Sum(nil)),
+		Size:   written,
+	}
+	part.path = filepath.Join(mp.uploadDir(upload.ID), fmt.Sprintf("%s%05d.%s", multipartPartFilePrefix, number, part.MD5))
+
+	// Serialise the replacement of parts, so that at most one file exists per
+	// part number.
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	previous, err := filepath.Glob(filepath.Join(mp.uploadDir(upload.ID), fmt.Sprintf("%s%05d.*", multipartPartFilePrefix, number)))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range previous {
+		if err := os.Remove(p); err != nil {
+			return nil, fmt.Errorf("failed to replace part: %w", err)
+		}
+	}
+	if err := os.Rename(temp.Name(), part.path); err != nil {
+		return nil, fmt.Errorf("failed to store part: %w", err)
+	}
+	return part, nil
+}
+
+// parts returns the uploaded parts of the given upload in ascending order of
+// part number.
+func (mp *multipartUploads) parts(upload *multipartUpload) ([]*uploadedPart, error) {
+	entries, err := os.ReadDir(mp.uploadDir(upload.ID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errUploadNotFound
+		}
+		return nil, err
+	}
+	var parts []*uploadedPart
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), multipartPartFilePrefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		numberStr, digest, ok := strings.Cut(name, ".")
+		if !ok {
+			continue
+		}
+		number, err := strconv.Atoi(numberStr)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, &uploadedPart{
+			Number: number,
+			MD5:    digest,
+			Size:   info.Size(),
+			path:   filepath.Join(mp.uploadDir(upload.ID), entry.Name()),
+		})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+// concat returns a reader that reads the content of the given parts in order.
+// Parts are opened one at a time as they are read.
+func (mp *multipartUploads) concat(parts []*uploadedPart) io.ReadCloser {
+	return &partsReader{parts: parts}
+}
+
+// remove removes the upload along with all of its parts.
+func (mp *multipartUploads) remove(id string) error {
+	return os.RemoveAll(mp.uploadDir(id))
+}
+
+type partsReader struct {
+	parts   []*uploadedPart
+	current *os.File
+}
+
+func (pr *partsReader) Read(p []byte) (int, error) {
+	for {
+		if pr.current == nil {
+			if len(pr.parts) == 0 {
+				return 0, io.EOF
+			}
+			file, err := os.Open(pr.parts[0].path)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return 0, errUploadNotFound
+				}
+				return 0, err
+			}
+			pr.current = file
+			pr.parts = pr.parts[1:]
+		}
+		n, err := pr.current.Read(p)
+		if err == io.EOF {
+			err = pr.current.Close()
+			pr.current = nil
+			if n == 0 && err == nil {
+				continue
+			}
+		}
+		return n, err
+	}
+}
+
+func (pr *partsReader) Close() error {
+	if pr.current != nil {
+		return pr.current.Close()
+	}
+	return nil
+}
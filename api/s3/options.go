@@ -0,0 +1,87 @@
+package s3
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type (
+	// Option is a configurable parameter in Gateway.
+	Option  func(*options) error
+	options struct {
+		listenAddr      string
+		dir             string
+		region          string
+		maxObjectLength uint64
+		credentials     map[string]string
+		now             func() time.Time
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		listenAddr:      "0.0.0.0:40081",
+		dir:             filepath.Join(os.TempDir(), "motion-s3"),
+		region:          "us-east-1",
+		maxObjectLength: 31 << 30, // 31 GiB
+		credentials:     make(map[string]string),
+		now:             time.Now,
+	}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithListenAddr sets the S3 gateway listen address.
+// Defaults to 0.0.0.0:40081 if unspecified.
+func WithListenAddr(addr string) Option {
+	return func(o *options) error {
+		o.listenAddr = addr
+		return nil
+	}
+}
+
+// WithDir sets the local directory at which the key index and in-progress
+// multipart uploads are stored.
+// Defaults to "motion-s3" under the OS temporary directory.
+// See: os.TempDir.
+func WithDir(dir string) Option {
+	return func(o *options) error {
+		o.dir = dir
+		return nil
+	}
+}
+
+// WithRegion sets the region against which request signatures are verified.
+// Defaults to "us-east-1".
+func WithRegion(region string) Option {
+	return func(o *options) error {
+		o.region = region
+		return nil
+	}
+}
+
+// WithMaxObjectLength sets the maximum length of objects accepted by the
+// gateway, including objects assembled from multipart uploads.
+// Defaults to 31 GiB.
+func WithMaxObjectLength(l uint64) Option {
+	return func(o *options) error {
+		o.maxObjectLength = l
+		return nil
+	}
+}
+
+// WithCredentials adds an access key ID and secret access key with which
+// clients may sign requests using AWS Signature Version 4. Multiple credentials
+// may be added by applying this option more than once.
+// If no credentials are added, all requests are served unauthenticated.
+func WithCredentials(accessKeyID, secretAccessKey string) Option {
+	return func(o *options) error {
+		o.credentials[accessKeyID] = secretAccessKey
+		return nil
+	}
+}
@@ -0,0 +1,428 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// See: https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+// See: https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+const (
+	sigV4Algorithm         = "AWS4-HMAC-SHA256"
+	sigV4ChunkAlgorithm    = "AWS4-HMAC-SHA256-PAYLOAD"
+	sigV4Service           = "s3"
+	sigV4Terminator        = "aws4_request"
+	sigV4DateFormat        = "20060102"
+	sigV4TimeFormat        = "20060102T150405Z"
+	sigV4MaxClockSkew      = 15 * time.Minute
+	emptyPayloadSHA256     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload        = "UNSIGNED-PAYLOAD"
+	streamingPayload       = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrail  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrail = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	headerAmzContentSHA256        = "X-Amz-Content-Sha256"
+	headerAmzDate                 = "X-Amz-Date"
+	headerAmzDecodedContentLength = "X-Amz-Decoded-Content-Length"
+
+	// maxChunkHeaderLength bounds the length of aws-chunked chunk header and
+	// trailer lines.
+	maxChunkHeaderLength = 4096
+)
+
+var errMalformedChunk = bodyError{errResponseInvalidRequest.withMessage("Malformed aws-chunked payload.")}
+
+type (
+	// sigV4 is a verified AWS Signature Version 4 of a request, used to verify
+	// the signatures of subsequent payload chunks.
+	sigV4 struct {
+		signingKey []byte
+		amzDate    string
+		scope      string
+		signature  string
+	}
+	// bodyError is an error that occurred while reading a request body, due to
+	// invalid body content.
+	bodyError struct {
+		s3Error
+	}
+)
+
+func (e bodyError) Error() string {
+	return e.message
+}
+
+// authenticate verifies the AWS Signature Version 4 of the given request,
+// carried in the Authorization header. Returns nil signature if no credentials
+// are configured, in which case all requests are served unauthenticated.
+// Presigned URLs are not supported.
+func (g *Gateway) authenticate(r *http.Request) (*sigV4, *s3Error) {
+	if len(g.credentials) == 0 {
+		return nil, nil
+	}
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		if r.URL.Query().Has("X-Amz-Signature") {
+			err := errResponseNotImplemented.withMessage("Presigned URLs are not supported.")
+			return nil, &err
+		}
+		return nil, &errResponseAccessDenied
+	}
+	params, ok := strings.CutPrefix(authorization, sigV4Algorithm+" ")
+	if !ok {
+		err := errResponseAuthorizationHeaderMalform.withMessage("Only AWS Signature Version 4 is supported.")
+		return nil, &err
+	}
+	var credential, signedHeaders, signature string
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch name {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			signedHeaders = value
+		case "Signature":
+			signature = value
+		}
+	}
+	credentialParts := strings.Split(credential, "/")
+	if len(credentialParts) != 5 || signedHeaders == "" || signature == "" {
+		return nil, &errResponseAuthorizationHeaderMalform
+	}
+	accessKeyID, date, region, service, terminator := credentialParts[0], credentialParts[1], credentialParts[2], credentialParts[3], credentialParts[4]
+	secret, ok := g.credentials[accessKeyID]
+	if !ok {
+		return nil, &errResponseInvalidAccessKeyID
+	}
+	if region != g.region || service != sigV4Service || terminator != sigV4Terminator {
+		err := errResponseAuthorizationHeaderMalform.withMessage(fmt.Sprintf("The credential scope is invalid; expecting region '%s' and service '%s'.", g.region, sigV4Service))
+		return nil, &err
+	}
+
+	amzDate := r.Header.Get(headerAmzDate)
+	requestTime, err := time.Parse(sigV4TimeFormat, amzDate)
+	if err != nil {
+		if requestTime, err = http.ParseTime(r.Header.Get("Date")); err != nil {
+			err := errResponseAccessDenied.withMessage("AWS authentication requires a valid Date or x-amz-date header.")
+			return nil, &err
+		}
+		amzDate = requestTime.UTC().Format(sigV4TimeFormat)
+	}
+	if skew := g.now().Sub(requestTime); skew > sigV4MaxClockSkew || skew < -sigV4MaxClockSkew {
+		return nil, &errResponseRequestTimeTooSkewed
+	}
+	if requestTime.UTC().Format(sigV4DateFormat) != date {
+		err := errResponseAuthorizationHeaderMalform.withMessage("The credential date does not match the request date.")
+		return nil, &err
+	}
+
+	payloadHash := r.Header.Get(headerAmzContentSHA256)
+	if payloadHash == "" {
+		err := errResponseInvalidRequest.withMessage("Missing required header for this request: x-amz-content-sha256.")
+		return nil, &err
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, strings.Split(signedHeaders, ";")),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join(credentialParts[1:], "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hexSHA256([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secret), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, terminator)
+	expected := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		logger.Debugw("Request signature does not match", "accessKeyID", accessKeyID, "canonicalRequest", canonicalRequest)
+		return nil, &errResponseSignatureDoesNotMatch
+	}
+	return &sigV4{
+		signingKey: signingKey,
+		amzDate:    amzDate,
+		scope:      scope,
+		signature:  signature,
+	}, nil
+}
+
+// requestBody returns the payload of the given request, decoding aws-chunked
+// content and verifying the payload hash or chunk signatures as it is read.
+// Reading the returned body fails with a bodyError if verification fails.
+func requestBody(r *http.Request, sig *sigV4) (io.Reader, *s3Error) {
+	switch payloadHash := r.Header.Get(headerAmzContentSHA256); payloadHash {
+	case "", unsignedPayload:
+		return r.Body, nil
+	case streamingPayload, streamingPayloadTrail:
+		return newChunkedReader(r.Body, sig), nil
+	case streamingUnsignedTrail:
+		return newChunkedReader(r.Body, nil), nil
+	default:
+		expected, err := hex.DecodeString(payloadHash)
+		if err != nil || len(expected) != sha256.Size {
+			err := errResponseInvalidArgument.withMessage("x-amz-content-sha256 must be UNSIGNED-PAYLOAD, STREAMING-AWS4-HMAC-SHA256-PAYLOAD, or a valid sha256 value.")
+			return nil, &err
+		}
+		return &digestReader{
+			r:        r.Body,
+			hash:     sha256.New(),
+			expected: expected,
+			mismatch: errResponseContentSHA256Mismatch,
+		}, nil
+	}
+}
+
+// decodedContentLength returns the length of the request payload, or -1 if
+// unknown.
+func decodedContentLength(r *http.Request) (int64, *s3Error) {
+	if !strings.HasPrefix(r.Header.Get(headerAmzContentSHA256), "STREAMING-") {
+		return r.ContentLength, nil
+	}
+	value := r.Header.Get(headerAmzDecodedContentLength)
+	if value == "" {
+		return -1, nil
+	}
+	var length int64
+	if _, err := fmt.Sscan(value, &length); err != nil || length < 0 {
+		err := errResponseInvalidArgument.withMessage("Invalid x-amz-decoded-content-length.")
+		return 0, &err
+	}
+	return length, nil
+}
+
+// digestReader verifies the digest of the content read from r once EOF is
+// reached.
+type digestReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected []byte
+	mismatch s3Error
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(d.hash.Sum(nil), d.expected) {
+		return n, bodyError{d.mismatch}
+	}
+	return n, err
+}
+
+// chunkedReader decodes aws-chunked payloads, verifying the signature of each
+// chunk if signed. Trailing headers, such as checksums, are discarded.
+type chunkedReader struct {
+	r   *bufio.Reader
+	sig *sigV4
+
+	previousSignature string
+	chunkSignature    string
+	chunkHash         hash.Hash
+	remaining         uint64
+	inChunk           bool
+	err               error
+}
+
+func newChunkedReader(r io.Reader, sig *sigV4) *chunkedReader {
+	cr := &chunkedReader{
+		r:         bufio.NewReaderSize(r, maxChunkHeaderLength),
+		sig:       sig,
+		chunkHash: sha256.New(),
+	}
+	if sig != nil {
+		cr.previousSignature = sig.signature
+	}
+	return cr
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for c.err == nil && c.remaining == 0 {
+		if c.inChunk {
+			c.err = c.endChunk()
+		} else {
+			c.err = c.beginChunk()
+		}
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+	if uint64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.chunkHash.Write(p[:n])
+	c.remaining -= uint64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	c.err = err
+	return n, err
+}
+
+func (c *chunkedReader) beginChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeHex, extension, _ := strings.Cut(line, ";")
+	var size uint64
+	if _, err := fmt.Sscanf(sizeHex, "%x", &size); err != nil {
+		return errMalformedChunk
+	}
+	c.chunkSignature, _ = strings.CutPrefix(extension, "chunk-signature=")
+	c.chunkHash.Reset()
+	c.remaining = size
+	c.inChunk = true
+	if size != 0 {
+		return nil
+	}
+
+	// The final, zero-length chunk is followed by optional trailers and an
+	// empty line.
+	if err := c.verifyChunk(); err != nil {
+		return err
+	}
+	for {
+		line, err := c.readLine()
+		if err == io.ErrUnexpectedEOF && line == "" {
+			// Some clients omit the empty line after trailers.
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			return io.EOF
+		}
+	}
+}
+
+func (c *chunkedReader) endChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return errMalformedChunk
+	}
+	c.inChunk = false
+	return c.verifyChunk()
+}
+
+func (c *chunkedReader) verifyChunk() error {
+	if c.sig == nil {
+		return nil
+	}
+	stringToSign := strings.Join([]string{
+		sigV4ChunkAlgorithm,
+		c.sig.amzDate,
+		c.sig.scope,
+		c.previousSignature,
+		emptyPayloadSHA256,
+		hex.EncodeToString(c.chunkHash.Sum(nil)),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(c.sig.signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(c.chunkSignature)) {
+		return bodyError{errResponseSignatureDoesNotMatch}
+	}
+	c.previousSignature = c.chunkSignature
+	return nil
+}
+
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	switch {
+	case err == io.EOF:
+		return string(line), io.ErrUnexpectedEOF
+	case err == bufio.ErrBufferFull:
+		return "", errMalformedChunk
+	case err != nil:
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func canonicalQuery(query url.Values) string {
+	type param struct{ name, value string }
+	var params []param
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, param{uriEncode(name, true), uriEncode(value, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
+	encoded := make([]string, 0, len(params))
+	for _, p := range params {
+		encoded = append(encoded, p.name+"="+p.value)
+	}
+	return strings.Join(encoded, "&")
+}
+
+func canonicalHeaders(r *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		var value string
+		if name == "host" {
+			value = r.Host
+		} else {
+			values := r.Header.Values(name)
+			for i := range values {
+				values[i] = strings.Join(strings.Fields(values[i]), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// uriEncode encodes the given string as specified by AWS Signature Version 4,
+// where all but the unreserved characters are percent-encoded.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,166 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	headerAmzRequestID  = "X-Amz-Request-Id"
+	headerAmzMetaPrefix = "X-Amz-Meta-"
+)
+
+var (
+	bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+	// responseHeaderOverrides maps the GetObject query parameters that override
+	// response headers to the header they override.
+	responseHeaderOverrides = map[string]string{
+		"response-cache-control":       "Cache-Control",
+		"response-content-disposition": "Content-Disposition",
+		"response-content-encoding":    "Content-Encoding",
+		"response-content-language":    "Content-Language",
+		"response-content-type":        "Content-Type",
+		"response-expires":             "Expires",
+	}
+)
+
+func isValidBucketName(name string) bool {
+	return bucketNamePattern.MatchString(name) && !strings.Contains(name, "..")
+}
+
+func newRequestID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return strings.ToUpper(hex.EncodeToString(id[:]))
+}
+
+// userMetadataFromHeader returns the user-defined "x-amz-meta-*" metadata in the
+// given header, keyed by lower-case name without the prefix.
+func userMetadataFromHeader(header http.Header) map[string]string {
+	var metadata map[string]string
+	for name, values := range header {
+		if suffix, ok := strings.CutPrefix(name, headerAmzMetaPrefix); ok && suffix != "" {
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[strings.ToLower(suffix)] = strings.Join(values, ",")
+		}
+	}
+	return metadata
+}
+
+// objectReader counts and digests object content as it is read. Reading fails
+// with a bodyError if the content exceeds the maximum length, or once EOF is
+// reached if the content does not match the expected length or MD5 digest.
+type objectReader struct {
+	r              io.Reader
+	max            uint64
+	expectedLength int64
+	expectedMD5    []byte
+	md5            hash.Hash
+	read           uint64
+}
+
+// newObjectReader instantiates a new objectReader that reads at most max bytes
+// from r. Negative expectedLength and nil expectedMD5 disable the respective
+// checks.
+func newObjectReader(r io.Reader, max uint64, expectedLength int64, expectedMD5 []byte) *objectReader {
+	return &objectReader{
+		r:              r,
+		max:            max,
+		expectedLength: expectedLength,
+		expectedMD5:    expectedMD5,
+		md5:            md5.New(),
+	}
+}
+
+func (o *objectReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	o.md5.Write(p[:n])
+	o.read += uint64(n)
+	if o.read > o.max {
+		return n, bodyError{errResponseEntityTooLarge}
+	}
+	if err == io.EOF {
+		if o.expectedLength >= 0 && o.read != uint64(o.expectedLength) {
+			return n, bodyError{errResponseIncompleteBody}
+		}
+		if o.expectedMD5 != nil && !bytes.Equal(o.md5.Sum(nil), o.expectedMD5) {
+			return n, bodyError{errResponseBadDigest}
+		}
+	}
+	return n, err
+}
+
+func respondWithXml(w http.ResponseWriter, body any, code int) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		logger.Errorw("Failed to write response", "err", err)
+		return
+	}
+	if err := xml.NewEncoder(w).Encode(body); err != nil {
+		logger.Errorw("Failed to encode response body", "err", err)
+	}
+}
+
+func respondWithError(w http.ResponseWriter, r *http.Request, e s3Error) {
+	respondWithXml(w, errorResponse{
+		Code:      e.code,
+		Message:   e.message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get(headerAmzRequestID),
+	}, e.status)
+}
+
+// respondWithBodyError responds with the error that occurred while reading the
+// request body.
+func respondWithBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var bodyErr bodyError
+	switch {
+	case errors.As(err, &bodyErr):
+		respondWithError(w, r, bodyErr.s3Error)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		respondWithError(w, r, errResponseIncompleteBody)
+	default:
+		logger.Errorw("Failed to read request body", "path", r.URL.Path, "err", err)
+		respondWithError(w, r, errResponseInternalError(err))
+	}
+}
+
+func respondWithIndexError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case errNoSuchBucket:
+		respondWithError(w, r, errResponseNoSuchBucket)
+	case errNoSuchKey:
+		respondWithError(w, r, errResponseNoSuchKey)
+	case errBucketExists:
+		respondWithError(w, r, errResponseBucketAlreadyOwnedByYou)
+	case errBucketNotEmpty:
+		respondWithError(w, r, errResponseBucketNotEmpty)
+	default:
+		logger.Errorw("Key index operation failed", "path", r.URL.Path, "err", err)
+		respondWithError(w, r, errResponseInternalError(err))
+	}
+}
+
+func respondWithMultipartError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case errUploadNotFound:
+		respondWithError(w, r, errResponseNoSuchUpload)
+	case errUploadInUse:
+		respondWithError(w, r, errResponseOperationAborted)
+	default:
+		respondWithBodyError(w, r, err)
+	}
+}
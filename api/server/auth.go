@@ -0,0 +1,263 @@
+package server
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scope is a permission granted to an authenticated API client.
+type Scope string
+
+const (
+	// ScopeBlobRead permits listing, retrieving and getting the status of blobs,
+	// and estimating storage costs.
+	ScopeBlobRead Scope = "blob:read"
+	// ScopeBlobWrite permits storing and removing blobs.
+	ScopeBlobWrite Scope = "blob:write"
+	// ScopeAdmin permits administrative operations, and implies all other scopes.
+	ScopeAdmin Scope = "admin"
+)
+
+var (
+	errUnauthenticated   = errors.New("missing or invalid bearer token")
+	errJWTMalformed      = errors.New("malformed JWT")
+	errJWTBadSignature   = errors.New("invalid JWT signature")
+	errJWTExpired        = errors.New("JWT is expired")
+	errJWTNotYetValid    = errors.New("JWT is not yet valid")
+	errJWTUnsupportedAlg = errors.New("unsupported JWT algorithm")
+
+	jwtHashes = map[string]func() hash.Hash{
+		"HS256": sha256.New,
+		"HS384": sha512.New384,
+		"HS512": sha512.New,
+	}
+)
+
+type (
+	// authenticator authenticates API requests using bearer tokens, which are
+	// either static tokens loaded from a file or HMAC signed JWTs.
+	authenticator struct {
+		// staticTokens maps the SHA-256 digest of static tokens to their scopes.
+		staticTokens map[[sha256.Size]byte][]Scope
+		jwtSecret    []byte
+	}
+	jwtHeader struct {
+		Alg string `json:"alg"`
+	}
+	jwtClaims struct {
+		Exp    *int64   `json:"exp"`
+		Nbf    *int64   `json:"nbf"`
+		Scope  string   `json:"scope"`
+		Scopes []string `json:"scopes"`
+	}
+)
+
+func newAuthenticator(tokenFile string, jwtSecret []byte) (*authenticator, error) {
+	if tokenFile == "" && len(jwtSecret) == 0 {
+		return nil, nil
+	}
+	auth := &authenticator{
+		staticTokens: make(map[[sha256.Size]byte][]Scope),
+		jwtSecret:    jwtSecret,
+	}
+	if tokenFile != "" {
+		if err := auth.loadTokenFile(tokenFile); err != nil {
+			return nil, err
+		}
+	}
+	return auth, nil
+}
+
+// loadTokenFile loads static tokens from the given file. Each line of the file
+// consists of a token followed by one or more scopes separated by whitespace.
+// Empty lines and lines starting with '#' are ignored.
+func (a *authenticator) loadTokenFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open token file: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var line int
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("token file line %d: expected token followed by at least one scope", line)
+		}
+		scopes := make([]Scope, 0, len(fields)-1)
+		for _, scope := range fields[1:] {
+			scopes = append(scopes, Scope(scope))
+		}
+		a.staticTokens[sha256.Sum256([]byte(fields[0]))] = scopes
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read token file: %w", err)
+	}
+	return nil
+}
+
+// authenticate returns the scopes granted to the bearer token of the given
+// request.
+func (a *authenticator) authenticate(r *http.Request) ([]Scope, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, errUnauthenticated
+	}
+	if scopes, ok := a.staticTokens[sha256.Sum256([]byte(token))]; ok {
+		return scopes, nil
+	}
+	if len(a.jwtSecret) != 0 && strings.Count(token, ".") == 2 {
+		scopes, err := a.verifyJWT(token, time.Now())
+		if err != nil {
+			logger.Debugw("Failed to verify JWT", "err", err)
+			return nil, errUnauthenticated
+		}
+		return scopes, nil
+	}
+	return nil, errUnauthenticated
+}
+
+// verifyJWT verifies the HMAC signature and validity period of the given JWT,
+// and returns the scopes it grants. Scopes are taken from either the
+// space-separated "scope" claim or the "scopes" array claim.
+func (a *authenticator) verifyJWT(token string, now time.Time) ([]Scope, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errJWTMalformed
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	newHash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return nil, errJWTUnsupportedAlg
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errJWTMalformed
+	}
+	mac := hmac.New(newHash, a.jwtSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if subtle.ConstantTimeCompare(mac.Sum(nil), signature) != 1 {
+		return nil, errJWTBadSignature
+	}
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.Exp != nil && !now.Before(time.Unix(*claims.Exp, 0)) {
+		return nil, errJWTExpired
+	}
+	if claims.Nbf != nil && now.Before(time.Unix(*claims.Nbf, 0)) {
+		return nil, errJWTNotYetValid
+	}
+	var scopes []Scope
+	for _, scope := range strings.Fields(claims.Scope) {
+		scopes = append(scopes, Scope(scope))
+	}
+	for _, scope := range claims.Scopes {
+		scopes = append(scopes, Scope(scope))
+	}
+	return scopes, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errJWTMalformed
+	}
+	if err := json.Unmarshal(decoded, v); err != nil {
+		return errJWTMalformed
+	}
+	return nil
+}
+
+func hasScope(granted []Scope, required Scope) bool {
+	for _, scope := range granted {
+		if scope == required || scope == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeFunc returns the scope required to serve the given request. Empty scope
+// means that the request requires no authentication.
+type scopeFunc func(*http.Request) Scope
+
+// blobScope requires ScopeBlobRead for safe methods, and ScopeBlobWrite for
+// all others. Pre-flight OPTIONS requests require no authentication.
+func blobScope(r *http.Request) Scope {
+	switch r.Method {
+	case http.MethodOptions:
+		return ""
+	case http.MethodGet, http.MethodHead:
+		return ScopeBlobRead
+	default:
+		return ScopeBlobWrite
+	}
+}
+
+// blobReadScope requires ScopeBlobRead for all methods, for endpoints that
+// only read despite accepting POST requests. Pre-flight OPTIONS requests
+// require no authentication.
+func blobReadScope(r *http.Request) Scope {
+	if r.Method == http.MethodOptions {
+		return ""
+	}
+	return ScopeBlobRead
+}
+
+// adminScope requires ScopeAdmin for all methods. Pre-flight OPTIONS requests
+// require no authentication.
+func adminScope(r *http.Request) Scope {
+	if r.Method == http.MethodOptions {
+		return ""
+	}
+	return ScopeAdmin
+}
+
+// requireScope wraps the given handler to only serve requests that are
+// authenticated with the scope returned by scopeOf. All requests are served if
+// authentication is not configured.
+func (m *HttpServer) requireScope(scopeOf scopeFunc, handler http.HandlerFunc) http.HandlerFunc {
+	if m.auth == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		required := scopeOf(r)
+		if required == "" {
+			handler(w, r)
+			return
+		}
+		granted, err := m.auth.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="motion"`)
+			respondWithJson(w, errResponseUnauthorized, http.StatusUnauthorized)
+			return
+		}
+		if !hasScope(granted, required) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="motion", error="insufficient_scope", scope="%s"`, required))
+			respondWithJson(w, errResponseForbidden(required), http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
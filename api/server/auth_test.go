@@ -0,0 +1,140 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/motion/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireScope(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(tokenFile, []byte(`
+# Comments and empty lines are ignored.
+reader blob:read
+writer blob:read blob:write
+root   admin
+`), 0600))
+	secret := []byte("fish")
+
+	auth, err := newAuthenticator(tokenFile, secret)
+	require.NoError(t, err)
+	subject := &HttpServer{auth: auth}
+	handler := subject.requireScope(blobScope, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	now := time.Now()
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "no token", method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodGet, authorization: "Bearer lobster", wantStatus: http.StatusUnauthorized},
+		{name: "non-bearer scheme", method: http.MethodGet, authorization: "Basic cmVhZGVy", wantStatus: http.StatusUnauthorized},
+		{name: "options needs no token", method: http.MethodOptions, wantStatus: http.StatusNoContent},
+		{name: "reader reads", method: http.MethodGet, authorization: "Bearer reader", wantStatus: http.StatusNoContent},
+		{name: "reader cannot write", method: http.MethodPost, authorization: "Bearer reader", wantStatus: http.StatusForbidden},
+		{name: "writer writes", method: http.MethodDelete, authorization: "Bearer writer", wantStatus: http.StatusNoContent},
+		{name: "admin writes", method: http.MethodPost, authorization: "Bearer root", wantStatus: http.StatusNoContent},
+		{
+			name:          "jwt with scope claim",
+			method:        http.MethodPost,
+			authorization: "Bearer " + signJWT(t, "HS256", secret, map[string]any{"scope": "blob:read blob:write"}),
+			wantStatus:    http.StatusNoContent,
+		},
+		{
+			name:          "jwt with scopes claim",
+			method:        http.MethodGet,
+			authorization: "Bearer " + signJWT(t, "HS512", secret, map[string]any{"scopes": []string{"blob:read"}}),
+			wantStatus:    http.StatusNoContent,
+		},
+		{
+			name:          "jwt without required scope",
+			method:        http.MethodPost,
+			authorization: "Bearer " + signJWT(t, "HS256", secret, map[string]any{"scope": "blob:read"}),
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "expired jwt",
+			method:        http.MethodGet,
+			authorization: "Bearer " + signJWT(t, "HS256", secret, map[string]any{"scope": "admin", "exp": now.Add(-time.Minute).Unix()}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "not yet valid jwt",
+			method:        http.MethodGet,
+			authorization: "Bearer " + signJWT(t, "HS256", secret, map[string]any{"scope": "admin", "nbf": now.Add(time.Hour).Unix()}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "jwt with wrong secret",
+			method:        http.MethodGet,
+			authorization: "Bearer " + signJWT(t, "HS256", []byte("lobster"), map[string]any{"scope": "admin"}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:          "unsigned jwt",
+			method:        http.MethodGet,
+			authorization: "Bearer " + signJWT(t, "none", secret, map[string]any{"scope": "admin"}),
+			wantStatus:    http.StatusUnauthorized,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "/v0/blob", nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			require.Equal(t, test.wantStatus, w.Code)
+			if test.wantStatus == http.StatusUnauthorized || test.wantStatus == http.StatusForbidden {
+				require.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+				var resp api.ErrorResponse
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+				require.NotEmpty(t, resp.Error)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	auth, err := newAuthenticator("", nil)
+	require.NoError(t, err)
+	require.Nil(t, auth)
+
+	malformed := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(malformed, []byte("token-without-scope\n"), 0600))
+	_, err = newAuthenticator(malformed, nil)
+	require.Error(t, err)
+
+	_, err = newAuthenticator(filepath.Join(t.TempDir(), "missing"), nil)
+	require.Error(t, err)
+}
+
+func signJWT(t *testing.T, alg string, secret []byte, claims map[string]any) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	newHash, ok := jwtHashes[alg]
+	if !ok {
+		newHash = sha256.New
+	}
+	mac := hmac.New(newHash, secret)
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -2,25 +2,75 @@ package server
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/filecoin-project/motion/api"
+	"github.com/filecoin-project/motion/webhook"
 )
 
 var (
-	errResponsePageNotFound         = api.ErrorResponse{Error: "404 Page Not found"}
-	errResponseInvalidBlobID        = api.ErrorResponse{Error: "Invalid blob ID"}
-	errResponseBlobNotFound         = api.ErrorResponse{Error: "No blob is found for the given ID"}
-	errResponseNotStreamContentType = api.ErrorResponse{Error: `Invalid content type, expected "application/octet-stream".`}
-	errResponseInvalidContentLength = api.ErrorResponse{Error: "Invalid content length, expected unsigned numerical value."}
+	errResponsePageNotFound           = api.ErrorResponse{Error: "404 Page Not found"}
+	errResponseInvalidBlobID          = api.ErrorResponse{Error: "Invalid blob ID"}
+	errResponseBlobNotFound           = api.ErrorResponse{Error: "No blob is found for the given ID"}
+	errResponseMissingContentType     = api.ErrorResponse{Error: `Missing content type, use "application/octet-stream" if unknown.`}
+	errResponseInvalidContentType     = api.ErrorResponse{Error: "Invalid content type."}
+	errResponseInvalidDisposition     = api.ErrorResponse{Error: "Invalid content disposition."}
+	errResponseInvalidRetainUntil     = api.ErrorResponse{Error: "Invalid X-Motion-Retain-Until, expected RFC 3339 time."}
+	errResponseUnknownPolicy          = api.ErrorResponse{Error: "Unknown storage policy in X-Motion-Policy."}
+	errResponseInvalidContentLength   = api.ErrorResponse{Error: "Invalid content length, expected unsigned numerical value."}
+	errResponseInvalidListCursor      = api.ErrorResponse{Error: "Invalid list cursor"}
+	errResponseUploadNotFound         = api.ErrorResponse{Error: "No upload session is found for the given ID"}
+	errResponseUploadInUse            = api.ErrorResponse{Error: "Upload session is in use by another request"}
+	errResponseUploadIncomplete       = api.ErrorResponse{Error: "Upload is not complete"}
+	errResponseNotOffsetContentType   = api.ErrorResponse{Error: `Invalid content type, expected "application/offset+octet-stream".`}
+	errResponseInvalidUploadOffset    = api.ErrorResponse{Error: "Invalid Upload-Offset, expected unsigned numerical value."}
+	errResponseInvalidUploadLength    = api.ErrorResponse{Error: "Invalid Upload-Length, expected unsigned numerical value."}
+	errResponseUnauthorized           = api.ErrorResponse{Error: "Missing or invalid bearer token"}
+	errResponseInvalidContentDigest   = api.ErrorResponse{Error: "Invalid or conflicting Content-Digest, Content-MD5 or X-Checksum-Sha256 header."}
+	errResponseContentDigestMismatch  = api.ErrorResponse{Error: "Blob content does not match the declared digest."}
+	errResponseContentLengthMismatch  = api.ErrorResponse{Error: "Blob content does not match the declared length."}
+	errResponseInvalidWebhookRequest  = api.ErrorResponse{Error: "Invalid webhook request body, expected JSON object."}
+	errResponseInvalidWebhookURL      = api.ErrorResponse{Error: "Invalid webhook URL, expected absolute http or https URL."}
+	errResponseWebhookNotFound        = api.ErrorResponse{Error: "No webhook is found for the given ID"}
+	errResponseInvalidEstimateRequest = api.ErrorResponse{Error: "Invalid estimate request body, expected JSON object with size."}
+	errResponseEstimateUnknownPolicy  = api.ErrorResponse{Error: "Unknown storage policy."}
+	errResponseEstimateTooLarge       = api.ErrorResponse{Error: "Size is too large to estimate."}
 )
 
 func errResponseInternalError(err error) api.ErrorResponse {
 	return api.ErrorResponse{Error: fmt.Sprintf("Internal error occurred: %s", err.Error())}
 }
 
+func errResponseWalletNotChecked(err error) api.ErrorResponse {
+	if err == nil {
+		return api.ErrorResponse{Error: "Wallet has not been checked yet."}
+	}
+	return api.ErrorResponse{Error: fmt.Sprintf("Wallet has not been checked successfully yet: %s", err.Error())}
+}
+
 func errResponseContentLengthTooLarge(max uint64) api.ErrorResponse {
 	return api.ErrorResponse{Error: fmt.Sprintf(`Content-Length exceeds the maximum accepted content length of %d bytes.`, max)}
 }
 func errResponseMaxBlobLengthExceeded(max uint64) api.ErrorResponse {
 	return api.ErrorResponse{Error: fmt.Sprintf(`Blob length exceeds the maximum accepted length of %d bytes.`, max)}
 }
+
+func errResponseInvalidQueryParam(name string) api.ErrorResponse {
+	return api.ErrorResponse{Error: fmt.Sprintf(`Invalid value for query parameter "%s".`, name)}
+}
+
+func errResponseUploadOffsetMismatch(offset uint64) api.ErrorResponse {
+	return api.ErrorResponse{Error: fmt.Sprintf(`Upload-Offset does not match the current upload offset of %d bytes.`, offset)}
+}
+
+func errResponseUnknownWebhookEvent(types []webhook.EventType) api.ErrorResponse {
+	names := make([]string, 0, len(types))
+	for _, typ := range types {
+		names = append(names, string(typ))
+	}
+	return api.ErrorResponse{Error: fmt.Sprintf(`Unknown webhook event type, expected one of: %s.`, strings.Join(names, ", "))}
+}
+
+func errResponseForbidden(required Scope) api.ErrorResponse {
+	return api.ErrorResponse{Error: fmt.Sprintf(`Token does not grant the required scope "%s".`, required)}
+}
@@ -1,38 +1,138 @@
 package server
 
 import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/filecoin-project/motion/api"
 	"github.com/filecoin-project/motion/blob"
+	"github.com/filecoin-project/motion/webhook"
 )
 
 func (m *HttpServer) handleBlobRoot(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodOptions:
-		w.Header().Set(httpHeaderAllow(http.MethodPost, http.MethodOptions))
+		w.Header().Set(httpHeaderAllow(m.blobRootAllowedMethods()...))
 	case http.MethodPost:
 		m.handlePostBlob(w, r)
+	case http.MethodGet:
+		if lister, ok := m.store.(blob.Lister); ok {
+			m.handleListBlobs(w, r, lister)
+			return
+		}
+		respondWithNotAllowed(w, m.blobRootAllowedMethods()...)
 	default:
-		respondWithNotAllowed(w, http.MethodPost, http.MethodOptions)
+		respondWithNotAllowed(w, m.blobRootAllowedMethods()...)
+	}
+}
+
+func (m *HttpServer) blobRootAllowedMethods() []string {
+	if _, ok := m.store.(blob.Lister); ok {
+		return []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+	}
+	return []string{http.MethodPost, http.MethodOptions}
+}
+
+func (m *HttpServer) handleListBlobs(w http.ResponseWriter, r *http.Request, lister blob.Lister) {
+	query := r.URL.Query()
+	options := blob.ListOptions{
+		Cursor: query.Get("cursor"),
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			respondWithJson(w, errResponseInvalidQueryParam("limit"), http.StatusBadRequest)
+			return
+		}
+		options.Limit = limit
+	}
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{name: "createdAfter", value: &options.CreatedAfter},
+		{name: "createdBefore", value: &options.CreatedBefore},
+	} {
+		if value := query.Get(param.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				respondWithJson(w, errResponseInvalidQueryParam(param.name), http.StatusBadRequest)
+				return
+			}
+			*param.value = t
+		}
+	}
+	for _, param := range []struct {
+		name  string
+		value *uint64
+	}{
+		{name: "minSize", value: &options.MinSize},
+		{name: "maxSize", value: &options.MaxSize},
+	} {
+		if value := query.Get(param.name); value != "" {
+			size, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				respondWithJson(w, errResponseInvalidQueryParam(param.name), http.StatusBadRequest)
+				return
+			}
+			*param.value = size
+		}
+	}
+
+	result, err := lister.ListBlobs(r.Context(), options)
+	switch err {
+	case nil:
+	case blob.ErrInvalidCursor:
+		respondWithJson(w, errResponseInvalidListCursor, http.StatusBadRequest)
+		return
+	default:
+		requestLogger(r).Errorw("Failed to list blobs", "err", err)
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+
+	response := api.ListBlobsResponse{
+		Blobs:      make([]api.Blob, 0, len(result.Descriptors)),
+		NextCursor: result.NextCursor,
 	}
+	for _, desc := range result.Descriptors {
+		response.Blobs = append(response.Blobs, api.Blob{
+			ID:        desc.ID.String(),
+			Size:      desc.Size,
+			CreatedAt: desc.ModificationTime,
+		})
+	}
+	respondWithJson(w, response, http.StatusOK)
 }
 
 func (m *HttpServer) handlePostBlob(w http.ResponseWriter, r *http.Request) {
 	// TODO: check Accept header accepts JSON response
-	if r.Header.Get("Content-Type") != "application/octet-stream" {
-		respondWithJson(w, errResponseNotStreamContentType, http.StatusBadRequest)
+	if r.Header.Get("Content-Type") == "" {
+		respondWithJson(w, errResponseMissingContentType, http.StatusBadRequest)
+		return
+	}
+	metadata, err := metadataFromHeader(r.Header)
+	if err != nil {
+		respondWithMetadataError(w, err)
+		return
+	}
+	digests, err := contentDigestsFromHeader(r.Header)
+	if err != nil {
+		respondWithJson(w, errResponseInvalidContentDigest, http.StatusBadRequest)
 		return
 	}
+	options := append(digests.putOptions(), blob.WithMetadata(metadata))
 	body := r.Body
-	var contentLength uint64
 	if value := r.Header.Get("Content-Length"); value != "" {
-		var err error
-		if contentLength, err = strconv.ParseUint(value, 10, 64); err != nil {
+		contentLength, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
 			if errors.Is(err, strconv.ErrSyntax) {
 				respondWithJson(w, errResponseInvalidContentLength, http.StatusBadRequest)
 			} else {
@@ -49,38 +149,75 @@ func (m *HttpServer) handlePostBlob(w http.ResponseWriter, r *http.Request) {
 			ReadCloser: r.Body,
 			size:       int64(contentLength),
 		}
+		options = append(options, blob.WithExpectedSize(contentLength))
 	}
 	defer body.Close()
-	desc, err := m.store.Put(r.Context(), body)
-	switch err {
-	case nil:
-	case blob.ErrBlobTooLarge:
-		respondWithJson(w, errResponseMaxBlobLengthExceeded(m.maxBlobLength), http.StatusBadRequest)
+	desc, ok := m.putBlob(w, r, body, options...)
+	if !ok {
 		return
+	}
+	respondWithJson(w, newPostBlobResponse(desc), http.StatusCreated)
+	requestLogger(r).Debugw("Blob crated successfully", "id", desc.ID, "size", desc.Size)
+}
+
+// putBlob stores the given body via the blob store. If storing fails, an error
+// response is written and false is returned.
+func (m *HttpServer) putBlob(w http.ResponseWriter, r *http.Request, body io.Reader, o ...blob.PutOption) (*blob.Descriptor, bool) {
+	desc, err := m.store.Put(r.Context(), body, o...)
+	switch {
+	case err == nil:
+		metricIngestedBytes.Add(float64(desc.Size))
+		if m.webhooks != nil {
+			m.webhooks.BlobStored(desc)
+		}
+		return desc, true
+	case errors.Is(err, blob.ErrBlobTooLarge):
+		respondWithJson(w, errResponseMaxBlobLengthExceeded(m.maxBlobLength), http.StatusBadRequest)
+	case errors.Is(err, blob.ErrDigestMismatch):
+		respondWithJson(w, errResponseContentDigestMismatch, http.StatusBadRequest)
+	case errors.Is(err, blob.ErrUnknownPolicy):
+		respondWithJson(w, errResponseUnknownPolicy, http.StatusBadRequest)
+	case errors.Is(err, blob.ErrSizeMismatch), errors.Is(err, io.ErrUnexpectedEOF):
+		// The HTTP server signals request bodies shorter than their declared
+		// Content-Length with io.ErrUnexpectedEOF.
+		respondWithJson(w, errResponseContentLengthMismatch, http.StatusBadRequest)
 	default:
 		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
-		return
 	}
-	logger := logger.With("id", desc.ID, "size", desc.Size)
-	if contentLength != 0 && desc.Size != contentLength {
-		logger.Warnw("Content-Length in request header did not match the data length", "expectedSize", contentLength)
-		// TODO: add option to reject such requests?
+	return nil, false
+}
+
+func newPostBlobResponse(desc *blob.Descriptor) api.PostBlobResponse {
+	return api.PostBlobResponse{
+		ID:     desc.ID.String(),
+		SHA256: hex.EncodeToString(desc.SHA256),
 	}
-	respondWithJson(w, api.PostBlobResponse{ID: desc.ID.String()}, http.StatusCreated)
-	logger.Debugw("Blob crated successfully", "id", desc.ID, "size", desc.Size)
 }
 
 func (m *HttpServer) handleBlobSubtree(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodOptions:
-		w.Header().Set(httpHeaderAllow(http.MethodGet, http.MethodOptions))
+		w.Header().Set(httpHeaderAllow(m.blobSubtreeAllowedMethods()...))
 	case http.MethodGet:
 		m.handleBlobGet(w, r)
+	case http.MethodDelete:
+		if _, ok := m.store.(blob.Remover); ok {
+			m.handleBlobDelete(w, r)
+			return
+		}
+		respondWithNotAllowed(w, m.blobSubtreeAllowedMethods()...)
 	default:
-		respondWithNotAllowed(w, http.MethodPost, http.MethodOptions)
+		respondWithNotAllowed(w, m.blobSubtreeAllowedMethods()...)
 	}
 }
 
+func (m *HttpServer) blobSubtreeAllowedMethods() []string {
+	if _, ok := m.store.(blob.Remover); ok {
+		return []string{http.MethodGet, http.MethodDelete, http.MethodOptions}
+	}
+	return []string{http.MethodGet, http.MethodOptions}
+}
+
 func (m *HttpServer) handleBlobGet(w http.ResponseWriter, r *http.Request) {
 	suffix := strings.TrimPrefix(r.URL.Path, "/v0/blob/")
 	segments := strings.Split(suffix, "/")
@@ -98,13 +235,45 @@ func (m *HttpServer) handleBlobGet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (m *HttpServer) handleBlobDelete(w http.ResponseWriter, r *http.Request) {
+	suffix := strings.TrimPrefix(r.URL.Path, "/v0/blob/")
+	if strings.Contains(suffix, "/") {
+		respondWithJson(w, errResponsePageNotFound, http.StatusNotFound)
+		return
+	}
+	var id blob.ID
+	if err := id.Decode(suffix); err != nil {
+		respondWithJson(w, errResponseInvalidBlobID, http.StatusBadRequest)
+		return
+	}
+	logger := requestLogger(r).With("id", id)
+	err := m.store.(blob.Remover).Remove(r.Context(), id)
+	switch err {
+	case nil:
+	case blob.ErrBlobNotFound:
+		respondWithJson(w, errResponseBlobNotFound, http.StatusNotFound)
+		return
+	default:
+		logger.Errorw("Failed to remove blob", "err", err)
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	logger.Debug("Blob removed successfully")
+}
+
 func (m *HttpServer) handleBlobGetByID(w http.ResponseWriter, r *http.Request, idUriSegment string) {
 	var id blob.ID
 	if err := id.Decode(idUriSegment); err != nil {
 		respondWithJson(w, errResponseInvalidBlobID, http.StatusBadRequest)
 		return
 	}
-	logger := logger.With("id", id)
+	format := r.URL.Query().Get("format")
+	if format != "" && format != blobFormatCAR {
+		respondWithJson(w, errResponseInvalidQueryParam("format"), http.StatusBadRequest)
+		return
+	}
+	logger := requestLogger(r).With("id", id)
 	blobDesc, err := m.store.Describe(r.Context(), id)
 	switch err {
 	case nil:
@@ -115,7 +284,24 @@ func (m *HttpServer) handleBlobGetByID(w http.ResponseWriter, r *http.Request, i
 		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
 		return
 	}
+	// Only blob content is counted as served, as opposed to error responses.
+	served := &responseRecorder{ResponseWriter: w, code: http.StatusOK}
+	defer func() {
+		if served.code == http.StatusOK || served.code == http.StatusPartialContent {
+			metricServedBytes.Add(float64(served.written))
+		}
+	}()
+	if format == blobFormatCAR {
+		m.handleBlobGetCAR(served, r, blobDesc)
+		return
+	}
+	w = served
 	if pass, ok := m.store.(blob.PassThroughGet); ok {
+		setMetadataHeader(w.Header(), id, blobDesc.Metadata)
+		// As with ServeContent below, the ETag is used by stores to evaluate
+		// conditional and If-Range requests.
+		setDigestHeader(w.Header(), blobDesc.SHA256)
+		w.Header().Set(httpHeaderContentTypeOptionsNoSniff())
 		pass.PassGet(w, r, id)
 		return
 	}
@@ -130,21 +316,58 @@ func (m *HttpServer) handleBlobGetByID(w http.ResponseWriter, r *http.Request, i
 		return
 	}
 	defer blobReader.Close()
-	w.Header().Set(httpHeaderContentTypeOctetStream())
+	setMetadataHeader(w.Header(), id, blobDesc.Metadata)
+	// Setting the ETag enables ServeContent to evaluate If-Match, If-None-Match
+	// and If-Range conditions against it.
+	setDigestHeader(w.Header(), blobDesc.SHA256)
 	w.Header().Set(httpHeaderContentTypeOptionsNoSniff())
 	w.Header().Set(httpHeaderContentLength(blobDesc.Size))
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachement; filename="%s.bin"`, id.String()))
 	http.ServeContent(w, r, "", blobDesc.ModificationTime, blobReader)
 	logger.Debug("Blob fetched successfully")
 }
 
+// handleBlobGetCAR streams the blob as a CARv1 that contains the UnixFS DAG
+// representing its content. See blob.WriteCAR.
+func (m *HttpServer) handleBlobGetCAR(w *responseRecorder, r *http.Request, blobDesc *blob.Descriptor) {
+	logger := requestLogger(r).With("id", blobDesc.ID)
+	blobReader, err := m.store.Get(r.Context(), blobDesc.ID)
+	switch err {
+	case nil:
+	case blob.ErrBlobNotFound:
+		respondWithJson(w, errResponseBlobNotFound, http.StatusNotFound)
+		return
+	default:
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	defer blobReader.Close()
+	filename := blobDesc.Metadata.Filename
+	if filename == "" {
+		filename = blobDesc.ID.String()
+	}
+	w.Header().Set(httpHeaderContentTypeCAR())
+	w.Header().Set(httpHeaderContentDispositionAttachment(filename + ".car"))
+	w.Header().Set(httpHeaderContentTypeOptionsNoSniff())
+	if _, err := blob.WriteCAR(r.Context(), w, blobReader, blobDesc.RootCID); err != nil {
+		logger.Errorw("Failed to export blob as CAR", "err", err)
+		if !w.wroteHeader {
+			respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+			return
+		}
+		// The CAR is streamed without a known length; abort the response so
+		// that the client does not mistake the truncated CAR for a whole one.
+		panic(http.ErrAbortHandler)
+	}
+	logger.Debug("Blob exported as CAR successfully")
+}
+
 func (m *HttpServer) handleBlobGetStatusByID(w http.ResponseWriter, r *http.Request, idUriSegment string) {
 	var id blob.ID
 	if err := id.Decode(idUriSegment); err != nil {
 		respondWithJson(w, errResponseInvalidBlobID, http.StatusBadRequest)
 		return
 	}
-	logger := logger.With("id", id)
+	logger := requestLogger(r).With("id", id)
 	blobDesc, err := m.store.Describe(r.Context(), id)
 	switch err {
 	case nil:
@@ -158,9 +381,31 @@ func (m *HttpServer) handleBlobGetStatusByID(w http.ResponseWriter, r *http.Requ
 	}
 
 	response := api.GetStatusResponse{
-		ID: idUriSegment,
+		ID:        idUriSegment,
+		Size:      blobDesc.Size,
+		CreatedAt: blobDesc.ModificationTime,
+		SHA256:    hex.EncodeToString(blobDesc.SHA256),
+	}
+	if blobDesc.RootCID.Defined() {
+		response.RootCID = blobDesc.RootCID.String()
+	}
+	if !blobDesc.Metadata.IsEmpty() {
+		response.Metadata = &api.Metadata{
+			ContentType: blobDesc.Metadata.ContentType,
+			Filename:    blobDesc.Metadata.Filename,
+			Tags:        blobDesc.Metadata.Tags,
+			RetainUntil: blobDesc.Metadata.RetainUntil,
+			Policy:      blobDesc.Metadata.Policy,
+		}
 	}
 
+	if blobDesc.TargetReplicas != 0 {
+		response.Replication = &api.Replication{
+			Target:   blobDesc.TargetReplicas,
+			Achieved: blobDesc.ActiveReplicas,
+			Safe:     blobDesc.ActiveReplicas >= blobDesc.TargetReplicas,
+		}
+	}
 	if len(blobDesc.Replicas) != 0 {
 		response.Replicas = make([]api.Replica, 0, len(blobDesc.Replicas))
 		for _, replica := range blobDesc.Replicas {
@@ -171,6 +416,15 @@ func (m *HttpServer) handleBlobGetStatusByID(w http.ResponseWriter, r *http.Requ
 					LastVerified: piece.LastUpdated,
 					PieceCID:     piece.PieceCID,
 					Status:       piece.Status,
+					PieceSize:    piece.PieceSize,
+					PayloadCID:   piece.PayloadCID,
+					DealID:       piece.DealID,
+					ProposalID:   piece.ProposalID,
+					Client:       piece.Client,
+					Verified:     piece.Verified,
+					Price:        piece.Price,
+					StartEpoch:   piece.StartEpoch,
+					EndEpoch:     piece.EndEpoch,
 				})
 			}
 			response.Replicas = append(response.Replicas, api.Replica{
@@ -179,6 +433,18 @@ func (m *HttpServer) handleBlobGetStatusByID(w http.ResponseWriter, r *http.Requ
 			})
 		}
 	}
+	if len(blobDesc.Packs) != 0 {
+		response.Packs = make([]api.Pack, 0, len(blobDesc.Packs))
+		for _, pack := range blobDesc.Packs {
+			response.Packs = append(response.Packs, api.Pack(pack))
+		}
+	}
+	if len(blobDesc.Repairs) != 0 {
+		response.Repairs = make([]api.Repair, 0, len(blobDesc.Repairs))
+		for _, repair := range blobDesc.Repairs {
+			response.Repairs = append(response.Repairs, api.Repair(repair))
+		}
+	}
 	respondWithJson(w, response, http.StatusOK)
 }
 
@@ -190,3 +456,446 @@ func (m *HttpServer) handleRoot(w http.ResponseWriter, r *http.Request) {
 		respondWithJson(w, errResponsePageNotFound, http.StatusNotFound)
 	}
 }
+
+func (m *HttpServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set(httpHeaderAllow(http.MethodGet, http.MethodHead, http.MethodOptions))
+	case http.MethodGet, http.MethodHead:
+		w.Header().Set("Cache-Control", "no-store")
+		respondWithJson(w, api.HealthResponse{Status: healthStatusOk}, http.StatusOK)
+	default:
+		respondWithNotAllowed(w, http.MethodGet, http.MethodHead, http.MethodOptions)
+	}
+}
+
+func (m *HttpServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set(httpHeaderAllow(http.MethodGet, http.MethodHead, http.MethodOptions))
+	case http.MethodGet, http.MethodHead:
+		response := api.HealthResponse{Status: healthStatusOk}
+		if checker, ok := m.store.(blob.HealthChecker); ok {
+			ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+			defer cancel()
+			for _, check := range checker.CheckHealth(ctx) {
+				result := api.HealthCheck{Name: check.Name, Status: healthStatusOk}
+				if check.Err != nil {
+					result.Status = healthCheckStatusFail
+					result.Error = check.Err.Error()
+					response.Status = healthStatusUnavailable
+					logger.Warnw("Readiness check failed", "check", check.Name, "err", check.Err)
+				}
+				response.Checks = append(response.Checks, result)
+			}
+		}
+		code := http.StatusOK
+		if response.Status != healthStatusOk {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		respondWithJson(w, response, code)
+	default:
+		respondWithNotAllowed(w, http.MethodGet, http.MethodHead, http.MethodOptions)
+	}
+}
+
+func (m *HttpServer) handleUploadRoot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set(httpHeaderAllow(http.MethodPost, http.MethodOptions))
+	case http.MethodPost:
+		m.handlePostUpload(w, r)
+	default:
+		respondWithNotAllowed(w, http.MethodPost, http.MethodOptions)
+	}
+}
+
+func (m *HttpServer) handlePostUpload(w http.ResponseWriter, r *http.Request) {
+	var length *uint64
+	if value := r.Header.Get("Upload-Length"); value != "" {
+		l, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondWithJson(w, errResponseInvalidUploadLength, http.StatusBadRequest)
+			return
+		}
+		if l > m.maxBlobLength {
+			respondWithJson(w, errResponseMaxBlobLengthExceeded(m.maxBlobLength), http.StatusBadRequest)
+			return
+		}
+		length = &l
+	}
+	metadata, err := metadataFromHeader(r.Header)
+	if err != nil {
+		respondWithMetadataError(w, err)
+		return
+	}
+	digests, err := contentDigestsFromHeader(r.Header)
+	if err != nil {
+		respondWithJson(w, errResponseInvalidContentDigest, http.StatusBadRequest)
+		return
+	}
+	session, err := m.uploads.create(length, metadata, digests)
+	if err != nil {
+		requestLogger(r).Errorw("Failed to create upload session", "err", err)
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/v0/upload/"+session.ID)
+	m.respondWithUploadSession(w, session, http.StatusCreated)
+	requestLogger(r).Debugw("Upload session created successfully", "id", session.ID)
+}
+
+func (m *HttpServer) handleUploadSubtree(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v0/upload/")
+	if strings.Contains(id, "/") {
+		respondWithJson(w, errResponsePageNotFound, http.StatusNotFound)
+		return
+	}
+	allowed := []string{http.MethodGet, http.MethodHead, http.MethodPatch, http.MethodPost, http.MethodDelete, http.MethodOptions}
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set(httpHeaderAllow(allowed...))
+	case http.MethodGet, http.MethodHead:
+		m.handleGetUpload(w, r, id)
+	case http.MethodPatch:
+		m.handlePatchUpload(w, r, id)
+	case http.MethodPost:
+		m.handleFinalizeUpload(w, r, id)
+	case http.MethodDelete:
+		m.handleDeleteUpload(w, r, id)
+	default:
+		respondWithNotAllowed(w, allowed...)
+	}
+}
+
+func (m *HttpServer) handleGetUpload(w http.ResponseWriter, r *http.Request, id string) {
+	session, err := m.uploads.get(id)
+	switch err {
+	case nil:
+	case errUploadNotFound:
+		respondWithJson(w, errResponseUploadNotFound, http.StatusNotFound)
+		return
+	default:
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	m.respondWithUploadSession(w, session, http.StatusOK)
+}
+
+func (m *HttpServer) handlePatchUpload(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		respondWithJson(w, errResponseNotOffsetContentType, http.StatusBadRequest)
+		return
+	}
+	offset, err := strconv.ParseUint(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		respondWithJson(w, errResponseInvalidUploadOffset, http.StatusBadRequest)
+		return
+	}
+	release, err := m.uploads.acquire(id)
+	if err != nil {
+		respondWithJson(w, errResponseUploadInUse, http.StatusConflict)
+		return
+	}
+	defer release()
+	session, err := m.uploads.get(id)
+	switch err {
+	case nil:
+	case errUploadNotFound:
+		respondWithJson(w, errResponseUploadNotFound, http.StatusNotFound)
+		return
+	default:
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	limit := m.maxBlobLength
+	if session.Length != nil {
+		limit = *session.Length
+	}
+	defer r.Body.Close()
+	newOffset, err := m.uploads.append(session, offset, limit, r.Body)
+	logger := requestLogger(r).With("id", id, "offset", newOffset)
+	switch err {
+	case nil:
+	case errUploadOffsetMismatch:
+		w.Header().Set(httpHeaderUploadOffset(newOffset))
+		respondWithJson(w, errResponseUploadOffsetMismatch(newOffset), http.StatusConflict)
+		return
+	case errUploadTooLarge:
+		w.Header().Set(httpHeaderUploadOffset(newOffset))
+		respondWithJson(w, errResponseMaxBlobLengthExceeded(limit), http.StatusBadRequest)
+		return
+	default:
+		// The data received so far is kept; the client may resume from the
+		// new offset.
+		logger.Warnw("Failed to append data to upload session", "err", err)
+		w.Header().Set(httpHeaderUploadOffset(newOffset))
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(httpHeaderUploadOffset(newOffset))
+	w.WriteHeader(http.StatusNoContent)
+	logger.Debug("Upload data appended successfully")
+}
+
+func (m *HttpServer) handleFinalizeUpload(w http.ResponseWriter, r *http.Request, id string) {
+	release, err := m.uploads.acquire(id)
+	if err != nil {
+		respondWithJson(w, errResponseUploadInUse, http.StatusConflict)
+		return
+	}
+	defer release()
+	session, err := m.uploads.get(id)
+	switch err {
+	case nil:
+	case errUploadNotFound:
+		respondWithJson(w, errResponseUploadNotFound, http.StatusNotFound)
+		return
+	default:
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	if session.Length != nil && session.Offset != *session.Length {
+		w.Header().Set(httpHeaderUploadOffset(session.Offset))
+		respondWithJson(w, errResponseUploadIncomplete, http.StatusConflict)
+		return
+	}
+	data, err := m.uploads.open(session)
+	if err != nil {
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	// Wrap data reader to signal content length to upstream components.
+	body := sizerReadCloser{
+		ReadCloser: data,
+		size:       int64(session.Offset),
+	}
+	options := append(session.Digests.putOptions(), blob.WithMetadata(session.Metadata))
+	desc, ok := m.putBlob(w, r, body, options...)
+	body.Close()
+	if !ok {
+		return
+	}
+	if err := m.uploads.remove(id); err != nil {
+		requestLogger(r).Warnw("Failed to remove finalised upload session", "id", id, "err", err)
+	}
+	respondWithJson(w, newPostBlobResponse(desc), http.StatusCreated)
+	requestLogger(r).Debugw("Blob created successfully from upload session", "uploadID", id, "id", desc.ID, "size", desc.Size)
+}
+
+func (m *HttpServer) handleDeleteUpload(w http.ResponseWriter, r *http.Request, id string) {
+	release, err := m.uploads.acquire(id)
+	if err != nil {
+		respondWithJson(w, errResponseUploadInUse, http.StatusConflict)
+		return
+	}
+	defer release()
+	if _, err := m.uploads.get(id); err != nil {
+		if err == errUploadNotFound {
+			respondWithJson(w, errResponseUploadNotFound, http.StatusNotFound)
+		} else {
+			respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := m.uploads.remove(id); err != nil {
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	requestLogger(r).Debugw("Upload session removed successfully", "id", id)
+}
+
+func (m *HttpServer) respondWithUploadSession(w http.ResponseWriter, session *uploadSession, code int) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set(httpHeaderUploadOffset(session.Offset))
+	if session.Length != nil {
+		w.Header().Set(httpHeaderUploadLength(*session.Length))
+	}
+	respondWithJson(w, api.UploadResponse{
+		ID:        session.ID,
+		Offset:    session.Offset,
+		Length:    session.Length,
+		ExpiresAt: m.uploads.expiresAt(session),
+	}, code)
+}
+
+func (m *HttpServer) handleWebhookRoot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set(httpHeaderAllow(http.MethodGet, http.MethodPost, http.MethodOptions))
+	case http.MethodGet:
+		subs := m.webhooks.Subscriptions()
+		response := api.ListWebhooksResponse{
+			Webhooks: make([]api.Webhook, 0, len(subs)),
+		}
+		for _, sub := range subs {
+			response.Webhooks = append(response.Webhooks, newWebhook(sub))
+		}
+		respondWithJson(w, response, http.StatusOK)
+	case http.MethodPost:
+		m.handlePostWebhook(w, r)
+	default:
+		respondWithNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodOptions)
+	}
+}
+
+func (m *HttpServer) handlePostWebhook(w http.ResponseWriter, r *http.Request) {
+	var request api.PostWebhookRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxWebhookRequestLength)).Decode(&request); err != nil {
+		respondWithJson(w, errResponseInvalidWebhookRequest, http.StatusBadRequest)
+		return
+	}
+	types := make([]webhook.EventType, 0, len(request.Events))
+	for _, event := range request.Events {
+		types = append(types, webhook.EventType(event))
+	}
+	sub, err := m.webhooks.Subscribe(request.URL, request.Secret, types...)
+	switch err {
+	case nil:
+	case webhook.ErrInvalidEndpoint:
+		respondWithJson(w, errResponseInvalidWebhookURL, http.StatusBadRequest)
+		return
+	case webhook.ErrUnknownEventType:
+		respondWithJson(w, errResponseUnknownWebhookEvent(webhook.EventTypes), http.StatusBadRequest)
+		return
+	default:
+		requestLogger(r).Errorw("Failed to subscribe webhook", "err", err)
+		respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/v0/webhook/"+sub.ID)
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJson(w, api.PostWebhookResponse{
+		Webhook: newWebhook(*sub),
+		Secret:  sub.Secret,
+	}, http.StatusCreated)
+}
+
+func (m *HttpServer) handleWebhookSubtree(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v0/webhook/")
+	if strings.Contains(id, "/") {
+		respondWithJson(w, errResponsePageNotFound, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set(httpHeaderAllow(http.MethodGet, http.MethodDelete, http.MethodOptions))
+	case http.MethodGet:
+		sub, err := m.webhooks.Subscription(id)
+		if err != nil {
+			respondWithJson(w, errResponseWebhookNotFound, http.StatusNotFound)
+			return
+		}
+		respondWithJson(w, newWebhook(*sub), http.StatusOK)
+	case http.MethodDelete:
+		switch err := m.webhooks.Unsubscribe(id); err {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case webhook.ErrSubscriptionNotFound:
+			respondWithJson(w, errResponseWebhookNotFound, http.StatusNotFound)
+		default:
+			requestLogger(r).Errorw("Failed to unsubscribe webhook", "id", id, "err", err)
+			respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+		}
+	default:
+		respondWithNotAllowed(w, http.MethodGet, http.MethodDelete, http.MethodOptions)
+	}
+}
+
+func newWebhook(sub webhook.Subscription) api.Webhook {
+	hook := api.Webhook{
+		ID:        sub.ID,
+		URL:       sub.URL,
+		CreatedAt: sub.CreatedAt,
+	}
+	for _, event := range sub.Events {
+		hook.Events = append(hook.Events, string(event))
+	}
+	return hook
+}
+
+func (m *HttpServer) handleWallet(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set(httpHeaderAllow(http.MethodGet, http.MethodHead, http.MethodOptions))
+	case http.MethodGet, http.MethodHead:
+		status, ok := m.wallet.Status()
+		w.Header().Set("Cache-Control", "no-store")
+		if !ok || status.CheckedAt.IsZero() {
+			respondWithJson(w, errResponseWalletNotChecked(status.Err), http.StatusServiceUnavailable)
+			return
+		}
+		response := api.WalletResponse{
+			Address:         status.Address.String(),
+			Balance:         status.Balance.String(),
+			MarketEscrow:    status.MarketEscrow.String(),
+			MarketLocked:    status.MarketLocked.String(),
+			MarketAvailable: status.MarketAvailable().String(),
+			DataCap:         status.DataCap.String(),
+			FundsLow:        status.FundsLow,
+			DataCapLow:      status.DataCapLow,
+			CheckedAt:       status.CheckedAt,
+		}
+		if status.Err != nil {
+			response.Error = status.Err.Error()
+		}
+		respondWithJson(w, response, http.StatusOK)
+	default:
+		respondWithNotAllowed(w, http.MethodGet, http.MethodHead, http.MethodOptions)
+	}
+}
+
+func (m *HttpServer) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set(httpHeaderAllow(http.MethodPost, http.MethodOptions))
+	case http.MethodPost:
+		var request api.EstimateRequest
+		defer r.Body.Close()
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxEstimateRequestLength)).Decode(&request); err != nil {
+			respondWithJson(w, errResponseInvalidEstimateRequest, http.StatusBadRequest)
+			return
+		}
+		estimate, err := m.store.(blob.Estimator).Estimate(r.Context(), request.Size, request.Policy)
+		switch {
+		case err == nil:
+		case errors.Is(err, blob.ErrUnknownPolicy):
+			respondWithJson(w, errResponseEstimateUnknownPolicy, http.StatusBadRequest)
+			return
+		case errors.Is(err, blob.ErrBlobTooLarge):
+			respondWithJson(w, errResponseEstimateTooLarge, http.StatusBadRequest)
+			return
+		default:
+			requestLogger(r).Errorw("Failed to estimate storage cost", "err", err)
+			respondWithJson(w, errResponseInternalError(err), http.StatusInternalServerError)
+			return
+		}
+		response := api.EstimateResponse{
+			Size:          estimate.Size,
+			Policy:        estimate.Policy,
+			MaxCARSize:    estimate.MaxCARSize,
+			PieceSize:     estimate.PieceSize,
+			Pieces:        estimate.Pieces,
+			PaddedSize:    estimate.PaddedSize,
+			DealDuration:  int64(estimate.DealDuration / time.Second),
+			Deals:         estimate.Deals,
+			PricePerEpoch: estimate.PricePerEpoch.String(),
+			PricePerDeal:  estimate.PricePerDeal.String(),
+			TotalCost:     estimate.TotalCost.String(),
+			Providers:     make([]api.ProviderEstimate, 0, len(estimate.Providers)),
+		}
+		for _, provider := range estimate.Providers {
+			response.Providers = append(response.Providers, api.ProviderEstimate{
+				Provider: provider.Provider,
+				Deals:    provider.Deals,
+				Cost:     provider.Cost.String(),
+			})
+		}
+		respondWithJson(w, response, http.StatusOK)
+	default:
+		respondWithNotAllowed(w, http.MethodPost, http.MethodOptions)
+	}
+}
@@ -0,0 +1,501 @@
+package server
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/motion/api"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/filecoin-project/motion/wallet"
+	"github.com/filecoin-project/motion/webhook"
+	"github.com/gammazero/fsutil/disk"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostBlobVerifiesContent(t *testing.T) {
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithUploadDir(t.TempDir()))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+
+	const content = "fish"
+	sha256Sum := sha256.Sum256([]byte(content))
+	md5Sum := md5.Sum([]byte(content))
+	wrongSum := sha256.Sum256([]byte("lobster"))
+
+	tests := []struct {
+		name       string
+		header     map[string]string
+		wantStatus int
+	}{
+		{name: "no digest", wantStatus: http.StatusCreated},
+		{
+			name:       "matching Content-Digest",
+			header:     map[string]string{"Content-Digest": "sha-512=:AAAA:, sha-256=:" + base64.StdEncoding.EncodeToString(sha256Sum[:]) + ":"},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "matching X-Checksum-Sha256 and Content-MD5",
+			header:     map[string]string{"X-Checksum-Sha256": hex.EncodeToString(sha256Sum[:]), "Content-MD5": base64.StdEncoding.EncodeToString(md5Sum[:])},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "mismatching Content-Digest",
+			header:     map[string]string{"Content-Digest": "sha-256=:" + base64.StdEncoding.EncodeToString(wrongSum[:]) + ":"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "mismatching Content-MD5",
+			header:     map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(wrongSum[:md5.Size])},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "conflicting SHA-256 digests",
+			header:     map[string]string{"Content-Digest": "sha-256=:" + base64.StdEncoding.EncodeToString(sha256Sum[:]) + ":", "X-Checksum-Sha256": hex.EncodeToString(wrongSum[:])},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "malformed Content-Digest",
+			header:     map[string]string{"Content-Digest": "sha-256=fish"},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v0/blob", strings.NewReader(content))
+			r.Header.Set("Content-Type", "application/octet-stream")
+			for key, value := range test.header {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			require.Equal(t, test.wantStatus, w.Code, w.Body.String())
+			if test.wantStatus != http.StatusCreated {
+				return
+			}
+			var resp api.PostBlobResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			require.Equal(t, hex.EncodeToString(sha256Sum[:]), resp.SHA256)
+		})
+	}
+}
+
+func TestGetBlobETag(t *testing.T) {
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithUploadDir(t.TempDir()))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+
+	r := httptest.NewRequest(http.MethodPost, "/v0/blob", strings.NewReader("foo"))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created api.PostBlobResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+
+	r = httptest.NewRequest(http.MethodGet, "/v0/blob/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.Equal(t, `"`+created.SHA256+`"`, etag)
+	require.Equal(t, "sha-256=:LCa0a2j/xo/5m0U8HTBBNBNCLXBkg7+g+YpeiGJm564=:", w.Header().Get("Repr-Digest"))
+
+	r = httptest.NewRequest(http.MethodGet, "/v0/blob/"+created.ID, nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/v0/blob/"+created.ID, nil)
+	r.Header.Set("If-Match", `"lobster"`)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/v0/blob/"+created.ID+"/status", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status api.GetStatusResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	require.Equal(t, created.SHA256, status.SHA256)
+}
+
+func TestGetBlobCAR(t *testing.T) {
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithUploadDir(t.TempDir()))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+
+	r := httptest.NewRequest(http.MethodPost, "/v0/blob", strings.NewReader("foo"))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created api.PostBlobResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+
+	r = httptest.NewRequest(http.MethodGet, "/v0/blob/"+created.ID+"/status", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status api.GetStatusResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	require.Equal(t, "bafkreibme22gw2h7y2h7tg2fhqotaqjucnbc24deqo72b6mkl2egezxhvy", status.RootCID)
+
+	r = httptest.NewRequest(http.MethodGet, "/v0/blob/"+created.ID+"?format=car", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/vnd.ipld.car; version=1", w.Header().Get("Content-Type"))
+	car, err := carv2.NewBlockReader(w.Body)
+	require.NoError(t, err)
+	require.Equal(t, status.RootCID, car.Roots[0].String())
+	block, err := car.Next()
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(block.RawData()))
+
+	r = httptest.NewRequest(http.MethodGet, "/v0/blob/"+created.ID+"?format=fish", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestReadyz(t *testing.T) {
+	dir := t.TempDir()
+	usage, err := disk.Usage(dir)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		store      blob.Store
+		wantStatus int
+		wantBody   api.HealthResponse
+	}{
+		{
+			name:       "ready",
+			store:      blob.NewLocalStore(dir),
+			wantStatus: http.StatusOK,
+			wantBody: api.HealthResponse{
+				Status: "ok",
+				Checks: []api.HealthCheck{
+					{Name: "local_store_writable", Status: "ok"},
+					{Name: "local_store_free_space", Status: "ok"},
+				},
+			},
+		},
+		{
+			name:       "not enough space",
+			store:      blob.NewLocalStore(dir, blob.WithMinFreeSpace(int64(usage.Free+blob.Gib))),
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subject, err := NewHttpServer(test.store, WithUploadDir(t.TempDir()))
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			subject.ServeMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			require.Equal(t, test.wantStatus, w.Code)
+			var got api.HealthResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+			if test.wantStatus == http.StatusOK {
+				require.Equal(t, test.wantBody, got)
+			} else {
+				require.Equal(t, "unavailable", got.Status)
+				require.Equal(t, "fail", got.Checks[1].Status)
+				require.NotEmpty(t, got.Checks[1].Error)
+			}
+
+			w = httptest.NewRecorder()
+			subject.ServeMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			require.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
+
+func TestWebhookAPI(t *testing.T) {
+	notifier, err := webhook.NewNotifier(webhook.WithDir(t.TempDir()))
+	require.NoError(t, err)
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithUploadDir(t.TempDir()), WithWebhookNotifier(notifier))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(http.MethodPost, "/v0/webhook", `{"url":"https://example.com/hook","events":["blob.stored","deal.active"]}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created api.PostWebhookResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	require.Equal(t, "https://example.com/hook", created.URL)
+	require.Equal(t, []string{"blob.stored", "deal.active"}, created.Events)
+	require.NotEmpty(t, created.Secret)
+	require.Equal(t, "/v0/webhook/"+created.ID, w.Header().Get("Location"))
+
+	w = serve(http.MethodGet, "/v0/webhook", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed api.ListWebhooksResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&listed))
+	require.Equal(t, []api.Webhook{created.Webhook}, listed.Webhooks)
+	require.NotContains(t, w.Body.String(), created.Secret)
+
+	w = serve(http.MethodGet, "/v0/webhook/"+created.ID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	for _, body := range []string{``, `{"url":"not a url"}`, `{"url":"https://example.com","events":["blob.eaten"]}`} {
+		require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/v0/webhook", body).Code, body)
+	}
+
+	require.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/v0/webhook/"+created.ID, "").Code)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/v0/webhook/"+created.ID, "").Code)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/v0/webhook/"+created.ID, "").Code)
+}
+
+func TestWalletAPI(t *testing.T) {
+	addr, err := address.NewFromString("f01234")
+	require.NoError(t, err)
+	chain := wallet.NewFakeChainClient()
+	chain.SetBalance(addr, abi.NewTokenAmount(5))
+	chain.SetMarketBalance(addr, wallet.MarketBalance{Escrow: abi.NewTokenAmount(30), Locked: abi.NewTokenAmount(10)})
+	monitor, err := wallet.NewMonitor(chain, wallet.WithMinBalance(abi.NewTokenAmount(10)))
+	require.NoError(t, err)
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithUploadDir(t.TempDir()), WithWalletMonitor(monitor))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/wallet", nil))
+		return w
+	}
+
+	require.Equal(t, http.StatusServiceUnavailable, get().Code)
+
+	status := monitor.Check(context.Background(), addr)
+	w := get()
+	require.Equal(t, http.StatusOK, w.Code)
+	var got api.WalletResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.True(t, status.CheckedAt.Equal(got.CheckedAt))
+	got.CheckedAt = time.Time{}
+	require.Equal(t, api.WalletResponse{
+		Address:         addr.String(),
+		Balance:         "5",
+		MarketEscrow:    "30",
+		MarketLocked:    "10",
+		MarketAvailable: "20",
+		DataCap:         "0",
+		FundsLow:        true,
+	}, got)
+}
+
+// estimatingStore is a blob.Store that estimates storage costs.
+type estimatingStore struct {
+	blob.Store
+}
+
+func (estimatingStore) Estimate(_ context.Context, size uint64, policy string) (*blob.Estimate, error) {
+	if policy != "" {
+		return nil, blob.ErrUnknownPolicy
+	}
+	return &blob.Estimate{
+		Size:          size,
+		MaxCARSize:    size,
+		PieceSize:     1 << 10,
+		Pieces:        1,
+		PaddedSize:    1 << 10,
+		DealDuration:  time.Hour,
+		Deals:         1,
+		PricePerEpoch: big.NewInt(1),
+		PricePerDeal:  big.NewInt(120),
+		TotalCost:     big.NewInt(120),
+		Providers:     []blob.ProviderEstimate{{Provider: "f01000", Deals: 1, Cost: big.NewInt(120)}},
+	}, nil
+}
+
+func TestEstimateAPI(t *testing.T) {
+	subject, err := NewHttpServer(estimatingStore{Store: blob.NewLocalStore(t.TempDir())}, WithUploadDir(t.TempDir()))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v0/estimate", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`{"size":1000}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var got api.EstimateResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.Equal(t, api.EstimateResponse{
+		Size:          1000,
+		MaxCARSize:    1000,
+		PieceSize:     1 << 10,
+		Pieces:        1,
+		PaddedSize:    1 << 10,
+		DealDuration:  3600,
+		Deals:         1,
+		PricePerEpoch: "1",
+		PricePerDeal:  "120",
+		TotalCost:     "120",
+		Providers:     []api.ProviderEstimate{{Provider: "f01000", Deals: 1, Cost: "120"}},
+	}, got)
+
+	for _, body := range []string{``, `{"size":-1}`, `{"size":1,"policy":"fish"}`} {
+		require.Equal(t, http.StatusBadRequest, post(body).Code, body)
+	}
+
+	// Stores that cannot estimate costs do not serve the endpoint.
+	subject, err = NewHttpServer(blob.NewLocalStore(t.TempDir()), WithUploadDir(t.TempDir()))
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	subject.ServeMux().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v0/estimate", strings.NewReader(`{"size":1}`)))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestResumableUpload(t *testing.T) {
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithUploadDir(t.TempDir()), WithMaxBlobLength(8))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+	do := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		for key, value := range header {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	create := func(header map[string]string) string {
+		w := do(http.MethodPost, "/v0/upload", "", header)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var session api.UploadResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&session))
+		require.Equal(t, "/v0/upload/"+session.ID, w.Header().Get("Location"))
+		return "/v0/upload/" + session.ID
+	}
+	patch := func(path, offset, body string) *httptest.ResponseRecorder {
+		return do(http.MethodPatch, path, body, map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": offset,
+		})
+	}
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/v0/upload", "", map[string]string{"Upload-Length": "9"}).Code)
+
+	upload := create(map[string]string{"Upload-Length": "6"})
+	w := patch(upload, "0", "foo")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	require.Equal(t, "3", w.Header().Get("Upload-Offset"))
+
+	// Data sent at any offset but the current one is rejected.
+	for _, offset := range []string{"0", "4"} {
+		w = patch(upload, offset, "bar")
+		require.Equal(t, http.StatusConflict, w.Code, offset)
+		require.Equal(t, "3", w.Header().Get("Upload-Offset"), offset)
+	}
+
+	// Incomplete uploads cannot be finalised.
+	w = do(http.MethodPost, upload, "", nil)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	require.Equal(t, "3", w.Header().Get("Upload-Offset"))
+
+	w = patch(upload, "3", "bar")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	require.Equal(t, "6", w.Header().Get("Upload-Offset"))
+
+	w = do(http.MethodGet, upload, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var session api.UploadResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&session))
+	require.Equal(t, uint64(6), session.Offset)
+	require.Equal(t, "6", w.Header().Get("Upload-Length"))
+
+	w = do(http.MethodPost, upload, "", nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created api.PostBlobResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	w = do(http.MethodGet, "/v0/blob/"+created.ID, "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "foobar", w.Body.String())
+
+	// Finalised sessions are removed.
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, upload, "", nil).Code)
+
+	t.Run("declared length exceeded", func(t *testing.T) {
+		upload := create(map[string]string{"Upload-Length": "4"})
+		w := patch(upload, "0", "lobster")
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		require.Equal(t, "4", w.Header().Get("Upload-Offset"))
+		require.Equal(t, http.StatusCreated, do(http.MethodPost, upload, "", nil).Code)
+	})
+
+	t.Run("max blob length exceeded", func(t *testing.T) {
+		upload := create(nil)
+		require.Equal(t, http.StatusNoContent, patch(upload, "0", "lobster").Code)
+		w := patch(upload, "7", "fish")
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		require.Equal(t, "8", w.Header().Get("Upload-Offset"))
+	})
+}
+
+func TestUploadSessionExpiry(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	uploadDir := t.TempDir()
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithHttpListenAddr("127.0.0.1:0"), WithUploadDir(uploadDir), WithUploadSessionTTL(ttl))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+	create := func() string {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v0/upload", nil))
+		require.Equal(t, http.StatusCreated, w.Code)
+		var session api.UploadResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&session))
+		require.WithinDuration(t, time.Now().Add(ttl), session.ExpiresAt, time.Second)
+		return "/v0/upload/" + session.ID
+	}
+	get := func(upload string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, upload, nil))
+		return w.Code
+	}
+
+	expired := create()
+	require.Equal(t, http.StatusOK, get(expired))
+	time.Sleep(2 * ttl)
+
+	// Expired sessions are not found even before they are removed, and are
+	// removed as new ones are created.
+	require.Equal(t, http.StatusNotFound, get(expired))
+	require.DirExists(t, filepath.Join(uploadDir, path.Base(expired)))
+	live := create()
+	require.NoDirExists(t, filepath.Join(uploadDir, path.Base(expired)))
+	require.Equal(t, http.StatusOK, get(live))
+
+	// Expired sessions are also removed periodically while the server runs.
+	require.NoError(t, subject.Start(context.Background()))
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(uploadDir, path.Base(live)))
+		return errors.Is(err, os.ErrNotExist)
+	}, time.Second, ttl)
+	require.NoError(t, subject.Shutdown(context.Background()))
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP API requests served, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	metricRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "motion",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP API requests, by route, method and status code.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"route", "method", "code"})
+	metricIngestedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "http",
+		Name:      "ingested_bytes_total",
+		Help:      "Number of blob bytes successfully stored via the HTTP API.",
+	})
+	metricServedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "http",
+		Name:      "served_bytes_total",
+		Help:      "Number of blob bytes successfully served via the HTTP API.",
+	})
+)
+
+// instrument records the count and latency of requests to the handler, which
+// is registered at the given ServeMux pattern.
+func instrument(pattern string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w, code: http.StatusOK}
+		handler(recorder, r)
+		labels := prometheus.Labels{
+			"route":  routeLabel(pattern, r.URL.Path),
+			"method": methodLabel(r.Method),
+			"code":   strconv.Itoa(recorder.code),
+		}
+		metricRequests.With(labels).Inc()
+		metricRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// methodLabel returns the given request method if it is a standard HTTP
+// method, or "other" otherwise, so that the label cardinality is bounded.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// routeLabel returns the route to which the given path belongs, with path
+// parameters replaced by their name so that the label cardinality is bounded.
+func routeLabel(pattern, path string) string {
+	suffix, ok := strings.CutPrefix(path, pattern)
+	if !ok || !strings.HasSuffix(pattern, "/") {
+		return pattern
+	}
+	switch segments := strings.Split(suffix, "/"); {
+	case len(segments) == 1:
+		return pattern + "{id}"
+	case len(segments) == 2 && segments[1] == "status":
+		return pattern + "{id}/status"
+	default:
+		return pattern + "*"
+	}
+}
+
+// responseRecorder records the status code and the number of body bytes
+// written to a http.ResponseWriter.
+type responseRecorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+	written     int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/motion/api"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteLabel(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    string
+	}{
+		{pattern: "/v0/blob", path: "/v0/blob", want: "/v0/blob"},
+		{pattern: "/v0/blob/", path: "/v0/blob/ad7ef987-a932-495c-aa0c-7ffcabeda45f", want: "/v0/blob/{id}"},
+		{pattern: "/v0/blob/", path: "/v0/blob/ad7ef987-a932-495c-aa0c-7ffcabeda45f/status", want: "/v0/blob/{id}/status"},
+		{pattern: "/v0/blob/", path: "/v0/blob/fish/lobster", want: "/v0/blob/*"},
+		{pattern: "/v0/upload/", path: "/v0/upload/ad7ef987-a932-495c-aa0c-7ffcabeda45f", want: "/v0/upload/{id}"},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, routeLabel(test.pattern, test.path), test.path)
+	}
+}
+
+func TestMethodLabel(t *testing.T) {
+	require.Equal(t, http.MethodGet, methodLabel(http.MethodGet))
+	require.Equal(t, http.MethodPatch, methodLabel(http.MethodPatch))
+	require.Equal(t, "other", methodLabel("FISH"))
+	require.Equal(t, "other", methodLabel("get"))
+}
+
+func TestMetrics(t *testing.T) {
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithUploadDir(t.TempDir()))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+
+	created := metricRequests.WithLabelValues("/v0/blob", http.MethodPost, "201")
+	notFound := metricRequests.WithLabelValues("/v0/blob/{id}", http.MethodGet, "404")
+	wantCreated := testutil.ToFloat64(created) + 1
+	wantNotFound := testutil.ToFloat64(notFound) + 1
+	other := metricRequests.WithLabelValues("/v0/blob", "other", "405")
+	wantOther := testutil.ToFloat64(other) + 1
+	wantIngested := testutil.ToFloat64(metricIngestedBytes) + 4
+	// Only the content of the blob is counted as served, and not the error
+	// response to the request for an unknown blob.
+	wantServed := testutil.ToFloat64(metricServedBytes) + 4
+
+	r := httptest.NewRequest(http.MethodPost, "/v0/blob", strings.NewReader("fish"))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var desc api.PostBlobResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&desc))
+	r = httptest.NewRequest(http.MethodGet, "/v0/blob/"+desc.ID, nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	r = httptest.NewRequest(http.MethodGet, "/v0/blob/00000000-0000-0000-0000-000000000000", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	r = httptest.NewRequest("FISH", "/v0/blob", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	require.Equal(t, wantCreated, testutil.ToFloat64(created))
+	require.Equal(t, wantNotFound, testutil.ToFloat64(notFound))
+	require.Equal(t, wantOther, testutil.ToFloat64(other))
+	require.Equal(t, wantIngested, testutil.ToFloat64(metricIngestedBytes))
+	require.Equal(t, wantServed, testutil.ToFloat64(metricServedBytes))
+}
@@ -1,24 +1,50 @@
 package server
 
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/filecoin-project/motion/wallet"
+	"github.com/filecoin-project/motion/webhook"
+)
+
 type (
 	// Option is a configurable parameter in HttpServer.
 	Option  func(*options) error
 	options struct {
-		httpListenAddr string
-		maxBlobLength  uint64
+		httpListenAddr   string
+		maxBlobLength    uint64
+		uploadDir        string
+		uploadSessionTTL time.Duration
+		authTokenFile    string
+		authJWTSecret    []byte
+		webhooks         *webhook.Notifier
+		wallet           *wallet.Monitor
+		tlsCertFile      string
+		tlsKeyFile       string
+		tlsClientCAFile  string
+		tlsReloadPeriod  time.Duration
 	}
 )
 
 func newOptions(o ...Option) (*options, error) {
 	opts := &options{
-		httpListenAddr: "0.0.0.0:40080",
-		maxBlobLength:  31 << 30, // 31 GiB
+		httpListenAddr:   "0.0.0.0:40080",
+		maxBlobLength:    31 << 30, // 31 GiB
+		uploadDir:        filepath.Join(os.TempDir(), "motion-uploads"),
+		uploadSessionTTL: 24 * time.Hour,
+		tlsReloadPeriod:  10 * time.Second,
 	}
 	for _, apply := range o {
 		if err := apply(opts); err != nil {
 			return nil, err
 		}
 	}
+	if opts.tlsClientCAFile != "" && opts.tlsCertFile == "" {
+		return nil, errors.New("TLS client CA file requires a TLS certificate to be set")
+	}
 	return opts, nil
 }
 
@@ -39,3 +65,110 @@ func WithMaxBlobLength(l uint64) Option {
 		return nil
 	}
 }
+
+// WithUploadDir sets the local directory at which resumable upload sessions are
+// staged until they are finalised.
+// Defaults to "motion-uploads" under the OS temporary directory.
+// See: os.TempDir.
+func WithUploadDir(dir string) Option {
+	return func(o *options) error {
+		o.uploadDir = dir
+		return nil
+	}
+}
+
+// WithUploadSessionTTL sets the duration of inactivity after which resumable
+// upload sessions and their staged data are removed.
+// Defaults to 24 hours.
+func WithUploadSessionTTL(ttl time.Duration) Option {
+	return func(o *options) error {
+		o.uploadSessionTTL = ttl
+		return nil
+	}
+}
+
+// WithAuthTokenFile enables bearer token authentication using the static tokens
+// listed in the given file. Each line of the file consists of a token followed
+// by one or more whitespace separated scopes, e.g. "s3cr3t blob:read blob:write".
+// Empty lines and lines starting with '#' are ignored.
+// Authentication is disabled if neither a token file nor a JWT secret is set.
+// See: WithAuthJWTSecret, Scope.
+func WithAuthTokenFile(path string) Option {
+	return func(o *options) error {
+		o.authTokenFile = path
+		return nil
+	}
+}
+
+// WithAuthJWTSecret enables bearer token authentication using JWTs signed with
+// the given HMAC secret. Tokens must be signed using HS256, HS384 or HS512, and
+// carry their scopes either as a space separated "scope" claim or a "scopes"
+// array claim. The "exp" and "nbf" claims are honoured if present.
+// Authentication is disabled if neither a token file nor a JWT secret is set.
+// See: WithAuthTokenFile, Scope.
+func WithAuthJWTSecret(secret []byte) Option {
+	return func(o *options) error {
+		o.authJWTSecret = secret
+		return nil
+	}
+}
+
+// WithWebhookNotifier enables the webhook API, via which endpoints are
+// subscribed to blob lifecycle events, and notifies the given notifier of every
+// blob stored via the server.
+// Defaults to disabled.
+func WithWebhookNotifier(n *webhook.Notifier) Option {
+	return func(o *options) error {
+		o.webhooks = n
+		return nil
+	}
+}
+
+// WithWalletMonitor enables the wallet API, via which the funds and DataCap of
+// the wallet with which deals are made are reported, as of the last check by
+// the given monitor.
+// Defaults to disabled.
+func WithWalletMonitor(m *wallet.Monitor) Option {
+	return func(o *options) error {
+		o.wallet = m
+		return nil
+	}
+}
+
+// WithTLSCertificate serves the HTTP API over TLS using the PEM encoded
+// certificate chain and private key at the given paths. Both files are checked
+// for changes periodically and reloaded without restarting the server.
+// Defaults to plain HTTP.
+// See: WithTLSReloadPeriod.
+func WithTLSCertificate(certFile, keyFile string) Option {
+	return func(o *options) error {
+		if (certFile == "") != (keyFile == "") {
+			return errors.New("both TLS certificate and key files must be set")
+		}
+		o.tlsCertFile = certFile
+		o.tlsKeyFile = keyFile
+		return nil
+	}
+}
+
+// WithTLSClientCAFile requires clients to present a certificate signed by one
+// of the PEM encoded certificate authorities in the given file, i.e. mutual TLS.
+// The file is reloaded on change along with the server certificate.
+// Requires WithTLSCertificate.
+// Defaults to no client certificate verification.
+func WithTLSClientCAFile(path string) Option {
+	return func(o *options) error {
+		o.tlsClientCAFile = path
+		return nil
+	}
+}
+
+// WithTLSReloadPeriod sets the minimum period between checks of the TLS files
+// for changes. Files are checked during TLS handshakes.
+// Defaults to 10 seconds.
+func WithTLSReloadPeriod(p time.Duration) Option {
+	return func(o *options) error {
+		o.tlsReloadPeriod = p
+		return nil
+	}
+}
@@ -2,9 +2,11 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"net/http"
+	"sync"
 
 	"github.com/filecoin-project/motion/blob"
 	"github.com/ipfs/go-log/v2"
@@ -17,6 +19,10 @@ type HttpServer struct {
 	*options
 	httpServer *http.Server
 	store      blob.Store
+	uploads    *uploadSessions
+	auth       *authenticator
+	closing    chan struct{}
+	closed     sync.WaitGroup
 }
 
 // NewHttpServer instantiates a new HTTP server that stores and retrieves blobs via the given store.
@@ -26,23 +32,49 @@ func NewHttpServer(store blob.Store, o ...Option) (*HttpServer, error) {
 	if err != nil {
 		return nil, err
 	}
+	uploads, err := newUploadSessions(opts.uploadDir, opts.uploadSessionTTL)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := newAuthenticator(opts.authTokenFile, opts.authJWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	if auth == nil {
+		logger.Warn("API authentication is disabled; all requests are served unauthenticated.")
+	}
 	server := &HttpServer{
 		options: opts,
 		store:   store,
+		uploads: uploads,
+		auth:    auth,
+		closing: make(chan struct{}),
 	}
 	server.httpServer = &http.Server{
 		Handler: server.ServeMux(),
 	}
+	if opts.tlsCertFile != "" {
+		reloader, err := newTLSReloader(opts.tlsCertFile, opts.tlsKeyFile, opts.tlsClientCAFile, opts.tlsReloadPeriod)
+		if err != nil {
+			return nil, err
+		}
+		server.httpServer.TLSConfig = reloader.tlsConfig()
+	}
 	return server, nil
 }
 
-// Start Starts the HTTP server.
-// See Shutdown.
+// Start Starts the HTTP server, serving over TLS if a certificate is set, and
+// periodically removes expired upload sessions.
+// See Shutdown, WithTLSCertificate, WithUploadSessionTTL.
 func (m *HttpServer) Start(_ context.Context) error {
 	listener, err := net.Listen("tcp", m.httpListenAddr)
 	if err != nil {
 		return err
 	}
+	serveTLS := m.httpServer.TLSConfig != nil
+	if serveTLS {
+		listener = tls.NewListener(listener, m.httpServer.TLSConfig)
+	}
 	go func() {
 		if err := m.httpServer.Serve(listener); errors.Is(err, http.ErrServerClosed) {
 			logger.Info("HTTP server stopped successfully.")
@@ -50,20 +82,54 @@ func (m *HttpServer) Start(_ context.Context) error {
 			logger.Errorw("HTTP server stopped erroneously.", "err", err)
 		}
 	}()
-	logger.Infow("HTTP server started successfully.", "address", listener.Addr())
+	m.closed.Add(1)
+	go func() {
+		defer m.closed.Done()
+		m.uploads.runExpiry(m.closing)
+	}()
+	logger.Infow("HTTP server started successfully.", "address", listener.Addr(), "tls", serveTLS, "mtls", m.tlsClientCAFile != "")
 	return nil
 }
 
 // ServeMux returns a new HTTP handler for the endpoints supported by the server.
+// When authentication is enabled, each blob endpoint requires the scope
+// appropriate to the request method, the estimate endpoint requires
+// ScopeBlobRead, and webhook and wallet endpoints require ScopeAdmin. See:
+// Scope. Requests to each endpoint are counted and timed in the default
+// Prometheus registry. Requests to the blob, upload, estimate, webhook and
+// wallet endpoints are assigned a request ID, echoed via the X-Request-ID
+// header, and traced via OpenTelemetry.
 func (m *HttpServer) ServeMux() *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/v0/blob", m.handleBlobRoot)
-	mux.HandleFunc("/v0/blob/", m.handleBlobSubtree)
+	handle := func(pattern string, scopeOf scopeFunc, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, traceRequest(pattern, instrument(pattern, m.requireScope(scopeOf, handler))))
+	}
+	handle("/v0/blob", blobScope, m.handleBlobRoot)
+	handle("/v0/blob/", blobScope, m.handleBlobSubtree)
+	handle("/v0/upload", blobScope, m.handleUploadRoot)
+	handle("/v0/upload/", blobScope, m.handleUploadSubtree)
+	if m.webhooks != nil {
+		handle("/v0/webhook", adminScope, m.handleWebhookRoot)
+		handle("/v0/webhook/", adminScope, m.handleWebhookSubtree)
+	}
+	if m.wallet != nil {
+		handle("/v0/wallet", adminScope, m.handleWallet)
+	}
+	if _, ok := m.store.(blob.Estimator); ok {
+		handle("/v0/estimate", blobReadScope, m.handleEstimate)
+	}
+	// Health endpoints are probed by orchestrators, and require no authentication.
+	mux.HandleFunc("/healthz", instrument("/healthz", m.handleHealthz))
+	mux.HandleFunc("/readyz", instrument("/readyz", m.handleReadyz))
 	mux.HandleFunc("/", m.handleRoot)
 	return mux
 }
 
-// Shutdown shuts down the HTTP Server.
+// Shutdown shuts down the HTTP Server, and stops removing expired upload
+// sessions.
 func (m *HttpServer) Shutdown(ctx context.Context) error {
-	return m.httpServer.Shutdown(ctx)
+	close(m.closing)
+	err := m.httpServer.Shutdown(ctx)
+	m.closed.Wait()
+	return err
 }
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var tlsNextProtos = []string{"h2", "http/1.1"}
+
+// tlsReloader serves the TLS configuration loaded from certificate, key and
+// client CA bundle files, and reloads it when any of the files change. Files
+// are checked for changes during TLS handshakes, at most once per interval.
+// A configuration that fails to load is logged and ignored, in which case the
+// previously loaded configuration continues to be served.
+type tlsReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+	interval     time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	stamps    []fileStamp
+	config    *tls.Config
+}
+
+// fileStamp identifies the version of a file by its modification time and size.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newTLSReloader(certFile, keyFile, clientCAFile string, interval time.Duration) (*tlsReloader, error) {
+	r := &tlsReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		interval:     interval,
+	}
+	stamps, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	config, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.stamps, r.config, r.checkedAt = stamps, config, time.Now()
+	return r, nil
+}
+
+// tlsConfig returns the TLS configuration with which to accept connections.
+// Its NextProtos signal to http.Server that HTTP/2 is to be served.
+func (r *tlsReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: tlsNextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current(), nil
+		},
+	}
+}
+
+// current returns the currently loaded configuration, reloading it first if
+// any of the files have changed since last checked.
+func (r *tlsReloader) current() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) < r.interval {
+		return r.config
+	}
+	r.checkedAt = time.Now()
+	stamps, err := r.stat()
+	if err != nil {
+		logger.Errorw("Failed to check TLS files for changes", "err", err)
+		return r.config
+	}
+	if equalStamps(stamps, r.stamps) {
+		return r.config
+	}
+	config, err := r.load()
+	if err != nil {
+		logger.Errorw("Failed to reload TLS configuration; continuing with previous configuration", "err", err)
+		return r.config
+	}
+	r.stamps, r.config = stamps, config
+	logger.Info("Reloaded TLS configuration.")
+	return r.config
+}
+
+func (r *tlsReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	return files
+}
+
+func (r *tlsReloader) stat() ([]fileStamp, error) {
+	files := r.files()
+	stamps := make([]fileStamp, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		stamps = append(stamps, fileStamp{modTime: info.ModTime(), size: info.Size()})
+	}
+	return stamps, nil
+}
+
+func (r *tlsReloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   tlsNextProtos,
+	}
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("TLS client CA bundle contains no PEM encoded certificates")
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+func equalStamps(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert issues a certificate with the given common name, signed by the
+// given parent or self-signed if parent is nil.
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM(), c.keyPEM(t))
+	require.NoError(t, err)
+	return cert
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string, modTime time.Time) {
+	require.NoError(t, os.WriteFile(certFile, c.certPEM(), 0600))
+	require.NoError(t, os.WriteFile(keyFile, c.keyPEM(t), 0600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+	ca := newTestCert(t, "ca", nil)
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM(), 0600))
+	first := newTestCert(t, "first", ca)
+	first.write(t, certFile, keyFile, time.Now().Add(-time.Minute))
+	client := newTestCert(t, "client", ca)
+	stranger := newTestCert(t, "stranger", newTestCert(t, "other-ca", nil))
+
+	_, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithTLSClientCAFile(caFile))
+	require.Error(t, err)
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()),
+		WithUploadDir(t.TempDir()),
+		WithTLSCertificate(certFile, keyFile),
+		WithTLSClientCAFile(caFile),
+		WithTLSReloadPeriod(0))
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = subject.httpServer.Serve(tls.NewListener(listener, subject.httpServer.TLSConfig)) }()
+	defer subject.httpServer.Close()
+	url := "https://" + listener.Addr().String() + "/healthz"
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(clientCert *testCert) (*http.Response, error) {
+		config := &tls.Config{RootCAs: roots}
+		if clientCert != nil {
+			config.Certificates = []tls.Certificate{clientCert.tlsCertificate(t)}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true}}
+		resp, err := c.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	_, err = get(nil)
+	require.Error(t, err, "client certificate is required")
+	_, err = get(stranger)
+	require.Error(t, err, "client certificate must be signed by trusted CA")
+	resp, err := get(client)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "first", resp.TLS.PeerCertificates[0].Subject.CommonName)
+	require.Equal(t, "h2", resp.TLS.NegotiatedProtocol)
+
+	// Changed certificates are served to new connections.
+	second := newTestCert(t, "second", ca)
+	second.write(t, certFile, keyFile, time.Now())
+	resp, err = get(client)
+	require.NoError(t, err)
+	require.Equal(t, "second", resp.TLS.PeerCertificates[0].Subject.CommonName)
+
+	// Invalid certificates are ignored in favour of the previous ones.
+	require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0600))
+	resp, err = get(client)
+	require.NoError(t, err)
+	require.Equal(t, "second", resp.TLS.PeerCertificates[0].Subject.CommonName)
+}
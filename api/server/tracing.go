@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/filecoin-project/motion/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// requestIDHeader is the header via which the ID of each API request is echoed
+// to the client. A client may set it on the request to choose the ID.
+const requestIDHeader = "X-Request-ID"
+
+var tracer = otel.Tracer("github.com/filecoin-project/motion/api/server")
+
+// traceRequest assigns an ID to each request to the handler, which is
+// registered at the given ServeMux pattern, and records the request as a span.
+// The span continues the trace propagated by the client, if any, and is carried
+// by the request context along with the request ID.
+func traceRequest(pattern string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		route := routeLabel(pattern, r.URL.Path)
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx = tracing.WithRequestID(ctx, id)
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(r.Method),
+				semconv.HTTPRoute(route),
+				attribute.String("motion.request_id", id),
+			))
+		defer span.End()
+
+		recorder := &responseRecorder{ResponseWriter: w, code: http.StatusOK}
+		handler(recorder, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPStatusCode(recorder.code))
+		if recorder.code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.code))
+		}
+	}
+}
+
+// isValidRequestID checks whether the request ID chosen by a client is short
+// and printable enough to be echoed and logged.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestLogger returns the logger with which to log while serving the given
+// request, annotated with the request and trace IDs.
+func requestLogger(r *http.Request) *zap.SugaredLogger {
+	return logger.With(tracing.LogFields(r.Context())...)
+}
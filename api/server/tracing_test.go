@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceRequest(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	subject, err := NewHttpServer(blob.NewLocalStore(t.TempDir()), WithUploadDir(t.TempDir()))
+	require.NoError(t, err)
+	handler := subject.ServeMux()
+
+	t.Run("generates request ID", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v0/blob/00000000-0000-0000-0000-000000000000", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Len(t, w.Header().Get(requestIDHeader), 36)
+	})
+
+	t.Run("honors client request ID", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/v0/blob/00000000-0000-0000-0000-000000000000", nil)
+		r.Header.Set(requestIDHeader, "fish-123")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, "fish-123", w.Header().Get(requestIDHeader))
+
+		r.Header.Set(requestIDHeader, "lobster\nthermidor")
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.NotEqual(t, "lobster\nthermidor", w.Header().Get(requestIDHeader))
+		require.Len(t, w.Header().Get(requestIDHeader), 36)
+	})
+
+	t.Run("continues client trace", func(t *testing.T) {
+		const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		r := httptest.NewRequest(http.MethodPost, "/v0/blob", strings.NewReader("fish"))
+		r.Header.Set("Content-Type", "application/octet-stream")
+		r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var server, put sdktrace.ReadOnlySpan
+		for _, span := range recorder.Ended() {
+			if span.SpanContext().TraceID().String() != traceID {
+				continue
+			}
+			switch span.Name() {
+			case "POST /v0/blob":
+				server = span
+			case "LocalStore.Put":
+				put = span
+			}
+		}
+		require.NotNil(t, server)
+		require.NotNil(t, put)
+		require.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+		require.True(t, server.Parent().IsRemote())
+		require.Equal(t, server.SpanContext().SpanID(), put.Parent().SpanID())
+	})
+}
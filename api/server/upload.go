@@ -0,0 +1,275 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/motion/blob"
+)
+
+var (
+	errUploadNotFound       = errors.New("no upload session is found with given ID")
+	errUploadInUse          = errors.New("upload session is in use")
+	errUploadOffsetMismatch = errors.New("upload offset does not match the current offset")
+	errUploadIncomplete     = errors.New("upload is not complete")
+	errUploadTooLarge       = errors.New("upload exceeds its declared length")
+)
+
+const (
+	uploadSessionInfoFileName = "info.json"
+	uploadSessionDataFileName = "data"
+	// uploadSweepInterval is the maximum interval at which expired upload
+	// sessions are removed.
+	uploadSweepInterval = 10 * time.Minute
+)
+
+type (
+	// uploadSessions manages resumable upload sessions staged on local disk.
+	// Each session is stored as a directory, named by the session ID,
+	// containing the session info and the data uploaded so far.
+	uploadSessions struct {
+		dir string
+		ttl time.Duration
+
+		mu    sync.Mutex
+		inUse map[string]struct{}
+	}
+	uploadSessionInfo struct {
+		// Length is the total length of the upload, if known upfront.
+		Length *uint64 `json:"length,omitempty"`
+		// CreatedAt is the time at which the session was created.
+		CreatedAt time.Time `json:"createdAt"`
+		// Metadata is the metadata to store along with the blob once the
+		// upload is finalised.
+		Metadata blob.Metadata `json:"metadata"`
+		// Digests are the client-declared digests against which the blob
+		// content is verified once the upload is finalised.
+		Digests contentDigests `json:"digests"`
+	}
+	uploadSession struct {
+		uploadSessionInfo
+		ID string
+		// Offset is the number of bytes uploaded so far.
+		Offset uint64
+		// UpdatedAt is the last time at which data was uploaded.
+		UpdatedAt time.Time
+	}
+)
+
+func newUploadSessions(dir string, ttl time.Duration) (*uploadSessions, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	return &uploadSessions{
+		dir:   dir,
+		ttl:   ttl,
+		inUse: make(map[string]struct{}),
+	}, nil
+}
+
+func (us *uploadSessions) sessionDir(id string) string {
+	return filepath.Join(us.dir, id)
+}
+
+// create creates a new upload session with optionally known total length, and
+// the metadata and declared digests of the blob to create. Expired sessions are
+// removed as a side effect.
+func (us *uploadSessions) create(length *uint64, metadata blob.Metadata, digests contentDigests) (*uploadSession, error) {
+	us.removeExpired()
+
+	blobID, err := blob.NewID()
+	if err != nil {
+		return nil, err
+	}
+	id := blobID.String()
+	if err := os.Mkdir(us.sessionDir(id), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create upload session directory: %w", err)
+	}
+	info := uploadSessionInfo{
+		Length:    length,
+		CreatedAt: time.Now().UTC(),
+		Metadata:  metadata,
+		Digests:   digests,
+	}
+	infoJson, err := json.Marshal(info)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(us.sessionDir(id), uploadSessionInfoFileName), infoJson, 0640)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(us.sessionDir(id), uploadSessionDataFileName), nil, 0640)
+	}
+	if err != nil {
+		_ = os.RemoveAll(us.sessionDir(id))
+		return nil, fmt.Errorf("failed to initialise upload session: %w", err)
+	}
+	return &uploadSession{
+		uploadSessionInfo: info,
+		ID:                id,
+		UpdatedAt:         info.CreatedAt,
+	}, nil
+}
+
+// get returns the upload session with the given ID, or errUploadNotFound if no
+// such session exists or the session has expired.
+func (us *uploadSessions) get(id string) (*uploadSession, error) {
+	session, err := us.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(us.expiresAt(session)) {
+		return nil, errUploadNotFound
+	}
+	return session, nil
+}
+
+// load loads the upload session with the given ID regardless of its expiry, or
+// returns errUploadNotFound if no such session exists.
+func (us *uploadSessions) load(id string) (*uploadSession, error) {
+	var decoded blob.ID
+	if err := decoded.Decode(id); err != nil {
+		return nil, errUploadNotFound
+	}
+	infoJson, err := os.ReadFile(filepath.Join(us.sessionDir(id), uploadSessionInfoFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errUploadNotFound
+		}
+		return nil, err
+	}
+	session := &uploadSession{ID: id}
+	if err := json.Unmarshal(infoJson, &session.uploadSessionInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode upload session info: %w", err)
+	}
+	stat, err := os.Stat(filepath.Join(us.sessionDir(id), uploadSessionDataFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errUploadNotFound
+		}
+		return nil, err
+	}
+	session.Offset = uint64(stat.Size())
+	session.UpdatedAt = stat.ModTime()
+	if session.UpdatedAt.Before(session.CreatedAt) {
+		session.UpdatedAt = session.CreatedAt
+	}
+	return session, nil
+}
+
+// acquire marks the session as in use, returning a function that releases it.
+// Returns errUploadInUse if the session is already in use. This prevents
+// concurrent writes to the same session.
+func (us *uploadSessions) acquire(id string) (func(), error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if _, ok := us.inUse[id]; ok {
+		return nil, errUploadInUse
+	}
+	us.inUse[id] = struct{}{}
+	return func() {
+		us.mu.Lock()
+		delete(us.inUse, id)
+		us.mu.Unlock()
+	}, nil
+}
+
+// append writes data from the given reader at the given offset of the session,
+// which must match the current session offset. At most limit bytes are
+// accepted; if the reader has more data errUploadTooLarge is returned. Any data
+// written before an error occurs remains part of the session, so that the
+// client can resume from the new offset.
+func (us *uploadSessions) append(session *uploadSession, offset uint64, limit uint64, r io.Reader) (uint64, error) {
+	if offset != session.Offset {
+		return session.Offset, errUploadOffsetMismatch
+	}
+	dataFile, err := os.OpenFile(filepath.Join(us.sessionDir(session.ID), uploadSessionDataFileName), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return session.Offset, err
+	}
+	defer dataFile.Close()
+
+	var remaining uint64
+	if limit > session.Offset {
+		remaining = limit - session.Offset
+	}
+	// Read one byte beyond the remaining length to detect oversized uploads.
+	written, err := io.Copy(dataFile, io.LimitReader(r, int64(remaining)+1))
+	if uint64(written) > remaining {
+		if truncErr := dataFile.Truncate(int64(limit)); truncErr != nil {
+			logger.Errorw("Failed to truncate oversized upload", "id", session.ID, "err", truncErr)
+		}
+		session.Offset = limit
+		return session.Offset, errUploadTooLarge
+	}
+	session.Offset += uint64(written)
+	return session.Offset, err
+}
+
+// open opens the uploaded data of the session for reading.
+func (us *uploadSessions) open(session *uploadSession) (*os.File, error) {
+	return os.Open(filepath.Join(us.sessionDir(session.ID), uploadSessionDataFileName))
+}
+
+// remove removes the session along with all of its uploaded data.
+func (us *uploadSessions) remove(id string) error {
+	return os.RemoveAll(us.sessionDir(id))
+}
+
+// expiresAt returns the time after which the session is removed if no further
+// data is uploaded.
+func (us *uploadSessions) expiresAt(session *uploadSession) time.Time {
+	return session.UpdatedAt.Add(us.ttl)
+}
+
+// runExpiry removes expired sessions periodically until closing is closed.
+// Sessions are removed within uploadSweepInterval of their expiry, or within
+// the TTL if shorter.
+func (us *uploadSessions) runExpiry(closing <-chan struct{}) {
+	ticker := time.NewTicker(min(us.ttl, uploadSweepInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closing:
+			return
+		case <-ticker.C:
+			us.removeExpired()
+		}
+	}
+}
+
+// removeExpired removes the sessions that have expired, unless in use.
+func (us *uploadSessions) removeExpired() {
+	entries, err := os.ReadDir(us.dir)
+	if err != nil {
+		logger.Warnw("Failed to list upload sessions", "err", err)
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		session, err := us.load(entry.Name())
+		if err != nil {
+			continue
+		}
+		if now.Before(us.expiresAt(session)) {
+			continue
+		}
+		release, err := us.acquire(session.ID)
+		if err != nil {
+			continue
+		}
+		if err := us.remove(session.ID); err != nil {
+			logger.Warnw("Failed to remove expired upload session", "id", session.ID, "err", err)
+		} else {
+			logger.Infow("Removed expired upload session", "id", session.ID)
+		}
+		release()
+	}
+}
@@ -1,19 +1,83 @@
 package server
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"mime"
 	"net/http"
+	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/filecoin-project/motion/api"
+	"github.com/filecoin-project/motion/blob"
 )
 
+const (
+	healthStatusOk          = "ok"
+	healthStatusUnavailable = "unavailable"
+	healthCheckStatusFail   = "fail"
+	// readinessCheckTimeout is the maximum time given to the store to perform
+	// its readiness checks.
+	readinessCheckTimeout = 10 * time.Second
+	// maxWebhookRequestLength is the maximum length of webhook subscription
+	// request bodies.
+	maxWebhookRequestLength = 64 << 10
+	// maxEstimateRequestLength is the maximum length of cost estimation
+	// request bodies.
+	maxEstimateRequestLength = 4 << 10
+
+	contentTypeOctetStream = "application/octet-stream"
+	// contentTypeCAR is the media type of blobs exported as CARv1.
+	// See: https://www.iana.org/assignments/media-types/application/vnd.ipld.car
+	contentTypeCAR = "application/vnd.ipld.car; version=1"
+	// blobFormatCAR is the value of the format query parameter with which blobs
+	// are exported as CAR.
+	blobFormatCAR = "car"
+	// httpHeaderMetadataTagPrefix is the prefix of HTTP headers that carry
+	// blob metadata tags.
+	httpHeaderMetadataTagPrefix = "X-Motion-Meta-"
+	// httpHeaderRetainUntil is the HTTP header that carries the time until
+	// which a blob must be retained, in RFC 3339 format.
+	httpHeaderRetainUntil = "X-Motion-Retain-Until"
+	// httpHeaderPolicy is the HTTP header that carries the name of the
+	// storage policy with which a blob is stored.
+	httpHeaderPolicy = "X-Motion-Policy"
+)
+
+var (
+	errInvalidContentType        = errors.New("invalid content type")
+	errInvalidContentDisposition = errors.New("invalid content disposition")
+	errInvalidContentDigest      = errors.New("invalid or conflicting content digest")
+	errInvalidRetainUntil        = errors.New("invalid retain until time")
+)
+
+// contentDigests are the digests of blob content declared by the client, used
+// to verify the content as it is stored.
+type contentDigests struct {
+	SHA256 []byte `json:"sha256,omitempty"`
+	MD5    []byte `json:"md5,omitempty"`
+}
+
 func httpHeaderContentTypeJson() (string, string) {
 	return "Content-Type", "application/json; charset=utf-8"
 }
 func httpHeaderContentTypeOctetStream() (string, string) {
-	return "Content-Type", "application/octet-stream"
+	return "Content-Type", contentTypeOctetStream
+}
+
+func httpHeaderContentTypeCAR() (string, string) {
+	return "Content-Type", contentTypeCAR
+}
+
+func httpHeaderContentDispositionAttachment(filename string) (string, string) {
+	return "Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename})
 }
 
 func httpHeaderContentTypeOptionsNoSniff() (string, string) {
@@ -24,6 +88,28 @@ func httpHeaderContentLength(length uint64) (string, string) {
 	return "Content-Length", strconv.FormatUint(length, 10)
 }
 
+func httpHeaderUploadOffset(offset uint64) (string, string) {
+	return "Upload-Offset", strconv.FormatUint(offset, 10)
+}
+
+func httpHeaderUploadLength(length uint64) (string, string) {
+	return "Upload-Length", strconv.FormatUint(length, 10)
+}
+
+// httpHeaderETag returns the strong entity tag of a blob with the given SHA-256
+// digest. Blobs are immutable, therefore their digest uniquely identifies the
+// representation.
+func httpHeaderETag(sha256 []byte) (string, string) {
+	return "ETag", `"` + hex.EncodeToString(sha256) + `"`
+}
+
+// httpHeaderReprDigest returns the Repr-Digest header of a blob with the given
+// SHA-256 digest.
+// See: https://www.rfc-editor.org/rfc/rfc9530
+func httpHeaderReprDigest(sha256 []byte) (string, string) {
+	return "Repr-Digest", "sha-256=:" + base64.StdEncoding.EncodeToString(sha256) + ":"
+}
+
 func httpHeaderAllow(methods ...string) (string, string) {
 	return "Allow", strings.Join(methods, ",")
 }
@@ -45,3 +131,181 @@ func respondWithNotAllowed(w http.ResponseWriter, allowedMethods ...string) {
 		Error: `Method not allowed. Please see "Allow" response header for the list of allowed methods.`,
 	}, http.StatusMethodNotAllowed)
 }
+
+// metadataFromHeader extracts the blob metadata from the given request header:
+//   - Content-Type: the blob content type; application/octet-stream is
+//     considered as unknown content type.
+//   - Content-Disposition: the filename parameter as the blob filename.
+//   - X-Motion-Meta-*: the blob tags, keyed by the lower-case header name
+//     suffix.
+//   - X-Motion-Retain-Until: the time until which the blob must be retained,
+//     in RFC 3339 format.
+//   - X-Motion-Policy: the name of the storage policy with which the blob is
+//     stored.
+func metadataFromHeader(header http.Header) (blob.Metadata, error) {
+	var metadata blob.Metadata
+	if value := header.Get("Content-Type"); value != "" {
+		mediaType, params, err := mime.ParseMediaType(value)
+		if err != nil {
+			return metadata, errInvalidContentType
+		}
+		if mediaType != contentTypeOctetStream {
+			metadata.ContentType = mime.FormatMediaType(mediaType, params)
+		}
+	}
+	if value := header.Get("Content-Disposition"); value != "" {
+		_, params, err := mime.ParseMediaType(value)
+		if err != nil {
+			return metadata, errInvalidContentDisposition
+		}
+		if filename := params["filename"]; filename != "" {
+			// Only keep the base name, discarding any directories.
+			filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+			if filename != "." && filename != "/" {
+				metadata.Filename = filename
+			}
+		}
+	}
+	for key := range header {
+		tag, ok := strings.CutPrefix(key, httpHeaderMetadataTagPrefix)
+		if !ok || tag == "" {
+			continue
+		}
+		if metadata.Tags == nil {
+			metadata.Tags = make(map[string]string)
+		}
+		metadata.Tags[strings.ToLower(tag)] = header.Get(key)
+	}
+	if value := header.Get(httpHeaderRetainUntil); value != "" {
+		retainUntil, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return metadata, errInvalidRetainUntil
+		}
+		metadata.RetainUntil = &retainUntil
+	}
+	metadata.Policy = header.Get(httpHeaderPolicy)
+	return metadata, nil
+}
+
+// setMetadataHeader sets the response headers that replay the given blob
+// metadata. See metadataFromHeader.
+func setMetadataHeader(header http.Header, id blob.ID, metadata blob.Metadata) {
+	if metadata.ContentType != "" {
+		header.Set("Content-Type", metadata.ContentType)
+	} else {
+		header.Set(httpHeaderContentTypeOctetStream())
+	}
+	filename := metadata.Filename
+	if filename == "" {
+		filename = id.String() + ".bin"
+	}
+	header.Set(httpHeaderContentDispositionAttachment(filename))
+	for tag, value := range metadata.Tags {
+		header.Set(httpHeaderMetadataTagPrefix+tag, value)
+	}
+	if metadata.RetainUntil != nil {
+		header.Set(httpHeaderRetainUntil, metadata.RetainUntil.Format(time.RFC3339))
+	}
+	if metadata.Policy != "" {
+		header.Set(httpHeaderPolicy, metadata.Policy)
+	}
+}
+
+// setDigestHeader sets the ETag and Repr-Digest response headers of a blob with
+// the given SHA-256 digest. No headers are set if the digest is unknown.
+func setDigestHeader(header http.Header, sha256 []byte) {
+	if sha256 == nil {
+		return
+	}
+	header.Set(httpHeaderETag(sha256))
+	header.Set(httpHeaderReprDigest(sha256))
+}
+
+func respondWithMetadataError(w http.ResponseWriter, err error) {
+	switch err {
+	case errInvalidContentDisposition:
+		respondWithJson(w, errResponseInvalidDisposition, http.StatusBadRequest)
+	case errInvalidRetainUntil:
+		respondWithJson(w, errResponseInvalidRetainUntil, http.StatusBadRequest)
+	default:
+		respondWithJson(w, errResponseInvalidContentType, http.StatusBadRequest)
+	}
+}
+
+// contentDigestsFromHeader extracts the client-declared digests of blob content
+// from the given request header:
+//   - Content-Digest: the "sha-256" digest, as specified by RFC 9530. Digests
+//     of other algorithms are ignored.
+//   - Content-MD5: the base64 encoded MD5 digest, as specified by RFC 1864.
+//   - X-Checksum-Sha256: the hex or base64 encoded SHA-256 digest.
+//
+// errInvalidContentDigest is returned if any of the headers is malformed, or
+// if the declared SHA-256 digests disagree with each other.
+func contentDigestsFromHeader(header http.Header) (contentDigests, error) {
+	var digests contentDigests
+	setSHA256 := func(digest []byte) error {
+		if len(digest) != sha256.Size || (digests.SHA256 != nil && !bytes.Equal(digests.SHA256, digest)) {
+			return errInvalidContentDigest
+		}
+		digests.SHA256 = digest
+		return nil
+	}
+	for _, value := range header.Values("Content-Digest") {
+		for _, member := range strings.Split(value, ",") {
+			algorithm, encoded, ok := strings.Cut(strings.TrimSpace(member), "=")
+			if !ok {
+				return digests, errInvalidContentDigest
+			}
+			if strings.ToLower(algorithm) != "sha-256" {
+				continue
+			}
+			encoded, ok = strings.CutPrefix(encoded, ":")
+			if !ok {
+				return digests, errInvalidContentDigest
+			}
+			encoded, ok = strings.CutSuffix(encoded, ":")
+			if !ok {
+				return digests, errInvalidContentDigest
+			}
+			digest, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return digests, errInvalidContentDigest
+			}
+			if err := setSHA256(digest); err != nil {
+				return digests, err
+			}
+		}
+	}
+	if value := header.Get("X-Checksum-Sha256"); value != "" {
+		digest, err := hex.DecodeString(value)
+		if err != nil {
+			if digest, err = base64.StdEncoding.DecodeString(value); err != nil {
+				return digests, errInvalidContentDigest
+			}
+		}
+		if err := setSHA256(digest); err != nil {
+			return digests, err
+		}
+	}
+	if value := header.Get("Content-MD5"); value != "" {
+		digest, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(digest) != md5.Size {
+			return digests, errInvalidContentDigest
+		}
+		digests.MD5 = digest
+	}
+	return digests, nil
+}
+
+// putOptions returns the options with which the declared digests are verified
+// by the blob store.
+func (d contentDigests) putOptions() []blob.PutOption {
+	var options []blob.PutOption
+	if d.SHA256 != nil {
+		options = append(options, blob.WithExpectedSHA256(d.SHA256))
+	}
+	if d.MD5 != nil {
+		options = append(options, blob.WithExpectedMD5(d.MD5))
+	}
+	return options
+}
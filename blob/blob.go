@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"io"
+	"math/big"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-log/v2"
 )
 
@@ -15,6 +17,10 @@ var (
 	ErrBlobNotFound   = errors.New("no blob is found with given ID")
 	ErrBlobTooLarge   = errors.New("blob size exceeds the maximum allowed")
 	ErrNotEnoughSpace = errors.New("insufficient local storage space remaining")
+	ErrInvalidCursor  = errors.New("invalid list cursor")
+	ErrSizeMismatch   = errors.New("blob size does not match the expected size")
+	ErrDigestMismatch = errors.New("blob content does not match the expected digest")
+	ErrUnknownPolicy  = errors.New("no storage policy is configured with given name")
 )
 
 var (
@@ -32,26 +38,240 @@ type (
 		Size uint64
 		// ModificationTime is the latest time at which the blob was modified.
 		ModificationTime time.Time
-		Replicas         []Replica
+		// Metadata is the client-supplied metadata stored along with the blob.
+		Metadata Metadata
+		// SHA256 is the SHA-256 digest of the blob content, computed by the
+		// store as the blob was written. Nil if the digest is unknown, e.g. for
+		// blobs stored before digests were recorded.
+		SHA256 []byte
+		// RootCID is the CID of the root of the UnixFS DAG that represents the
+		// blob content, as exported by WriteCAR. Undefined if the root CID is
+		// unknown, e.g. for blobs stored before root CIDs were recorded.
+		RootCID cid.Cid
+		// Replicas are the copies of the blob made with storage providers, one
+		// per provider.
+		Replicas []Replica
+		// Packs are the CARs into which the blob was packed, in order of the
+		// blob content they contain. Empty if the store does not pack blobs or
+		// if the blob is not packed yet.
+		Packs []Pack
+		// TargetReplicas is the number of storage providers that should hold
+		// active deals for the blob. Zero if the store does not replicate
+		// blobs.
+		TargetReplicas uint
+		// ActiveReplicas is the number of storage providers that hold active
+		// deals for all the pieces that contain the blob. The blob is safe once
+		// ActiveReplicas reaches TargetReplicas.
+		ActiveReplicas uint
+		// Repairs are the repairs made to replicas of the pieces that contain
+		// the blob, most recent first. Empty if the store does not repair
+		// replicas or if no replica was lost.
+		Repairs []Repair
 	}
+	// Metadata is the client-supplied information about a blob, stored along
+	// with it and replayed on retrieval.
+	Metadata struct {
+		// ContentType is the media type of the blob content.
+		ContentType string `json:"contentType,omitempty"`
+		// Filename is the name of the file from which the blob was created.
+		Filename string `json:"filename,omitempty"`
+		// Tags are arbitrary key-value pairs associated to the blob.
+		Tags map[string]string `json:"tags,omitempty"`
+		// RetainUntil, if non-nil, is the time until which the blob must be
+		// kept stored on Filecoin. Deals for the blob are renewed up to that
+		// time, after which they are allowed to lapse. The blob is retained
+		// indefinitely if nil.
+		RetainUntil *time.Time `json:"retainUntil,omitempty"`
+		// Policy is the name of the storage policy with which the blob is
+		// stored on Filecoin, or empty for the default deal settings of the
+		// store. Stores reject unknown policies with ErrUnknownPolicy, and
+		// stores that make no deals ignore it.
+		Policy string `json:"policy,omitempty"`
+	}
+	// Replica is the copy of a blob held by a storage provider.
 	Replica struct {
 		Provider string
-		Pieces   []Piece
+		// Pieces are the deals made with the provider for the pieces that
+		// contain the blob.
+		Pieces []Piece
 	}
+	// Piece describes a deal made for a piece that contains a blob.
 	Piece struct {
 		Expiration  time.Time
 		LastUpdated time.Time
 		PieceCID    string
 		Status      string
+		// PieceSize is the padded size of the piece in bytes.
+		PieceSize uint64
+		// PayloadCID is the root CID of the CAR from which the piece was made.
+		PayloadCID string
+		// DealID is the on-chain ID of the deal. Zero until the deal is
+		// published.
+		DealID uint64
+		// ProposalID identifies the deal proposal, i.e. the proposal CID of
+		// deals made over the legacy deal protocol, or the Boost deal UUID.
+		ProposalID string
+		// Client is the address of the wallet that made the deal.
+		Client string
+		// Verified is whether the deal was made with DataCap.
+		Verified bool
+		// Price is the storage price of the deal per epoch, in attoFIL.
+		Price string
+		// StartEpoch is the epoch at which the deal starts.
+		StartEpoch int64
+		// EndEpoch is the epoch at which the deal expires.
+		EndEpoch int64
+	}
+	// Pack describes a CAR into which a range of blob content was packed.
+	Pack struct {
+		// JobID is the ID of the job that packed the CAR.
+		JobID int64
+		// Offset is the offset of the packed range in the blob content.
+		Offset uint64
+		// Length is the length of the packed range in bytes.
+		Length uint64
+		// PieceCID is the piece CID of the CAR. Empty until the pack job is
+		// complete.
+		PieceCID string
+		// PieceSize is the padded size of the piece in bytes.
+		PieceSize uint64
+		// PayloadCID is the root CID of the CAR.
+		PayloadCID string
+		// CARSize is the size of the CAR in bytes.
+		CARSize uint64
+	}
+	// Repair describes the repair of a piece that contains a blob after some
+	// replicas of the piece were lost, i.e. their deals expired, were slashed
+	// or failed.
+	Repair struct {
+		// PieceCID is the CID of the repaired piece.
+		PieceCID string
+		// Lost are the storage providers whose replicas of the piece were lost.
+		Lost []string
+		// Replacements are the storage providers with which new deals for the
+		// piece are made in place of the lost replicas. Empty if deals are
+		// retried with the providers that lost them.
+		Replacements []string
+		// Restored reports whether the local copy of the blob was restored
+		// from a surviving replica, so that new deals can be made for it.
+		Restored bool
+		// Time is the time at which the repair was made.
+		Time time.Time
+		// Error describes why the repair failed, if it did.
+		Error string
 	}
 	Store interface {
-		Put(context.Context, io.Reader) (*Descriptor, error)
+		Put(context.Context, io.Reader, ...PutOption) (*Descriptor, error)
 		Describe(context.Context, ID) (*Descriptor, error)
 		Get(context.Context, ID) (io.ReadSeekCloser, error)
 	}
+	// PassThroughGet is implemented by stores that serve the content of blobs
+	// over HTTP directly. Implementations handle byte range and conditional
+	// requests as http.ServeContent does, using the ETag response header set by
+	// the caller, if any.
 	PassThroughGet interface {
 		PassGet(http.ResponseWriter, *http.Request, ID)
 	}
+	// ListOptions specifies the filtering and pagination of blobs listed by a
+	// Lister.
+	ListOptions struct {
+		// Cursor is the opaque position after which listing starts, as returned
+		// by ListResult.NextCursor. Empty cursor lists from the beginning.
+		Cursor string
+		// Limit is the maximum number of blobs to list. Defaults to
+		// DefaultListLimit if zero, and is capped at MaxListLimit.
+		Limit int
+		// CreatedAfter, if non-zero, only lists blobs created at or after the
+		// given time. Blobs are immutable, therefore their creation time is
+		// their Descriptor.ModificationTime.
+		CreatedAfter time.Time
+		// CreatedBefore, if non-zero, only lists blobs created before the given
+		// time.
+		CreatedBefore time.Time
+		// MinSize only lists blobs with size of at least the given bytes.
+		MinSize uint64
+		// MaxSize, if non-zero, only lists blobs with size of at most the given
+		// bytes.
+		MaxSize uint64
+	}
+	// ListResult is a single page of blobs listed by a Lister.
+	ListResult struct {
+		// Descriptors describe the listed blobs in ascending order of ID.
+		Descriptors []*Descriptor
+		// NextCursor is the cursor from which to list the next page. Empty
+		// if there are no more blobs to list.
+		NextCursor string
+	}
+	// Lister is implemented by stores that support enumerating the blobs they
+	// hold. Invalid ListOptions.Cursor must result in ErrInvalidCursor.
+	Lister interface {
+		ListBlobs(context.Context, ListOptions) (*ListResult, error)
+	}
+	// HealthCheck is the result of checking a single aspect of a store's health.
+	HealthCheck struct {
+		// Name identifies the checked aspect, e.g. "local_store_writable".
+		Name string
+		// Err is the reason for which the check failed, or nil if it passed.
+		Err error
+	}
+	// HealthChecker is implemented by stores that can check whether they are
+	// ready to store and retrieve blobs.
+	HealthChecker interface {
+		// CheckHealth performs the store health checks and returns the result
+		// of each. The store is healthy only if all checks pass.
+		CheckHealth(context.Context) []HealthCheck
+	}
+	// Estimate is the expected cost of storing data on Filecoin, as estimated
+	// by an Estimator. Amounts are in attoFIL.
+	Estimate struct {
+		// Size is the size of the data in bytes.
+		Size uint64
+		// Policy is the name of the storage policy with which the data would be
+		// stored, or empty for the default deal settings of the store.
+		Policy string
+		// MaxCARSize is the maximum size in bytes of the CARs into which the
+		// data is packed.
+		MaxCARSize uint64
+		// PieceSize is the padded size in bytes of the piece made from each CAR.
+		PieceSize uint64
+		// Pieces is the number of pieces made from the data.
+		Pieces uint64
+		// PaddedSize is the total padded size of the pieces in bytes.
+		PaddedSize uint64
+		// DealDuration is the duration of each deal.
+		DealDuration time.Duration
+		// Deals is the number of deals made for the pieces across all storage
+		// providers.
+		Deals uint64
+		// PricePerEpoch is the price of each deal per epoch, as proposed to
+		// storage providers.
+		PricePerEpoch *big.Int
+		// PricePerDeal is the price of each deal over its duration, i.e. its
+		// price per epoch times the number of epochs in DealDuration.
+		PricePerDeal *big.Int
+		// TotalCost is the price of all the deals.
+		TotalCost *big.Int
+		// Providers break down the deals and their cost by storage provider.
+		Providers []ProviderEstimate
+	}
+	// ProviderEstimate is the share of an Estimate expected to be stored by a
+	// single storage provider.
+	ProviderEstimate struct {
+		Provider string
+		Deals    uint64
+		Cost     *big.Int
+	}
+	// Estimator is implemented by stores that can estimate the cost of storing
+	// data on Filecoin. Estimate must return ErrUnknownPolicy if no storage
+	// policy is configured with the given name.
+	Estimator interface {
+		Estimate(ctx context.Context, size uint64, policy string) (*Estimate, error)
+	}
+	// Remover is implemented by stores that support the removal of blobs.
+	// Remove must return ErrBlobNotFound if no blob exists for the given ID.
+	Remover interface {
+		Remove(context.Context, ID) error
+	}
 )
 
 // NewID instantiates a new randomly generated ID.
@@ -78,3 +298,8 @@ func (i *ID) Decode(v string) error {
 	*i = ID(id)
 	return nil
 }
+
+// IsEmpty checks whether the metadata has no information.
+func (m Metadata) IsEmpty() bool {
+	return m.ContentType == "" && m.Filename == "" && len(m.Tags) == 0 && m.RetainUntil == nil && m.Policy == ""
+}
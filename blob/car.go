@@ -0,0 +1,152 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-unixfsnode/data/builder"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/storage"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+)
+
+// dagChunker is the chunker with which the UnixFS DAG of blob content is built.
+// Chunks are 1 MiB long, and encoded as raw leaves with CIDv1. This matches the
+// chunks in which the RIBS store keeps blobs, so that the DAG of a blob stored
+// in RIBS is made of the very chunks that RIBS holds.
+const dagChunker = "size-1048576"
+
+// errRootWriterClosed signals that a RootWriter was closed before the root was
+// computed.
+var errRootWriterClosed = errors.New("root writer closed")
+
+// RootWriter computes the CID of the root of the UnixFS DAG that represents
+// the content written to it, i.e. the root of the CAR written by WriteCAR for
+// the same content. Store implementations use it to find the root CID of blob
+// content while it is streamed to storage.
+//
+// Close must be called once the RootWriter is no longer needed, whether or not
+// Root was called.
+type RootWriter struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	root cid.Cid
+	err  error
+}
+
+// NewRootWriter instantiates a new RootWriter.
+func NewRootWriter() *RootWriter {
+	pr, pw := io.Pipe()
+	w := &RootWriter{
+		pw:   pw,
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(w.done)
+		ls := cidlink.DefaultLinkSystem()
+		ls.StorageWriteOpener = func(ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+			return io.Discard, func(ipld.Link) error { return nil }, nil
+		}
+		w.root, w.err = buildDAG(pr, &ls)
+		// Unblock any pending writes in case building the DAG failed.
+		_ = pr.CloseWithError(w.err)
+	}()
+	return w
+}
+
+func (w *RootWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Root returns the root CID of the content written so far, which is considered
+// complete. Root must only be called once.
+func (w *RootWriter) Root() (cid.Cid, error) {
+	_ = w.pw.Close()
+	<-w.done
+	return w.root, w.err
+}
+
+// Close stops computing the root CID, if not done already.
+func (w *RootWriter) Close() error {
+	_ = w.pw.CloseWithError(errRootWriterClosed)
+	<-w.done
+	return nil
+}
+
+// RootOf computes the CID of the root of the UnixFS DAG that represents the
+// given content. See RootWriter.
+func RootOf(content io.Reader) (cid.Cid, error) {
+	w := NewRootWriter()
+	defer w.Close()
+	if _, err := io.Copy(w, content); err != nil {
+		return cid.Undef, err
+	}
+	return w.Root()
+}
+
+// WriteCAR writes the given content to w as a CARv1 that contains the UnixFS
+// DAG representing the content. The CAR is streamed as the content is read,
+// with the given root CID in its header, as computed by RootWriter. If root is
+// undefined, it is computed first by reading the content fully, after which
+// the content is sought back to its start.
+//
+// The root CID is returned. If the DAG built from the content does not match
+// the given root, e.g. because the content changed, an error is returned once
+// the CAR is written.
+func WriteCAR(ctx context.Context, w io.Writer, content io.ReadSeeker, root cid.Cid) (cid.Cid, error) {
+	if !root.Defined() {
+		var err error
+		if root, err = RootOf(content); err != nil {
+			return cid.Undef, fmt.Errorf("failed to compute root CID: %w", err)
+		}
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return cid.Undef, err
+		}
+	}
+
+	car, err := storage.NewWritable(w, []cid.Cid{root}, carv2.WriteAsCarV1(true))
+	if err != nil {
+		return cid.Undef, err
+	}
+	ls := cidlink.DefaultLinkSystem()
+	ls.StorageWriteOpener = func(ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		var buf bytes.Buffer
+		return &buf, func(l ipld.Link) error {
+			return car.Put(ctx, l.(cidlink.Link).Cid.KeyString(), buf.Bytes())
+		}, nil
+	}
+	built, err := buildDAG(contextReader{ctx: ctx, r: content}, &ls)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !built.Equals(root) {
+		return cid.Undef, fmt.Errorf("root CID of content %s does not match the expected root %s", built, root)
+	}
+	return root, nil
+}
+
+func buildDAG(content io.Reader, ls *ipld.LinkSystem) (cid.Cid, error) {
+	link, _, err := builder.BuildUnixFSFile(content, dagChunker, ls)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return link.(cidlink.Link).Cid, nil
+}
+
+// contextReader stops reading once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,78 @@
+package blob_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/filecoin-project/motion/blob"
+	"github.com/ipfs/go-cid"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCAR(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		wantLeaves int
+	}{
+		{name: "empty", size: 0, wantLeaves: 1},
+		{name: "single chunk", size: 1 << 10, wantLeaves: 1},
+		{name: "multiple chunks", size: 5<<20 + 42, wantLeaves: 6},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := make([]byte, test.size)
+			_, err := rand.Read(content)
+			require.NoError(t, err)
+
+			root, err := blob.RootOf(bytes.NewReader(content))
+			require.NoError(t, err)
+			if test.wantLeaves == 1 {
+				// Content that fits in a single chunk is represented by a raw leaf.
+				mh, err := multihash.Sum(content, multihash.SHA2_256, -1)
+				require.NoError(t, err)
+				require.Equal(t, cid.NewCidV1(cid.Raw, mh), root)
+			}
+
+			for _, given := range []cid.Cid{root, cid.Undef} {
+				var car bytes.Buffer
+				got, err := blob.WriteCAR(context.Background(), &car, bytes.NewReader(content), given)
+				require.NoError(t, err)
+				require.Equal(t, root, got)
+
+				reader, err := carv2.NewBlockReader(&car)
+				require.NoError(t, err)
+				require.Equal(t, uint64(1), reader.Version)
+				require.Equal(t, []cid.Cid{root}, reader.Roots)
+				leaves := []byte{}
+				var leafCount int
+				for {
+					block, err := reader.Next()
+					if err == io.EOF {
+						break
+					}
+					require.NoError(t, err)
+					if block.Cid().Prefix().Codec == uint64(multicodec.Raw) {
+						leaves = append(leaves, block.RawData()...)
+						leafCount++
+					}
+				}
+				require.Equal(t, test.wantLeaves, leafCount)
+				require.Equal(t, content, leaves)
+			}
+		})
+	}
+}
+
+func TestWriteCARMismatchingRoot(t *testing.T) {
+	root, err := blob.RootOf(bytes.NewReader([]byte("fish")))
+	require.NoError(t, err)
+	_, err = blob.WriteCAR(context.Background(), io.Discard, bytes.NewReader([]byte("lobster")), root)
+	require.Error(t, err)
+}
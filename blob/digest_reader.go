@@ -0,0 +1,76 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"hash"
+	"io"
+)
+
+// DigestReader computes the SHA-256 digest of the content read through it.
+// Once the underlying reader reaches EOF, the content is verified against the
+// expectations of PutOptions, and reading fails with ErrSizeMismatch or
+// ErrDigestMismatch if it does not meet them. Store implementations use it to
+// digest and verify blob content while it is streamed to storage.
+type DigestReader struct {
+	r      io.Reader
+	opts   *PutOptions
+	sha256 hash.Hash
+	md5    hash.Hash
+	read   uint64
+	sum    []byte
+}
+
+// NewDigestReader instantiates a new DigestReader that reads from r and
+// verifies the content against the given options.
+func NewDigestReader(r io.Reader, opts *PutOptions) *DigestReader {
+	d := &DigestReader{
+		r:      r,
+		opts:   opts,
+		sha256: sha256.New(),
+	}
+	if opts.ExpectedMD5 != nil {
+		d.md5 = md5.New()
+	}
+	return d
+}
+
+func (d *DigestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.sha256.Write(p[:n])
+	if d.md5 != nil {
+		d.md5.Write(p[:n])
+	}
+	d.read += uint64(n)
+	if size := d.opts.ExpectedSize; size != nil && d.read > *size {
+		return n, ErrSizeMismatch
+	}
+	if err == io.EOF {
+		if verr := d.verify(); verr != nil {
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+func (d *DigestReader) verify() error {
+	if size := d.opts.ExpectedSize; size != nil && d.read != *size {
+		return ErrSizeMismatch
+	}
+	sum := d.sha256.Sum(nil)
+	if d.opts.ExpectedSHA256 != nil && !bytes.Equal(sum, d.opts.ExpectedSHA256) {
+		return ErrDigestMismatch
+	}
+	if d.md5 != nil && !bytes.Equal(d.md5.Sum(nil), d.opts.ExpectedMD5) {
+		return ErrDigestMismatch
+	}
+	d.sum = sum
+	return nil
+}
+
+// SHA256 returns the SHA-256 digest of the content. It is only available once
+// the content has been read fully and verified; nil is returned otherwise.
+func (d *DigestReader) SHA256() []byte {
+	return d.sum
+}
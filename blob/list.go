@@ -0,0 +1,116 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+const (
+	// DefaultListLimit is the number of blobs listed when ListOptions.Limit is
+	// unset.
+	DefaultListLimit = 100
+	// MaxListLimit is the maximum number of blobs listed in a single page.
+	MaxListLimit = 1000
+)
+
+// Match checks whether the given descriptor satisfies the filters in options.
+// Pagination options are not considered.
+func (o ListOptions) Match(d *Descriptor) bool {
+	if !o.CreatedAfter.IsZero() && d.ModificationTime.Before(o.CreatedAfter) {
+		return false
+	}
+	if !o.CreatedBefore.IsZero() && !d.ModificationTime.Before(o.CreatedBefore) {
+		return false
+	}
+	if d.Size < o.MinSize {
+		return false
+	}
+	if o.MaxSize != 0 && d.Size > o.MaxSize {
+		return false
+	}
+	return true
+}
+
+// PageLimit returns the maximum number of blobs to list in a page, i.e. Limit
+// defaulted to DefaultListLimit and capped at MaxListLimit.
+func (o ListOptions) PageLimit() int {
+	switch {
+	case o.Limit <= 0:
+		return DefaultListLimit
+	case o.Limit > MaxListLimit:
+		return MaxListLimit
+	default:
+		return o.Limit
+	}
+}
+
+// CursorID decodes Cursor into the ID of the blob after which listing starts,
+// or nil if listing starts from the beginning. Returns ErrInvalidCursor if the
+// cursor is not a blob ID.
+func (o ListOptions) CursorID() (*ID, error) {
+	if o.Cursor == "" {
+		return nil, nil
+	}
+	var id ID
+	if err := id.Decode(o.Cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &id, nil
+}
+
+// ListIDs lists a page of blobs from the given IDs according to options. Only
+// the IDs after the options cursor are described using describe, until the page
+// is full. IDs for which describe returns ErrBlobNotFound are skipped, since
+// they may have been removed concurrently.
+//
+// This function is intended to help Lister implementations that can cheaply
+// enumerate their blob IDs but need more work to describe each blob.
+func ListIDs(ctx context.Context, ids []ID, options ListOptions, describe func(context.Context, ID) (*Descriptor, error)) (*ListResult, error) {
+	var after string
+	cursor, err := options.CursorID()
+	if err != nil {
+		return nil, err
+	}
+	if cursor != nil {
+		after = cursor.String()
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if key := id.String(); key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	limit := options.PageLimit()
+	result := &ListResult{}
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var id ID
+		if err := id.Decode(key); err != nil {
+			return nil, err
+		}
+		desc, err := describe(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrBlobNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if !options.Match(desc) {
+			continue
+		}
+		result.Descriptors = append(result.Descriptors, desc)
+		if len(result.Descriptors) == limit {
+			if i+1 < len(keys) {
+				result.NextCursor = key
+			}
+			break
+		}
+	}
+	return result, nil
+}
@@ -2,6 +2,7 @@ package blob
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -10,21 +11,45 @@ import (
 
 	"strings"
 
+	"github.com/filecoin-project/motion/tracing"
 	"github.com/gammazero/fsutil/disk"
+	"github.com/ipfs/go-cid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var _ Store = (*LocalStore)(nil)
+var (
+	_ Store         = (*LocalStore)(nil)
+	_ Lister        = (*LocalStore)(nil)
+	_ Remover       = (*LocalStore)(nil)
+	_ HealthChecker = (*LocalStore)(nil)
+
+	tracer = otel.Tracer("github.com/filecoin-project/motion/blob")
+)
 
 // LocalStore is a Store that stores blobs as flat files in a configured directory.
 // Blobs are stored as flat files, named by their ID with .bin extension.
+// The SHA-256 digest, root CID and any metadata of a blob are stored in a
+// sidecar JSON file named by the blob file name with .meta extension, e.g.
+// <id>.bin.meta.
 // This store is used primarily for testing purposes.
 type LocalStore struct {
 	dir          string
 	minFreeSpace uint64
 }
 
+// localSidecar is the information stored alongside a blob in LocalStore.
+type localSidecar struct {
+	Metadata Metadata `json:"metadata"`
+	SHA256   []byte   `json:"sha256,omitempty"`
+	RootCID  cid.Cid  `json:"rootCid"`
+}
+
 // NewLocalStore instantiates a new LocalStore and uses the given dir as the place to store blobs.
 // Blobs are stored as flat files, named by their ID with .bin extension.
+// The disk usage of the store directory is reported by the collector returned
+// by Collector, once registered.
 func NewLocalStore(dir string, options ...Option) *LocalStore {
 	opts := getOpts(options)
 	logger.Debugw("Instantiated local store", "dir", dir)
@@ -40,6 +65,10 @@ func (l *LocalStore) Dir() string {
 }
 
 // Put reads the given reader fully and stores its content in the store directory as flat files.
+// The SHA-256 digest and root CID of the content, along with any metadata
+// specified via WithMetadata, are stored in a sidecar file. Content that does
+// not meet the expected size or digests in PutOptions is rejected with
+// ErrSizeMismatch or ErrDigestMismatch respectively.
 //
 // The reader content is first stored in a temporary directory and upon
 // successful storage is moved to the store directory. The
@@ -50,7 +79,12 @@ func (l *LocalStore) Dir() string {
 // Before a blob is written, the minimum amount of free space must be available
 // on the local disk. If writing the blob consumes more then the available
 // space (free space - minimum free), then this results in an error.
-func (l *LocalStore) Put(_ context.Context, reader io.Reader) (*Descriptor, error) {
+func (l *LocalStore) Put(ctx context.Context, reader io.Reader, o ...PutOption) (_ *Descriptor, err error) {
+	_, span := tracer.Start(ctx, "LocalStore.Put")
+	defer func() { tracing.End(span, err) }()
+	opts := NewPutOptions(o...)
+	digests := NewDigestReader(reader, opts)
+	reader = digests
 	var limit int64
 	if l.minFreeSpace != 0 {
 		usage, err := disk.Usage(l.dir)
@@ -72,7 +106,9 @@ func (l *LocalStore) Put(_ context.Context, reader io.Reader) (*Descriptor, erro
 	}
 	defer dest.Close()
 
-	written, err := io.Copy(dest, reader)
+	rootWriter := NewRootWriter()
+	defer rootWriter.Close()
+	written, err := io.Copy(io.MultiWriter(dest, rootWriter), reader)
 	if err != nil {
 		os.Remove(dest.Name())
 		return nil, err
@@ -83,28 +119,95 @@ func (l *LocalStore) Put(_ context.Context, reader io.Reader) (*Descriptor, erro
 		return nil, ErrBlobTooLarge
 	}
 
+	root, err := rootWriter.Root()
+	if err != nil {
+		os.Remove(dest.Name())
+		return nil, fmt.Errorf("failed to compute root CID: %w", err)
+	}
 	id, err := NewID()
 	if err != nil {
 		return nil, err
 	}
-	if err = os.Rename(dest.Name(), filepath.Join(l.dir, id.String()+".bin")); err != nil {
+	sidecar := &localSidecar{
+		Metadata: opts.Metadata,
+		SHA256:   digests.SHA256(),
+		RootCID:  root,
+	}
+	if err := l.writeSidecar(*id, sidecar); err != nil {
+		os.Remove(dest.Name())
+		return nil, err
+	}
+	if err = os.Rename(dest.Name(), l.path(*id)); err != nil {
+		os.Remove(l.sidecarPath(*id))
 		return nil, err
 	}
 	stat, err := dest.Stat()
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(attribute.String("motion.blob.id", id.String()), attribute.Int64("motion.blob.size", written))
 	return &Descriptor{
 		ID:               *id,
 		Size:             uint64(written),
 		ModificationTime: stat.ModTime(),
+		Metadata:         opts.Metadata,
+		SHA256:           sidecar.SHA256,
+		RootCID:          sidecar.RootCID,
 	}, nil
 }
 
+func (l *LocalStore) path(id ID) string {
+	return filepath.Join(l.dir, id.String()+".bin")
+}
+
+func (l *LocalStore) sidecarPath(id ID) string {
+	return l.path(id) + ".meta"
+}
+
+func (l *LocalStore) writeSidecar(id ID, sidecar *localSidecar) error {
+	sidecarJson, err := json.Marshal(sidecar)
+	if err != nil {
+		return fmt.Errorf("failed to encode sidecar: %w", err)
+	}
+	tmp, err := os.CreateTemp(l.dir, "motion_local_store_*.meta.temp")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+	if _, err := tmp.Write(sidecarJson); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.sidecarPath(id)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to move sidecar to store: %w", err)
+	}
+	return nil
+}
+
+// readSidecar reads the sidecar of the blob with the given ID. An empty sidecar
+// is returned if the blob has none.
+func (l *LocalStore) readSidecar(id ID) (*localSidecar, error) {
+	var sidecar localSidecar
+	sidecarJson, err := os.ReadFile(l.sidecarPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &sidecar, nil
+		}
+		return nil, fmt.Errorf("failed to read sidecar: %w", err)
+	}
+	if err := json.Unmarshal(sidecarJson, &sidecar); err != nil {
+		return nil, fmt.Errorf("failed to decode sidecar: %w", err)
+	}
+	return &sidecar, nil
+}
+
 // Get Retrieves the content of blob.
 // If no blob is found for the given id, ErrBlobNotFound is returned.
-func (l *LocalStore) Get(_ context.Context, id ID) (io.ReadSeekCloser, error) {
-	blob, err := os.Open(filepath.Join(l.dir, id.String()+".bin"))
+func (l *LocalStore) Get(ctx context.Context, id ID) (_ io.ReadSeekCloser, err error) {
+	_, span := tracer.Start(ctx, "LocalStore.Get", trace.WithAttributes(attribute.String("motion.blob.id", id.String())))
+	defer func() { tracing.End(span, err) }()
+	blob, err := os.Open(l.path(id))
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, ErrBlobNotFound
@@ -116,18 +219,27 @@ func (l *LocalStore) Get(_ context.Context, id ID) (io.ReadSeekCloser, error) {
 
 // Describe gets the description of the blob for the given id.
 // If no blob is found for the given id, ErrBlobNotFound is returned.
-func (l *LocalStore) Describe(ctx context.Context, id ID) (*Descriptor, error) {
-	stat, err := os.Stat(filepath.Join(l.dir, id.String()+".bin"))
+func (l *LocalStore) Describe(ctx context.Context, id ID) (_ *Descriptor, err error) {
+	_, span := tracer.Start(ctx, "LocalStore.Describe", trace.WithAttributes(attribute.String("motion.blob.id", id.String())))
+	defer func() { tracing.End(span, err) }()
+	stat, err := os.Stat(l.path(id))
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil, ErrBlobNotFound
 		}
 		return nil, err
 	}
+	sidecar, err := l.readSidecar(id)
+	if err != nil {
+		return nil, err
+	}
 	return &Descriptor{
 		ID:               id,
 		Size:             uint64(stat.Size()),
 		ModificationTime: stat.ModTime(),
+		Metadata:         sidecar.Metadata,
+		SHA256:           sidecar.SHA256,
+		RootCID:          sidecar.RootCID,
 	}, nil
 }
 
@@ -166,8 +278,20 @@ func (l *LocalStore) List(ctx context.Context) ([]ID, error) {
 	return ids, nil
 }
 
+// ListBlobs lists a page of locally stored blobs in ascending order of ID.
+// See ListOptions.
+func (l *LocalStore) ListBlobs(ctx context.Context, options ListOptions) (*ListResult, error) {
+	ids, err := l.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ListIDs(ctx, ids, options, l.Describe)
+}
+
 // Removes the blob. Errors with ErrBlobNotFound if the blob does not exist.
-func (l *LocalStore) Remove(ctx context.Context, id ID) error {
+func (l *LocalStore) Remove(ctx context.Context, id ID) (err error) {
+	_, span := tracer.Start(ctx, "LocalStore.Remove", trace.WithAttributes(attribute.String("motion.blob.id", id.String())))
+	defer func() { tracing.End(span, err) }()
 	binFileName := id.String() + ".bin"
 	if err := os.Remove(filepath.Join(l.dir, binFileName)); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -176,6 +300,28 @@ func (l *LocalStore) Remove(ctx context.Context, id ID) error {
 
 		return fmt.Errorf("failed to remove bin file '%s': %w", binFileName, err)
 	}
+	if err := os.Remove(l.sidecarPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove sidecar of bin file '%s': %w", binFileName, err)
+	}
 
 	return nil
 }
+
+// CheckHealth checks that the store directory is writable, and that the free
+// space on its disk is above the configured minimum free space.
+func (l *LocalStore) CheckHealth(_ context.Context) []HealthCheck {
+	writable := HealthCheck{Name: "local_store_writable"}
+	if probe, err := os.CreateTemp(l.dir, "motion_local_store_*.health.temp"); err != nil {
+		writable.Err = fmt.Errorf("cannot write to store directory: %w", err)
+	} else {
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+	freeSpace := HealthCheck{Name: "local_store_free_space"}
+	if usage, err := disk.Usage(l.dir); err != nil {
+		freeSpace.Err = fmt.Errorf("cannot get disk usage: %w", err)
+	} else if usage.Free <= l.minFreeSpace {
+		freeSpace.Err = fmt.Errorf("%d bytes free is at or below the minimum of %d bytes: %w", usage.Free, l.minFreeSpace, ErrNotEnoughSpace)
+	}
+	return []HealthCheck{writable, freeSpace}
+}
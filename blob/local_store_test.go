@@ -3,11 +3,18 @@ package blob_test
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/filecoin-project/motion/blob"
 	"github.com/gammazero/fsutil/disk"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 )
 
@@ -22,6 +29,74 @@ func TestWriteOK(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, desc)
 	require.Equal(t, uint64(len(buf)), desc.Size)
+	wantSHA256 := sha256.Sum256(buf)
+	require.Equal(t, wantSHA256[:], desc.SHA256)
+	wantRoot, err := blob.RootOf(bytes.NewReader(buf))
+	require.NoError(t, err)
+	require.Equal(t, wantRoot, desc.RootCID)
+
+	got, err := store.Describe(context.Background(), desc.ID)
+	require.NoError(t, err)
+	require.Equal(t, desc.SHA256, got.SHA256)
+	require.Equal(t, desc.RootCID, got.RootCID)
+}
+
+func TestWriteVerified(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	store := blob.NewLocalStore(tmpDir)
+	buf := []byte("This is a test")
+	goodSHA256 := sha256.Sum256(buf)
+	goodMD5 := md5.Sum(buf)
+	badSHA256 := sha256.Sum256([]byte("fish"))
+	badMD5 := md5.Sum([]byte("fish"))
+
+	tests := []struct {
+		name    string
+		options []blob.PutOption
+		wantErr error
+	}{
+		{
+			name:    "matching",
+			options: []blob.PutOption{blob.WithExpectedSize(uint64(len(buf))), blob.WithExpectedSHA256(goodSHA256[:]), blob.WithExpectedMD5(goodMD5[:])},
+		},
+		{
+			name:    "shorter than expected",
+			options: []blob.PutOption{blob.WithExpectedSize(uint64(len(buf) + 1))},
+			wantErr: blob.ErrSizeMismatch,
+		},
+		{
+			name:    "longer than expected",
+			options: []blob.PutOption{blob.WithExpectedSize(uint64(len(buf) - 1))},
+			wantErr: blob.ErrSizeMismatch,
+		},
+		{
+			name:    "mismatching SHA-256",
+			options: []blob.PutOption{blob.WithExpectedSHA256(badSHA256[:])},
+			wantErr: blob.ErrDigestMismatch,
+		},
+		{
+			name:    "mismatching MD5",
+			options: []blob.PutOption{blob.WithExpectedSHA256(goodSHA256[:]), blob.WithExpectedMD5(badMD5[:])},
+			wantErr: blob.ErrDigestMismatch,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			desc, err := store.Put(context.Background(), bytes.NewReader(buf), test.options...)
+			if test.wantErr != nil {
+				require.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, goodSHA256[:], desc.SHA256)
+		})
+	}
+
+	// Rejected content must not be stored.
+	ids, err := store.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
 }
 
 func TestInsufficientSpace(t *testing.T) {
@@ -49,3 +124,134 @@ func TestWriteTooLarge(t *testing.T) {
 	_, err = store.Put(context.Background(), readCloser)
 	require.ErrorIs(t, err, blob.ErrBlobTooLarge)
 }
+
+func TestRemove(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	store := blob.NewLocalStore(tmpDir)
+	desc, err := store.Put(context.Background(), bytes.NewReader([]byte("This is a test")))
+	require.NoError(t, err)
+
+	require.NoError(t, store.Remove(context.Background(), desc.ID))
+	_, err = store.Describe(context.Background(), desc.ID)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	require.ErrorIs(t, store.Remove(context.Background(), desc.ID), blob.ErrBlobNotFound)
+}
+
+func TestListBlobs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	store := blob.NewLocalStore(tmpDir)
+	var ids []string
+	for i := 1; i <= 5; i++ {
+		desc, err := store.Put(context.Background(), bytes.NewReader(make([]byte, i)))
+		require.NoError(t, err)
+		ids = append(ids, desc.ID.String())
+	}
+	sort.Strings(ids)
+
+	// Page through all blobs two at a time.
+	var listed []string
+	var options blob.ListOptions
+	options.Limit = 2
+	for {
+		result, err := store.ListBlobs(context.Background(), options)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(result.Descriptors), 2)
+		for _, desc := range result.Descriptors {
+			listed = append(listed, desc.ID.String())
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		options.Cursor = result.NextCursor
+	}
+	require.Equal(t, ids, listed)
+
+	// Filter by size.
+	result, err := store.ListBlobs(context.Background(), blob.ListOptions{MinSize: 2, MaxSize: 3})
+	require.NoError(t, err)
+	require.Len(t, result.Descriptors, 2)
+	require.Empty(t, result.NextCursor)
+
+	// Filter by creation time.
+	result, err = store.ListBlobs(context.Background(), blob.ListOptions{CreatedAfter: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.Empty(t, result.Descriptors)
+
+	_, err = store.ListBlobs(context.Background(), blob.ListOptions{Cursor: "fish"})
+	require.ErrorIs(t, err, blob.ErrInvalidCursor)
+}
+
+func TestWriteWithMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	store := blob.NewLocalStore(tmpDir)
+	metadata := blob.Metadata{
+		ContentType: "text/plain",
+		Filename:    "test.txt",
+		Tags:        map[string]string{"project": "motion"},
+	}
+	desc, err := store.Put(context.Background(), bytes.NewReader([]byte("This is a test")), blob.WithMetadata(metadata))
+	require.NoError(t, err)
+	require.Equal(t, metadata, desc.Metadata)
+
+	got, err := store.Describe(context.Background(), desc.ID)
+	require.NoError(t, err)
+	require.Equal(t, metadata, got.Metadata)
+
+	// Metadata sidecar must not be listed as a blob, and must be removed along with the blob.
+	ids, err := store.List(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{desc.ID}, ids)
+	require.NoError(t, store.Remove(context.Background(), desc.ID))
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestCheckHealth(t *testing.T) {
+	tmpDir := t.TempDir()
+	usage, err := disk.Usage(tmpDir)
+	require.NoError(t, err)
+
+	checks := blob.NewLocalStore(tmpDir).CheckHealth(context.Background())
+	require.Len(t, checks, 2)
+	for _, check := range checks {
+		require.NoError(t, check.Err, check.Name)
+	}
+
+	checks = blob.NewLocalStore(tmpDir, blob.WithMinFreeSpace(int64(usage.Free+blob.Gib))).CheckHealth(context.Background())
+	require.NoError(t, checks[0].Err)
+	require.ErrorIs(t, checks[1].Err, blob.ErrNotEnoughSpace)
+
+	checks = blob.NewLocalStore(filepath.Join(tmpDir, "missing")).CheckHealth(context.Background())
+	require.Error(t, checks[0].Err)
+
+	// Health probes must not leave any files behind.
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestCollector(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	registry := prometheus.NewRegistry()
+	for _, dir := range dirs {
+		require.NoError(t, registry.Register(blob.NewLocalStore(dir, blob.WithMinFreeSpace(1024)).Collector()))
+	}
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	minFree := make(map[string]float64)
+	for _, family := range families {
+		require.Len(t, family.GetMetric(), len(dirs), family.GetName())
+		if family.GetName() != "motion_local_store_min_free_bytes" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			minFree[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+		}
+	}
+	require.Equal(t, map[string]float64{dirs[0]: 1024, dirs[1]: 1024}, minFree)
+}
@@ -0,0 +1,63 @@
+package blob
+
+import (
+	"github.com/gammazero/fsutil/disk"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = (*localStoreCollector)(nil)
+
+// localStoreCollector reports the disk usage of a local store at collection
+// time, so that the reported free space is current even when no blobs are
+// being written. The directory of the store is set as a constant label, so
+// that the collectors of stores at different directories can be registered
+// together.
+type localStoreCollector struct {
+	store    *LocalStore
+	free     *prometheus.Desc
+	minFree  *prometheus.Desc
+	headroom *prometheus.Desc
+}
+
+// Collector returns a Prometheus collector that reports the disk usage of the
+// store directory, i.e. motion_local_store_free_bytes,
+// motion_local_store_min_free_bytes and motion_local_store_headroom_bytes. The
+// collector is not registered; it is up to the caller to register it, and
+// unregister it once the store is no longer in use.
+func (l *LocalStore) Collector() prometheus.Collector {
+	labels := prometheus.Labels{"dir": l.dir}
+	return &localStoreCollector{
+		store: l,
+		free: prometheus.NewDesc(
+			"motion_local_store_free_bytes",
+			"Free space on the disk of a local store directory.",
+			nil, labels),
+		minFree: prometheus.NewDesc(
+			"motion_local_store_min_free_bytes",
+			"Minimum free space that must remain on the disk of a local store directory after writing a blob.",
+			nil, labels),
+		headroom: prometheus.NewDesc(
+			"motion_local_store_headroom_bytes",
+			"Space that can be written to a local store directory before blobs are rejected, i.e. free space minus minimum free space.",
+			nil, labels),
+	}
+}
+
+func (c *localStoreCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.free
+	ch <- c.minFree
+	ch <- c.headroom
+}
+
+func (c *localStoreCollector) Collect(ch chan<- prometheus.Metric) {
+	usage, err := disk.Usage(c.store.dir)
+	if err != nil {
+		logger.Debugw("Failed to get disk usage of local store", "dir", c.store.dir, "err", err)
+		return
+	}
+	free := float64(usage.Free)
+	minFree := float64(c.store.minFreeSpace)
+	ch <- prometheus.MustNewConstMetric(c.free, prometheus.GaugeValue, free)
+	ch <- prometheus.MustNewConstMetric(c.minFree, prometheus.GaugeValue, minFree)
+	ch <- prometheus.MustNewConstMetric(c.headroom, prometheus.GaugeValue, free-minFree)
+}
@@ -0,0 +1,62 @@
+package blob
+
+type (
+	// PutOption configures a single call to Store.Put.
+	PutOption func(*PutOptions)
+	// PutOptions are the options of a single call to Store.Put. Store
+	// implementations obtain them via NewPutOptions.
+	PutOptions struct {
+		// Metadata is the client-supplied metadata to store along with the blob.
+		Metadata Metadata
+		// ExpectedSize, if non-nil, is the size in bytes that the blob content
+		// must have. Mismatching content must be rejected with ErrSizeMismatch.
+		ExpectedSize *uint64
+		// ExpectedSHA256, if non-nil, is the SHA-256 digest that the blob content
+		// must have. Mismatching content must be rejected with ErrDigestMismatch.
+		ExpectedSHA256 []byte
+		// ExpectedMD5, if non-nil, is the MD5 digest that the blob content must
+		// have. Mismatching content must be rejected with ErrDigestMismatch.
+		ExpectedMD5 []byte
+	}
+)
+
+// NewPutOptions instantiates PutOptions and applies the given options to it.
+func NewPutOptions(o ...PutOption) *PutOptions {
+	opts := &PutOptions{}
+	for _, apply := range o {
+		apply(opts)
+	}
+	return opts
+}
+
+// WithMetadata sets the client-supplied metadata to store along with the blob.
+// Defaults to no metadata.
+func WithMetadata(m Metadata) PutOption {
+	return func(o *PutOptions) {
+		o.Metadata = m
+	}
+}
+
+// WithExpectedSize sets the size in bytes that the blob content must have.
+// Defaults to no size check.
+func WithExpectedSize(size uint64) PutOption {
+	return func(o *PutOptions) {
+		o.ExpectedSize = &size
+	}
+}
+
+// WithExpectedSHA256 sets the SHA-256 digest that the blob content must have.
+// Defaults to no SHA-256 check.
+func WithExpectedSHA256(digest []byte) PutOption {
+	return func(o *PutOptions) {
+		o.ExpectedSHA256 = digest
+	}
+}
+
+// WithExpectedMD5 sets the MD5 digest that the blob content must have.
+// Defaults to no MD5 check.
+func WithExpectedMD5(digest []byte) PutOption {
+	return func(o *PutOptions) {
+		o.ExpectedMD5 = digest
+	}
+}
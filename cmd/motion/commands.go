@@ -0,0 +1,521 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/filecoin-project/motion/api"
+	"github.com/filecoin-project/motion/api/client"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/urfave/cli/v2"
+)
+
+// clientFlags are the flags common to the subcommands that interact with a
+// running Motion instance.
+var clientFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "apiURL",
+		Usage:   "The URL of the Motion HTTP API to interact with",
+		Value:   "http://localhost:40080",
+		EnvVars: []string{"MOTION_API_URL"},
+	},
+	&cli.StringFlag{
+		Name:    "apiToken",
+		Usage:   "The bearer token with which to authenticate to the Motion HTTP API",
+		EnvVars: []string{"MOTION_API_TOKEN"},
+	},
+	&cli.StringFlag{
+		Name:        "apiCAFile",
+		Usage:       "The path to the PEM encoded CA bundle against which to verify the Motion API certificate",
+		DefaultText: "system certificate authorities",
+		EnvVars:     []string{"MOTION_API_CA_FILE"},
+	},
+	&cli.StringFlag{
+		Name:    "apiCertFile",
+		Usage:   "The path to the PEM encoded client certificate with which to authenticate to the Motion API over mutual TLS",
+		EnvVars: []string{"MOTION_API_CERT_FILE"},
+	},
+	&cli.StringFlag{
+		Name:    "apiKeyFile",
+		Usage:   "The path to the PEM encoded private key of apiCertFile",
+		EnvVars: []string{"MOTION_API_KEY_FILE"},
+	},
+	&cli.BoolFlag{
+		Name:  "json",
+		Usage: "Whether to print output as JSON for scripting",
+	},
+}
+
+var clientCommands = []*cli.Command{
+	{
+		Name:      "put",
+		Usage:     "Store a file as a blob, reading from stdin if the file is '-'",
+		ArgsUsage: "<file>",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:        "contentType",
+				Usage:       "The media type of the blob",
+				DefaultText: "application/octet-stream",
+			},
+			&cli.StringFlag{
+				Name:        "filename",
+				Usage:       "The filename stored along with the blob",
+				DefaultText: "The base name of the file",
+			},
+			&cli.StringSliceFlag{
+				Name:  "tag",
+				Usage: "A tag stored along with the blob as key=value. Multiple tags may be specified.",
+			},
+			&cli.TimestampFlag{
+				Name:        "retainUntil",
+				Usage:       "The time until which the blob must be kept stored on Filecoin, in RFC 3339 format",
+				Layout:      time.RFC3339,
+				DefaultText: "Retained indefinitely",
+			},
+			&cli.StringFlag{
+				Name:        "policy",
+				Usage:       "The name of the storage policy with which to store the blob on Filecoin",
+				DefaultText: "default deal settings",
+			},
+		}, clientFlags...),
+		Action: putAction,
+	},
+	{
+		Name:      "get",
+		Usage:     "Retrieve the content of a blob",
+		ArgsUsage: "<id>",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "The file to which to write the blob content",
+				DefaultText: "stdout",
+			},
+			&cli.BoolFlag{
+				Name:  "car",
+				Usage: "Whether to retrieve the blob as a CAR file that contains the UnixFS DAG of its content",
+			},
+		}, clientFlags...),
+		Action: getAction,
+	},
+	{
+		Name:      "status",
+		Usage:     "Show the status of a blob",
+		ArgsUsage: "<id>",
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "watch",
+				Usage: "Whether to keep showing the status whenever it changes, until interrupted",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "How often to check the status when watching",
+				Value: 10 * time.Second,
+			},
+		}, clientFlags...),
+		Action: statusAction,
+	},
+	{
+		Name:  "ls",
+		Usage: "List stored blobs",
+		Flags: append([]cli.Flag{
+			&cli.IntFlag{
+				Name:        "limit",
+				Usage:       "The maximum number of blobs to list",
+				DefaultText: "All blobs are listed",
+			},
+			&cli.StringFlag{
+				Name:  "cursor",
+				Usage: "The cursor from which to list blobs, as printed by a previous listing",
+			},
+		}, clientFlags...),
+		Action: lsAction,
+	},
+	{
+		Name:      "rm",
+		Usage:     "Remove one or more blobs",
+		ArgsUsage: "<id>...",
+		Flags:     clientFlags,
+		Action:    rmAction,
+	},
+}
+
+func newClient(cctx *cli.Context) (*client.Client, error) {
+	var options []client.Option
+	if token := cctx.String("apiToken"); token != "" {
+		options = append(options, client.WithBearerToken(token))
+	}
+	tlsConfig, err := newClientTLSConfig(cctx)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		options = append(options, client.WithHttpClient(&http.Client{Transport: transport}))
+	}
+	return client.NewClient(cctx.String("apiURL"), options...)
+}
+
+// newClientTLSConfig returns the TLS configuration with which to connect to
+// the Motion API, or nil if the defaults are to be used.
+func newClientTLSConfig(cctx *cli.Context) (*tls.Config, error) {
+	caFile, certFile, keyFile := cctx.String("apiCAFile"), cctx.String("apiCertFile"), cctx.String("apiKeyFile")
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM encoded certificates found in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func blobIDArg(cctx *cli.Context, index int) (blob.ID, error) {
+	var id blob.ID
+	arg := cctx.Args().Get(index)
+	if arg == "" {
+		return id, errors.New("blob ID must be specified")
+	}
+	if err := id.Decode(arg); err != nil {
+		return id, fmt.Errorf("invalid blob ID %q: %w", arg, err)
+	}
+	return id, nil
+}
+
+func printJson(v any) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+func putAction(cctx *cli.Context) error {
+	path := cctx.Args().First()
+	if path == "" || cctx.NArg() > 1 {
+		return errors.New("exactly one file must be specified")
+	}
+	metadata := blob.Metadata{
+		ContentType: cctx.String("contentType"),
+		Filename:    cctx.String("filename"),
+	}
+	for _, tag := range cctx.StringSlice("tag") {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid tag %q: must be key=value", tag)
+		}
+		if metadata.Tags == nil {
+			metadata.Tags = make(map[string]string)
+		}
+		metadata.Tags[key] = value
+	}
+	metadata.RetainUntil = cctx.Timestamp("retainUntil")
+	metadata.Policy = cctx.String("policy")
+
+	var content io.Reader = os.Stdin
+	total := int64(-1)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if metadata.Filename == "" {
+			metadata.Filename = filepath.Base(path)
+		}
+		content, total = file, info.Size()
+	}
+	c, err := newClient(cctx)
+	if err != nil {
+		return err
+	}
+	var bar *progress
+	if !cctx.Bool("json") {
+		bar = newProgress("Uploading", total)
+	}
+	desc, err := c.Put(cctx.Context, bar.reader(content), blob.WithMetadata(metadata))
+	bar.done()
+	if err != nil {
+		return err
+	}
+	if cctx.Bool("json") {
+		return printJson(api.PostBlobResponse{ID: desc.ID.String(), SHA256: hex.EncodeToString(desc.SHA256)})
+	}
+	fmt.Println(desc.ID.String())
+	return nil
+}
+
+func getAction(cctx *cli.Context) error {
+	id, err := blobIDArg(cctx, 0)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cctx)
+	if err != nil {
+		return err
+	}
+	var content io.ReadCloser
+	var size int64
+	if cctx.Bool("car") {
+		// The size of the CAR is not known until it is fully retrieved.
+		if content, err = c.GetCAR(cctx.Context, id); err != nil {
+			return err
+		}
+		defer content.Close()
+	} else {
+		blobContent, err := c.Get(cctx.Context, id)
+		if err != nil {
+			return err
+		}
+		defer blobContent.Close()
+		if size, err = blobContent.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+		if _, err := blobContent.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		content = blobContent
+	}
+
+	output := cctx.String("output")
+	if output == "" || output == "-" {
+		_, err = io.Copy(os.Stdout, content)
+		return err
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	var bar *progress
+	if !cctx.Bool("json") {
+		bar = newProgress("Downloading", size)
+	}
+	_, err = io.Copy(bar.writer(file), content)
+	bar.done()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(output)
+		return err
+	}
+	return nil
+}
+
+func statusAction(cctx *cli.Context) error {
+	id, err := blobIDArg(cctx, 0)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cctx)
+	if err != nil {
+		return err
+	}
+	var last *api.GetStatusResponse
+	ticker := time.NewTicker(cctx.Duration("interval"))
+	defer ticker.Stop()
+	for {
+		status, err := c.Status(cctx.Context, id)
+		if err != nil {
+			if cctx.Context.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if !reflect.DeepEqual(status, last) {
+			if cctx.Bool("json") {
+				err = printJson(status)
+			} else {
+				if last != nil {
+					fmt.Println()
+				}
+				err = printStatus(status)
+			}
+			if err != nil {
+				return err
+			}
+			last = status
+		}
+		if !cctx.Bool("watch") {
+			return nil
+		}
+		select {
+		case <-cctx.Context.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func printStatus(status *api.GetStatusResponse) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "ID:\t%s\n", status.ID)
+	_, _ = fmt.Fprintf(w, "Size:\t%s (%d bytes)\n", formatBytes(int64(status.Size)), status.Size)
+	_, _ = fmt.Fprintf(w, "Created:\t%s\n", status.CreatedAt.Format(time.RFC3339))
+	if status.SHA256 != "" {
+		_, _ = fmt.Fprintf(w, "SHA-256:\t%s\n", status.SHA256)
+	}
+	if status.RootCID != "" {
+		_, _ = fmt.Fprintf(w, "Root CID:\t%s\n", status.RootCID)
+	}
+	if status.Metadata != nil {
+		if status.Metadata.ContentType != "" {
+			_, _ = fmt.Fprintf(w, "Content type:\t%s\n", status.Metadata.ContentType)
+		}
+		if status.Metadata.Filename != "" {
+			_, _ = fmt.Fprintf(w, "Filename:\t%s\n", status.Metadata.Filename)
+		}
+		for key, value := range status.Metadata.Tags {
+			_, _ = fmt.Fprintf(w, "Tag:\t%s=%s\n", key, value)
+		}
+		if status.Metadata.RetainUntil != nil {
+			_, _ = fmt.Fprintf(w, "Retain until:\t%s\n", status.Metadata.RetainUntil.Format(time.RFC3339))
+		}
+		if status.Metadata.Policy != "" {
+			_, _ = fmt.Fprintf(w, "Policy:\t%s\n", status.Metadata.Policy)
+		}
+	}
+	if status.Replication != nil {
+		safe := ""
+		if status.Replication.Safe {
+			safe = " (safe)"
+		}
+		_, _ = fmt.Fprintf(w, "Replication:\t%d of %d%s\n", status.Replication.Achieved, status.Replication.Target, safe)
+	}
+	if len(status.Packs) != 0 {
+		_, _ = fmt.Fprintf(w, "Packs:\n")
+		_, _ = fmt.Fprintf(w, "  JOB\tOFFSET\tLENGTH\tPIECE CID\tPAYLOAD CID\n")
+		for _, pack := range status.Packs {
+			_, _ = fmt.Fprintf(w, "  %d\t%d\t%d\t%s\t%s\n", pack.JobID, pack.Offset, pack.Length, valueOrDash(pack.PieceCID), valueOrDash(pack.PayloadCID))
+		}
+	}
+	if len(status.Repairs) != 0 {
+		_, _ = fmt.Fprintf(w, "Repairs:\n")
+		_, _ = fmt.Fprintf(w, "  TIME\tPIECE CID\tLOST\tREPLACEMENTS\tRESTORED\tERROR\n")
+		for _, repair := range status.Repairs {
+			_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%t\t%s\n", repair.Time.Format(time.RFC3339), repair.PieceCID, strings.Join(repair.Lost, ","), valueOrDash(strings.Join(repair.Replacements, ",")), repair.Restored, valueOrDash(repair.Error))
+		}
+	}
+	if len(status.Replicas) == 0 {
+		_, _ = fmt.Fprintf(w, "Replicas:\tnone\n")
+		return w.Flush()
+	}
+	_, _ = fmt.Fprintf(w, "Replicas:\n")
+	_, _ = fmt.Fprintf(w, "  PROVIDER\tDEAL ID\tPIECE CID\tSTATUS\tVERIFIED\tEXPIRATION\n")
+	for _, replica := range status.Replicas {
+		for _, piece := range replica.Pieces {
+			dealID := "-"
+			if piece.DealID != 0 {
+				dealID = strconv.FormatUint(piece.DealID, 10)
+			}
+			_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%t\t%s\n", replica.Provider, dealID, piece.PieceCID, piece.Status, piece.Verified, piece.Expiration.Format(time.RFC3339))
+		}
+	}
+	return w.Flush()
+}
+
+// valueOrDash returns the given value, or a dash if it is empty.
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func lsAction(cctx *cli.Context) error {
+	c, err := newClient(cctx)
+	if err != nil {
+		return err
+	}
+	limit := cctx.Int("limit")
+	options := blob.ListOptions{Cursor: cctx.String("cursor")}
+	response := api.ListBlobsResponse{Blobs: []api.Blob{}}
+	for {
+		if limit > 0 {
+			options.Limit = limit - len(response.Blobs)
+		}
+		result, err := c.ListBlobs(cctx.Context, options)
+		if err != nil {
+			return err
+		}
+		for _, desc := range result.Descriptors {
+			response.Blobs = append(response.Blobs, api.Blob{
+				ID:        desc.ID.String(),
+				Size:      desc.Size,
+				CreatedAt: desc.ModificationTime,
+			})
+		}
+		response.NextCursor = result.NextCursor
+		if result.NextCursor == "" || (limit > 0 && len(response.Blobs) >= limit) {
+			break
+		}
+		options.Cursor = result.NextCursor
+	}
+
+	if cctx.Bool("json") {
+		return printJson(response)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "ID\tSIZE\tCREATED\n")
+	for _, listed := range response.Blobs {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", listed.ID, formatBytes(int64(listed.Size)), listed.CreatedAt.Format(time.RFC3339))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if response.NextCursor != "" {
+		fmt.Fprintf(os.Stderr, "More blobs are available; list them with --cursor %s\n", response.NextCursor)
+	}
+	return nil
+}
+
+func rmAction(cctx *cli.Context) error {
+	if cctx.NArg() == 0 {
+		return errors.New("at least one blob ID must be specified")
+	}
+	c, err := newClient(cctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < cctx.NArg(); i++ {
+		id, err := blobIDArg(cctx, i)
+		if err != nil {
+			return err
+		}
+		if err := c.Remove(cctx.Context, id); err != nil {
+			return fmt.Errorf("failed to remove blob %s: %w", id.String(), err)
+		}
+		if !cctx.Bool("json") {
+			fmt.Println(id.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/motion/api"
+	"github.com/filecoin-project/motion/api/server"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// runClientCommand runs the client command with the given arguments against
+// the Motion API at the given URL, and returns what it printed to stdout.
+func runClientCommand(t *testing.T, apiURL string, args ...string) (string, error) {
+	t.Helper()
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	output := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		output <- string(out)
+	}()
+
+	app := &cli.App{Name: "motion", Commands: clientCommands}
+	args = append([]string{"motion", args[0], "--apiURL", apiURL}, args[1:]...)
+	err = app.Run(args)
+	require.NoError(t, w.Close())
+	return <-output, err
+}
+
+func newTestAPI(t *testing.T) string {
+	subject, err := server.NewHttpServer(blob.NewLocalStore(t.TempDir()), server.WithUploadDir(t.TempDir()))
+	require.NoError(t, err)
+	ts := httptest.NewServer(subject.ServeMux())
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestClientCommands(t *testing.T) {
+	apiURL := newTestAPI(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "fish.txt")
+	require.NoError(t, os.WriteFile(file, []byte("lobster"), 0640))
+
+	out, err := runClientCommand(t, apiURL, "put", "--json", "--tag", "kind=crustacean", file)
+	require.NoError(t, err)
+	var put api.PostBlobResponse
+	require.NoError(t, json.Unmarshal([]byte(out), &put))
+	require.NotEmpty(t, put.ID)
+
+	out, err = runClientCommand(t, apiURL, "put", file)
+	require.NoError(t, err)
+	other := strings.TrimSpace(out)
+	require.NotEqual(t, put.ID, other)
+
+	out, err = runClientCommand(t, apiURL, "status", "--json", put.ID)
+	require.NoError(t, err)
+	var status api.GetStatusResponse
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+	require.Equal(t, uint64(7), status.Size)
+	require.Equal(t, "fish.txt", status.Metadata.Filename)
+	require.Equal(t, map[string]string{"kind": "crustacean"}, status.Metadata.Tags)
+
+	output := filepath.Join(dir, "got.txt")
+	_, err = runClientCommand(t, apiURL, "get", "-o", output, put.ID)
+	require.NoError(t, err)
+	got, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, "lobster", string(got))
+
+	out, err = runClientCommand(t, apiURL, "get", put.ID)
+	require.NoError(t, err)
+	require.Equal(t, "lobster", out)
+
+	var listed api.ListBlobsResponse
+	out, err = runClientCommand(t, apiURL, "ls", "--json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &listed))
+	require.Len(t, listed.Blobs, 2)
+	require.Empty(t, listed.NextCursor)
+
+	out, err = runClientCommand(t, apiURL, "ls", "--json", "--limit", "1")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &listed))
+	require.Len(t, listed.Blobs, 1)
+	require.NotEmpty(t, listed.NextCursor)
+
+	out, err = runClientCommand(t, apiURL, "rm", put.ID, other)
+	require.NoError(t, err)
+	require.Equal(t, put.ID+"\n"+other+"\n", out)
+	_, err = runClientCommand(t, apiURL, "status", put.ID)
+	require.Error(t, err)
+}
+
+func TestClientCommandsRejectInvalidArguments(t *testing.T) {
+	apiURL := newTestAPI(t)
+	file := filepath.Join(t.TempDir(), "fish.txt")
+	require.NoError(t, os.WriteFile(file, []byte("lobster"), 0640))
+
+	for _, args := range [][]string{
+		{"put"},
+		{"put", file, file},
+		{"put", "--tag", "kind", file},
+		{"put", "--tag", "=crustacean", file},
+		{"get"},
+		{"get", "fish"},
+		{"status", "fish"},
+		{"rm"},
+	} {
+		_, err := runClientCommand(t, apiURL, args...)
+		require.Error(t, err, args)
+	}
+}
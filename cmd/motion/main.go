@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	stdbig "math/big"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
@@ -13,10 +18,16 @@ import (
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/builtin"
 	"github.com/filecoin-project/motion"
+	"github.com/filecoin-project/motion/api/s3"
+	"github.com/filecoin-project/motion/api/server"
 	"github.com/filecoin-project/motion/blob"
 	"github.com/filecoin-project/motion/integration/singularity"
+	"github.com/filecoin-project/motion/tracing"
+	"github.com/filecoin-project/motion/wallet"
+	"github.com/filecoin-project/motion/webhook"
 	"github.com/ipfs/go-log/v2"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/urfave/cli/v2"
 )
 
@@ -27,8 +38,9 @@ func main() {
 		_ = log.SetLogLevel("*", "INFO")
 	}
 	app := cli.App{
-		Name:  "motion",
-		Usage: "Propelling data onto Filecoin",
+		Name:     "motion",
+		Usage:    "Propelling data onto Filecoin",
+		Commands: clientCommands,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:        "storeDir",
@@ -72,10 +84,65 @@ func main() {
 				Category: "Lotus",
 				EnvVars:  []string{"LOTUS_TEST"},
 			},
+			&cli.StringFlag{
+				Name:     "lotus-api",
+				Category: "Lotus",
+				Usage:    "The Lotus JSON-RPC API endpoint via which to check the wallet funds and DataCap, e.g. https://api.node.glif.io/rpc/v1. Wallet checks are disabled if empty",
+				EnvVars:  []string{"LOTUS_API"},
+			},
+			&cli.StringFlag{
+				Name:     "lotus-token",
+				Category: "Lotus",
+				Usage:    "The token with which to authenticate to the Lotus API",
+				EnvVars:  []string{"LOTUS_TOKEN"},
+			},
+			&cli.DurationFlag{
+				Name:    "walletCheckInterval",
+				Usage:   "How often to check the wallet funds and DataCap",
+				Value:   10 * time.Minute,
+				EnvVars: []string{"MOTION_WALLET_CHECK_INTERVAL"},
+			},
+			&cli.Float64Flag{
+				Name:        "minWalletBalance",
+				Usage:       "The wallet balance in FIL below which deal schedules are paused",
+				DefaultText: "0, i.e. never paused",
+				EnvVars:     []string{"MOTION_MIN_WALLET_BALANCE"},
+			},
+			&cli.Float64Flag{
+				Name:        "minMarketEscrow",
+				Usage:       "The storage market escrow in FIL, not locked by deals, below which deal schedules are paused",
+				DefaultText: "0, i.e. never paused",
+				EnvVars:     []string{"MOTION_MIN_MARKET_ESCROW"},
+			},
+			&cli.Uint64Flag{
+				Name:        "minDataCap",
+				Usage:       "The DataCap in bytes below which verified deal schedules are paused",
+				DefaultText: "0, i.e. never paused",
+				EnvVars:     []string{"MOTION_MIN_DATACAP"},
+			},
 			&cli.UintFlag{
 				Name:        "replicationFactor",
-				Usage:       "The number of desired replicas per blob",
+				Usage:       "The number of storage providers, out of the ones configured, with which to make deals for each blob",
 				DefaultText: "Number of storage providers; see 'storageProvider' flag.",
+				EnvVars:     []string{"MOTION_REPLICATION_FACTOR"},
+			},
+			&cli.StringFlag{
+				Name:    "providerSelection",
+				Usage:   "The strategy with which to select storage providers for each piece when the replication factor is less than the number of storage providers: static, weighted, lowest-price or success-rate",
+				Value:   "static",
+				EnvVars: []string{"MOTION_PROVIDER_SELECTION"},
+			},
+			&cli.StringSliceFlag{
+				Name:        "providerDiversityLabel",
+				Usage:       "The provider catalogue labels, such as owner or region, that no two storage providers of a piece may share. Multiple labels may be specified.",
+				DefaultText: "no diversity constraints",
+				EnvVars:     []string{"MOTION_PROVIDER_DIVERSITY_LABELS"},
+			},
+			&cli.StringFlag{
+				Name:        "providerCatalogueFile",
+				Usage:       "The path to a JSON file describing storage providers by weight, ask price and labels, to select storage providers with. Providers listed but not configured are also candidates of policies that replicate selectively. The file is re-read on every selection.",
+				DefaultText: "no catalogue",
+				EnvVars:     []string{"MOTION_PROVIDER_CATALOGUE_FILE"},
 			},
 			&cli.Float64Flag{
 				Name:    "pricePerGiBEpoch",
@@ -106,6 +173,18 @@ func main() {
 				Value:       356 * 24 * time.Hour,
 				EnvVars:     []string{"MOTION_DEAL_DURATION"},
 			},
+			&cli.StringFlag{
+				Name:        "storagePolicyFile",
+				Usage:       "The path to a JSON file defining named storage policies with which clients may request blobs to be stored, via the X-Motion-Policy header",
+				DefaultText: "no storage policies",
+				EnvVars:     []string{"MOTION_STORAGE_POLICY_FILE"},
+			},
+			&cli.DurationFlag{
+				Name:    "renewalWindow",
+				Usage:   "How long before their expiration to renew deals for blobs that are still retained. Zero disables renewal.",
+				Value:   30 * 24 * time.Hour,
+				EnvVars: []string{"MOTION_RENEWAL_WINDOW"},
+			},
 			&cli.StringFlag{
 				Name:    "singularityMaxCarSize",
 				Usage:   "The maximum Singularity generated CAR size",
@@ -153,12 +232,163 @@ func main() {
 				Value:       1,
 				EnvVars:     []string{"MOTION_SINGULARITY_SCHEDULE_DEAL_NUMBER"},
 			},
+			&cli.StringFlag{
+				Name:        "uploadDir",
+				Usage:       "The path at which to stage resumable uploads until they are finalised",
+				DefaultText: "'uploads' directory under storeDir",
+				EnvVars:     []string{"MOTION_UPLOAD_DIR"},
+			},
+			&cli.DurationFlag{
+				Name:    "uploadSessionTTL",
+				Usage:   "The duration of inactivity after which resumable upload sessions are removed",
+				Value:   24 * time.Hour,
+				EnvVars: []string{"MOTION_UPLOAD_SESSION_TTL"},
+			},
+			&cli.StringFlag{
+				Name:        "authTokenFile",
+				Usage:       "The path to a file listing API bearer tokens, one per line, each followed by its space separated scopes",
+				DefaultText: "no static tokens",
+				EnvVars:     []string{"MOTION_AUTH_TOKEN_FILE"},
+			},
+			&cli.StringFlag{
+				Name:        "tlsCertFile",
+				Usage:       "The path to the PEM encoded certificate chain with which to serve the Motion API over TLS. Reloaded on change.",
+				DefaultText: "API is served over plain HTTP",
+				EnvVars:     []string{"MOTION_TLS_CERT_FILE"},
+			},
+			&cli.StringFlag{
+				Name:    "tlsKeyFile",
+				Usage:   "The path to the PEM encoded private key of tlsCertFile. Reloaded on change.",
+				EnvVars: []string{"MOTION_TLS_KEY_FILE"},
+			},
+			&cli.StringFlag{
+				Name:        "tlsClientCAFile",
+				Usage:       "The path to the PEM encoded CA bundle against which client certificates are verified, i.e. mutual TLS. Requires tlsCertFile.",
+				DefaultText: "client certificates are not required",
+				EnvVars:     []string{"MOTION_TLS_CLIENT_CA_FILE"},
+			},
+			&cli.StringFlag{
+				Name:        "s3ListenAddr",
+				Usage:       "The address on which to serve the S3 compatible gateway",
+				DefaultText: "S3 gateway is disabled",
+				EnvVars:     []string{"MOTION_S3_LISTEN_ADDR"},
+			},
+			&cli.StringFlag{
+				Name:        "s3Dir",
+				Usage:       "The path at which to store the S3 gateway key index and in-progress multipart uploads",
+				DefaultText: "'s3' directory under storeDir",
+				EnvVars:     []string{"MOTION_S3_DIR"},
+			},
+			&cli.StringFlag{
+				Name:    "s3Region",
+				Usage:   "The region against which S3 gateway request signatures are verified",
+				Value:   "us-east-1",
+				EnvVars: []string{"MOTION_S3_REGION"},
+			},
+			&cli.StringFlag{
+				Name:    "s3AccessKeyID",
+				Usage:   "The access key ID with which S3 gateway clients sign requests. Requests are served unauthenticated if unset.",
+				EnvVars: []string{"MOTION_S3_ACCESS_KEY_ID"},
+			},
+			&cli.StringFlag{
+				Name:    "s3SecretAccessKey",
+				Usage:   "The secret access key with which S3 gateway clients sign requests",
+				EnvVars: []string{"MOTION_S3_SECRET_ACCESS_KEY"},
+			},
+			&cli.StringFlag{
+				Name:        "metricsListenAddr",
+				Usage:       "The address on which to serve Prometheus metrics at /metrics",
+				DefaultText: "metrics endpoint is disabled",
+				EnvVars:     []string{"MOTION_METRICS_LISTEN_ADDR"},
+			},
+			&cli.StringFlag{
+				Name:    "tracingExporter",
+				Usage:   "The destination to which trace spans are exported: none, stdout or otlp",
+				Value:   string(tracing.ExporterNone),
+				EnvVars: []string{"MOTION_TRACING_EXPORTER"},
+			},
+			&cli.StringFlag{
+				Name:    "tracingOTLPEndpoint",
+				Usage:   "The OTLP/HTTP URL to which trace spans are sent when tracingExporter is otlp",
+				Value:   "http://localhost:4318/v1/traces",
+				EnvVars: []string{"MOTION_TRACING_OTLP_ENDPOINT"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "tracingOTLPHeader",
+				Usage:   "A header in the form key=value to send to tracingOTLPEndpoint. Multiple headers may be specified.",
+				EnvVars: []string{"MOTION_TRACING_OTLP_HEADERS"},
+			},
+			&cli.Float64Flag{
+				Name:    "tracingSampleRatio",
+				Usage:   "The ratio of traces to sample, between 0 and 1. Traces continued from a sampled client are always sampled.",
+				Value:   1,
+				EnvVars: []string{"MOTION_TRACING_SAMPLE_RATIO"},
+			},
+			&cli.StringFlag{
+				Name:    "tracingServiceName",
+				Usage:   "The service name with which exported trace spans are tagged",
+				Value:   "motion",
+				EnvVars: []string{"MOTION_TRACING_SERVICE_NAME"},
+			},
+			&cli.StringFlag{
+				Name:        "authJWTSecret",
+				Usage:       "The HMAC secret with which API bearer JWTs are signed",
+				DefaultText: "JWTs are not accepted",
+				EnvVars:     []string{"MOTION_AUTH_JWT_SECRET"},
+			},
+			&cli.StringFlag{
+				Name:        "webhookDir",
+				Usage:       "The path at which to store webhook subscriptions and pending deliveries",
+				DefaultText: "'webhooks' directory under storeDir",
+				EnvVars:     []string{"MOTION_WEBHOOK_DIR"},
+			},
+			&cli.StringSliceFlag{
+				Name:        "webhookURL",
+				Usage:       "URL to which all blob lifecycle events are delivered. Multiple URLs may be specified.",
+				DefaultText: "Only endpoints subscribed via the API are notified",
+				EnvVars:     []string{"MOTION_WEBHOOK_URLS"},
+			},
+			&cli.StringFlag{
+				Name:    "webhookSecret",
+				Usage:   "The secret with which events delivered to webhookURL endpoints are signed",
+				EnvVars: []string{"MOTION_WEBHOOK_SECRET"},
+			},
+			&cli.DurationFlag{
+				Name:    "webhookPollInterval",
+				Usage:   "How often to check blobs for lifecycle transitions to notify webhook endpoints of",
+				Value:   5 * time.Minute,
+				EnvVars: []string{"MOTION_WEBHOOK_POLL_INTERVAL"},
+			},
 			&cli.DurationFlag{
 				Name:    "experimentalSingularityCleanupInterval",
 				Usage:   "How often to check for and delete files from the local store that have already had deals made",
 				Value:   time.Hour,
 				EnvVars: []string{"MOTION_SINGULARITY_LOCAL_CLEANUP_INTERVAL"},
 			},
+			&cli.DurationFlag{
+				Name:    "experimentalSingularityRepairInterval",
+				Usage:   "How often to check for expired, slashed or failed deals and repair the replicas they held",
+				Value:   time.Hour,
+				EnvVars: []string{"MOTION_SINGULARITY_REPAIR_INTERVAL"},
+			},
+			&cli.IntFlag{
+				Name:    "experimentalSingularityMaxRepairs",
+				Usage:   "The maximum number of pieces to repair per repair interval",
+				Value:   10,
+				EnvVars: []string{"MOTION_SINGULARITY_MAX_REPAIRS"},
+			},
+			&cli.DurationFlag{
+				Name:    "experimentalSingularityRenewalInterval",
+				Usage:   "How often to check for deals to renew ahead of their expiration",
+				Value:   time.Hour,
+				EnvVars: []string{"MOTION_SINGULARITY_RENEWAL_INTERVAL"},
+			},
+			&cli.DurationFlag{
+				Name:    "shutdownTimeout",
+				Usage:   "How long to wait for in-flight work to finish when shutting down, after which Motion exits regardless",
+				Value:   30 * time.Second,
+				EnvVars: []string{"MOTION_SHUTDOWN_TIMEOUT"},
+			},
 		},
 		Action: func(cctx *cli.Context) error {
 			if cctx.Bool("lotus-test") {
@@ -167,8 +397,61 @@ func main() {
 			} else {
 				address.CurrentNetwork = address.Mainnet
 			}
+
+			tracingOptions := []tracing.Option{
+				tracing.WithExporter(tracing.Exporter(cctx.String("tracingExporter"))),
+				tracing.WithOTLPEndpoint(cctx.String("tracingOTLPEndpoint")),
+				tracing.WithSampleRatio(cctx.Float64("tracingSampleRatio")),
+				tracing.WithServiceName(cctx.String("tracingServiceName")),
+			}
+			if headers := cctx.StringSlice("tracingOTLPHeader"); len(headers) != 0 {
+				otlpHeaders := make(map[string]string, len(headers))
+				for _, header := range headers {
+					key, value, ok := strings.Cut(header, "=")
+					if !ok {
+						return fmt.Errorf("invalid tracing OTLP header %q: expected key=value", header)
+					}
+					otlpHeaders[strings.TrimSpace(key)] = strings.TrimSpace(value)
+				}
+				tracingOptions = append(tracingOptions, tracing.WithOTLPHeaders(otlpHeaders))
+			}
+			tracingProvider, err := tracing.NewProvider(tracingOptions...)
+			if err != nil {
+				logger.Errorw("Failed to instantiate tracing", "err", err)
+				return err
+			}
+			// shutdownContext bounds the time spent shutting down each component.
+			shutdownContext := func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), cctx.Duration("shutdownTimeout"))
+			}
+			defer func() {
+				ctx, cancel := shutdownContext()
+				defer cancel()
+				if err := tracingProvider.Shutdown(ctx); err != nil {
+					logger.Warnw("Failed to flush trace spans", "err", err)
+				}
+			}()
+
 			storeDir := cctx.String("storeDir")
+			webhookDir := cctx.String("webhookDir")
+			if webhookDir == "" {
+				webhookDir = filepath.Join(storeDir, "webhooks")
+			}
+			webhookOptions := []webhook.Option{
+				webhook.WithDir(webhookDir),
+				webhook.WithPollInterval(cctx.Duration("webhookPollInterval")),
+			}
+			for _, endpoint := range cctx.StringSlice("webhookURL") {
+				webhookOptions = append(webhookOptions, webhook.WithEndpoint(endpoint, cctx.String("webhookSecret")))
+			}
+			notifier, err := webhook.NewNotifier(webhookOptions...)
+			if err != nil {
+				logger.Errorw("Failed to instantiate webhook notifier", "err", err)
+				return err
+			}
+
 			var store blob.Store
+			var walletMonitor *wallet.Monitor
 			if cctx.Bool("experimentalSingularityStore") {
 				singularityAPIUrl := cctx.String("experimentalRemoteSingularityAPIUrl")
 				// Instantiate Singularity client depending on specified flags.
@@ -192,6 +475,27 @@ func main() {
 					}
 					spAddrs = append(spAddrs, spAddr)
 				}
+				var policies []singularity.Policy
+				if path := cctx.String("storagePolicyFile"); path != "" {
+					if policies, err = loadStoragePolicies(path); err != nil {
+						return err
+					}
+				}
+				selector, err := newProviderSelector(cctx.String("providerSelection"), cctx.StringSlice("providerDiversityLabel"))
+				if err != nil {
+					return err
+				}
+				if lotusAPI := cctx.String("lotus-api"); lotusAPI != "" {
+					walletMonitor, err = wallet.NewMonitor(
+						wallet.NewLotusClient(lotusAPI, cctx.String("lotus-token")),
+						wallet.WithMinBalance(filToTokenAmount(cctx.Float64("minWalletBalance"))),
+						wallet.WithMinMarketEscrow(filToTokenAmount(cctx.Float64("minMarketEscrow"))),
+						wallet.WithMinDataCap(abi.NewStoragePower(int64(cctx.Uint64("minDataCap")))),
+					)
+					if err != nil {
+						return err
+					}
+				}
 				singularityStore, err := singularity.NewStore(
 					singularity.WithStoreDir(cctx.String("storeDir")),
 					singularity.WithStorageProviders(spAddrs...),
@@ -201,6 +505,9 @@ func main() {
 					singularity.WithPricePerDeal(attoFilToTokenAmount(cctx.Float64("pricePerDeal"))),
 					singularity.WithDealStartDelay(durationToFilecoinEpoch(cctx.Duration("dealStartDelay"))),
 					singularity.WithDealDuration(durationToFilecoinEpoch(cctx.Duration("dealDuration"))),
+					singularity.WithPolicies(policies...),
+					singularity.WithProviderSelector(selector),
+					singularity.WithProviderCatalogue(cctx.String("providerCatalogueFile")),
 					singularity.WithSingularityClient(singClient),
 					singularity.WithWalletKey(cctx.String("walletKey")),
 					singularity.WithMaxCarSize(cctx.String("singularityMaxCarSize")),
@@ -211,7 +518,14 @@ func main() {
 					singularity.WithScheduleDealNumber(cctx.Int("experimentalSingularityScheduleDealNumber")),
 					singularity.WithVerifiedDeal(cctx.Bool("verifiedDeal")),
 					singularity.WithCleanupInterval(cctx.Duration("experimentalSingularityCleanupInterval")),
+					singularity.WithRepairInterval(cctx.Duration("experimentalSingularityRepairInterval")),
+					singularity.WithMaxRepairsPerInterval(cctx.Int("experimentalSingularityMaxRepairs")),
+					singularity.WithRenewalWindow(cctx.Duration("renewalWindow")),
+					singularity.WithRenewalInterval(cctx.Duration("experimentalSingularityRenewalInterval")),
 					singularity.WithMinFreeSpace(cctx.Int64("minFreeDiskSpace")),
+					singularity.WithWalletMonitor(walletMonitor),
+					singularity.WithWalletCheckInterval(cctx.Duration("walletCheckInterval")),
+					singularity.WithCleanupListener(notifier.LocalCleaned),
 				)
 				if err != nil {
 					logger.Errorw("Failed to instantiate singularity store", "err", err)
@@ -223,38 +537,100 @@ func main() {
 					return err
 				}
 				defer func() {
-					if err := singularityStore.Shutdown(context.Background()); err != nil {
+					ctx, cancel := shutdownContext()
+					defer cancel()
+					if err := singularityStore.Shutdown(ctx); err != nil {
 						logger.Errorw("Failed to shut down Singularity blob store", "err", err)
 					}
 				}()
 				store = singularityStore
 			} else {
-				store = blob.NewLocalStore(storeDir, blob.WithMinFreeSpace(cctx.Int64("minFreeDiskSpace")))
+				localStore := blob.NewLocalStore(storeDir, blob.WithMinFreeSpace(cctx.Int64("minFreeDiskSpace")))
+				if err := prometheus.Register(localStore.Collector()); err != nil {
+					logger.Warnw("Failed to register local store metrics", "err", err)
+				}
 				logger.Infow("Using local blob store", "storeDir", storeDir)
+				store = localStore
+			}
+
+			uploadDir := cctx.String("uploadDir")
+			if uploadDir == "" {
+				uploadDir = filepath.Join(storeDir, "uploads")
+			}
+			serverOptions := []server.Option{
+				server.WithUploadDir(uploadDir),
+				server.WithUploadSessionTTL(cctx.Duration("uploadSessionTTL")),
+				server.WithAuthTokenFile(cctx.String("authTokenFile")),
+			}
+			if secret := cctx.String("authJWTSecret"); secret != "" {
+				serverOptions = append(serverOptions, server.WithAuthJWTSecret([]byte(secret)))
+			}
+			if certFile, keyFile := cctx.String("tlsCertFile"), cctx.String("tlsKeyFile"); certFile != "" || keyFile != "" {
+				serverOptions = append(serverOptions, server.WithTLSCertificate(certFile, keyFile))
+			}
+			if caFile := cctx.String("tlsClientCAFile"); caFile != "" {
+				serverOptions = append(serverOptions, server.WithTLSClientCAFile(caFile))
+			}
+			if walletMonitor != nil {
+				serverOptions = append(serverOptions, server.WithWalletMonitor(walletMonitor))
 			}
 
-			m, err := motion.New(motion.WithBlobStore(store))
+			motionOptions := []motion.Option{
+				motion.WithBlobStore(store),
+				motion.WithServerOptions(serverOptions...),
+				motion.WithWebhookNotifier(notifier),
+			}
+			if s3ListenAddr := cctx.String("s3ListenAddr"); s3ListenAddr != "" {
+				s3Dir := cctx.String("s3Dir")
+				if s3Dir == "" {
+					s3Dir = filepath.Join(storeDir, "s3")
+				}
+				s3Options := []s3.Option{
+					s3.WithListenAddr(s3ListenAddr),
+					s3.WithDir(s3Dir),
+					s3.WithRegion(cctx.String("s3Region")),
+				}
+				if accessKeyID := cctx.String("s3AccessKeyID"); accessKeyID != "" {
+					secretAccessKey := cctx.String("s3SecretAccessKey")
+					if secretAccessKey == "" {
+						return errors.New("S3 secret access key must be set when access key ID is set")
+					}
+					s3Options = append(s3Options, s3.WithCredentials(accessKeyID, secretAccessKey))
+				}
+				motionOptions = append(motionOptions, motion.WithS3Gateway(s3Options...))
+			}
+			if metricsListenAddr := cctx.String("metricsListenAddr"); metricsListenAddr != "" {
+				motionOptions = append(motionOptions, motion.WithMetricsListenAddr(metricsListenAddr))
+			}
+
+			m, err := motion.New(motionOptions...)
 			if err != nil {
-				logger.Fatalw("Failed to instantiate Motion", "err", err)
+				logger.Errorw("Failed to instantiate Motion", "err", err)
+				return err
 			}
 			ctx := cctx.Context
 
 			if err := m.Start(ctx); err != nil {
-				logger.Fatalw("Failed to start Motion", "err", err)
+				logger.Errorw("Failed to start Motion", "err", err)
+				return err
 			}
-			c := make(chan os.Signal, 1)
-			signal.Notify(c, os.Interrupt)
-			<-c
+			<-ctx.Done()
 			logger.Info("Terminating...")
-			if err := m.Shutdown(ctx); err != nil {
+			shutdownCtx, cancel := shutdownContext()
+			defer cancel()
+			if err := m.Shutdown(shutdownCtx); err != nil {
 				logger.Warnw("Failure occurred while shutting down Motion.", "err", err)
 			}
 			logger.Info("Shut down Motion successfully.")
 			return nil
 		},
 	}
-	if err := app.Run(os.Args); err != nil {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err := app.RunContext(ctx, os.Args); err != nil {
 		logger.Error(err)
+		cancel()
+		os.Exit(1)
 	}
 }
 
@@ -265,3 +641,10 @@ func durationToFilecoinEpoch(d time.Duration) abi.ChainEpoch {
 func attoFilToTokenAmount(v float64) abi.TokenAmount {
 	return big.NewInt(int64(v * 1e18))
 }
+
+// filToTokenAmount converts the given amount of FIL to attoFIL, without
+// overflowing for amounts larger than a few FIL.
+func filToTokenAmount(v float64) abi.TokenAmount {
+	atto, _ := new(stdbig.Float).Mul(stdbig.NewFloat(v), stdbig.NewFloat(1e18)).Int(nil)
+	return big.NewFromGo(atto)
+}
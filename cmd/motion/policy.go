@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/motion/integration/singularity"
+)
+
+// storagePolicyConfig is the configuration of a storage policy, as read from
+// the storage policy file. Unset fields default to the corresponding flags.
+type storagePolicyConfig struct {
+	StorageProviders  []string `json:"storageProviders"`
+	ReplicationFactor uint     `json:"replicationFactor"`
+	DealDuration      string   `json:"dealDuration"`
+	VerifiedDeal      *bool    `json:"verifiedDeal"`
+	PricePerGiBEpoch  *float64 `json:"pricePerGiBEpoch"`
+	PricePerGiB       *float64 `json:"pricePerGiB"`
+	PricePerDeal      *float64 `json:"pricePerDeal"`
+}
+
+// loadStoragePolicies reads the storage policies from the JSON file at the
+// given path, which maps policy names to their configuration. The policies are
+// returned in ascending order of name.
+func loadStoragePolicies(path string) ([]singularity.Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage policy file: %w", err)
+	}
+	var configs map[string]storagePolicyConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to decode storage policy file: %w", err)
+	}
+	policies := make([]singularity.Policy, 0, len(configs))
+	for name, config := range configs {
+		policy := singularity.Policy{
+			Name:              name,
+			ReplicationFactor: config.ReplicationFactor,
+			VerifiedDeal:      config.VerifiedDeal,
+		}
+		for _, sp := range config.StorageProviders {
+			spAddr, err := address.NewFromString(sp)
+			if err != nil {
+				return nil, fmt.Errorf("storage provider '%s' of storage policy %s is not a valid address: %w", sp, name, err)
+			}
+			policy.StorageProviders = append(policy.StorageProviders, spAddr)
+		}
+		if config.DealDuration != "" {
+			duration, err := time.ParseDuration(config.DealDuration)
+			if err != nil {
+				return nil, fmt.Errorf("deal duration of storage policy %s is not a valid duration: %w", name, err)
+			}
+			policy.DealDuration = durationToFilecoinEpoch(duration)
+		}
+		if config.PricePerGiBEpoch != nil {
+			policy.PricePerGiBEpoch = attoFilToTokenAmount(*config.PricePerGiBEpoch)
+		}
+		if config.PricePerGiB != nil {
+			policy.PricePerGiB = attoFilToTokenAmount(*config.PricePerGiB)
+		}
+		if config.PricePerDeal != nil {
+			policy.PricePerDeal = attoFilToTokenAmount(*config.PricePerDeal)
+		}
+		policies = append(policies, policy)
+	}
+	slices.SortFunc(policies, func(a, b singularity.Policy) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return policies, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	progressBarWidth       = 30
+	progressRedrawInterval = 100 * time.Millisecond
+)
+
+var errNotSeekable = errors.New("not seekable")
+
+// progress renders the progress of a transfer on a single terminal line.
+type progress struct {
+	w        io.Writer
+	label    string
+	total    int64
+	n        int64
+	lastDraw time.Time
+}
+
+// newProgress instantiates a progress bar for a transfer of total bytes, or
+// unknown length if total is negative. Nil is returned if stderr is not a
+// terminal, in which case no progress is rendered.
+func newProgress(label string, total int64) *progress {
+	if !isatty.IsTerminal(os.Stderr.Fd()) && !isatty.IsCygwinTerminal(os.Stderr.Fd()) {
+		return nil
+	}
+	return &progress{w: os.Stderr, label: label, total: total}
+}
+
+func (p *progress) set(n int64) {
+	if p == nil {
+		return
+	}
+	p.n = n
+	if now := time.Now(); now.Sub(p.lastDraw) >= progressRedrawInterval {
+		p.lastDraw = now
+		p.draw()
+	}
+}
+
+func (p *progress) draw() {
+	if p.total <= 0 {
+		_, _ = fmt.Fprintf(p.w, "\r%s %s", p.label, formatBytes(p.n))
+		return
+	}
+	n := min(p.n, p.total)
+	done := int(n * progressBarWidth / p.total)
+	_, _ = fmt.Fprintf(p.w, "\r%s [%s%s] %3d%% %s / %s", p.label,
+		strings.Repeat("=", done), strings.Repeat(" ", progressBarWidth-done),
+		n*100/p.total, formatBytes(n), formatBytes(p.total))
+}
+
+// done renders the final state of the transfer and ends the line.
+func (p *progress) done() {
+	if p == nil {
+		return
+	}
+	p.draw()
+	_, _ = fmt.Fprintln(p.w)
+}
+
+// reader returns a reader that reports the bytes read from r. The returned
+// reader is seekable if r is an io.Seeker.
+func (p *progress) reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &progressReader{r: r, p: p}
+}
+
+// writer returns a writer that reports the bytes written to w.
+func (p *progress) writer(w io.Writer) io.Writer {
+	if p == nil {
+		return w
+	}
+	return &progressWriter{w: w, p: p}
+}
+
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.set(r.p.n + int64(n))
+	return n, err
+}
+
+// Seek seeks the underlying reader, if seekable, so that uploads of seekable
+// content can be retried.
+func (r *progressReader) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := r.r.(io.Seeker)
+	if !ok {
+		return 0, errNotSeekable
+	}
+	abs, err := seeker.Seek(offset, whence)
+	if err == nil && whence != io.SeekEnd {
+		r.p.set(abs)
+	}
+	return abs, err
+}
+
+type progressWriter struct {
+	w io.Writer
+	p *progress
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.p.set(w.p.n + int64(n))
+	return n, err
+}
+
+// formatBytes formats the given number of bytes in binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
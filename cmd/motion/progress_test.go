@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KiB",
+		1536:            "1.5 KiB",
+		5 << 20:         "5.0 MiB",
+		3<<30 + 512<<20: "3.5 GiB",
+		1 << 60:         "1.0 EiB",
+	} {
+		require.Equal(t, want, formatBytes(n), n)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{w: &out, label: "Uploading", total: 2048}
+	_, err := io.Copy(io.Discard, p.reader(strings.NewReader(strings.Repeat("a", 1024))))
+	require.NoError(t, err)
+	p.done()
+	require.Equal(t, "\rUploading ["+strings.Repeat("=", 15)+strings.Repeat(" ", 15)+"]  50% 1.0 KiB / 2.0 KiB\n", lastLine(out.String()))
+
+	// Transfers of unknown length report the bytes transferred so far.
+	out.Reset()
+	p = &progress{w: &out, label: "Downloading", total: -1}
+	_, err = p.writer(io.Discard).Write(make([]byte, 3<<10))
+	require.NoError(t, err)
+	p.done()
+	require.Equal(t, "\rDownloading 3.0 KiB\n", lastLine(out.String()))
+
+	// Progress beyond the total is capped.
+	out.Reset()
+	p = &progress{w: &out, label: "Uploading", total: 10}
+	p.n = 20
+	p.done()
+	require.Equal(t, "\rUploading ["+strings.Repeat("=", progressBarWidth)+"] 100% 10 B / 10 B\n", out.String())
+}
+
+func TestProgressReaderSeek(t *testing.T) {
+	p := &progress{w: io.Discard, label: "Uploading", total: 6}
+	r := p.reader(strings.NewReader("lobster")).(io.ReadSeeker)
+	_, err := io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), p.n)
+
+	// Seeking back, e.g. to retry an upload, rewinds the progress.
+	offset, err := r.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	require.Zero(t, offset)
+	require.Zero(t, p.n)
+
+	r = p.reader(io.MultiReader(strings.NewReader("fish"))).(io.ReadSeeker)
+	_, err = r.Seek(0, io.SeekStart)
+	require.ErrorIs(t, err, errNotSeekable)
+}
+
+func TestNilProgress(t *testing.T) {
+	var p *progress
+	r := strings.NewReader("fish")
+	require.Same(t, r, p.reader(r))
+	var w bytes.Buffer
+	require.Same(t, &w, p.writer(&w))
+	p.set(1)
+	p.done()
+}
+
+// lastLine returns the last line redrawn with a carriage return.
+func lastLine(s string) string {
+	return s[strings.LastIndex(s, "\r"):]
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/filecoin-project/motion/integration/singularity"
+)
+
+// newProviderSelector instantiates the storage provider selector with the
+// given strategy name, constrained to select providers that share none of the
+// given catalogue labels for each piece, if any.
+func newProviderSelector(strategy string, diversityLabels []string) (singularity.ProviderSelector, error) {
+	var selector singularity.ProviderSelector
+	switch strategy {
+	case "static":
+		selector = singularity.NewStaticSelector()
+	case "weighted":
+		selector = singularity.NewWeightedRoundRobinSelector()
+	case "lowest-price":
+		selector = singularity.NewLowestPriceSelector()
+	case "success-rate":
+		selector = singularity.NewSuccessRateSelector()
+	default:
+		return nil, fmt.Errorf("unknown provider selection strategy %q", strategy)
+	}
+	if len(diversityLabels) != 0 {
+		selector = singularity.NewDiversitySelector(selector, diversityLabels...)
+	}
+	return selector, nil
+}
@@ -24,11 +24,15 @@ import (
 )
 
 // TODO parameterize this.
+// Note that the chunks match the leaves of the UnixFS DAG of the blob, as built
+// by blob.WriteCAR, only as long as they are 1 MiB long.
 const storeChunkSize = 1 << 20 // 1 MiB
 
 var (
-	_ blob.Store        = (*Store)(nil)
-	_ io.ReadSeekCloser = (*storedBlobReader)(nil)
+	_ blob.Store         = (*Store)(nil)
+	_ blob.Lister        = (*Store)(nil)
+	_ blob.HealthChecker = (*Store)(nil)
+	_ io.ReadSeekCloser  = (*storedBlobReader)(nil)
 )
 
 type (
@@ -86,7 +90,14 @@ func (s *Store) Start(_ context.Context) error {
 	// TODO: change RIBS to take context.
 	return s.ribs.Start()
 }
-func (s *Store) Put(ctx context.Context, in io.Reader) (*blob.Descriptor, error) {
+
+// Put stores the given content in RIBS. The SHA-256 digest and root CID of the
+// content, along with any metadata specified via blob.WithMetadata, are stored
+// as part of the blob index. Content that does not meet the expected size or
+// digests is rejected.
+func (s *Store) Put(ctx context.Context, in io.Reader, o ...blob.PutOption) (*blob.Descriptor, error) {
+	opts := blob.NewPutOptions(o...)
+	digests := blob.NewDigestReader(in, opts)
 
 	// Generate ID early to fail early if generation fails.
 	id, err := uuid.NewRandom()
@@ -101,7 +112,9 @@ func (s *Store) Put(ctx context.Context, in io.Reader) (*blob.Descriptor, error)
 	//      for now this implementation remains highly experimental and optimised for velocity.
 	batch := s.ribs.Session(ctx).Batch(ctx)
 
-	splitter := chunk.NewSizeSplitter(in, storeChunkSize)
+	rootWriter := blob.NewRootWriter()
+	defer rootWriter.Close()
+	splitter := chunk.NewSizeSplitter(io.TeeReader(digests, rootWriter), storeChunkSize)
 
 	// TODO: Store the byte ranges for satisfying io.ReadSeaker in case chunk size is not constant across blocks?
 	var chunkCids []cid.Cid
@@ -136,11 +149,18 @@ SplitLoop:
 	if err := batch.Flush(ctx); err != nil {
 		return nil, err
 	}
+	root, err := rootWriter.Root()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute root CID: %w", err)
+	}
 	storedBlob := &storedBlob{
 		Descriptor: &blob.Descriptor{
 			ID:               blob.ID(id),
 			Size:             uint64(size),
 			ModificationTime: modtime,
+			Metadata:         opts.Metadata,
+			SHA256:           digests.SHA256(),
+			RootCID:          root,
 		},
 		Chunks: chunkCids,
 	}
@@ -176,6 +196,26 @@ func (s *Store) Describe(ctx context.Context, id blob.ID) (*blob.Descriptor, err
 	return storedBlob.Descriptor, err
 }
 
+// ListBlobs lists a page of blobs in the store index, in ascending order of ID.
+func (s *Store) ListBlobs(ctx context.Context, options blob.ListOptions) (*blob.ListResult, error) {
+	entries, err := os.ReadDir(s.indexDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RIBS index directory: %w", err)
+	}
+	ids := make([]blob.ID, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		var id blob.ID
+		if err := id.Decode(entry.Name()); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return blob.ListIDs(ctx, ids, options, s.Describe)
+}
+
 func (s *Store) describeStoredBlob(_ context.Context, id blob.ID) (*storedBlob, error) {
 	index, err := os.Open(filepath.Join(s.indexDir, id.String()))
 	if err != nil {
@@ -279,3 +319,27 @@ func (r *storedBlobReader) Seek(offset int64, whence int) (int64, error) {
 func (r *storedBlobReader) Close() error {
 	return nil
 }
+
+// CheckHealth reports the state of RIBS storage, deal making and wallet, along
+// with the accessibility of the blob index.
+func (s *Store) CheckHealth(_ context.Context) []blob.HealthCheck {
+	checks := []blob.HealthCheck{
+		{Name: "ribs_index"},
+		{Name: "ribs_storage"},
+		{Name: "ribs_deals"},
+		{Name: "ribs_wallet"},
+	}
+	if _, err := os.ReadDir(s.indexDir); err != nil {
+		checks[0].Err = fmt.Errorf("cannot read index directory: %w", err)
+	}
+	if _, err := s.ribs.StorageDiag().GetGroupStats(); err != nil {
+		checks[1].Err = fmt.Errorf("failed to get group stats: %w", err)
+	}
+	if _, err := s.ribs.DealDiag().DealSummary(); err != nil {
+		checks[2].Err = fmt.Errorf("failed to get deal summary: %w", err)
+	}
+	if _, err := s.ribs.Wallet().WalletInfo(); err != nil {
+		checks[3].Err = fmt.Errorf("failed to get wallet info: %w", err)
+	}
+	return checks
+}
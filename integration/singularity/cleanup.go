@@ -11,6 +11,8 @@ import (
 
 type cleanupSchedulerConfig struct {
 	interval time.Duration
+	// listener, if non-nil, is called with the ID of each removed blob.
+	listener func(blob.ID)
 }
 
 // This is run by the cleanup scheduler to determine whether to clean up a local
@@ -84,8 +86,9 @@ func (cs *cleanupScheduler) stop(ctx context.Context) error {
 	}
 }
 
-func (cs *cleanupScheduler) cleanup(ctx context.Context) error {
+func (cs *cleanupScheduler) cleanup(ctx context.Context) (err error) {
 	logger.Info("Starting cleanup")
+	defer func() { metricCleanupRuns.WithLabelValues(resultLabel(err)).Inc() }()
 
 	ids, err := cs.local.List(ctx)
 	if err != nil {
@@ -105,7 +108,14 @@ func (cs *cleanupScheduler) cleanup(ctx context.Context) error {
 	}
 
 	for _, blobID := range removals {
-		cs.local.Remove(ctx, blobID)
+		if err := cs.local.Remove(ctx, blobID); err != nil {
+			logger.Warnw("Failed to remove local file", "id", blobID, "err", err)
+			continue
+		}
+		metricCleanupRemovals.Inc()
+		if cs.cfg.listener != nil {
+			cs.cfg.listener(blobID)
+		}
 	}
 
 	if len(removals) > 0 {
@@ -0,0 +1,135 @@
+package singularity
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/motion/blob"
+)
+
+// maxEstimatedPieces caps the number of pieces for which costs are estimated,
+// since the pieces are assigned to providers one by one.
+const maxEstimatedPieces = 1 << 20
+
+var _ blob.Estimator = (*Store)(nil)
+
+// Estimate estimates the cost of storing data of the given size with the
+// storage policy of the given name, or the default one if empty.
+//
+// The data is assumed to be packed on its own into CARs of the maximum CAR
+// size, ignoring the CAR overhead, and each CAR to be padded to the piece size
+// Singularity derives from the maximum CAR size. Deals are priced per epoch as
+// Singularity prices them, given the same prices and duration as the deal
+// schedules of the policy, and cost their price per epoch over every epoch of
+// their duration. For policies that replicate selectively, pieces are
+// distributed across providers as they would be assigned given the current
+// assignments and the provider catalogue, but not the outcome of past deals.
+func (s *Store) Estimate(_ context.Context, size uint64, policyName string) (*blob.Estimate, error) {
+	p, ok := s.policy(policyName)
+	if !ok {
+		return nil, blob.ErrUnknownPolicy
+	}
+	// Singularity parses the maximum CAR size of preparations the same way.
+	maxCarSize, err := humanize.ParseBytes(s.maxCarSize)
+	if err != nil || maxCarSize == 0 {
+		return nil, fmt.Errorf("invalid max CAR size %q", s.maxCarSize)
+	}
+	pieces := size / maxCarSize
+	if size%maxCarSize != 0 {
+		pieces++
+	}
+	if pieces > maxEstimatedPieces {
+		return nil, fmt.Errorf("%w: estimates are limited to %d pieces", blob.ErrBlobTooLarge, maxEstimatedPieces)
+	}
+	pieceSize := nextPowerOfTwo(maxCarSize)
+	_, duration := s.scheduleDurations(p)
+	dealDuration, err := time.ParseDuration(duration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deal duration: %w", err)
+	}
+	epochs := int64(dealDuration / (builtin.EpochDurationSeconds * time.Second))
+	pricePerEpoch := s.dealPricePerEpoch(p, pieceSize, dealDuration)
+
+	var deals map[string]uint64
+	providers := p.storageProviders
+	if p.replicatesSelectively() {
+		catalogue, err := s.readProviderCatalogue()
+		if err != nil {
+			return nil, err
+		}
+		candidates := providerInfos(p, catalogue, nil)
+		deals = p.replicas.project(pieces, candidates, p.replicationFactor, s.providerSelector)
+		providers = make([]address.Address, 0, len(candidates))
+		for _, candidate := range candidates {
+			providers = append(providers, candidate.Address)
+		}
+	} else {
+		deals = make(map[string]uint64, len(p.storageProviders))
+		for _, sp := range p.storageProviders {
+			deals[sp.String()] = pieces
+		}
+	}
+
+	estimate := &blob.Estimate{
+		Size:          size,
+		Policy:        p.name,
+		MaxCARSize:    maxCarSize,
+		PieceSize:     pieceSize,
+		Pieces:        pieces,
+		PaddedSize:    pieces * pieceSize,
+		DealDuration:  dealDuration,
+		PricePerEpoch: pricePerEpoch,
+		PricePerDeal:  new(big.Int).Mul(pricePerEpoch, big.NewInt(epochs)),
+		TotalCost:     new(big.Int),
+		Providers:     make([]blob.ProviderEstimate, 0, len(providers)),
+	}
+	for _, sp := range providers {
+		provider := sp.String()
+		cost := new(big.Int).Mul(estimate.PricePerDeal, new(big.Int).SetUint64(deals[provider]))
+		estimate.Providers = append(estimate.Providers, blob.ProviderEstimate{
+			Provider: provider,
+			Deals:    deals[provider],
+			Cost:     cost,
+		})
+		estimate.Deals += deals[provider]
+		estimate.TotalCost.Add(estimate.TotalCost, cost)
+	}
+	return estimate, nil
+}
+
+// dealPricePerEpoch returns the price per epoch in attoFIL of a deal for a
+// piece of the given padded size and duration, as computed by Singularity from
+// the deal prices of the policy in FIL and proposed on-chain as the storage
+// price per epoch. See: DealConfig.GetPrice in
+// github.com/data-preservation-programs/singularity/replication.
+func (s *Store) dealPricePerEpoch(p *storagePolicy, pieceSize uint64, duration time.Duration) *big.Int {
+	pricePerGBEpoch, pricePerGB, pricePerDeal := s.schedulePrices(p)
+	gb := float64(pieceSize) / 1e9
+	epochs := duration.Minutes() * 2
+	price := new(big.Int)
+	for _, candidate := range []uint64{
+		uint64(pricePerGBEpoch * 1e18 * gb * epochs),
+		uint64(pricePerGB * 1e18 * gb),
+		uint64(pricePerDeal * 1e18),
+	} {
+		if c := new(big.Int).SetUint64(candidate); c.Cmp(price) > 0 {
+			price = c
+		}
+	}
+	return price
+}
+
+// nextPowerOfTwo returns the smallest power of two greater than or equal to x,
+// as Singularity pads CARs to form pieces.
+func nextPowerOfTwo(x uint64) uint64 {
+	if x <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(x-1)
+}
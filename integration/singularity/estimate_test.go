@@ -0,0 +1,78 @@
+package singularity
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimate(t *testing.T) {
+	s, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithStorageProviders(mustAddresses(t, "f01000", "f02000", "f03000")...),
+		WithPricePerDeal(abi.NewTokenAmount(2e18)),
+		WithPolicies(Policy{
+			Name:              "cheap",
+			ReplicationFactor: 1,
+			PricePerDeal:      abi.NewTokenAmount(0),
+			PricePerGiBEpoch:  abi.NewTokenAmount(1e9),
+		}),
+	)
+	require.NoError(t, err)
+	providers := s.policies[0].storageProviders
+	ctx := context.Background()
+
+	// 100 GiB is packed into 4 CARs of up to 31.5 GiB, padded to 32 GiB pieces,
+	// each stored by every provider.
+	got, err := s.Estimate(ctx, 100<<30, "")
+	require.NoError(t, err)
+	require.Equal(t, uint64(33822867456), got.MaxCARSize)
+	require.Equal(t, uint64(32<<30), got.PieceSize)
+	require.Equal(t, uint64(4), got.Pieces)
+	require.Equal(t, uint64(128<<30), got.PaddedSize)
+	require.Equal(t, time.Duration(builtin.EpochsInYear*builtin.EpochDurationSeconds)*time.Second, got.DealDuration)
+	require.Equal(t, uint64(12), got.Deals)
+	// The price per deal is proposed as the price per epoch, and paid over
+	// each of the 1,051,200 epochs of the deal.
+	epochs := big.NewInt(builtin.EpochsInYear)
+	require.Equal(t, big.NewInt(2e18), got.PricePerEpoch)
+	require.Equal(t, new(big.Int).Mul(big.NewInt(2e18), epochs), got.PricePerDeal)
+	require.Equal(t, new(big.Int).Mul(new(big.Int).Mul(big.NewInt(24), big.NewInt(1e18)), epochs), got.TotalCost)
+	require.Len(t, got.Providers, 3)
+	for i, provider := range got.Providers {
+		require.Equal(t, providers[i].String(), provider.Provider)
+		require.Equal(t, uint64(4), provider.Deals)
+		require.Equal(t, new(big.Int).Mul(new(big.Int).Mul(big.NewInt(8), big.NewInt(1e18)), epochs), provider.Cost)
+	}
+
+	// Pieces are spread across providers when replicating selectively, and
+	// priced per GB per epoch as by Singularity.
+	got, err = s.Estimate(ctx, 100<<30, "cheap")
+	require.NoError(t, err)
+	require.Equal(t, "cheap", got.Policy)
+	require.Equal(t, uint64(4), got.Deals)
+	require.Equal(t, []uint64{2, 1, 1}, []uint64{got.Providers[0].Deals, got.Providers[1].Deals, got.Providers[2].Deals})
+	// Singularity multiplies the price per GB per epoch by the epochs of the
+	// deal, i.e. 1e-9 FIL for 34.36 GB over 1,051,200 epochs, to get the price
+	// per epoch.
+	require.InDelta(t, 3.6118957e16, float64(got.PricePerEpoch.Int64()), 1e9)
+	require.Equal(t, new(big.Int).Mul(got.PricePerEpoch, epochs), got.PricePerDeal)
+	require.Equal(t, new(big.Int).Mul(got.PricePerDeal, big.NewInt(4)), got.TotalCost)
+
+	got, err = s.Estimate(ctx, 0, "")
+	require.NoError(t, err)
+	require.Zero(t, got.Pieces)
+	require.Zero(t, got.TotalCost.Sign())
+
+	_, err = s.Estimate(ctx, 1, "fish")
+	require.ErrorIs(t, err, blob.ErrUnknownPolicy)
+	_, err = s.Estimate(ctx, 1<<62, "")
+	require.ErrorIs(t, err, blob.ErrBlobTooLarge)
+}
@@ -0,0 +1,10 @@
+package singularity
+
+import "github.com/filecoin-project/motion/blob"
+
+// IndexedFileID returns the ID of the Singularity file to which the blob was
+// pushed, as recorded in the index of the store.
+func IndexedFileID(s *Store, id blob.ID) (int64, error) {
+	info, err := s.storedInfo(id)
+	return info.FileID, err
+}
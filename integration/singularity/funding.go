@@ -0,0 +1,188 @@
+package singularity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/data-preservation-programs/singularity/client/swagger/http/deal_schedule"
+	"github.com/data-preservation-programs/singularity/client/swagger/models"
+	"github.com/filecoin-project/go-address"
+)
+
+// scheduleHolds records the deal schedules paused by Motion because the wallet
+// ran low on funds or DataCap, so that only those are resumed once the wallet
+// is replenished, and not the ones paused by operators. The holds are
+// persisted in the index, so that they are released across restarts.
+type scheduleHolds struct {
+	mu sync.Mutex
+	// ids holds the IDs of the held schedules in decimal.
+	ids *persistedMap[bool]
+	// legacyPath is the path of the JSON file in which earlier versions of
+	// Motion persisted the holds.
+	legacyPath string
+}
+
+func newScheduleHolds(index *blobIndex, dir string) *scheduleHolds {
+	return &scheduleHolds{
+		ids:        newPersistedMap[bool](index, "held_schedules"),
+		legacyPath: filepath.Join(dir, "held_schedules.json"),
+	}
+}
+
+// read reads the persisted holds, if any.
+func (sh *scheduleHolds) read() error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	// Earlier versions of Motion persisted the holds as an array of IDs.
+	if err := sh.ids.load(sh.legacyPath, func(data []byte) (map[string]bool, error) {
+		var ids []int64
+		if err := json.Unmarshal(data, &ids); err != nil {
+			return nil, err
+		}
+		held := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			held[strconv.FormatInt(id, 10)] = true
+		}
+		return held, nil
+	}); err != nil {
+		return err
+	}
+	metricHeldSchedules.Set(float64(len(sh.ids.entries)))
+	return nil
+}
+
+// held reports whether the schedule is held.
+func (sh *scheduleHolds) held(id int64) bool {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	_, ok := sh.ids.get(strconv.FormatInt(id, 10))
+	return ok
+}
+
+// set records and persists whether the schedule is held.
+func (sh *scheduleHolds) set(id int64, held bool) error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	key := strconv.FormatInt(id, 10)
+	if _, ok := sh.ids.get(key); held == ok {
+		return nil
+	}
+	var err error
+	if held {
+		err = sh.ids.set(key, true)
+	} else {
+		err = sh.ids.delete(key)
+	}
+	metricHeldSchedules.Set(float64(len(sh.ids.entries)))
+	return err
+}
+
+func (s *Store) runWalletCheck() {
+	defer s.closed.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.closing
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.walletCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.checkWallet(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorw("Failed to check wallet", "err", err)
+		}
+	}
+}
+
+// checkWallet checks the funds and DataCap of the wallet, then holds or
+// releases deal schedules accordingly. Schedules are left as they are if the
+// wallet could not be checked.
+func (s *Store) checkWallet(ctx context.Context) error {
+	addr, err := address.NewFromString(s.walletAddress)
+	if err != nil {
+		return fmt.Errorf("invalid wallet address: %w", err)
+	}
+	if status := s.walletMonitor.Check(ctx, addr); status.Err != nil {
+		return status.Err
+	}
+	return s.holdDeals(ctx)
+}
+
+// dealsAllowed checks whether new deals may be made for the storage policy, as
+// of the last check of the wallet. Deals are allowed if the wallet is not
+// monitored or not checked yet.
+func (s *Store) dealsAllowed(p *storagePolicy) error {
+	if s.walletMonitor == nil {
+		return nil
+	}
+	status, ok := s.walletMonitor.Status()
+	if !ok {
+		return nil
+	}
+	return status.AllowsDeals(p.verifiedDeal)
+}
+
+// holdDeals pauses the active deal schedules of the storage policies for which
+// new deals are not allowed, and resumes the schedules it paused before for
+// which new deals are allowed again.
+func (s *Store) holdDeals(ctx context.Context) error {
+	if s.walletMonitor == nil {
+		return nil
+	}
+	var errs []error
+	for _, p := range s.policies {
+		schedules, err := s.listSchedules(ctx, p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		allowed := s.dealsAllowed(p)
+		for _, schedule := range schedules {
+			switch {
+			case allowed != nil && schedule.State == models.ModelScheduleStateActive:
+				errs = append(errs, s.holdSchedule(ctx, p, schedule.ID, allowed))
+			case allowed == nil && schedule.State == models.ModelScheduleStatePaused && s.holds.held(schedule.ID):
+				if _, err := s.singularityClient.DealSchedule.ResumeSchedule(&deal_schedule.ResumeScheduleParams{
+					Context: ctx,
+					ID:      schedule.ID,
+				}); err != nil {
+					errs = append(errs, fmt.Errorf("failed to resume schedule %d: %w", schedule.ID, err))
+					continue
+				}
+				logger.Infow("Resumed deal schedule, since the wallet was replenished", "preparation", p.preparationName, "id", schedule.ID, "provider", schedule.Provider)
+				errs = append(errs, s.holds.set(schedule.ID, false))
+			case schedule.State != models.ModelScheduleStatePaused && s.holds.held(schedule.ID):
+				// The schedule was resumed by others, completed or failed since
+				// it was held.
+				errs = append(errs, s.holds.set(schedule.ID, false))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// holdSchedule pauses the deal schedule of the storage policy for the given
+// reason, and records it as held.
+func (s *Store) holdSchedule(ctx context.Context, p *storagePolicy, id int64, reason error) error {
+	if _, err := s.singularityClient.DealSchedule.PauseSchedule(&deal_schedule.PauseScheduleParams{
+		Context: ctx,
+		ID:      id,
+	}); err != nil {
+		return fmt.Errorf("failed to pause schedule %d: %w", id, err)
+	}
+	logger.Warnw("Paused deal schedule", "preparation", p.preparationName, "id", id, "reason", reason)
+	return s.holds.set(id, true)
+}
@@ -0,0 +1,103 @@
+package singularity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/motion/wallet"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoldDeals(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		states = map[int]string{1: "active", 2: "paused"}
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case req.URL.Path == "/api/preparation/MOTION_PREPARATION/schedules":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"id": 1, "provider": "f01000", "state": states[1]},
+				{"id": 2, "provider": "f02000", "state": states[2]},
+			})
+		case req.Method == http.MethodPost:
+			var id int
+			var action string
+			if _, err := fmt.Sscanf(req.URL.Path, "/api/schedule/%d/%s", &id, &action); err != nil {
+				http.Error(w, "", http.StatusNotFound)
+				return
+			}
+			states[id] = map[string]string{"pause": "paused", "resume": "active"}[action]
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "state": states[id]})
+		default:
+			http.Error(w, "", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(testServer.Close)
+
+	addr, err := address.NewFromString("f01234")
+	require.NoError(t, err)
+	chain := wallet.NewFakeChainClient()
+	monitor, err := wallet.NewMonitor(chain, wallet.WithMinBalance(abi.NewTokenAmount(10)))
+	require.NoError(t, err)
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	storeDir := t.TempDir()
+	s, err := NewStore(
+		WithStoreDir(storeDir),
+		WithWalletKey("dummy"),
+		WithSingularityClient(singularityclient.NewHTTPClientWithConfig(nil, cfg)),
+		WithStorageProviders(mustAddresses(t, "f01000", "f02000")...),
+		WithWalletMonitor(monitor),
+	)
+	require.NoError(t, err)
+	s.walletAddress = addr.String()
+	ctx := context.Background()
+
+	// Deals are allowed until the wallet is checked.
+	require.NoError(t, s.dealsAllowed(s.policies[0]))
+
+	// Active schedules are held while funds are low.
+	require.NoError(t, s.checkWallet(ctx))
+	require.ErrorIs(t, s.dealsAllowed(s.policies[0]), wallet.ErrInsufficientFunds)
+	mu.Lock()
+	require.Equal(t, map[int]string{1: "paused", 2: "paused"}, states)
+	mu.Unlock()
+
+	// Holds are kept across restarts.
+	reloaded := newScheduleHolds(s.index, storeDir)
+	require.NoError(t, reloaded.read())
+	require.True(t, reloaded.held(1))
+	require.False(t, reloaded.held(2))
+
+	// Failed checks leave schedules as they are.
+	chain.SetBalance(addr, abi.NewTokenAmount(10))
+	chain.SetError(fmt.Errorf("fish"))
+	require.ErrorContains(t, s.checkWallet(ctx), "fish")
+	mu.Lock()
+	require.Equal(t, "paused", states[1])
+	mu.Unlock()
+
+	// Only the schedules held by Motion are resumed once replenished.
+	chain.SetError(nil)
+	require.NoError(t, s.checkWallet(ctx))
+	require.NoError(t, s.dealsAllowed(s.policies[0]))
+	mu.Lock()
+	require.Equal(t, map[int]string{1: "active", 2: "paused"}, states)
+	mu.Unlock()
+	require.False(t, s.holds.held(1))
+}
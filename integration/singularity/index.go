@@ -0,0 +1,497 @@
+package singularity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/motion/blob"
+	"github.com/ipfs/go-cid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// blobsBucket is the bucket of the index that maps blob IDs to blobInfo.
+	blobsBucket = []byte("blobs")
+	// piecesBucket is the bucket of the index that holds the blobs packed into
+	// each piece, keyed by the piece CID followed by the blob ID.
+	piecesBucket = []byte("pieces")
+	// unpackedBucket is the bucket of the index that holds the IDs of the
+	// blobs that are not known to be fully packed yet.
+	unpackedBucket = []byte("unpacked")
+)
+
+// blobState is the state of a blob in its lifecycle, as last recorded when the
+// blob was stored, its packs were checked or its local copy was checked for
+// cleanup.
+type blobState string
+
+const (
+	// blobStatePending is the state of blobs stored locally that are being
+	// pushed to Singularity, whose Singularity file is not known yet. Pending
+	// blobs are neither listed nor described, and are pushed again on startup
+	// if Motion stopped midway.
+	blobStatePending blobState = "pending"
+	// blobStateStored is the state of blobs pushed to Singularity that are not
+	// fully packed yet.
+	blobStateStored blobState = "stored"
+	// blobStatePacked is the state of blobs fully packed into CARs that are
+	// not replicated onto enough storage providers yet.
+	blobStatePacked blobState = "packed"
+	// blobStateReplicated is the state of blobs with as many active replicas
+	// as targeted by their storage policy.
+	blobStateReplicated blobState = "replicated"
+)
+
+// blobInfo is the information about a blob kept in the index, which outlives
+// the local copy of the blob. Metadata is embedded so that its fields are
+// encoded at the top level, as they were in the sidecars of ID files.
+type blobInfo struct {
+	blob.Metadata
+	SHA256  []byte  `json:"sha256,omitempty"`
+	RootCID cid.Cid `json:"rootCid"`
+	// FileID is the ID of the Singularity file to which the blob was pushed.
+	FileID int64 `json:"fileId"`
+	// Size is the size of the blob in bytes.
+	Size uint64 `json:"size"`
+	// CreatedAt is the time at which the blob was stored. Zero for blobs
+	// migrated from ID files without a local copy, until they are described.
+	CreatedAt time.Time `json:"createdAt"`
+	// UpdatedAt is the time at which the entry was last updated.
+	UpdatedAt time.Time `json:"updatedAt"`
+	State     blobState `json:"state,omitempty"`
+	// Packs are the packs of the blob content into CARs, recorded as the CARs
+	// are packed. All of them are recorded once the blob is in the packed
+	// state.
+	Packs []packInfo `json:"packs,omitempty"`
+	// RemovedAt is the time at which the blob was removed, if its entry is
+	// kept until it is fully packed, or while the pieces into which it is
+	// packed contain blobs that are not removed. See Store.Remove.
+	RemovedAt *time.Time `json:"removedAt,omitempty"`
+}
+
+// packInfo is a pack of a range of blob content into a CAR, as recorded in the
+// index. See blob.Pack.
+type packInfo struct {
+	JobID      int64  `json:"jobId"`
+	Offset     uint64 `json:"offset"`
+	Length     uint64 `json:"length"`
+	PieceCID   string `json:"pieceCid"`
+	PieceSize  uint64 `json:"pieceSize"`
+	PayloadCID string `json:"payloadCid"`
+	CARSize    uint64 `json:"carSize"`
+}
+
+// pieceCIDs returns the CIDs of the pieces into which the blob is packed, as
+// recorded in the index.
+func (info blobInfo) pieceCIDs() []string {
+	var pieceCIDs []string
+	for _, pack := range info.Packs {
+		if !slices.Contains(pieceCIDs, pack.PieceCID) {
+			pieceCIDs = append(pieceCIDs, pack.PieceCID)
+		}
+	}
+	return pieceCIDs
+}
+
+// packed reports whether the blob is fully packed, as recorded in the index.
+func (info blobInfo) packed() bool {
+	return info.State == blobStatePacked || info.State == blobStateReplicated
+}
+
+// blobIndex is the index of the blobs stored via Motion, backed by an embedded
+// bbolt database, so that every update is atomic and survives crashes, and
+// blobs can be listed without reading the store directory. Blobs are also
+// indexed by the pieces into which they are packed, once their packs are
+// recorded.
+type blobIndex struct {
+	db *bolt.DB
+}
+
+// openBlobIndex opens the index at the given path, creating it if it does not
+// exist. Opening fails if the index is open by another process.
+func openBlobIndex(path string) (*blobIndex, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob index: %w", err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		blobs, err := tx.CreateBucketIfNotExists(blobsBucket)
+		if err != nil {
+			return err
+		}
+		if tx.Bucket(unpackedBucket) != nil {
+			return nil
+		}
+		// The blobs indexed before packs were recorded are not known to be
+		// packed.
+		if _, err := tx.CreateBucket(piecesBucket); err != nil {
+			return err
+		}
+		unpacked, err := tx.CreateBucket(unpackedBucket)
+		if err != nil {
+			return err
+		}
+		return blobs.ForEach(func(k, _ []byte) error {
+			return unpacked.Put(k, []byte{})
+		})
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to initialize blob index: %w", err)
+	}
+	return &blobIndex{db: db}, nil
+}
+
+func (bi *blobIndex) close() error {
+	return bi.db.Close()
+}
+
+// put inserts or replaces the information about the blob.
+func (bi *blobIndex) put(id blob.ID, info blobInfo) error {
+	return bi.db.Update(func(tx *bolt.Tx) error {
+		return putBlobInfo(tx, id, info)
+	})
+}
+
+// get gets the information about the blob. Returns blob.ErrBlobNotFound if the
+// blob is not indexed.
+func (bi *blobIndex) get(id blob.ID) (blobInfo, error) {
+	var info blobInfo
+	err := bi.db.View(func(tx *bolt.Tx) error {
+		var err error
+		info, err = getBlobInfo(tx, id)
+		return err
+	})
+	return info, err
+}
+
+// update atomically updates the information about the blob with the given
+// function. Returns blob.ErrBlobNotFound if the blob is not indexed.
+func (bi *blobIndex) update(id blob.ID, f func(*blobInfo)) error {
+	return bi.db.Update(func(tx *bolt.Tx) error {
+		info, err := getBlobInfo(tx, id)
+		if err != nil {
+			return err
+		}
+		f(&info)
+		return putBlobInfo(tx, id, info)
+	})
+}
+
+// list lists the IDs of all indexed blobs in ascending order.
+func (bi *blobIndex) list() ([]blob.ID, error) {
+	var ids []blob.ID
+	err := bi.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(blobsBucket).ForEach(func(k, _ []byte) error {
+			id, err := blobIDOf(k)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+			return nil
+		})
+	})
+	return ids, err
+}
+
+// scan calls f with each indexed blob after the given ID, or from the first one
+// if nil, in ascending order of ID until f returns false, all within a single
+// read transaction. Blob IDs sort the same as bytes and as strings.
+func (bi *blobIndex) scan(after *blob.ID, f func(blob.ID, blobInfo) (bool, error)) error {
+	return bi.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(blobsBucket).Cursor()
+		k, v := c.First()
+		if after != nil {
+			if k, v = c.Seek(after[:]); bytes.Equal(k, after[:]) {
+				k, v = c.Next()
+			}
+		}
+		for ; k != nil; k, v = c.Next() {
+			id, err := blobIDOf(k)
+			if err != nil {
+				return err
+			}
+			var info blobInfo
+			if err := json.Unmarshal(v, &info); err != nil {
+				return fmt.Errorf("could not decode index entry of blob '%s': %w", id.String(), err)
+			}
+			if next, err := f(id, info); err != nil || !next {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// pending lists the IDs of the indexed blobs in the pending state, in
+// ascending order.
+func (bi *blobIndex) pending() ([]blob.ID, error) {
+	var ids []blob.ID
+	err := bi.scan(nil, func(id blob.ID, info blobInfo) (bool, error) {
+		if info.State == blobStatePending {
+			ids = append(ids, id)
+		}
+		return true, nil
+	})
+	return ids, err
+}
+
+// unpacked lists the IDs of the indexed blobs that are not known to be fully
+// packed yet, in ascending order.
+func (bi *blobIndex) unpacked() ([]blob.ID, error) {
+	var ids []blob.ID
+	err := bi.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(unpackedBucket).ForEach(func(k, _ []byte) error {
+			id, err := blobIDOf(k)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+			return nil
+		})
+	})
+	return ids, err
+}
+
+// inPiece lists the IDs of the indexed blobs packed into the piece with the
+// given CID, in ascending order.
+func (bi *blobIndex) inPiece(pieceCID string) ([]blob.ID, error) {
+	var ids []blob.ID
+	prefix := pieceKey(pieceCID, blob.ID{})[:len(pieceCID)+1]
+	err := bi.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(piecesBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			id, err := blobIDOf(k[len(prefix):])
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// remove removes the blob from the index. Returns blob.ErrBlobNotFound if the
+// blob is not indexed.
+func (bi *blobIndex) remove(id blob.ID) error {
+	return bi.db.Update(func(tx *bolt.Tx) error {
+		info, err := getBlobInfo(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := unindexPacks(tx, id, info); err != nil {
+			return err
+		}
+		return tx.Bucket(blobsBucket).Delete(id[:])
+	})
+}
+
+func getBlobInfo(tx *bolt.Tx, id blob.ID) (blobInfo, error) {
+	var info blobInfo
+	value := tx.Bucket(blobsBucket).Get(id[:])
+	if value == nil {
+		return info, blob.ErrBlobNotFound
+	}
+	if err := json.Unmarshal(value, &info); err != nil {
+		return info, fmt.Errorf("could not decode index entry of blob '%s': %w", id.String(), err)
+	}
+	return info, nil
+}
+
+// putBlobInfo inserts or replaces the information about the blob, and indexes
+// the blob by the pieces into which it is packed, as far as known. Blobs that
+// are stored but not packed yet, or whose packs were never recorded, are
+// indexed as unpacked.
+func putBlobInfo(tx *bolt.Tx, id blob.ID, info blobInfo) error {
+	switch previous, err := getBlobInfo(tx, id); {
+	case err == nil:
+		if err := unindexPacks(tx, id, previous); err != nil {
+			return err
+		}
+	case !errors.Is(err, blob.ErrBlobNotFound):
+		return err
+	}
+	info.UpdatedAt = time.Now()
+	value, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to encode index entry: %w", err)
+	}
+	if err := tx.Bucket(blobsBucket).Put(id[:], value); err != nil {
+		return err
+	}
+	if info.State != blobStatePending && (info.State == blobStateStored || len(info.Packs) == 0) {
+		if err := tx.Bucket(unpackedBucket).Put(id[:], []byte{}); err != nil {
+			return err
+		}
+	}
+	for _, pieceCID := range info.pieceCIDs() {
+		if err := tx.Bucket(piecesBucket).Put(pieceKey(pieceCID, id), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexPacks removes the blob from the pieces into which it is packed, as
+// recorded in the given information about it.
+func unindexPacks(tx *bolt.Tx, id blob.ID, info blobInfo) error {
+	if err := tx.Bucket(unpackedBucket).Delete(id[:]); err != nil {
+		return err
+	}
+	for _, pieceCID := range info.pieceCIDs() {
+		if err := tx.Bucket(piecesBucket).Delete(pieceKey(pieceCID, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pieceKey returns the key of the blob in the pieces bucket, i.e. the piece
+// CID and the blob ID separated by a zero byte, so that the blobs of a piece
+// are contiguous.
+func pieceKey(pieceCID string, id blob.ID) []byte {
+	key := make([]byte, 0, len(pieceCID)+1+len(id))
+	key = append(key, pieceCID...)
+	key = append(key, 0)
+	return append(key, id[:]...)
+}
+
+// blobIDOf decodes the blob ID from the given index key, i.e. its 16 bytes.
+func blobIDOf(key []byte) (blob.ID, error) {
+	var id blob.ID
+	if len(key) != len(id) {
+		return id, fmt.Errorf("invalid blob ID in index: %x", key)
+	}
+	copy(id[:], key)
+	return id, nil
+}
+
+// migrateIDFiles moves the blobs recorded by earlier versions of Motion as one
+// "<blob ID>.id" file per blob, holding the Singularity file ID, and a JSON
+// sidecar into the index. The size and creation time of the blobs are taken
+// from their local copy, if any. The files are removed once indexed, so that
+// the migration is resumed if interrupted, and entries that are already
+// indexed are kept as they are.
+func (s *Store) migrateIDFiles() error {
+	entries, err := os.ReadDir(s.storeDir)
+	if err != nil {
+		return fmt.Errorf("failed to read store directory: %w", err)
+	}
+	var paths []string
+	infos := make(map[blob.ID]blobInfo)
+	for _, entry := range entries {
+		idString, isID := strings.CutSuffix(entry.Name(), ".id")
+		if !isID || entry.IsDir() {
+			continue
+		}
+		var id blob.ID
+		if err := id.Decode(idString); err != nil {
+			continue
+		}
+		path := filepath.Join(s.storeDir, entry.Name())
+		fileIDString, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read ID file: %w", err)
+		}
+		fileID, err := strconv.ParseUint(string(fileIDString), 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not parse Singularity file ID '%s' of ID file for blob '%s': %w", fileIDString, idString, err)
+		}
+		var info blobInfo
+		switch sidecar, err := os.ReadFile(path + ".meta"); {
+		case err == nil:
+			if err := json.Unmarshal(sidecar, &info); err != nil {
+				return fmt.Errorf("could not decode metadata file of blob '%s': %w", idString, err)
+			}
+		case !errors.Is(err, os.ErrNotExist):
+			return fmt.Errorf("could not read metadata file: %w", err)
+		}
+		info.FileID = int64(fileID)
+		info.State = blobStateStored
+		if stat, err := os.Stat(s.localPath(id)); err == nil {
+			info.Size = uint64(stat.Size())
+			info.CreatedAt = stat.ModTime()
+		}
+		infos[id] = info
+		paths = append(paths, path, path+".meta")
+	}
+	if len(infos) == 0 {
+		return nil
+	}
+
+	if err := s.index.db.Update(func(tx *bolt.Tx) error {
+		for id, info := range infos {
+			if _, err := getBlobInfo(tx, id); !errors.Is(err, blob.ErrBlobNotFound) {
+				continue
+			}
+			if err := putBlobInfo(tx, id, info); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to migrate ID files to blob index: %w", err)
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove migrated ID file: %w", err)
+		}
+	}
+	logger.Infow("Migrated blob ID files to index", "count", len(infos))
+	return nil
+}
+
+// checkIndex checks that the index is structurally sound and that all its
+// entries decode. Local copies of blobs that are not indexed, i.e. left over
+// from blobs whose storage failed midway, are reported but kept.
+func (s *Store) checkIndex(ctx context.Context) error {
+	var indexed int
+	if err := s.index.db.View(func(tx *bolt.Tx) error {
+		var errs []error
+		for err := range tx.Check() {
+			errs = append(errs, err)
+		}
+		if len(errs) != 0 {
+			return fmt.Errorf("blob index is corrupt: %w", errors.Join(errs...))
+		}
+		return tx.Bucket(blobsBucket).ForEach(func(k, v []byte) error {
+			id, err := blobIDOf(k)
+			if err != nil {
+				return err
+			}
+			var info blobInfo
+			if err := json.Unmarshal(v, &info); err != nil {
+				return fmt.Errorf("could not decode index entry of blob '%s': %w", id.String(), err)
+			}
+			indexed++
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+
+	local, err := s.local.List(ctx)
+	if err != nil {
+		return err
+	}
+	var unindexed []string
+	for _, id := range local {
+		if _, err := s.index.get(id); errors.Is(err, blob.ErrBlobNotFound) {
+			unindexed = append(unindexed, id.String())
+		}
+	}
+	if len(unindexed) != 0 {
+		logger.Warnw("Found local copies of blobs that are not indexed", "count", len(unindexed), "ids", unindexed)
+	}
+	logger.Infow("Checked blob index", "blobs", indexed)
+	return nil
+}
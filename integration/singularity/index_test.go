@@ -0,0 +1,233 @@
+package singularity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBlobIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+	subject, err := openBlobIndex(path)
+	require.NoError(t, err)
+	id, err := blob.NewID()
+	require.NoError(t, err)
+
+	_, err = subject.get(*id)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	require.ErrorIs(t, subject.update(*id, func(*blobInfo) {}), blob.ErrBlobNotFound)
+	require.ErrorIs(t, subject.remove(*id), blob.ErrBlobNotFound)
+
+	require.NoError(t, subject.put(*id, blobInfo{FileID: 42, Size: 7, Metadata: blob.Metadata{Filename: "fish.txt"}}))
+	require.NoError(t, subject.update(*id, func(info *blobInfo) { info.State = blobStatePacked }))
+
+	// Entries are kept across restarts.
+	require.NoError(t, subject.close())
+	subject, err = openBlobIndex(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { subject.close() })
+	info, err := subject.get(*id)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), info.FileID)
+	require.Equal(t, uint64(7), info.Size)
+	require.Equal(t, "fish.txt", info.Filename)
+	require.Equal(t, blobStatePacked, info.State)
+	require.False(t, info.UpdatedAt.IsZero())
+	ids, err := subject.list()
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{*id}, ids)
+
+	unpacked, err := subject.unpacked()
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{*id}, unpacked)
+
+	// Blobs are indexed by piece once their packs are recorded.
+	require.NoError(t, subject.update(*id, func(info *blobInfo) {
+		info.Packs = []packInfo{{JobID: 1, PieceCID: "baga1"}, {JobID: 2, PieceCID: "baga2"}}
+	}))
+	other, err := blob.NewID()
+	require.NoError(t, err)
+	require.NoError(t, subject.put(*other, blobInfo{State: blobStatePacked, Packs: []packInfo{{PieceCID: "baga1"}}}))
+	inPiece, err := subject.inPiece("baga1")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []blob.ID{*id, *other}, inPiece)
+	inPiece, err = subject.inPiece("baga")
+	require.NoError(t, err)
+	require.Empty(t, inPiece)
+	unpacked, err = subject.unpacked()
+	require.NoError(t, err)
+	require.Empty(t, unpacked)
+
+	// Blobs are scanned in ascending order of ID, after the given one if any.
+	scanned := func(after *blob.ID) []blob.ID {
+		var ids []blob.ID
+		require.NoError(t, subject.scan(after, func(id blob.ID, _ blobInfo) (bool, error) {
+			ids = append(ids, id)
+			return true, nil
+		}))
+		return ids
+	}
+	first, second := *id, *other
+	if second.String() < first.String() {
+		first, second = second, first
+	}
+	require.Equal(t, []blob.ID{first, second}, scanned(nil))
+	require.Equal(t, []blob.ID{second}, scanned(&first))
+	require.Empty(t, scanned(&second))
+
+	// Pending blobs are not packed yet, nor known to be unpacked.
+	pending, err := blob.NewID()
+	require.NoError(t, err)
+	require.NoError(t, subject.put(*pending, blobInfo{State: blobStatePending}))
+	pendingIDs, err := subject.pending()
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{*pending}, pendingIDs)
+	unpacked, err = subject.unpacked()
+	require.NoError(t, err)
+	require.Empty(t, unpacked)
+	require.NoError(t, subject.update(*pending, func(info *blobInfo) { info.State = blobStateStored }))
+	unpacked, err = subject.unpacked()
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{*pending}, unpacked)
+	require.NoError(t, subject.remove(*pending))
+
+	require.NoError(t, subject.remove(*id))
+	ids, err = subject.list()
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{*other}, ids)
+	inPiece, err = subject.inPiece("baga2")
+	require.NoError(t, err)
+	require.Empty(t, inPiece)
+}
+
+func TestMigrateIDFiles(t *testing.T) {
+	storeDir := t.TempDir()
+	local, err := blob.NewID()
+	require.NoError(t, err)
+	remote, err := blob.NewID()
+	require.NoError(t, err)
+	indexed, err := blob.NewID()
+	require.NoError(t, err)
+
+	// A blob with a local copy and metadata, one whose local copy was removed
+	// by cleanup, and one already indexed by an interrupted migration.
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(storeDir, name), []byte(content), 0644))
+	}
+	write(local.String()+".id", "3")
+	write(local.String()+".id.meta", `{"contentType":"text/plain","sha256":"AQI=","rootCid":null}`)
+	write(local.String()+".bin", "fish")
+	write(remote.String()+".id", "4")
+	write(indexed.String()+".id", "5")
+	write("unrelated.id", "6")
+
+	s, err := NewStore(WithStoreDir(storeDir), WithWalletKey("dummy"))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.index.close() })
+	require.NoError(t, s.index.put(*indexed, blobInfo{FileID: 5, Size: 9, State: blobStateReplicated}))
+
+	require.NoError(t, s.migrateIDFiles())
+	info, err := s.index.get(*local)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), info.FileID)
+	require.Equal(t, "text/plain", info.ContentType)
+	require.Equal(t, []byte{1, 2}, info.SHA256)
+	require.Equal(t, uint64(4), info.Size)
+	require.False(t, info.CreatedAt.IsZero())
+	require.Equal(t, blobStateStored, info.State)
+	info, err = s.index.get(*remote)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), info.FileID)
+	require.True(t, info.CreatedAt.IsZero())
+	info, err = s.index.get(*indexed)
+	require.NoError(t, err)
+	require.Equal(t, blobStateReplicated, info.State)
+
+	// Migrated files are removed, so that the migration is done only once.
+	require.NoFileExists(t, filepath.Join(storeDir, local.String()+".id"))
+	require.NoFileExists(t, filepath.Join(storeDir, local.String()+".id.meta"))
+	require.NoFileExists(t, filepath.Join(storeDir, remote.String()+".id"))
+	require.FileExists(t, filepath.Join(storeDir, local.String()+".bin"))
+	require.FileExists(t, filepath.Join(storeDir, "unrelated.id"))
+	require.NoError(t, s.migrateIDFiles())
+}
+
+func TestCheckIndex(t *testing.T) {
+	storeDir := t.TempDir()
+	s, err := NewStore(WithStoreDir(storeDir), WithWalletKey("dummy"))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.index.close() })
+	ctx := context.Background()
+
+	// Local copies that are not indexed are kept.
+	unindexed, err := blob.NewID()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(s.localPath(*unindexed), []byte("fish"), 0644))
+	require.NoError(t, s.checkIndex(ctx))
+	require.FileExists(t, s.localPath(*unindexed))
+
+	// Entries that cannot be decoded fail the check.
+	id, err := blob.NewID()
+	require.NoError(t, err)
+	require.NoError(t, s.index.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(blobsBucket).Put(id[:], []byte("lobster"))
+	}))
+	require.ErrorContains(t, s.checkIndex(ctx), id.String())
+}
+
+func TestPushPending(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPost && req.URL.Path == "/api/preparation/MOTION_PREPARATION/source/source/file" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": 9})
+			return
+		}
+		http.Error(w, "", http.StatusNotFound)
+	}))
+	t.Cleanup(testServer.Close)
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	s, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithSingularityClient(singularityclient.NewHTTPClientWithConfig(nil, cfg)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.index.close() })
+
+	// A blob left pending with its local copy, and one without.
+	local, err := blob.NewID()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(s.localPath(*local), []byte("fish"), 0644))
+	require.NoError(t, s.index.put(*local, blobInfo{State: blobStatePending}))
+	gone, err := blob.NewID()
+	require.NoError(t, err)
+	require.NoError(t, s.index.put(*gone, blobInfo{State: blobStatePending}))
+
+	// Pending blobs are neither described nor listed.
+	_, err = s.Describe(context.Background(), *local)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	listed, err := s.ListBlobs(context.Background(), blob.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, listed.Descriptors)
+
+	require.NoError(t, s.pushPending(context.Background()))
+	info, err := s.index.get(*local)
+	require.NoError(t, err)
+	require.Equal(t, int64(9), info.FileID)
+	require.Equal(t, blobStateStored, info.State)
+	_, err = s.index.get(*gone)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+}
@@ -0,0 +1,105 @@
+package singularity
+
+import (
+	"context"
+	"time"
+
+	"github.com/data-preservation-programs/singularity/client/swagger/http/deal"
+	"github.com/data-preservation-programs/singularity/client/swagger/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const dealMetricsTimeout = 10 * time.Second
+
+var (
+	metricPackQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "motion",
+		Subsystem: "singularity",
+		Name:      "pack_queue_depth",
+		Help:      "Number of stored files waiting to be prepared for packing.",
+	})
+	metricPackTriggers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "singularity",
+		Name:      "pack_triggers_total",
+		Help:      `Number of times packing of the source was triggered, by reason ("threshold" or "timeout") and result ("success" or "failure").`,
+	}, []string{"reason", "result"})
+	metricCleanupRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "singularity",
+		Name:      "cleanup_runs_total",
+		Help:      `Number of local file cleanup runs, by result ("success" or "failure").`,
+	}, []string{"result"})
+	metricCleanupRemovals = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "singularity",
+		Name:      "cleanup_removed_blobs_total",
+		Help:      "Number of local blob copies removed by cleanup once deals were made for them.",
+	})
+	metricRepairs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "singularity",
+		Name:      "repairs_total",
+		Help:      `Number of pieces repaired after replicas were lost, by result ("success" or "failure").`,
+	}, []string{"result"})
+	metricRenewals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "singularity",
+		Name:      "renewals_total",
+		Help:      `Number of deals proposed to renew pieces ahead of their expiration, by result ("success" or "failure").`,
+	}, []string{"result"})
+	metricHeldSchedules = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "motion",
+		Subsystem: "singularity",
+		Name:      "held_schedules",
+		Help:      "Number of deal schedules paused because the wallet is low on funds or DataCap.",
+	})
+	metricDeals = prometheus.NewDesc(
+		"motion_singularity_deals",
+		"Number of deals made for the Singularity preparations, by storage provider and deal state.",
+		[]string{"provider", "state"}, nil)
+)
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// dealCollector reports the number of deals per provider and state, as listed by
+// Singularity at collection time.
+type dealCollector struct {
+	store *Store
+}
+
+func (c dealCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metricDeals
+}
+
+func (c dealCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), dealMetricsTimeout)
+	defer cancel()
+	listDealsRes, err := c.store.singularityClient.Deal.ListDeals(&deal.ListDealsParams{
+		Context: ctx,
+		Request: &models.DealListDealRequest{
+			Preparations: c.store.preparationNames(),
+		},
+	})
+	if err != nil {
+		logger.Warnw("Failed to list deals for metrics", "err", err)
+		return
+	}
+	type key struct {
+		provider string
+		state    models.ModelDealState
+	}
+	counts := make(map[key]int)
+	for _, d := range listDealsRes.Payload {
+		counts[key{provider: d.Provider, state: d.State}]++
+	}
+	for k, count := range counts {
+		ch <- prometheus.MustNewConstMetric(metricDeals, prometheus.GaugeValue, float64(count), k.provider, string(k.state))
+	}
+}
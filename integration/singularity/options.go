@@ -10,63 +10,85 @@ import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/filecoin-project/motion/wallet"
 )
 
 type (
 	// Option represents a configurable parameter in Motion service.
 	Option  func(*options) error
 	options struct {
-		walletKey             string
-		storeDir              string
-		storageProviders      []address.Address
-		replicationFactor     uint
-		pricePerGiBEpoch      abi.TokenAmount
-		pricePerGiB           abi.TokenAmount
-		pricePerDeal          abi.TokenAmount
-		dealStartDelay        abi.ChainEpoch
-		dealDuration          abi.ChainEpoch
-		maxCarSize            string
-		packThreshold         int64
-		forcePackAfter        time.Duration
-		preparationName       string
-		singularityClient     *singularityclient.SingularityAPI
-		scheduleUrlTemplate   string
-		scheduleDealNumber    int
-		scheduleCron          string
-		scheduleCronPerpetual bool
-		verifiedDeal          bool
-		ipniAnnounce          bool
-		keepUnsealed          bool
-		totalDealNumber       int
-		scheduleDealSize      string
-		totalDealSize         string
-		maxPendingDealSize    string
-		maxPendingDealNumber  int
-		cleanupInterval       time.Duration
-		minFreeSpace          int64
+		walletKey                string
+		storeDir                 string
+		storageProviders         []address.Address
+		replicationFactor        uint
+		pricePerGiBEpoch         abi.TokenAmount
+		pricePerGiB              abi.TokenAmount
+		pricePerDeal             abi.TokenAmount
+		dealStartDelay           abi.ChainEpoch
+		dealDuration             abi.ChainEpoch
+		maxCarSize               string
+		packThreshold            int64
+		forcePackAfter           time.Duration
+		preparationName          string
+		singularityClient        *singularityclient.SingularityAPI
+		scheduleUrlTemplate      string
+		scheduleDealNumber       int
+		scheduleCron             string
+		scheduleCronPerpetual    bool
+		verifiedDeal             bool
+		ipniAnnounce             bool
+		keepUnsealed             bool
+		totalDealNumber          int
+		scheduleDealSize         string
+		totalDealSize            string
+		maxPendingDealSize       string
+		maxPendingDealNumber     int
+		cleanupInterval          time.Duration
+		replicationCheckInterval time.Duration
+		packCheckInterval        time.Duration
+		repairInterval           time.Duration
+		maxRepairsPerInterval    int
+		renewalWindow            time.Duration
+		renewalInterval          time.Duration
+		policies                 []Policy
+		providerSelector         ProviderSelector
+		providerCatalogue        string
+		walletMonitor            *wallet.Monitor
+		walletCheckInterval      time.Duration
+		cleanupListener          func(blob.ID)
+		minFreeSpace             int64
 	}
 )
 
 func newOptions(o ...Option) (*options, error) {
 	opts := &options{
-		dealDuration:          builtin.EpochsInYear,
-		dealStartDelay:        builtin.EpochsInHour * 72,
-		maxCarSize:            "31.5GiB",
-		packThreshold:         16 << 30,
-		forcePackAfter:        time.Hour * 24,
-		preparationName:       "MOTION_PREPARATION",
-		scheduleCronPerpetual: true,
-		verifiedDeal:          false,
-		keepUnsealed:          true,
-		ipniAnnounce:          true,
-		scheduleDealSize:      "0",
-		totalDealSize:         "0",
-		maxPendingDealSize:    "0",
-		maxPendingDealNumber:  0,
-		cleanupInterval:       time.Hour,
-		pricePerGiBEpoch:      abi.NewTokenAmount(0),
-		pricePerGiB:           abi.NewTokenAmount(0),
-		pricePerDeal:          abi.NewTokenAmount(0),
+		dealDuration:             builtin.EpochsInYear,
+		dealStartDelay:           builtin.EpochsInHour * 72,
+		maxCarSize:               "31.5GiB",
+		packThreshold:            16 << 30,
+		forcePackAfter:           time.Hour * 24,
+		preparationName:          "MOTION_PREPARATION",
+		scheduleCronPerpetual:    true,
+		verifiedDeal:             false,
+		keepUnsealed:             true,
+		ipniAnnounce:             true,
+		scheduleDealSize:         "0",
+		totalDealSize:            "0",
+		maxPendingDealSize:       "0",
+		maxPendingDealNumber:     0,
+		cleanupInterval:          time.Hour,
+		replicationCheckInterval: 10 * time.Minute,
+		packCheckInterval:        10 * time.Minute,
+		repairInterval:           time.Hour,
+		maxRepairsPerInterval:    10,
+		renewalWindow:            30 * 24 * time.Hour,
+		renewalInterval:          time.Hour,
+		pricePerGiBEpoch:         abi.NewTokenAmount(0),
+		pricePerGiB:              abi.NewTokenAmount(0),
+		pricePerDeal:             abi.NewTokenAmount(0),
+		providerSelector:         NewStaticSelector(),
+		walletCheckInterval:      10 * time.Minute,
 	}
 	for _, apply := range o {
 		if err := apply(opts); err != nil {
@@ -83,6 +105,12 @@ func newOptions(o ...Option) (*options, error) {
 		// Default replication factor to the number of storage providers if zero.
 		opts.replicationFactor = uint(len(opts.storageProviders))
 	}
+	if int(opts.replicationFactor) > len(opts.storageProviders) {
+		return nil, fmt.Errorf("replication factor %d exceeds the number of storage providers %d", opts.replicationFactor, len(opts.storageProviders))
+	}
+	if startDelay := time.Duration(opts.dealStartDelay) * builtin.EpochDurationSeconds * time.Second; opts.renewalWindow != 0 && opts.renewalWindow <= startDelay {
+		return nil, fmt.Errorf("renewal window %s must be longer than the deal start delay %s", opts.renewalWindow, startDelay)
+	}
 	if opts.singularityClient == nil {
 		opts.singularityClient = singularityclient.Default
 	}
@@ -116,7 +144,10 @@ func WithStorageProviders(sp ...address.Address) Option {
 	}
 }
 
-// WithReplicationFactor sets the replication factor for the blobs.
+// WithReplicationFactor sets the replication factor for the blobs, i.e. the
+// number of storage providers out of the ones specified with which deals are
+// made for each piece. A blob is considered safe once as many providers hold
+// active deals for it, after which its local copy is cleaned up.
 // Defaults to the number of storage providers specified.
 // If no storage providers are specified the replication factor will be zero,
 // i.e. data will only be stored locally.
@@ -199,6 +230,8 @@ func WithForcePackAfter(d time.Duration) Option {
 }
 
 // WithPreparationName sets the singularity preparation name used to store data.
+// The preparations of storage policies are named after it, suffixed with "_"
+// and the policy name.
 // Defaults to "MOTION_PREPARATION".
 func WithPreparationName(n string) Option {
 	return func(o *options) error {
@@ -333,6 +366,173 @@ func WithCleanupInterval(v time.Duration) Option {
 	}
 }
 
+// WithReplicationCheckInterval sets how often newly packed pieces are assigned
+// to storage providers when the replication factor is lower than the number of
+// storage providers.
+// Defaults to 10 minutes.
+func WithReplicationCheckInterval(v time.Duration) Option {
+	return func(o *options) error {
+		o.replicationCheckInterval = v
+		return nil
+	}
+}
+
+// WithPackCheckInterval sets how often blobs that are not known to be packed
+// yet are checked, in order to record the CARs into which they were packed.
+// Defaults to 10 minutes.
+func WithPackCheckInterval(v time.Duration) Option {
+	return func(o *options) error {
+		o.packCheckInterval = v
+		return nil
+	}
+}
+
+// WithRepairInterval sets how often replicas are checked for expired, slashed
+// or failed deals, and repaired by making new deals for the affected pieces.
+// Defaults to 1 hour.
+func WithRepairInterval(v time.Duration) Option {
+	return func(o *options) error {
+		o.repairInterval = v
+		return nil
+	}
+}
+
+// WithMaxRepairsPerInterval sets the maximum number of pieces repaired per
+// repair interval, which limits the number of new deals and retrievals made
+// at once when many replicas are lost. Remaining pieces are repaired in later
+// intervals.
+// Defaults to 10.
+func WithMaxRepairsPerInterval(v int) Option {
+	return func(o *options) error {
+		if v < 1 {
+			return fmt.Errorf("max repairs per interval must be at least 1, got %d", v)
+		}
+		o.maxRepairsPerInterval = v
+		return nil
+	}
+}
+
+// WithRenewalWindow sets how long before their expiration deals are renewed,
+// by proposing new deals for the same pieces to the same storage providers.
+// Deals are only renewed for pieces that contain blobs retained past the
+// expiration; see blob.Metadata.RetainUntil. The window must be longer than
+// the deal start delay, so that renewal deals start before the renewed ones
+// expire. Zero disables renewal.
+// Defaults to 30 days.
+func WithRenewalWindow(v time.Duration) Option {
+	return func(o *options) error {
+		o.renewalWindow = v
+		return nil
+	}
+}
+
+// WithRenewalInterval sets how often deals are checked for renewal.
+// Defaults to 1 hour.
+func WithRenewalInterval(v time.Duration) Option {
+	return func(o *options) error {
+		o.renewalInterval = v
+		return nil
+	}
+}
+
+// WithPolicies sets the storage policies with which clients may request blobs
+// to be stored, by name; see blob.Metadata.Policy. Blobs stored without a
+// policy are stored with the store-wide settings.
+// Defaults to no policies.
+func WithPolicies(p ...Policy) Option {
+	return func(o *options) error {
+		names := make(map[string]bool)
+		for _, policy := range p {
+			if !policyNamePattern.MatchString(policy.Name) {
+				return fmt.Errorf("invalid storage policy name %q", policy.Name)
+			}
+			if names[policy.Name] {
+				return fmt.Errorf("duplicate storage policy %s", policy.Name)
+			}
+			names[policy.Name] = true
+		}
+		o.policies = p
+		return nil
+	}
+}
+
+// WithProviderSelector sets the strategy with which storage providers are
+// selected to store each piece, when blobs are replicated to fewer storage
+// providers than are configured, and to replace the providers of lost
+// replicas.
+// Defaults to NewStaticSelector.
+//
+// See WithReplicationFactor.
+func WithProviderSelector(v ProviderSelector) Option {
+	return func(o *options) error {
+		if v == nil {
+			return errors.New("provider selector must not be nil")
+		}
+		o.providerSelector = v
+		return nil
+	}
+}
+
+// WithProviderCatalogue sets the path to a JSON file that describes the
+// storage providers to the provider selector, with their weight, ask price
+// and labels. Providers with a zero weight are never selected. The file is
+// read anew on every selection round, so that it can be changed without
+// restarting Motion.
+//
+// Providers listed in the catalogue but not set via WithStorageProviders or
+// the storage policies are also candidates of the policies that replicate
+// selectively, or of those named in their entry if any, so that providers can
+// be added and dropped without restarting Motion. Policies that replicate onto
+// all their providers only make deals with the configured ones.
+// Defaults to no catalogue, i.e. every provider has a weight of 1, unknown
+// ask price and no labels.
+func WithProviderCatalogue(path string) Option {
+	return func(o *options) error {
+		o.providerCatalogue = path
+		return nil
+	}
+}
+
+// WithWalletMonitor sets the monitor with which the funds and DataCap of the
+// wallet are checked, every wallet check interval. While the wallet is low on
+// funds, the deal schedules are paused and renewals are deferred; while it is
+// low on DataCap, the same applies to storage policies that make verified
+// deals. Schedules are resumed once the wallet is replenished.
+// Defaults to none, i.e. the wallet is not checked.
+//
+// See WithWalletCheckInterval.
+func WithWalletMonitor(m *wallet.Monitor) Option {
+	return func(o *options) error {
+		o.walletMonitor = m
+		return nil
+	}
+}
+
+// WithWalletCheckInterval sets how often the funds and DataCap of the wallet
+// are checked, when a wallet monitor is set.
+// Defaults to 10 minutes.
+//
+// See WithWalletMonitor.
+func WithWalletCheckInterval(v time.Duration) Option {
+	return func(o *options) error {
+		if v <= 0 {
+			return fmt.Errorf("wallet check interval must be positive, got %s", v)
+		}
+		o.walletCheckInterval = v
+		return nil
+	}
+}
+
+// WithCleanupListener sets the function called with the ID of each blob whose
+// local copy is removed by cleanup.
+// Defaults to none.
+func WithCleanupListener(l func(blob.ID)) Option {
+	return func(o *options) error {
+		o.cleanupListener = l
+		return nil
+	}
+}
+
 // WithMinFreeSpce configures the minimul free disk space that must remain
 // after storing a blob. A value of zero uses the default value and -1 disabled
 // checks.
@@ -0,0 +1,165 @@
+package singularity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/data-preservation-programs/singularity/client/swagger/models"
+	"github.com/filecoin-project/motion/blob"
+)
+
+func (s *Store) runPackCheck() {
+	defer s.closed.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.closing
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.packCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.checkPacks(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorw("Failed to check packs", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkPacks records in the index the CARs into which the blobs that are not
+// fully packed yet were packed since the last check, so that blobs are
+// described and found by piece without listing the CARs of their preparation.
+// Blobs are packed once all their content ranges are packed into CARs. The CARs
+// of each preparation are listed at most once per check, and only if a range
+// of any of its blobs was assigned to a pack job whose CAR is not recorded yet.
+// The size and creation time of blobs migrated from ID files without a local
+// copy are backfilled along the way.
+func (s *Store) checkPacks(ctx context.Context) error {
+	ids, err := s.index.unpacked()
+	if err != nil {
+		return err
+	}
+	carsByJob := make(map[*storagePolicy]map[int64]*models.ModelCar)
+	var (
+		packed  int
+		removed []blob.ID
+		errs    []error
+	)
+	for _, id := range ids {
+		info, err := s.index.get(id)
+		if err != nil {
+			if errors.Is(err, blob.ErrBlobNotFound) {
+				// The blob was removed since listed.
+				continue
+			}
+			return err
+		}
+		getFileRes, err := s.getFile(ctx, info.FileID)
+		if err != nil {
+			if strings.Contains(err.Error(), "404") {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("error loading singularity entry of blob %s: %w", id.String(), err))
+			continue
+		}
+		singularityFile := getFileRes.Payload
+
+		packs := info.Packs
+		p, ok := s.policy(info.Policy)
+		if ok && len(packs) < countJobRanges(singularityFile) {
+			if _, ok := carsByJob[p]; !ok {
+				cars, err := s.listCars(ctx, p)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				carsByJob[p] = make(map[int64]*models.ModelCar)
+				for _, car := range cars {
+					carsByJob[p][car.JobID] = car
+				}
+			}
+			packs = packsOf(singularityFile, carsByJob[p])
+		}
+		complete := len(singularityFile.FileRanges) != 0 && len(packs) == len(singularityFile.FileRanges)
+		if len(packs) == len(info.Packs) && !(complete && info.State == blobStateStored) && !info.CreatedAt.IsZero() {
+			continue
+		}
+		if err := s.index.update(id, func(info *blobInfo) {
+			if info.CreatedAt.IsZero() {
+				// The blob was migrated from ID files without a local copy,
+				// and its size and creation time are backfilled from the
+				// Singularity file.
+				info.Size = uint64(singularityFile.Size)
+				info.CreatedAt = time.Unix(0, singularityFile.LastModifiedNano)
+			}
+			info.Packs = packs
+			if complete && info.State == blobStateStored {
+				info.State = blobStatePacked
+			}
+		}); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			return err
+		}
+		if complete {
+			packed++
+			if info.RemovedAt != nil {
+				removed = append(removed, id)
+			}
+		}
+	}
+	if packed != 0 {
+		logger.Infow("Recorded packs of blobs", "count", packed)
+	}
+	// The local copy of blobs removed before they were fully packed was kept
+	// for Singularity to pack them. They are purged once the packs of all the
+	// blobs are recorded, so that the other blobs packed into the same pieces
+	// are known.
+	for _, id := range removed {
+		if err := s.purgeRemoved(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("failed to purge removed blob %s: %w", id.String(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// countJobRanges counts the ranges of the Singularity file that are assigned
+// to a pack job.
+func countJobRanges(singularityFile *models.ModelFile) int {
+	var n int
+	for _, fileRange := range singularityFile.FileRanges {
+		if fileRange.JobID != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// packsOf returns the packs of the ranges of the Singularity file that are
+// packed into any of the given CARs of its preparation, by pack job.
+func packsOf(singularityFile *models.ModelFile, carsByJob map[int64]*models.ModelCar) []packInfo {
+	var packs []packInfo
+	for _, fileRange := range singularityFile.FileRanges {
+		car, ok := carsByJob[fileRange.JobID]
+		if !ok || fileRange.JobID == 0 {
+			continue
+		}
+		packs = append(packs, packInfo{
+			JobID:      fileRange.JobID,
+			Offset:     uint64(fileRange.Offset),
+			Length:     uint64(fileRange.Length),
+			PieceCID:   car.PieceCid,
+			PieceSize:  uint64(car.PieceSize),
+			PayloadCID: car.RootCid,
+			CARSize:    uint64(car.FileSize),
+		})
+	}
+	return packs
+}
@@ -0,0 +1,115 @@
+package singularity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPacksPurgesRemovedBlobs(t *testing.T) {
+	content := []byte("fish")
+
+	var (
+		mu     sync.Mutex
+		packed bool
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.URL.Path {
+		case "/api/preparation/MOTION_PREPARATION/piece":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"pieces": []map[string]any{
+					{"jobId": 1, "pieceCid": "baga1", "pieceSize": 2048, "rootCid": "bafyroot1", "fileSize": 1024},
+				}},
+			})
+		case "/api/file/0", "/api/file/1":
+			// Both files are packed into the same piece by the same job, once
+			// packed.
+			jobID := 0
+			if packed {
+				jobID = 1
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"size":       len(content),
+				"fileRanges": []map[string]any{{"jobId": jobID, "offset": 0, "length": len(content)}},
+			})
+		default:
+			http.Error(w, "", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(testServer.Close)
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	s, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithSingularityClient(singularityclient.NewHTTPClientWithConfig(nil, cfg)),
+	)
+	require.NoError(t, err)
+
+	// Two blobs stored but not packed yet, with their local copy staged.
+	var ids []blob.ID
+	for fileID := int64(0); fileID < 2; fileID++ {
+		id, err := blob.NewID()
+		require.NoError(t, err)
+		require.NoError(t, s.index.put(*id, blobInfo{FileID: fileID, Size: uint64(len(content)), CreatedAt: time.Now(), State: blobStateStored}))
+		require.NoError(t, os.WriteFile(s.localPath(*id), content, 0644))
+		ids = append(ids, *id)
+	}
+	ctx := context.Background()
+
+	// The local copy of a blob removed before it is packed is kept for
+	// Singularity to pack it, while the blob is no longer known to Motion.
+	require.NoError(t, s.Remove(ctx, ids[0]))
+	require.FileExists(t, s.localPath(ids[0]))
+	_, err = s.Describe(ctx, ids[0])
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	require.ErrorIs(t, s.Remove(ctx, ids[0]), blob.ErrBlobNotFound)
+	listed, err := s.ListBlobs(ctx, blob.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, listed.Descriptors, 1)
+	require.Equal(t, ids[1], listed.Descriptors[0].ID)
+	require.NoError(t, s.checkPacks(ctx))
+	require.FileExists(t, s.localPath(ids[0]))
+
+	// Once packed, the local copy is deleted, but the index entry is kept
+	// since the piece also holds a blob that is not removed, so that it can be
+	// rebuilt.
+	mu.Lock()
+	packed = true
+	mu.Unlock()
+	require.NoError(t, s.repairs.record("baga1", repairRecord{Lost: []string{"f01000"}, Time: time.Now()}))
+	require.NoError(t, s.checkPacks(ctx))
+	require.NoFileExists(t, s.localPath(ids[0]))
+	info, err := s.index.get(ids[0])
+	require.NoError(t, err)
+	require.Equal(t, blobStatePacked, info.State)
+	require.False(t, retained([]*packedBlob{{id: ids[0], info: info}}, time.Now()))
+	_, ok := s.repairs.last("baga1")
+	require.True(t, ok)
+
+	// Removing the last blob in the piece purges both, along with the repair
+	// state of the piece.
+	require.NoError(t, s.Remove(ctx, ids[1]))
+	for _, id := range ids {
+		require.NoFileExists(t, s.localPath(id))
+		_, err := s.index.get(id)
+		require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	}
+	_, ok = s.repairs.last("baga1")
+	require.False(t, ok)
+}
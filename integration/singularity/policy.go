@@ -0,0 +1,155 @@
+package singularity
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/data-preservation-programs/singularity/client/swagger/models"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+// policyNamePattern matches valid storage policy names, which are used as part
+// of Singularity preparation and storage names.
+var policyNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Policy is a named set of deal settings with which clients may request blobs
+// to be stored, instead of the store-wide settings. Blobs stored with a policy
+// are packed by a Singularity preparation of their own, so that the deals for
+// their pieces are made with the policy settings. Unset fields default to the
+// store-wide settings.
+type Policy struct {
+	// Name identifies the policy. It must start with a letter or digit, and
+	// only contain letters, digits, '.', '-' and '_'.
+	Name string
+	// StorageProviders are the storage providers to make deals with.
+	// Defaults to the store-wide storage providers.
+	StorageProviders []address.Address
+	// ReplicationFactor is the number of storage providers out of the policy
+	// ones with which deals are made for each piece.
+	// Defaults to the number of storage providers of the policy.
+	ReplicationFactor uint
+	// DealDuration is the duration of the deals made.
+	// Defaults to the store-wide deal duration.
+	DealDuration abi.ChainEpoch
+	// VerifiedDeal, if non-nil, is whether the deals made are verified.
+	// Defaults to the store-wide setting.
+	VerifiedDeal *bool
+	// PricePerGiBEpoch, PricePerGiB and PricePerDeal are the maximum prices
+	// paid for the deals made.
+	// Default to the store-wide prices if nil.
+	PricePerGiBEpoch abi.TokenAmount
+	PricePerGiB      abi.TokenAmount
+	PricePerDeal     abi.TokenAmount
+}
+
+// storagePolicy is a policy resolved against the store-wide settings, along
+// with the Singularity preparation that packs the blobs stored with it.
+type storagePolicy struct {
+	// name is the name of the policy, empty for the store-wide settings.
+	name              string
+	preparationName   string
+	sourceName        string
+	storageProviders  []address.Address
+	replicationFactor uint
+	dealDuration      abi.ChainEpoch
+	verifiedDeal      bool
+	pricePerGiBEpoch  abi.TokenAmount
+	pricePerGiB       abi.TokenAmount
+	pricePerDeal      abi.TokenAmount
+	replicas          *replicaAssignments
+}
+
+// newStoragePolicies resolves the configured policies against the store-wide
+// settings. The first policy returned is the default one, i.e. the store-wide
+// settings, followed by the configured policies in order. The replica
+// assignments of each policy are persisted in the given index.
+func newStoragePolicies(opts *options, index *blobIndex) ([]*storagePolicy, error) {
+	policies := []*storagePolicy{{
+		preparationName:   opts.preparationName,
+		sourceName:        "source",
+		storageProviders:  opts.storageProviders,
+		replicationFactor: opts.replicationFactor,
+		dealDuration:      opts.dealDuration,
+		verifiedDeal:      opts.verifiedDeal,
+		pricePerGiBEpoch:  opts.pricePerGiBEpoch,
+		pricePerGiB:       opts.pricePerGiB,
+		pricePerDeal:      opts.pricePerDeal,
+		replicas:          newReplicaAssignments(index, "replicas", filepath.Join(opts.storeDir, "replication.json")),
+	}}
+	for _, policy := range opts.policies {
+		p := &storagePolicy{
+			name:              policy.Name,
+			preparationName:   opts.preparationName + "_" + policy.Name,
+			sourceName:        "source_" + policy.Name,
+			storageProviders:  policy.StorageProviders,
+			replicationFactor: policy.ReplicationFactor,
+			dealDuration:      policy.DealDuration,
+			verifiedDeal:      opts.verifiedDeal,
+			pricePerGiBEpoch:  policy.PricePerGiBEpoch,
+			pricePerGiB:       policy.PricePerGiB,
+			pricePerDeal:      policy.PricePerDeal,
+			replicas:          newReplicaAssignments(index, "replicas_"+policy.Name, filepath.Join(opts.storeDir, "replication_"+policy.Name+".json")),
+		}
+		if len(p.storageProviders) == 0 {
+			p.storageProviders = opts.storageProviders
+		}
+		if p.replicationFactor == 0 {
+			p.replicationFactor = uint(len(p.storageProviders))
+		}
+		if int(p.replicationFactor) > len(p.storageProviders) {
+			return nil, fmt.Errorf("replication factor %d of storage policy %s exceeds its number of storage providers %d", p.replicationFactor, p.name, len(p.storageProviders))
+		}
+		if p.dealDuration == 0 {
+			p.dealDuration = opts.dealDuration
+		}
+		if policy.VerifiedDeal != nil {
+			p.verifiedDeal = *policy.VerifiedDeal
+		}
+		if p.pricePerGiBEpoch.Nil() {
+			p.pricePerGiBEpoch = opts.pricePerGiBEpoch
+		}
+		if p.pricePerGiB.Nil() {
+			p.pricePerGiB = opts.pricePerGiB
+		}
+		if p.pricePerDeal.Nil() {
+			p.pricePerDeal = opts.pricePerDeal
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// replicatesSelectively reports whether blobs are replicated to fewer storage
+// providers than are configured for the policy, in which case deals for each
+// piece are only made with the providers assigned to it.
+func (p *storagePolicy) replicatesSelectively() bool {
+	return int(p.replicationFactor) < len(p.storageProviders)
+}
+
+// policy returns the storage policy with the given name, or the default one
+// if the name is empty.
+func (s *Store) policy(name string) (*storagePolicy, bool) {
+	for _, p := range s.policies {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// preparationNames returns the names of the preparations of all policies.
+func (s *Store) preparationNames() []string {
+	names := make([]string, 0, len(s.policies))
+	for _, p := range s.policies {
+		names = append(names, p.preparationName)
+	}
+	return names
+}
+
+// policyCar is a CAR packed by the preparation of a storage policy.
+type policyCar struct {
+	*models.ModelCar
+	policy *storagePolicy
+}
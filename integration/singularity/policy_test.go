@@ -0,0 +1,130 @@
+package singularity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/gotidy/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStoragePolicies(t *testing.T) {
+	providers := mustAddresses(t, "f01000", "f02000", "f03000")
+
+	opts, err := newOptions(
+		WithWalletKey("dummy"),
+		WithStoreDir(t.TempDir()),
+		WithStorageProviders(providers...),
+		WithPricePerGiB(abi.NewTokenAmount(7)),
+		WithPolicies(Policy{
+			Name:             "archive-5y-2x",
+			StorageProviders: providers[1:],
+			DealDuration:     5 * builtin.EpochsInYear,
+			VerifiedDeal:     ptr.Bool(true),
+			PricePerDeal:     abi.NewTokenAmount(3),
+		}, Policy{
+			Name:              "cheap",
+			ReplicationFactor: 1,
+		}),
+	)
+	require.NoError(t, err)
+	index, err := openBlobIndex(filepath.Join(t.TempDir(), "index.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, index.close()) })
+	policies, err := newStoragePolicies(opts, index)
+	require.NoError(t, err)
+	require.Len(t, policies, 3)
+
+	// The default policy has the store-wide settings.
+	require.Equal(t, "MOTION_PREPARATION", policies[0].preparationName)
+	require.Equal(t, uint(3), policies[0].replicationFactor)
+	require.False(t, policies[0].replicatesSelectively())
+
+	// Unset policy settings default to the store-wide ones.
+	archive := policies[1]
+	require.Equal(t, "MOTION_PREPARATION_archive-5y-2x", archive.preparationName)
+	require.Equal(t, "source_archive-5y-2x", archive.sourceName)
+	require.Equal(t, providers[1:], archive.storageProviders)
+	require.Equal(t, uint(2), archive.replicationFactor)
+	require.Equal(t, abi.ChainEpoch(5*builtin.EpochsInYear), archive.dealDuration)
+	require.True(t, archive.verifiedDeal)
+	require.Equal(t, abi.NewTokenAmount(7), archive.pricePerGiB)
+	require.Equal(t, abi.NewTokenAmount(3), archive.pricePerDeal)
+	require.Equal(t, abi.NewTokenAmount(0), archive.pricePerGiBEpoch)
+
+	cheap := policies[2]
+	require.Equal(t, providers, cheap.storageProviders)
+	require.True(t, cheap.replicatesSelectively())
+	require.Equal(t, abi.ChainEpoch(builtin.EpochsInYear), cheap.dealDuration)
+	require.False(t, cheap.verifiedDeal)
+
+	// Invalid policies are rejected.
+	_, err = newOptions(WithWalletKey("dummy"), WithPolicies(Policy{Name: "../archive"}))
+	require.ErrorContains(t, err, "invalid storage policy name")
+	_, err = newOptions(WithWalletKey("dummy"), WithPolicies(Policy{Name: "archive"}, Policy{Name: "archive"}))
+	require.ErrorContains(t, err, "duplicate storage policy")
+	opts, err = newOptions(WithWalletKey("dummy"), WithStorageProviders(providers...), WithPolicies(Policy{Name: "archive", ReplicationFactor: 4}))
+	require.NoError(t, err)
+	_, err = newStoragePolicies(opts, index)
+	require.ErrorContains(t, err, "exceeds its number of storage providers")
+}
+
+func TestPutWithPolicy(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		pushed []string
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/file") {
+			mu.Lock()
+			pushed = append(pushed, req.URL.Path)
+			mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": 5})
+			return
+		}
+		http.Error(w, "", http.StatusNotFound)
+	}))
+	t.Cleanup(testServer.Close)
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	s, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithSingularityClient(singularityclient.NewHTTPClientWithConfig(nil, cfg)),
+		WithPolicies(Policy{Name: "archive"}),
+	)
+	require.NoError(t, err)
+
+	// Blobs are pushed to the preparation of the requested policy.
+	ctx := context.Background()
+	desc, err := s.Put(ctx, strings.NewReader("fish"), blob.WithMetadata(blob.Metadata{Policy: "archive"}))
+	require.NoError(t, err)
+	require.Equal(t, "archive", desc.Metadata.Policy)
+	mu.Lock()
+	require.Equal(t, []string{"/api/preparation/MOTION_PREPARATION_archive/source/source_archive/file"}, pushed)
+	mu.Unlock()
+	req := <-s.toPack
+	require.Equal(t, uint64(5), req.fileID)
+	require.Equal(t, "archive", req.policy.name)
+
+	// Blobs requesting unknown policies are rejected before being stored.
+	_, err = s.Put(ctx, strings.NewReader("lobster"), blob.WithMetadata(blob.Metadata{Policy: "unknown"}))
+	require.ErrorIs(t, err, blob.ErrUnknownPolicy)
+	ids, err := s.local.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+}
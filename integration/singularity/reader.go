@@ -9,7 +9,10 @@ import (
 
 	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
 	"github.com/data-preservation-programs/singularity/client/swagger/http/file"
+	"github.com/filecoin-project/motion/tracing"
 	"github.com/gotidy/ptr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // io.ReadSeekCloser implementation that reads from remote singularity
@@ -18,6 +21,8 @@ type Reader struct {
 	fileID uint64
 	offset int64
 	size   int64
+	// traceCtx carries the span under which retrievals are traced, if any.
+	traceCtx context.Context
 
 	// Reads remaining data from current range.
 	rangeReader *rangeReader
@@ -25,9 +30,10 @@ type Reader struct {
 
 func NewReader(client *singularityclient.SingularityAPI, fileID uint64, size int64) *Reader {
 	return &Reader{
-		client: client,
-		fileID: fileID,
-		size:   size,
+		client:   client,
+		fileID:   fileID,
+		size:     size,
+		traceCtx: context.Background(),
 	}
 }
 
@@ -99,7 +105,7 @@ func (r *Reader) WriteToN(w io.Writer, readLen int64) (int64, error) {
 	byteRange := fmt.Sprintf("bytes=%d-%d", r.offset, r.offset+readLen-1)
 	rr := &rangeReader{
 		offset:    r.offset,
-		reader:    r.retrieveReader(context.Background(), int64(r.fileID), byteRange),
+		reader:    retrieveReader(r.traceCtx, r.client, int64(r.fileID), byteRange),
 		remaining: remainingRange,
 	}
 
@@ -163,15 +169,26 @@ func (r *Reader) Close() error {
 	return err
 }
 
-func (r *Reader) retrieveReader(ctx context.Context, fileID int64, byteRange string) io.ReadCloser {
+// retrieveReader retrieves the given byte range of the Singularity file with the
+// given ID. Closing the returned reader before the range is fully read aborts
+// the retrieval.
+func retrieveReader(ctx context.Context, client *singularityclient.SingularityAPI, fileID int64, byteRange string) io.ReadCloser {
 	// Start goroutine to read from singularity into write end of pipe.
 	reader, writer := io.Pipe()
 	go func() {
-		_, _, err := r.client.File.RetrieveFile(&file.RetrieveFileParams{
+		ctx, span := tracer.Start(ctx, "singularity.RetrieveFile", trace.WithAttributes(
+			attribute.Int64("singularity.file_id", fileID),
+			attribute.String("http.range", byteRange)))
+		_, _, err := client.File.RetrieveFile(&file.RetrieveFileParams{
 			Context: ctx,
 			ID:      fileID,
 			Range:   ptr.String(byteRange),
 		}, writer)
+		// The read end of the pipe is only closed once no more data is needed.
+		if errors.Is(err, io.ErrClosedPipe) {
+			err = nil
+		}
+		tracing.End(span, err)
 		if err != nil {
 			err = fmt.Errorf("failed to retrieve file slice: %w", err)
 		}
@@ -0,0 +1,327 @@
+package singularity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/data-preservation-programs/singularity/client/swagger/http/deal"
+	"github.com/data-preservation-programs/singularity/client/swagger/models"
+	"github.com/data-preservation-programs/singularity/service/epochutil"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/gotidy/ptr"
+)
+
+// renewalLog records the pieces whose deals are being renewed, along with the
+// time at which a renewal deal was last proposed to each provider. The log is
+// persisted in the index, so that renewals are neither repeated nor forgotten
+// across restarts.
+type renewalLog struct {
+	mu sync.Mutex
+	// pieces maps the CIDs of the pieces being renewed to the time at which a
+	// renewal deal was last proposed to each provider.
+	pieces *persistedMap[map[string]time.Time]
+	// legacyPath is the path of the JSON file in which earlier versions of
+	// Motion persisted the log.
+	legacyPath string
+	// lapsing holds the CIDs of the pieces whose deals are allowed to lapse,
+	// since no blob in them is retained past their expiration. Retention never
+	// changes, so such pieces are not looked up again until their deals end.
+	lapsing map[string]struct{}
+}
+
+func newRenewalLog(index *blobIndex, dir string) *renewalLog {
+	return &renewalLog{
+		pieces:     newPersistedMap[map[string]time.Time](index, "renewals"),
+		legacyPath: filepath.Join(dir, "renewals.json"),
+		lapsing:    make(map[string]struct{}),
+	}
+}
+
+// read reads the persisted renewals, if any.
+func (rl *renewalLog) read() error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.pieces.load(rl.legacyPath, nil)
+}
+
+// start records that the piece is being renewed.
+func (rl *renewalLog) start(pieceCID string) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if _, ok := rl.pieces.get(pieceCID); ok {
+		return nil
+	}
+	return rl.pieces.set(pieceCID, make(map[string]time.Time))
+}
+
+// proposed records that a renewal deal for the piece was proposed to the
+// provider at the given time.
+func (rl *renewalLog) proposed(pieceCID, provider string, t time.Time) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	proposals, _ := rl.pieces.get(pieceCID)
+	proposals = maps.Clone(proposals)
+	if proposals == nil {
+		proposals = make(map[string]time.Time)
+	}
+	proposals[provider] = t
+	return rl.pieces.set(pieceCID, proposals)
+}
+
+// lastProposed returns the time at which a renewal deal for the piece was last
+// proposed to the provider, if any.
+func (rl *renewalLog) lastProposed(pieceCID, provider string) (time.Time, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	proposals, _ := rl.pieces.get(pieceCID)
+	t, ok := proposals[provider]
+	return t, ok
+}
+
+// finish records that the piece is no longer being renewed.
+func (rl *renewalLog) finish(pieceCID string) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.pieces.delete(pieceCID)
+}
+
+// lapse records that the deals of the piece are allowed to lapse, and that it
+// is no longer being renewed.
+func (rl *renewalLog) lapse(pieceCID string) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.lapsing[pieceCID] = struct{}{}
+	return rl.pieces.delete(pieceCID)
+}
+
+// lapses reports whether the deals of the piece are allowed to lapse.
+func (rl *renewalLog) lapses(pieceCID string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	_, ok := rl.lapsing[pieceCID]
+	return ok
+}
+
+// forgetLapsed forgets the lapsing pieces that are not in the given set of
+// pieces with deals.
+func (rl *renewalLog) forgetLapsed(pieceCIDs []string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for pieceCID := range rl.lapsing {
+		if _, ok := slices.BinarySearch(pieceCIDs, pieceCID); !ok {
+			delete(rl.lapsing, pieceCID)
+		}
+	}
+}
+
+// renewing reports whether any of the given pieces is being renewed.
+func (rl *renewalLog) renewing(pieceCIDs ...string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return slices.ContainsFunc(pieceCIDs, func(pieceCID string) bool {
+		_, ok := rl.pieces.get(pieceCID)
+		return ok
+	})
+}
+
+func (s *Store) runRenewal() {
+	defer s.closed.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.closing
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.renewalInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.renew(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorw("Failed to renew deals", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// renew proposes new deals for the pieces whose deals expire within the
+// renewal window, with the same providers and the settings of the storage
+// policy of each piece. Only the pieces that contain blobs retained past the
+// expiration, as recorded in the index, are renewed; the deals of the others
+// are allowed to lapse.
+//
+// Renewal deals need the content of the piece, so the local copies of its
+// blobs that were removed by cleanup are restored first, and kept until the
+// renewal deals are published. Only published and active deals count towards
+// the expiration of a piece, so that renewals last until then. A renewal deal
+// is proposed again to a provider if none is published by the time the
+// proposed deal would have started.
+// Renewals are deferred while the wallet is low on funds, or on DataCap for
+// verified deals.
+func (s *Store) renew(ctx context.Context) error {
+	listDealsRes, err := s.singularityClient.Deal.ListDeals(&deal.ListDealsParams{
+		Context: ctx,
+		Request: &models.DealListDealRequest{
+			Preparations: s.preparationNames(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list deals: %w", err)
+	}
+	// The latest end epoch of the published or active deals for each piece by
+	// each provider.
+	ends := make(map[string]map[string]int64)
+	for _, deal := range listDealsRes.Payload {
+		if deal.PieceCid == "" || (deal.State != models.ModelDealStatePublished && deal.State != models.ModelDealStateActive) {
+			continue
+		}
+		if _, ok := ends[deal.PieceCid]; !ok {
+			ends[deal.PieceCid] = make(map[string]int64)
+		}
+		ends[deal.PieceCid][deal.Provider] = max(ends[deal.PieceCid][deal.Provider], deal.EndEpoch)
+	}
+	pieceCIDs := make([]string, 0, len(ends))
+	for pieceCID := range ends {
+		pieceCIDs = append(pieceCIDs, pieceCID)
+	}
+	slices.Sort(pieceCIDs)
+	s.renewals.forgetLapsed(pieceCIDs)
+
+	startDelay := time.Duration(s.dealStartDelay) * builtin.EpochDurationSeconds * time.Second
+	deadline := time.Now().Add(s.renewalWindow)
+	var (
+		carsByPiece map[string]policyCar
+		errs        []error
+	)
+	for _, pieceCID := range pieceCIDs {
+		logger := logger.With("piece", pieceCID)
+		var (
+			due        []string
+			expiration time.Time
+		)
+		for provider, end := range ends[pieceCID] {
+			if providerExpiration := epochutil.EpochToTime(int32(end)); providerExpiration.Before(deadline) {
+				due = append(due, provider)
+				if expiration.IsZero() || providerExpiration.Before(expiration) {
+					expiration = providerExpiration
+				}
+			}
+		}
+		if len(due) == 0 {
+			if s.renewals.renewing(pieceCID) {
+				logger.Info("Renewed deals for piece")
+			}
+			if err := s.renewals.finish(pieceCID); err != nil {
+				return err
+			}
+			continue
+		}
+		slices.Sort(due)
+		if s.renewals.lapses(pieceCID) {
+			continue
+		}
+
+		packed, err := s.packedBlobs(pieceCID)
+		if err != nil {
+			return err
+		}
+		if len(packed) == 0 {
+			// The piece is not recorded as containing any blob known to Motion,
+			// or not yet.
+			if err := s.renewals.finish(pieceCID); err != nil {
+				return err
+			}
+			continue
+		}
+		if !retained(packed, expiration) {
+			logger.Debugw("Letting deals for piece lapse, since no blob in it is retained", "expiration", expiration)
+			if err := s.renewals.lapse(pieceCID); err != nil {
+				return err
+			}
+			continue
+		}
+		if carsByPiece == nil {
+			cars, err := s.listAllCars(ctx)
+			if err != nil {
+				return err
+			}
+			carsByPiece = make(map[string]policyCar)
+			for _, car := range cars {
+				carsByPiece[car.PieceCid] = car
+			}
+		}
+		car, ok := carsByPiece[pieceCID]
+		if !ok {
+			errs = append(errs, fmt.Errorf("piece %s not found in preparation", pieceCID))
+			continue
+		}
+		if err := s.dealsAllowed(car.policy); err != nil {
+			errs = append(errs, fmt.Errorf("deferred renewal of piece %s: %w", pieceCID, err))
+			continue
+		}
+		if err := s.renewals.start(pieceCID); err != nil {
+			return err
+		}
+		if _, err := s.restoreLocalCopies(ctx, packed); err != nil {
+			errs = append(errs, fmt.Errorf("failed to renew piece %s: %w", pieceCID, err))
+			continue
+		}
+		for _, provider := range due {
+			if last, ok := s.renewals.lastProposed(pieceCID, provider); ok && time.Since(last) < startDelay {
+				// Awaiting the proposed deal to be published.
+				continue
+			}
+			err := s.proposeRenewal(ctx, car, provider)
+			metricRenewals.WithLabelValues(resultLabel(err)).Inc()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to propose deal to renew piece %s with provider %s: %w", pieceCID, provider, err))
+				continue
+			}
+			logger.Infow("Proposed deal to renew piece", "provider", provider, "expiration", expiration)
+			if err := s.renewals.proposed(pieceCID, provider, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// proposeRenewal proposes a deal for the piece packed as the given CAR to the
+// provider, using the deal settings of the policy of the CAR.
+func (s *Store) proposeRenewal(ctx context.Context, car policyCar, provider string) error {
+	pricePerGBEpoch, pricePerGB, pricePerDeal := s.schedulePrices(car.policy)
+	startDelay, duration := s.scheduleDurations(car.policy)
+	_, err := s.singularityClient.Deal.SendManual(&deal.SendManualParams{
+		Context: ctx,
+		Proposal: &models.DealProposal{
+			ClientAddress:   s.walletAddress,
+			ProviderID:      provider,
+			PieceCid:        car.PieceCid,
+			PieceSize:       strconv.FormatInt(car.PieceSize, 10),
+			RootCid:         ptr.String(car.RootCid),
+			FileSize:        car.FileSize,
+			PricePerGbEpoch: pricePerGBEpoch,
+			PricePerGb:      pricePerGB,
+			PricePerDeal:    pricePerDeal,
+			Verified:        &car.policy.verifiedDeal,
+			Ipni:            &s.ipniAnnounce,
+			KeepUnsealed:    &s.keepUnsealed,
+			StartDelay:      ptr.String(startDelay),
+			Duration:        ptr.String(duration),
+			URLTemplate:     s.scheduleUrlTemplate,
+			HTTPHeaders:     []string{},
+		},
+	})
+	return err
+}
@@ -0,0 +1,128 @@
+package singularity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
+	"github.com/data-preservation-programs/singularity/service/epochutil"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenew(t *testing.T) {
+	providers := mustAddresses(t, "f01000", "f02000")
+	content := []byte("fish")
+	soon := int64(epochutil.TimeToEpoch(time.Now().Add(10 * 24 * time.Hour)))
+	later := int64(epochutil.TimeToEpoch(time.Now().Add(300 * 24 * time.Hour)))
+
+	var (
+		mu        sync.Mutex
+		deals     []map[string]any
+		proposals []map[string]any
+	)
+	deals = []map[string]any{
+		{"provider": providers[0].String(), "pieceCid": "baga1", "state": "active", "endEpoch": soon},
+		{"provider": providers[1].String(), "pieceCid": "baga1", "state": "active", "endEpoch": later},
+		{"provider": providers[0].String(), "pieceCid": "baga2", "state": "active", "endEpoch": soon},
+	}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.URL.Path {
+		case "/api/deal":
+			_ = json.NewEncoder(w).Encode(deals)
+		case "/api/preparation/MOTION_PREPARATION/piece":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"pieces": []map[string]any{
+					{"jobId": 1, "pieceCid": "baga1", "pieceSize": 2048, "rootCid": "bafyroot1", "fileSize": 1024},
+					{"jobId": 2, "pieceCid": "baga2", "pieceSize": 2048, "rootCid": "bafyroot2", "fileSize": 1024},
+				}},
+			})
+		case "/api/file/0", "/api/file/1":
+			jobID := 1
+			if req.URL.Path == "/api/file/1" {
+				jobID = 2
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"size":       len(content),
+				"fileRanges": []map[string]any{{"jobId": jobID, "offset": 0, "length": len(content)}},
+			})
+		case "/api/file/0/retrieve":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(content)
+		case "/api/send_deal":
+			var proposal map[string]any
+			_ = json.NewDecoder(req.Body).Decode(&proposal)
+			proposals = append(proposals, proposal)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": len(proposals)})
+		default:
+			http.Error(w, "", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(testServer.Close)
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	s, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithSingularityClient(singularityclient.NewHTTPClientWithConfig(nil, cfg)),
+		WithStorageProviders(providers...),
+	)
+	require.NoError(t, err)
+
+	// A blob retained indefinitely, whose local copy was removed by cleanup, and
+	// a blob retained until before its deals expire, as recorded once packed.
+	retainedID, err := blob.NewID()
+	require.NoError(t, err)
+	require.NoError(t, s.index.put(*retainedID, blobInfo{FileID: 0, Packs: []packInfo{{JobID: 1, PieceCID: "baga1"}}}))
+	lapsingID, err := blob.NewID()
+	require.NoError(t, err)
+	retainUntil := time.Now().Add(24 * time.Hour)
+	require.NoError(t, s.index.put(*lapsingID, blobInfo{Metadata: blob.Metadata{RetainUntil: &retainUntil}, FileID: 1, Packs: []packInfo{{JobID: 2, PieceCID: "baga2"}}}))
+
+	ctx := context.Background()
+	require.NoError(t, s.renew(ctx))
+	mu.Lock()
+	require.Len(t, proposals, 1)
+	require.Equal(t, providers[0].String(), proposals[0]["providerId"])
+	require.Equal(t, "baga1", proposals[0]["pieceCid"])
+	require.Equal(t, "2048", proposals[0]["pieceSize"])
+	require.Equal(t, "bafyroot1", proposals[0]["rootCid"])
+	mu.Unlock()
+
+	// The local copy is restored to make the renewal deal, and kept until the
+	// deal is published.
+	restored, err := os.ReadFile(s.localPath(*retainedID))
+	require.NoError(t, err)
+	require.Equal(t, content, restored)
+	require.True(t, s.renewals.renewing("baga1"))
+	require.False(t, s.renewals.renewing("baga2"))
+
+	// Renewal deals are not proposed again while awaiting publication, and
+	// proposed deals do not complete the renewal.
+	mu.Lock()
+	deals = append(deals, map[string]any{"provider": providers[0].String(), "pieceCid": "baga1", "state": "proposed", "endEpoch": later})
+	mu.Unlock()
+	require.NoError(t, s.renew(ctx))
+	require.True(t, s.renewals.renewing("baga1"))
+	require.True(t, s.renewals.lapses("baga2"))
+	mu.Lock()
+	require.Len(t, proposals, 1)
+	deals = append(deals, map[string]any{"provider": providers[0].String(), "pieceCid": "baga1", "state": "published", "endEpoch": later})
+	mu.Unlock()
+
+	// Renewal completes once the renewal deal is published.
+	require.NoError(t, s.renew(ctx))
+	require.False(t, s.renewals.renewing("baga1"))
+}
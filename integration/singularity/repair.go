@@ -0,0 +1,464 @@
+package singularity
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/data-preservation-programs/singularity/client/swagger/http/deal"
+	"github.com/data-preservation-programs/singularity/client/swagger/models"
+	"github.com/filecoin-project/motion/blob"
+)
+
+const (
+	// maxRepairRecords is the maximum number of repairs recorded per piece.
+	// Older repairs are forgotten.
+	maxRepairRecords = 10
+	// maxRepairBackoff is the maximum time to wait before retrying the repair
+	// of a piece after failed repairs.
+	maxRepairBackoff = 24 * time.Hour
+)
+
+// repairLog records the repairs made to pieces whose replicas were lost. The
+// log is persisted in the index, so that repairs are reported and not repeated
+// across restarts.
+type repairLog struct {
+	mu sync.Mutex
+	// pieces maps piece CIDs to their repairs, oldest first.
+	pieces *persistedMap[[]repairRecord]
+	// legacyPath is the path of the JSON file in which earlier versions of
+	// Motion persisted the log.
+	legacyPath string
+}
+
+// repairRecord is a repair of a piece as recorded in repairLog. See
+// blob.Repair.
+type repairRecord struct {
+	Lost         []string `json:"lost"`
+	Replacements []string `json:"replacements,omitempty"`
+	// Restored are the IDs of the blobs whose local copy was restored.
+	Restored []string  `json:"restored,omitempty"`
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func newRepairLog(index *blobIndex, dir string) *repairLog {
+	return &repairLog{
+		pieces:     newPersistedMap[[]repairRecord](index, "repairs"),
+		legacyPath: filepath.Join(dir, "repairs.json"),
+	}
+}
+
+// read reads the persisted repairs, if any.
+func (rl *repairLog) read() error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.pieces.load(rl.legacyPath, nil)
+}
+
+// record records and persists a repair of the piece.
+func (rl *repairLog) record(pieceCID string, record repairRecord) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	records, _ := rl.pieces.get(pieceCID)
+	records = append(slices.Clone(records), record)
+	if len(records) > maxRepairRecords {
+		records = records[len(records)-maxRepairRecords:]
+	}
+	return rl.pieces.set(pieceCID, records)
+}
+
+// last returns the most recent repair of the piece, if any.
+func (rl *repairLog) last(pieceCID string) (repairRecord, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	records, _ := rl.pieces.get(pieceCID)
+	if len(records) == 0 {
+		return repairRecord{}, false
+	}
+	return records[len(records)-1], true
+}
+
+// failures counts the consecutive failed repairs of the piece for the set of
+// lost replicas of its most recent repair.
+func (rl *repairLog) failures(pieceCID string) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	records, _ := rl.pieces.get(pieceCID)
+	var n int
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Error == "" || !slices.Equal(records[i].Lost, records[len(records)-1].Lost) {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// forget forgets the repairs of the piece.
+func (rl *repairLog) forget(pieceCID string) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.pieces.delete(pieceCID)
+}
+
+// forBlob returns the repairs of the given pieces, which contain the blob,
+// most recent first.
+func (rl *repairLog) forBlob(id blob.ID, pieceCIDs []string) []blob.Repair {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	var repairs []blob.Repair
+	for _, pieceCID := range pieceCIDs {
+		records, _ := rl.pieces.get(pieceCID)
+		for _, record := range records {
+			repairs = append(repairs, blob.Repair{
+				PieceCID:     pieceCID,
+				Lost:         record.Lost,
+				Replacements: record.Replacements,
+				Restored:     slices.Contains(record.Restored, id.String()),
+				Time:         record.Time,
+				Error:        record.Error,
+			})
+		}
+	}
+	slices.SortStableFunc(repairs, func(a, b blob.Repair) int { return b.Time.Compare(a.Time) })
+	return repairs
+}
+
+// isHealthyDealState reports whether a deal in the given state holds, or is
+// expected to hold, a replica. Deals in any other state, i.e. expired,
+// slashed, rejected or failed, no longer do.
+func isHealthyDealState(state models.ModelDealState) bool {
+	switch state {
+	case models.ModelDealStateProposed, models.ModelDealStatePublished, models.ModelDealStateActive:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Store) runRepair() {
+	defer s.closed.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.closing
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.repairInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.repair(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorw("Failed to repair replicas", "err", err)
+		}
+	}
+}
+
+// repair looks for pieces with lost replicas, i.e. providers whose deals for
+// the piece all expired, were slashed or failed, and that have fewer healthy
+// replicas than the replication factor of their storage policy. Each such
+// piece is repaired once per set of lost replicas, and at most
+// maxRepairsPerInterval pieces are repaired per run; the rest are left to the
+// next run.
+//
+// When replicating selectively, other storage providers of the policy are
+// assigned to the piece in place of the lost ones, as selected by the provider
+// selector. Otherwise, deals are retried by Singularity with the providers
+// that lost them. Either way, new deals need the content of the piece, so the
+// local copies of its blobs that were removed by cleanup are restored by
+// retrieving them from a surviving replica. Pieces that contain no blob
+// retained any longer are not repaired. Failed repairs are retried after a
+// backoff that doubles with each consecutive failure, up to maxRepairBackoff.
+func (s *Store) repair(ctx context.Context) error {
+	listDealsRes, err := s.singularityClient.Deal.ListDeals(&deal.ListDealsParams{
+		Context: ctx,
+		Request: &models.DealListDealRequest{
+			Preparations: s.preparationNames(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list deals: %w", err)
+	}
+	outcomes := dealOutcomes(listDealsRes.Payload)
+	catalogue, err := s.readProviderCatalogue()
+	if err != nil {
+		return err
+	}
+	cars, err := s.listAllCars(ctx)
+	if err != nil {
+		return err
+	}
+	policies := make(map[string]*storagePolicy)
+	for _, car := range cars {
+		policies[car.PieceCid] = car.policy
+	}
+	// Whether each provider with deals for a piece holds a healthy replica.
+	healthy := make(map[string]map[string]bool)
+	for _, deal := range listDealsRes.Payload {
+		if deal.PieceCid == "" {
+			continue
+		}
+		if _, ok := healthy[deal.PieceCid]; !ok {
+			healthy[deal.PieceCid] = make(map[string]bool)
+		}
+		healthy[deal.PieceCid][deal.Provider] = healthy[deal.PieceCid][deal.Provider] || isHealthyDealState(deal.State)
+	}
+	pieceCIDs := make([]string, 0, len(healthy))
+	for pieceCID := range healthy {
+		pieceCIDs = append(pieceCIDs, pieceCID)
+	}
+	slices.Sort(pieceCIDs)
+
+	var (
+		repaired   int
+		reassigned []*storagePolicy
+	)
+	for _, pieceCID := range pieceCIDs {
+		p, ok := policies[pieceCID]
+		if !ok {
+			// The piece is not packed by the preparation of any policy.
+			continue
+		}
+		var live, lost []string
+		for provider, ok := range healthy[pieceCID] {
+			if ok {
+				live = append(live, provider)
+			} else {
+				lost = append(lost, provider)
+			}
+		}
+		if len(lost) == 0 || uint(len(live)) >= p.replicationFactor {
+			continue
+		}
+		slices.Sort(lost)
+		if last, ok := s.repairs.last(pieceCID); ok && slices.Equal(last.Lost, lost) {
+			if last.Error == "" {
+				// Already repaired.
+				continue
+			}
+			if time.Since(last.Time) < s.repairBackoff(s.repairs.failures(pieceCID)) {
+				// Backing off after failed repairs.
+				continue
+			}
+		}
+		packed, err := s.packedBlobs(pieceCID)
+		if err != nil {
+			return err
+		}
+		if !retained(packed, time.Now()) {
+			// The blobs packed into the piece are no longer retained.
+			continue
+		}
+		if repaired >= s.maxRepairsPerInterval {
+			logger.Infow("Reached the maximum number of repairs per interval. Deferring remaining repairs", "max", s.maxRepairsPerInterval)
+			break
+		}
+		repaired++
+
+		logger := logger.With("piece", pieceCID, "lost", lost)
+		record := repairRecord{Lost: lost, Time: time.Now()}
+		var errs []error
+		if p.replicatesSelectively() {
+			// Providers that are expected to hold a replica are those with
+			// healthy deals, and those assigned to the piece without any deal
+			// yet.
+			expected := live
+			for _, provider := range p.replicas.get(pieceCID) {
+				if _, dealt := healthy[pieceCID][provider]; !dealt {
+					expected = append(expected, provider)
+				}
+			}
+			if shortfall := int(p.replicationFactor) - len(expected); shortfall > 0 {
+				var candidates, existing []ProviderInfo
+				for _, info := range providerInfos(p, catalogue, outcomes) {
+					switch provider := info.Address.String(); {
+					case slices.Contains(expected, provider):
+						existing = append(existing, info)
+					case !slices.Contains(lost, provider):
+						candidates = append(candidates, info)
+					}
+				}
+				chosen, err := p.replicas.extend(pieceCID, candidates, existing, shortfall, s.providerSelector)
+				if err != nil {
+					errs = append(errs, err)
+				} else if len(chosen) < shortfall {
+					errs = append(errs, fmt.Errorf("no other storage provider to replace %d lost replicas", shortfall-len(chosen)))
+				}
+				record.Replacements = chosen
+				if len(chosen) != 0 && !slices.Contains(reassigned, p) {
+					reassigned = append(reassigned, p)
+				}
+			}
+		}
+
+		restored, err := s.restoreLocalCopies(ctx, packed)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		record.Restored = restored
+
+		err = errors.Join(errs...)
+		if err != nil {
+			record.Error = err.Error()
+			logger.Warnw("Failed to repair lost replicas", "replacements", record.Replacements, "restored", record.Restored, "err", err)
+		} else {
+			logger.Infow("Repaired lost replicas", "replacements", record.Replacements, "restored", record.Restored)
+		}
+		metricRepairs.WithLabelValues(resultLabel(err)).Inc()
+		if err := s.repairs.record(pieceCID, record); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, p := range reassigned {
+		if err := s.syncSchedules(ctx, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// repairBackoff returns the time to wait before retrying the repair of a piece
+// after the given number of consecutive failed repairs, i.e. the repair
+// interval doubled for each failure after the first, up to maxRepairBackoff.
+func (s *Store) repairBackoff(failures int) time.Duration {
+	backoff := s.repairInterval
+	for i := 1; i < failures && backoff < maxRepairBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxRepairBackoff)
+}
+
+// packedBlob is a blob packed into a piece.
+type packedBlob struct {
+	id   blob.ID
+	info blobInfo
+	// restored is whether the local copy of the blob was restored, as opposed
+	// to never removed.
+	restored bool
+}
+
+// retained reports whether any of the given blobs that are not removed is to
+// be retained past the given time.
+func retained(blobs []*packedBlob, t time.Time) bool {
+	return slices.ContainsFunc(blobs, func(pb *packedBlob) bool {
+		return pb.info.RemovedAt == nil && (pb.info.RetainUntil == nil || pb.info.RetainUntil.After(t))
+	})
+}
+
+// packedBlobs returns the blobs known to Motion that are packed into the
+// piece, as recorded in the index.
+func (s *Store) packedBlobs(pieceCID string) ([]*packedBlob, error) {
+	ids, err := s.index.inPiece(pieceCID)
+	if err != nil {
+		return nil, err
+	}
+	packed := make([]*packedBlob, 0, len(ids))
+	for _, id := range ids {
+		info, err := s.index.get(id)
+		if err != nil {
+			if errors.Is(err, blob.ErrBlobNotFound) {
+				// The blob was removed since listed.
+				continue
+			}
+			return nil, err
+		}
+		packed = append(packed, &packedBlob{id: id, info: info})
+	}
+	return packed, nil
+}
+
+// restoreLocalCopies restores the local copies of the given blobs that were
+// removed, so that new deals can be made for the piece into which they are
+// packed. The IDs of the restored blobs are returned.
+func (s *Store) restoreLocalCopies(ctx context.Context, blobs []*packedBlob) ([]string, error) {
+	var (
+		restored []string
+		errs     []error
+	)
+	for _, pb := range blobs {
+		if !pb.restored {
+			if _, err := os.Stat(s.localPath(pb.id)); !errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			getFileRes, err := s.getFile(ctx, pb.info.FileID)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error loading singularity entry of blob %s: %w", pb.id, err))
+				continue
+			}
+			if err := s.restoreLocalCopy(ctx, pb.id, getFileRes.Payload); err != nil {
+				errs = append(errs, fmt.Errorf("failed to restore local copy of blob %s: %w", pb.id, err))
+				continue
+			}
+			pb.restored = true
+		}
+		restored = append(restored, pb.id.String())
+	}
+	return restored, errors.Join(errs...)
+}
+
+// restoreLocalCopy restores the local copy of the blob from the corresponding
+// Singularity file, which Singularity retrieves from the storage providers
+// that hold it. The restored content is verified against the SHA-256 digest
+// of the blob, if known, and given the modification time recorded by
+// Singularity, which it expects of the files it packs.
+func (s *Store) restoreLocalCopy(ctx context.Context, id blob.ID, singularityFile *models.ModelFile) error {
+	info, err := s.index.get(id)
+	if err != nil {
+		return err
+	}
+	dest, err := os.CreateTemp(s.local.Dir(), "motion_local_store_*.bin.temp")
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	reader := NewReader(s.singularityClient, uint64(singularityFile.ID), singularityFile.Size)
+	reader.traceCtx = ctx
+	defer reader.Close()
+	digest := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dest, digest), reader); err != nil && !errors.Is(err, io.EOF) {
+		os.Remove(dest.Name())
+		return fmt.Errorf("failed to retrieve content: %w", err)
+	}
+	if len(info.SHA256) != 0 && !bytes.Equal(info.SHA256, digest.Sum(nil)) {
+		os.Remove(dest.Name())
+		return blob.ErrDigestMismatch
+	}
+	if err := dest.Close(); err != nil {
+		os.Remove(dest.Name())
+		return err
+	}
+	modTime := time.Unix(0, singularityFile.LastModifiedNano)
+	if err := os.Chtimes(dest.Name(), modTime, modTime); err != nil {
+		os.Remove(dest.Name())
+		return err
+	}
+	if err := os.Rename(dest.Name(), s.localPath(id)); err != nil {
+		os.Remove(dest.Name())
+		return err
+	}
+	return nil
+}
+
+// localPath returns the path of the local copy of the blob, as pushed to
+// Singularity.
+func (s *Store) localPath(id blob.ID) string {
+	return filepath.Join(s.local.Dir(), id.String()+".bin")
+}
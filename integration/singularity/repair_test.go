@@ -0,0 +1,149 @@
+package singularity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepair(t *testing.T) {
+	providers := mustAddresses(t, "f01000", "f02000", "f03000")
+	content := []byte("fish")
+	modTime := time.Date(2023, 9, 20, 9, 12, 43, 0, time.UTC)
+
+	var (
+		mu      sync.Mutex
+		created = make(map[string][]string)
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case req.URL.Path == "/api/deal":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"provider": providers[0].String(), "pieceCid": "baga1", "state": "active"},
+				{"provider": providers[1].String(), "pieceCid": "baga1", "state": "slashed"},
+				{"provider": providers[2].String(), "pieceCid": "baga2", "state": "expired"},
+				{"provider": providers[2].String(), "pieceCid": "baga2", "state": "active"},
+			})
+		case req.URL.Path == "/api/preparation/MOTION_PREPARATION/piece":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"pieces": []map[string]any{{"jobId": 1, "pieceCid": "baga1"}, {"jobId": 2, "pieceCid": "baga2"}}},
+			})
+		case req.URL.Path == "/api/preparation/MOTION_PREPARATION/schedules":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"id": 1, "provider": providers[0].String(), "allowedPieceCids": []string{"baga1"}},
+				{"id": 2, "provider": providers[1].String(), "allowedPieceCids": []string{"baga1"}},
+			})
+		case req.URL.Path == "/api/schedule" && req.Method == http.MethodPost:
+			var request struct {
+				Provider         string   `json:"provider"`
+				AllowedPieceCids []string `json:"allowedPieceCids"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			created[request.Provider] = request.AllowedPieceCids
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": 3})
+		case req.URL.Path == "/api/file/0":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id":               0,
+				"size":             len(content),
+				"lastModifiedNano": modTime.UnixNano(),
+				"fileRanges":       []map[string]any{{"jobId": 1, "offset": 0, "length": len(content)}},
+			})
+		case req.URL.Path == "/api/file/0/retrieve":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(content)
+		default:
+			http.Error(w, "", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(testServer.Close)
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	s, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithSingularityClient(singularityclient.NewHTTPClientWithConfig(nil, cfg)),
+		WithStorageProviders(providers...),
+		WithReplicationFactor(2),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.policies[0].replicas.assign([]string{"baga1"}, providerInfos(s.policies[0], nil, nil), 2, s.providerSelector))
+
+	// A blob packed into the piece with a lost replica, whose local copy was
+	// removed by cleanup, as recorded once packed.
+	id, err := blob.NewID()
+	require.NoError(t, err)
+	digest := sha256.Sum256(content)
+	require.NoError(t, s.index.put(*id, blobInfo{SHA256: digest[:], FileID: 0, Packs: []packInfo{{JobID: 1, PieceCID: "baga1"}}}))
+
+	ctx := context.Background()
+	require.NoError(t, s.repair(ctx))
+
+	// The lost replica is replaced with the remaining provider.
+	require.Equal(t, []string{providers[0].String(), providers[1].String(), providers[2].String()}, s.policies[0].replicas.get("baga1"))
+	mu.Lock()
+	require.Equal(t, map[string][]string{providers[2].String(): {"baga1"}}, created)
+	mu.Unlock()
+
+	// The local copy is restored as Singularity packed it.
+	restored, err := os.ReadFile(s.localPath(*id))
+	require.NoError(t, err)
+	require.Equal(t, content, restored)
+	stat, err := os.Stat(s.localPath(*id))
+	require.NoError(t, err)
+	require.True(t, modTime.Equal(stat.ModTime()))
+
+	repairs := s.repairs.forBlob(*id, []string{"baga1", "baga2"})
+	require.Len(t, repairs, 1)
+	require.Equal(t, "baga1", repairs[0].PieceCID)
+	require.Equal(t, []string{providers[1].String()}, repairs[0].Lost)
+	require.Equal(t, []string{providers[2].String()}, repairs[0].Replacements)
+	require.True(t, repairs[0].Restored)
+	require.Empty(t, repairs[0].Error)
+
+	// Pieces are repaired once per set of lost replicas, across restarts.
+	reloaded := newRepairLog(s.index, s.storeDir)
+	require.NoError(t, reloaded.read())
+	s.repairs = reloaded
+	require.NoError(t, s.repair(ctx))
+	require.Len(t, s.repairs.forBlob(*id, []string{"baga1"}), 1)
+}
+
+func TestRepairBackoff(t *testing.T) {
+	s, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithRepairInterval(time.Hour),
+	)
+	require.NoError(t, err)
+
+	// Failed repairs are counted per set of lost replicas.
+	require.Zero(t, s.repairs.failures("baga1"))
+	require.NoError(t, s.repairs.record("baga1", repairRecord{Lost: []string{"f01000"}, Error: "fish"}))
+	require.NoError(t, s.repairs.record("baga1", repairRecord{Lost: []string{"f01000", "f02000"}, Error: "fish"}))
+	require.NoError(t, s.repairs.record("baga1", repairRecord{Lost: []string{"f01000", "f02000"}, Error: "fish"}))
+	require.Equal(t, 2, s.repairs.failures("baga1"))
+	require.NoError(t, s.repairs.record("baga1", repairRecord{Lost: []string{"f01000", "f02000"}}))
+	require.Zero(t, s.repairs.failures("baga1"))
+
+	// The backoff doubles with each failure, up to a maximum.
+	require.Equal(t, time.Hour, s.repairBackoff(1))
+	require.Equal(t, 2*time.Hour, s.repairBackoff(2))
+	require.Equal(t, 8*time.Hour, s.repairBackoff(4))
+	require.Equal(t, maxRepairBackoff, s.repairBackoff(10))
+}
@@ -0,0 +1,285 @@
+package singularity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/data-preservation-programs/singularity/client/swagger/http/deal"
+	"github.com/data-preservation-programs/singularity/client/swagger/http/deal_schedule"
+	"github.com/data-preservation-programs/singularity/client/swagger/models"
+	"github.com/filecoin-project/go-address"
+)
+
+// replicaAssignments records the storage providers chosen to store each piece
+// when blobs are replicated to fewer providers than are configured for their
+// storage policy. The assignments are persisted in the index, so that the same
+// providers are kept across restarts.
+type replicaAssignments struct {
+	mu sync.Mutex
+	// pieces maps piece CIDs to the providers chosen to store them.
+	pieces *persistedMap[[]string]
+	// legacyPath is the path of the JSON file in which earlier versions of
+	// Motion persisted the assignments.
+	legacyPath string
+}
+
+func newReplicaAssignments(index *blobIndex, bucket, legacyPath string) *replicaAssignments {
+	return &replicaAssignments{
+		pieces:     newPersistedMap[[]string](index, bucket),
+		legacyPath: legacyPath,
+	}
+}
+
+// read reads the persisted assignments, if any.
+func (ra *replicaAssignments) read() error {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	return ra.pieces.load(ra.legacyPath, nil)
+}
+
+// assign selects factor providers out of the given candidates for each piece
+// that is not assigned yet, using the given selector, and persists the
+// assignment of each.
+func (ra *replicaAssignments) assign(pieceCIDs []string, candidates []ProviderInfo, factor uint, selector ProviderSelector) error {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	load := ra.load()
+	for _, pieceCID := range pieceCIDs {
+		if _, ok := ra.pieces.get(pieceCID); ok || pieceCID == "" {
+			continue
+		}
+		if err := ra.pieces.set(pieceCID, selectProviders(selector, candidates, nil, load, int(factor))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extend assigns up to n more providers out of the given candidates to the
+// piece, selected as by assign, given the providers that are expected to
+// already store it. The newly assigned providers are returned.
+func (ra *replicaAssignments) extend(pieceCID string, candidates, existing []ProviderInfo, n int, selector ProviderSelector) ([]string, error) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	selected := selectProviders(selector, candidates, existing, ra.load(), n)
+	if len(selected) == 0 {
+		return nil, nil
+	}
+	assigned, _ := ra.pieces.get(pieceCID)
+	if err := ra.pieces.set(pieceCID, append(slices.Clone(assigned), selected...)); err != nil {
+		return nil, err
+	}
+	return selected, nil
+}
+
+// project counts the pieces each provider would be assigned out of the given
+// number of new pieces, if they were assigned as by assign given the current
+// assignments. Nothing is assigned.
+func (ra *replicaAssignments) project(pieces uint64, candidates []ProviderInfo, factor uint, selector ProviderSelector) map[string]uint64 {
+	ra.mu.Lock()
+	load := ra.load()
+	ra.mu.Unlock()
+	projected := make(map[string]uint64)
+	for i := uint64(0); i < pieces; i++ {
+		for _, provider := range selectProviders(selector, candidates, nil, load, int(factor)) {
+			projected[provider]++
+		}
+	}
+	return projected
+}
+
+// get returns the providers assigned to the piece.
+func (ra *replicaAssignments) get(pieceCID string) []string {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	assigned, _ := ra.pieces.get(pieceCID)
+	return slices.Clone(assigned)
+}
+
+// load counts the pieces assigned to each provider. The caller must hold mu.
+func (ra *replicaAssignments) load() map[string]int {
+	load := make(map[string]int)
+	for _, assigned := range ra.pieces.entries {
+		for _, provider := range assigned {
+			load[provider]++
+		}
+	}
+	return load
+}
+
+// selectProviders selects up to n of the candidates with non-zero weight
+// using the given selector, after setting the number of pieces assigned to
+// each candidate from the given load. The load of the selected providers is
+// incremented.
+func selectProviders(selector ProviderSelector, candidates, existing []ProviderInfo, load map[string]int, n int) []string {
+	eligible := make([]ProviderInfo, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.Weight == 0 {
+			continue
+		}
+		candidate.Assigned = load[candidate.Address.String()]
+		eligible = append(eligible, candidate)
+	}
+	selectedAddrs := selector.Select(eligible, existing, n)
+	selected := make([]string, 0, len(selectedAddrs))
+	for _, addr := range selectedAddrs[:min(n, len(selectedAddrs))] {
+		provider := addr.String()
+		if slices.Contains(selected, provider) {
+			continue
+		}
+		selected = append(selected, provider)
+		load[provider]++
+	}
+	return selected
+}
+
+// byProvider returns the pieces assigned to each provider.
+func (ra *replicaAssignments) byProvider() map[string][]string {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	pieces := make(map[string][]string)
+	for pieceCID, providers := range ra.pieces.entries {
+		for _, provider := range providers {
+			pieces[provider] = append(pieces[provider], pieceCID)
+		}
+	}
+	for _, assigned := range pieces {
+		slices.Sort(assigned)
+	}
+	return pieces
+}
+
+func (s *Store) runReplication() {
+	defer s.closed.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.closing
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.replicationCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.replicate(ctx); err != nil && ctx.Err() == nil {
+			logger.Errorw("Failed to replicate pieces", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// replicate assigns the pieces packed since the last run to storage providers,
+// for each storage policy that replicates selectively, as selected by the
+// provider selector given the provider catalogue and the outcome of the deals
+// made so far. It then makes sure that the deal schedule of each provider
+// allows the pieces assigned to it. Schedules are created for providers on
+// their first assignment, since schedules that allow no piece in particular
+// make deals for every piece.
+func (s *Store) replicate(ctx context.Context) error {
+	if !slices.ContainsFunc(s.policies, (*storagePolicy).replicatesSelectively) {
+		return nil
+	}
+	catalogue, err := s.readProviderCatalogue()
+	if err != nil {
+		return err
+	}
+	listDealsRes, err := s.singularityClient.Deal.ListDeals(&deal.ListDealsParams{
+		Context: ctx,
+		Request: &models.DealListDealRequest{
+			Preparations: s.preparationNames(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list deals: %w", err)
+	}
+	outcomes := dealOutcomes(listDealsRes.Payload)
+
+	var errs []error
+	for _, p := range s.policies {
+		if !p.replicatesSelectively() {
+			continue
+		}
+		cars, err := s.listCars(ctx, p)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pieceCIDs := make([]string, 0, len(cars))
+		for _, car := range cars {
+			pieceCIDs = append(pieceCIDs, car.PieceCid)
+		}
+		if err := p.replicas.assign(pieceCIDs, providerInfos(p, catalogue, outcomes), p.replicationFactor, s.providerSelector); err != nil {
+			return err
+		}
+		if err := s.syncSchedules(ctx, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// syncSchedules makes sure that the deal schedule of each provider allows the
+// pieces assigned to it by the storage policy. Schedules created while new
+// deals are not allowed for the policy are held right away.
+func (s *Store) syncSchedules(ctx context.Context, p *storagePolicy) error {
+	schedules, err := s.listSchedules(ctx, p)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for provider, assigned := range p.replicas.byProvider() {
+		logger := logger.With("preparation", p.preparationName, "provider", provider)
+		sp, err := address.NewFromString(provider)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid provider assigned to pieces: %w", err))
+			continue
+		}
+		schedule, ok := schedules[sp]
+		if !ok {
+			createScheduleRes, err := s.singularityClient.DealSchedule.CreateSchedule(&deal_schedule.CreateScheduleParams{
+				Context:  ctx,
+				Schedule: s.newScheduleCreateRequest(p, sp, assigned),
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to create schedule for provider %s: %w", sp, err))
+				continue
+			}
+			logger.Infow("Created schedule for provider", "id", createScheduleRes.Payload.ID, "pieces", len(assigned))
+			if reason := s.dealsAllowed(p); reason != nil {
+				if err := s.holdSchedule(ctx, p, createScheduleRes.Payload.ID, reason); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			continue
+		}
+		var missing []string
+		for _, pieceCID := range assigned {
+			if !slices.Contains(schedule.AllowedPieceCids, pieceCID) {
+				missing = append(missing, pieceCID)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		// Singularity adds the given piece CIDs to the ones already allowed.
+		if _, err := s.singularityClient.DealSchedule.UpdateSchedule(&deal_schedule.UpdateScheduleParams{
+			Context: ctx,
+			ID:      schedule.ID,
+			Body:    &models.ScheduleUpdateRequest{AllowedPieceCids: missing},
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update schedule for provider %s: %w", sp, err))
+			continue
+		}
+		logger.Infow("Assigned pieces to provider", "id", schedule.ID, "pieces", missing)
+	}
+	return errors.Join(errs...)
+}
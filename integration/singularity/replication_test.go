@@ -0,0 +1,161 @@
+package singularity
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicaAssignments(t *testing.T) {
+	providers := mustAddresses(t, "f01000", "f02000", "f03000")
+	var candidates []ProviderInfo
+	for _, sp := range providers {
+		candidates = append(candidates, ProviderInfo{Address: sp, Weight: 1})
+	}
+	dir := t.TempDir()
+	index, err := openBlobIndex(filepath.Join(dir, "index.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, index.close()) })
+
+	// Assignments persisted as a JSON file by earlier versions are migrated.
+	path := filepath.Join(dir, "replication.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"baga1":["`+providers[0].String()+`","`+providers[1].String()+`"]}`), 0644))
+	subject := newReplicaAssignments(index, "replicas", path)
+	require.NoError(t, subject.read())
+	require.NoFileExists(t, path)
+	require.Equal(t, []string{providers[0].String(), providers[1].String()}, subject.get("baga1"))
+	require.NoError(t, subject.assign([]string{"baga1", "baga2", ""}, candidates, 2, NewStaticSelector()))
+	require.NoError(t, subject.assign([]string{"baga1", "baga3"}, candidates, 2, NewStaticSelector()))
+	require.Equal(t, map[string][]string{
+		providers[0].String(): {"baga1", "baga2"},
+		providers[1].String(): {"baga1", "baga3"},
+		providers[2].String(): {"baga2", "baga3"},
+	}, subject.byProvider())
+
+	// Assignments are kept across restarts.
+	reloaded := newReplicaAssignments(index, "replicas", path)
+	require.NoError(t, reloaded.read())
+	require.Equal(t, subject.byProvider(), reloaded.byProvider())
+}
+
+func TestActiveReplicas(t *testing.T) {
+	active := func(pieceCID string) blob.Piece { return blob.Piece{PieceCID: pieceCID, Status: "active"} }
+	proposed := func(pieceCID string) blob.Piece { return blob.Piece{PieceCID: pieceCID, Status: "proposed"} }
+
+	tests := []struct {
+		name string
+		desc blob.Descriptor
+		want uint
+	}{
+		{name: "no deals"},
+		{
+			name: "pieces from deals",
+			desc: blob.Descriptor{Replicas: []blob.Replica{
+				{Provider: "f01000", Pieces: []blob.Piece{active("baga1")}},
+				{Provider: "f02000", Pieces: []blob.Piece{proposed("baga1")}},
+				{Provider: "f03000", Pieces: []blob.Piece{proposed("baga1"), active("baga1")}},
+			}},
+			want: 2,
+		},
+		{
+			name: "pieces from packs",
+			desc: blob.Descriptor{
+				Packs: []blob.Pack{{PieceCID: "baga1"}, {PieceCID: "baga2"}},
+				Replicas: []blob.Replica{
+					{Provider: "f01000", Pieces: []blob.Piece{active("baga1")}},
+					{Provider: "f02000", Pieces: []blob.Piece{active("baga1"), active("baga2")}},
+				},
+			},
+			want: 1,
+		},
+		{
+			name: "partially packed",
+			desc: blob.Descriptor{
+				Packs: []blob.Pack{{PieceCID: "baga1"}, {}},
+				Replicas: []blob.Replica{
+					{Provider: "f01000", Pieces: []blob.Piece{active("baga1")}},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.want, activeReplicas(&test.desc))
+		})
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		created = make(map[string][]string)
+		updated = make(map[string][]string)
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case req.URL.Path == "/api/preparation/MOTION_PREPARATION/piece":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"pieces": []map[string]any{{"pieceCid": "baga1"}, {"pieceCid": "baga2"}}},
+			})
+		case req.URL.Path == "/api/deal":
+			_ = json.NewEncoder(w).Encode([]map[string]any{})
+		case req.URL.Path == "/api/preparation/MOTION_PREPARATION/schedules":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"id": 1, "provider": "f01000", "allowedPieceCids": []string{"baga0"}},
+			})
+		case req.URL.Path == "/api/schedule" && req.Method == http.MethodPost:
+			var request struct {
+				Provider         string   `json:"provider"`
+				AllowedPieceCids []string `json:"allowedPieceCids"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			created[request.Provider] = request.AllowedPieceCids
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": 2})
+		case req.URL.Path == "/api/schedule/1" && req.Method == http.MethodPatch:
+			var request struct {
+				AllowedPieceCids []string `json:"allowedPieceCids"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&request)
+			updated["1"] = request.AllowedPieceCids
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": 1})
+		default:
+			http.Error(w, "", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(testServer.Close)
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	providers := mustAddresses(t, "f01000", "f02000", "f03000")
+	s, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithSingularityClient(singularityclient.NewHTTPClientWithConfig(nil, cfg)),
+		WithStorageProviders(providers...),
+		WithReplicationFactor(1),
+	)
+	require.NoError(t, err)
+	require.True(t, s.policies[0].replicatesSelectively())
+
+	require.NoError(t, s.replicate(context.Background()))
+	mu.Lock()
+	defer mu.Unlock()
+	// Pieces are assigned to the least loaded providers, in the order in which
+	// they are configured.
+	require.Equal(t, map[string][]string{"1": {"baga1"}}, updated)
+	require.Equal(t, map[string][]string{providers[1].String(): {"baga2"}}, created)
+}
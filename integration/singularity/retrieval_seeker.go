@@ -0,0 +1,107 @@
+package singularity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	singularityclient "github.com/data-preservation-programs/singularity/client/swagger/http"
+)
+
+var _ io.ReadSeekCloser = (*retrievalSeeker)(nil)
+
+// retrievalSeeker reads a Singularity file sequentially, from wherever it was
+// last sought to. Like Reader, each retrieval is bounded to the bytes asked for
+// by the read that starts it, and is reused by consecutive reads until those
+// bytes are consumed. Retrieval starts on the first read after a seek, so that
+// seeking alone, e.g. by http.ServeContent to find the size of the file or to
+// skip to a range that ends up not being served, retrieves nothing.
+type retrievalSeeker struct {
+	ctx    context.Context
+	client *singularityclient.SingularityAPI
+	fileID int64
+	size   int64
+	offset int64
+	// body streams the file from offset to end, if a retrieval is ongoing.
+	body io.ReadCloser
+	end  int64
+}
+
+func newRetrievalSeeker(ctx context.Context, client *singularityclient.SingularityAPI, fileID int64, size int64) *retrievalSeeker {
+	return &retrievalSeeker{
+		ctx:    ctx,
+		client: client,
+		fileID: fileID,
+		size:   size,
+	}
+}
+
+func (r *retrievalSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.body == nil {
+		r.end = min(r.offset+int64(len(p)), r.size)
+		r.body = retrieveReader(r.ctx, r.client, r.fileID, fmt.Sprintf("bytes=%d-%d", r.offset, r.end-1))
+	}
+	if remaining := r.end - r.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if r.offset == r.end {
+		// The retrieved range is consumed, and the next read retrieves the
+		// following one.
+		r.closeBody()
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		return n, err
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		r.closeBody()
+	}
+	return n, err
+}
+
+func (r *retrievalSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("unknown seek mode")
+	}
+	if offset > r.size {
+		return 0, errors.New("seek past end of file")
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of file")
+	}
+	if offset != r.offset {
+		r.closeBody()
+		r.offset = offset
+	}
+	return r.offset, nil
+}
+
+func (r *retrievalSeeker) Close() error {
+	r.closeBody()
+	return nil
+}
+
+func (r *retrievalSeeker) closeBody() {
+	if r.body != nil {
+		_ = r.body.Close()
+		r.body = nil
+	}
+}
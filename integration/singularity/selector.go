@@ -0,0 +1,292 @@
+package singularity
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/data-preservation-programs/singularity/client/swagger/models"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+var (
+	_ ProviderSelector = staticSelector{}
+	_ ProviderSelector = weightedRoundRobinSelector{}
+	_ ProviderSelector = lowestPriceSelector{}
+	_ ProviderSelector = successRateSelector{}
+	_ ProviderSelector = diversitySelector{}
+)
+
+type (
+	// ProviderInfo describes a storage provider as a candidate to store a
+	// piece, as known from the provider catalogue and the deals made by
+	// Motion.
+	ProviderInfo struct {
+		Address address.Address
+		// Weight is the relative share of pieces to assign to the provider.
+		// Defaults to 1 if the provider is not in the catalogue.
+		Weight uint
+		// AskPrice is the price per GiB per epoch asked by the provider, or
+		// nil if unknown.
+		AskPrice abi.TokenAmount
+		// Labels are arbitrary labels of the provider, such as its owner or
+		// region.
+		Labels map[string]string
+		// Assigned is the number of pieces assigned to the provider so far.
+		Assigned int
+		// SucceededDeals is the number of deals made with the provider that
+		// became active.
+		SucceededDeals int
+		// FailedDeals is the number of deals made with the provider that were
+		// rejected, failed, or were slashed.
+		FailedDeals int
+	}
+	// ProviderSelector selects the storage providers with which deals are
+	// made for each piece, when pieces are replicated to fewer storage
+	// providers than are configured.
+	ProviderSelector interface {
+		// Select returns up to n of the candidates to store a piece, in order
+		// of preference. Existing are the providers that already store the
+		// piece, if any, none of which is a candidate.
+		Select(candidates, existing []ProviderInfo, n int) []address.Address
+	}
+)
+
+type (
+	staticSelector             struct{}
+	weightedRoundRobinSelector struct{}
+	lowestPriceSelector        struct{}
+	successRateSelector        struct{}
+	diversitySelector          struct {
+		selector ProviderSelector
+		labels   []string
+	}
+)
+
+// NewStaticSelector returns a selector that favours the providers that were
+// assigned the fewest pieces so far, then the order in which the candidates
+// are given, i.e. the order in which storage providers are configured.
+func NewStaticSelector() ProviderSelector {
+	return staticSelector{}
+}
+
+func (staticSelector) Select(candidates, _ []ProviderInfo, n int) []address.Address {
+	return selectSorted(candidates, n, func(a, b ProviderInfo) int { return a.Assigned - b.Assigned })
+}
+
+// NewWeightedRoundRobinSelector returns a selector that assigns pieces to
+// providers in proportion to their weight, by favouring the providers with
+// the fewest pieces assigned relative to their weight.
+func NewWeightedRoundRobinSelector() ProviderSelector {
+	return weightedRoundRobinSelector{}
+}
+
+func (weightedRoundRobinSelector) Select(candidates, _ []ProviderInfo, n int) []address.Address {
+	return selectSorted(candidates, n, func(a, b ProviderInfo) int {
+		// Compares a.Assigned/a.Weight to b.Assigned/b.Weight.
+		return a.Assigned*int(b.Weight) - b.Assigned*int(a.Weight)
+	})
+}
+
+// NewLowestPriceSelector returns a selector that favours the providers with
+// the lowest ask price, then the ones that were assigned the fewest pieces.
+// Providers with unknown ask price come last.
+func NewLowestPriceSelector() ProviderSelector {
+	return lowestPriceSelector{}
+}
+
+func (lowestPriceSelector) Select(candidates, _ []ProviderInfo, n int) []address.Address {
+	return selectSorted(candidates, n, func(a, b ProviderInfo) int {
+		switch {
+		case a.AskPrice.Nil() && b.AskPrice.Nil():
+		case a.AskPrice.Nil():
+			return 1
+		case b.AskPrice.Nil():
+			return -1
+		default:
+			if c := a.AskPrice.Cmp(b.AskPrice.Int); c != 0 {
+				return c
+			}
+		}
+		return a.Assigned - b.Assigned
+	})
+}
+
+// NewSuccessRateSelector returns a selector that favours the providers with
+// the best observed deal success rate, then the ones that were assigned the
+// fewest pieces. Providers with no deal history are given even odds, so that
+// they are tried before the ones that keep failing deals.
+func NewSuccessRateSelector() ProviderSelector {
+	return successRateSelector{}
+}
+
+func (successRateSelector) Select(candidates, _ []ProviderInfo, n int) []address.Address {
+	return selectSorted(candidates, n, func(a, b ProviderInfo) int {
+		// Compares the success rates with add-one smoothing, i.e.
+		// (succeeded+1)/(succeeded+failed+2), in reverse.
+		if c := (b.SucceededDeals+1)*(a.SucceededDeals+a.FailedDeals+2) - (a.SucceededDeals+1)*(b.SucceededDeals+b.FailedDeals+2); c != 0 {
+			return c
+		}
+		return a.Assigned - b.Assigned
+	})
+}
+
+// NewDiversitySelector returns a selector that selects providers in the order
+// of preference of the given selector, skipping the ones that share the value
+// of any of the given labels with a provider that already stores the piece or
+// was selected before. For example, with the label "owner" no two replicas of
+// a piece are stored by providers of the same owner. Providers without a
+// label are not constrained by it.
+func NewDiversitySelector(selector ProviderSelector, labels ...string) ProviderSelector {
+	return diversitySelector{selector: selector, labels: labels}
+}
+
+func (d diversitySelector) Select(candidates, existing []ProviderInfo, n int) []address.Address {
+	byAddress := make(map[address.Address]ProviderInfo, len(candidates))
+	for _, candidate := range candidates {
+		byAddress[candidate.Address] = candidate
+	}
+	taken := make(map[string][]string)
+	take := func(info ProviderInfo) {
+		for _, label := range d.labels {
+			if value := info.Labels[label]; value != "" {
+				taken[label] = append(taken[label], value)
+			}
+		}
+	}
+	for _, info := range existing {
+		take(info)
+	}
+	var selected []address.Address
+	for _, addr := range d.selector.Select(candidates, existing, len(candidates)) {
+		if len(selected) >= n {
+			break
+		}
+		info := byAddress[addr]
+		if slices.ContainsFunc(d.labels, func(label string) bool {
+			value := info.Labels[label]
+			return value != "" && slices.Contains(taken[label], value)
+		}) {
+			continue
+		}
+		take(info)
+		selected = append(selected, addr)
+	}
+	return selected
+}
+
+// selectSorted returns up to n of the candidates, stably sorted by cmp.
+func selectSorted(candidates []ProviderInfo, n int, cmp func(a, b ProviderInfo) int) []address.Address {
+	sorted := slices.Clone(candidates)
+	slices.SortStableFunc(sorted, cmp)
+	selected := make([]address.Address, 0, min(n, len(sorted)))
+	for _, info := range sorted[:max(0, min(n, len(sorted)))] {
+		selected = append(selected, info.Address)
+	}
+	return selected
+}
+
+// catalogueEntry is the description of a storage provider in the provider
+// catalogue file.
+type catalogueEntry struct {
+	Address  string            `json:"address"`
+	Weight   *uint             `json:"weight,omitempty"`
+	AskPrice *abi.TokenAmount  `json:"askPrice,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	// Policies are the names of the storage policies of which the provider is
+	// a candidate, if not configured for them. Defaults to all policies that
+	// replicate selectively. The empty name denotes the default policy.
+	Policies []string `json:"policies,omitempty"`
+}
+
+// candidateOf reports whether the catalogued provider is a candidate of the
+// storage policy.
+func (e catalogueEntry) candidateOf(p *storagePolicy) bool {
+	return len(e.Policies) == 0 || slices.Contains(e.Policies, p.name)
+}
+
+// readProviderCatalogue reads the provider catalogue file, if configured. The
+// catalogue is read anew on every use, so that it can be changed without
+// restarting Motion.
+func (s *Store) readProviderCatalogue() (map[address.Address]catalogueEntry, error) {
+	if s.providerCatalogue == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.providerCatalogue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider catalogue: %w", err)
+	}
+	var entries []catalogueEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode provider catalogue: %w", err)
+	}
+	catalogue := make(map[address.Address]catalogueEntry, len(entries))
+	for _, entry := range entries {
+		addr, err := address.NewFromString(entry.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage provider address %q in provider catalogue: %w", entry.Address, err)
+		}
+		catalogue[addr] = entry
+	}
+	return catalogue, nil
+}
+
+// dealOutcomes counts the deals that succeeded and failed for each provider.
+// Deals that are still pending are not counted.
+func dealOutcomes(deals []*models.ModelDeal) map[string][2]int {
+	outcomes := make(map[string][2]int)
+	for _, deal := range deals {
+		outcome := outcomes[deal.Provider]
+		switch deal.State {
+		case models.ModelDealStateActive, models.ModelDealStateExpired:
+			outcome[0]++
+		case models.ModelDealStateRejected, models.ModelDealStateError, models.ModelDealStateProposalExpired, models.ModelDealStateSlashed:
+			outcome[1]++
+		default:
+			continue
+		}
+		outcomes[deal.Provider] = outcome
+	}
+	return outcomes
+}
+
+// providerInfos describes the candidates for selection of the storage policy,
+// given the provider catalogue and the outcomes of the deals made so far. The
+// candidates are the providers configured for the policy and, if the policy
+// replicates selectively, the other providers in the catalogue that are
+// candidates of it, in ascending order of address. Deals are only made with
+// the providers assigned to each piece by such policies, so that new providers
+// can be added via the catalogue alone.
+func providerInfos(p *storagePolicy, catalogue map[address.Address]catalogueEntry, outcomes map[string][2]int) []ProviderInfo {
+	providers := slices.Clone(p.storageProviders)
+	if p.replicatesSelectively() {
+		var added []address.Address
+		for sp, entry := range catalogue {
+			if entry.candidateOf(p) && !slices.Contains(p.storageProviders, sp) {
+				added = append(added, sp)
+			}
+		}
+		slices.SortFunc(added, func(a, b address.Address) int { return strings.Compare(a.String(), b.String()) })
+		providers = append(providers, added...)
+	}
+	infos := make([]ProviderInfo, 0, len(providers))
+	for _, sp := range providers {
+		info := ProviderInfo{Address: sp, Weight: 1}
+		if entry, ok := catalogue[sp]; ok {
+			if entry.Weight != nil {
+				info.Weight = *entry.Weight
+			}
+			if entry.AskPrice != nil {
+				info.AskPrice = *entry.AskPrice
+			}
+			info.Labels = entry.Labels
+		}
+		outcome := outcomes[sp.String()]
+		info.SucceededDeals, info.FailedDeals = outcome[0], outcome[1]
+		infos = append(infos, info)
+	}
+	return infos
+}
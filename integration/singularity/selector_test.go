@@ -0,0 +1,168 @@
+package singularity
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/data-preservation-programs/singularity/client/swagger/models"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderSelectors(t *testing.T) {
+	providers := mustAddresses(t, "f01000", "f02000", "f03000", "f04000")
+	candidates := []ProviderInfo{
+		{Address: providers[0], Weight: 1, AskPrice: abi.NewTokenAmount(30), Labels: map[string]string{"owner": "a", "region": "eu"}, Assigned: 2, SucceededDeals: 1, FailedDeals: 9},
+		{Address: providers[1], Weight: 3, AskPrice: abi.NewTokenAmount(10), Labels: map[string]string{"owner": "a", "region": "us"}, Assigned: 3, SucceededDeals: 8},
+		{Address: providers[2], Weight: 1, Labels: map[string]string{"owner": "b", "region": "eu"}, Assigned: 1},
+		{Address: providers[3], Weight: 1, AskPrice: abi.NewTokenAmount(20), Assigned: 1, SucceededDeals: 4, FailedDeals: 4},
+	}
+
+	tests := []struct {
+		name     string
+		selector ProviderSelector
+		existing []ProviderInfo
+		n        int
+		want     []address.Address
+	}{
+		{
+			name:     "static",
+			selector: NewStaticSelector(),
+			n:        3,
+			want:     []address.Address{providers[2], providers[3], providers[0]},
+		},
+		{
+			name:     "weighted round-robin",
+			selector: NewWeightedRoundRobinSelector(),
+			n:        2,
+			want:     []address.Address{providers[1], providers[2]},
+		},
+		{
+			name:     "lowest price",
+			selector: NewLowestPriceSelector(),
+			n:        4,
+			want:     []address.Address{providers[1], providers[3], providers[0], providers[2]},
+		},
+		{
+			name:     "success rate",
+			selector: NewSuccessRateSelector(),
+			n:        4,
+			want:     []address.Address{providers[1], providers[2], providers[3], providers[0]},
+		},
+		{
+			name:     "diverse owners",
+			selector: NewDiversitySelector(NewLowestPriceSelector(), "owner"),
+			n:        3,
+			want:     []address.Address{providers[1], providers[3], providers[2]},
+		},
+		{
+			name:     "diverse owners and regions of existing replicas",
+			selector: NewDiversitySelector(NewStaticSelector(), "owner", "region"),
+			existing: []ProviderInfo{{Labels: map[string]string{"owner": "c", "region": "eu"}}},
+			n:        3,
+			want:     []address.Address{providers[3], providers[1]},
+		},
+		{
+			name:     "fewer than candidates",
+			selector: NewStaticSelector(),
+			n:        0,
+			want:     []address.Address{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.want, test.selector.Select(candidates, test.existing, test.n))
+		})
+	}
+}
+
+func TestSelectProviders(t *testing.T) {
+	var candidates []ProviderInfo
+	for _, sp := range mustAddresses(t, "f01000", "f02000", "f03000") {
+		candidates = append(candidates, ProviderInfo{Address: sp, Weight: 1})
+	}
+	candidates[0].Weight = 2
+	candidates[2].Weight = 0
+
+	// Pieces are spread in proportion to weights, and providers with zero
+	// weight are never selected.
+	load := make(map[string]int)
+	var selected []string
+	for i := 0; i < 6; i++ {
+		selected = append(selected, selectProviders(NewWeightedRoundRobinSelector(), candidates, nil, load, 1)...)
+	}
+	first, second := candidates[0].Address.String(), candidates[1].Address.String()
+	require.Equal(t, []string{first, second, first, first, second, first}, selected)
+	require.Equal(t, map[string]int{first: 4, second: 2}, load)
+}
+
+func TestProviderCatalogue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"address": "f01000", "weight": 0},
+		{"address": "f02000", "weight": 2, "askPrice": "5", "labels": {"owner": "a"}},
+		{"address": "f05000", "policies": ["cold"]},
+		{"address": "f04000", "weight": 3}
+	]`), 0600))
+	s, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithStorageProviders(mustAddresses(t, "f01000", "f02000", "f03000")...),
+		WithProviderCatalogue(path),
+	)
+	require.NoError(t, err)
+
+	catalogue, err := s.readProviderCatalogue()
+	require.NoError(t, err)
+	second, third := s.policies[0].storageProviders[1].String(), s.policies[0].storageProviders[2].String()
+	outcomes := dealOutcomes([]*models.ModelDeal{
+		{Provider: second, State: models.ModelDealStateActive},
+		{Provider: second, State: models.ModelDealStateProposed},
+		{Provider: third, State: models.ModelDealStateRejected},
+		{Provider: third, State: models.ModelDealStateExpired},
+		{Provider: third, State: models.ModelDealStateSlashed},
+	})
+	infos := providerInfos(s.policies[0], catalogue, outcomes)
+	require.Equal(t, []ProviderInfo{
+		{Address: mustAddresses(t, "f01000")[0], Weight: 0},
+		{Address: mustAddresses(t, "f02000")[0], Weight: 2, AskPrice: abi.NewTokenAmount(5), Labels: map[string]string{"owner": "a"}, SucceededDeals: 1},
+		{Address: mustAddresses(t, "f03000")[0], Weight: 1, SucceededDeals: 1, FailedDeals: 2},
+	}, infos)
+
+	// Providers in the catalogue but not configured are candidates of the
+	// policies that replicate selectively, unless restricted to other ones.
+	selective, err := NewStore(
+		WithStoreDir(t.TempDir()),
+		WithWalletKey("dummy"),
+		WithStorageProviders(mustAddresses(t, "f01000", "f02000", "f03000")...),
+		WithReplicationFactor(2),
+		WithPolicies(Policy{Name: "cold", StorageProviders: mustAddresses(t, "f01000", "f02000", "f03000"), ReplicationFactor: 2}),
+		WithProviderCatalogue(path),
+	)
+	require.NoError(t, err)
+	infos = providerInfos(selective.policies[0], catalogue, outcomes)
+	require.Len(t, infos, 4)
+	require.Equal(t, infos[:3], providerInfos(s.policies[0], catalogue, outcomes))
+	require.Equal(t, ProviderInfo{Address: mustAddresses(t, "f04000")[0], Weight: 3}, infos[3])
+	infos = providerInfos(selective.policies[1], catalogue, outcomes)
+	require.Len(t, infos, 5)
+	require.Equal(t, mustAddresses(t, "f04000", "f05000"), []address.Address{infos[3].Address, infos[4].Address})
+
+	// Invalid catalogues are reported.
+	require.NoError(t, os.WriteFile(path, []byte(`[{"address": "fish"}]`), 0600))
+	_, err = s.readProviderCatalogue()
+	require.ErrorContains(t, err, "invalid storage provider address")
+}
+
+func mustAddresses(t *testing.T, addrs ...string) []address.Address {
+	t.Helper()
+	var parsed []address.Address
+	for _, addr := range addrs {
+		a, err := address.NewFromString(addr)
+		require.NoError(t, err)
+		parsed = append(parsed, a)
+	}
+	return parsed
+}
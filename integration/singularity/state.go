@@ -0,0 +1,131 @@
+package singularity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// persistedMap is a map persisted in a bucket of the index, one entry per key,
+// so that each change is persisted on its own rather than by rewriting the
+// whole map. It is not safe for concurrent use.
+type persistedMap[V any] struct {
+	index   *blobIndex
+	bucket  []byte
+	entries map[string]V
+}
+
+func newPersistedMap[V any](index *blobIndex, bucket string) *persistedMap[V] {
+	return &persistedMap[V]{
+		index:   index,
+		bucket:  []byte(bucket),
+		entries: make(map[string]V),
+	}
+}
+
+// load loads the persisted entries. Entries persisted as a JSON file at the
+// given path by earlier versions of Motion, if any, are moved into the index
+// first, as decoded by decodeLegacy, or as a JSON object if nil.
+func (pm *persistedMap[V]) load(legacyPath string, decodeLegacy func([]byte) (map[string]V, error)) error {
+	if err := pm.migrate(legacyPath, decodeLegacy); err != nil {
+		return err
+	}
+	return pm.index.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pm.bucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var value V
+			if err := json.Unmarshal(v, &value); err != nil {
+				return fmt.Errorf("could not decode %s entry '%s': %w", pm.bucket, k, err)
+			}
+			pm.entries[string(k)] = value
+			return nil
+		})
+	})
+}
+
+func (pm *persistedMap[V]) migrate(legacyPath string, decodeLegacy func([]byte) (map[string]V, error)) error {
+	data, err := os.ReadFile(legacyPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s file: %w", pm.bucket, err)
+	}
+	var legacy map[string]V
+	if decodeLegacy != nil {
+		legacy, err = decodeLegacy(data)
+	} else {
+		err = json.Unmarshal(data, &legacy)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %s file: %w", pm.bucket, err)
+	}
+	if err := pm.index.db.Update(func(tx *bolt.Tx) error {
+		for key, value := range legacy {
+			if err := pm.put(tx, key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to migrate %s file to index: %w", pm.bucket, err)
+	}
+	if err := os.Remove(legacyPath); err != nil {
+		return fmt.Errorf("failed to remove migrated %s file: %w", pm.bucket, err)
+	}
+	logger.Infow("Migrated state file to index", "path", legacyPath, "entries", len(legacy))
+	return nil
+}
+
+// get returns the entry with the given key, if any.
+func (pm *persistedMap[V]) get(key string) (V, bool) {
+	value, ok := pm.entries[key]
+	return value, ok
+}
+
+// set sets and persists the entry with the given key.
+func (pm *persistedMap[V]) set(key string, value V) error {
+	if err := pm.index.db.Update(func(tx *bolt.Tx) error {
+		return pm.put(tx, key, value)
+	}); err != nil {
+		return fmt.Errorf("failed to persist %s entry: %w", pm.bucket, err)
+	}
+	pm.entries[key] = value
+	return nil
+}
+
+// delete deletes the entry with the given key, if any.
+func (pm *persistedMap[V]) delete(key string) error {
+	if _, ok := pm.entries[key]; !ok {
+		return nil
+	}
+	if err := pm.index.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pm.bucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(key))
+	}); err != nil {
+		return fmt.Errorf("failed to delete %s entry: %w", pm.bucket, err)
+	}
+	delete(pm.entries, key)
+	return nil
+}
+
+func (pm *persistedMap[V]) put(tx *bolt.Tx, key string, value V) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	bucket, err := tx.CreateBucketIfNotExists(pm.bucket)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), data)
+}
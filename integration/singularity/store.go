@@ -7,7 +7,10 @@ import (
 	"io"
 	"math/big"
 	"net/http"
+	"os"
 	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +20,7 @@ import (
 	"github.com/data-preservation-programs/singularity/client/swagger/http/deal_schedule"
 	"github.com/data-preservation-programs/singularity/client/swagger/http/file"
 	"github.com/data-preservation-programs/singularity/client/swagger/http/job"
+	"github.com/data-preservation-programs/singularity/client/swagger/http/piece"
 	"github.com/data-preservation-programs/singularity/client/swagger/http/preparation"
 	"github.com/data-preservation-programs/singularity/client/swagger/http/storage"
 	"github.com/data-preservation-programs/singularity/client/swagger/http/wallet"
@@ -26,22 +30,49 @@ import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/builtin"
 	"github.com/filecoin-project/motion/blob"
+	"github.com/filecoin-project/motion/tracing"
 	"github.com/gotidy/ptr"
 	"github.com/ipfs/go-log/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var logger = log.Logger("motion/integration/singularity")
+var (
+	logger = log.Logger("motion/integration/singularity")
+	tracer = otel.Tracer("github.com/filecoin-project/motion/integration/singularity")
+)
+
+var (
+	_ blob.Store          = (*Store)(nil)
+	_ blob.PassThroughGet = (*Store)(nil)
+	_ blob.Lister         = (*Store)(nil)
+	_ blob.Remover        = (*Store)(nil)
+	_ blob.HealthChecker  = (*Store)(nil)
+)
 
 type Store struct {
 	*options
 	local            *blob.LocalStore
-	idMap            *idMap
+	index            *blobIndex
 	cleanupScheduler *cleanupScheduler
-	sourceName       string
-	toPack           chan uint64
-	closing          chan struct{}
-	closed           sync.WaitGroup
-	forcePack        *time.Ticker
+	// policies are the storage policies with which blobs are stored, the
+	// first of which is the default one.
+	policies      []*storagePolicy
+	repairs       *repairLog
+	renewals      *renewalLog
+	holds         *scheduleHolds
+	toPack        chan packRequest
+	closing       chan struct{}
+	closed        sync.WaitGroup
+	forcePack     *time.Ticker
+	dealCollector prometheus.Collector
+	// localCollector reports the disk usage of the local store.
+	localCollector prometheus.Collector
+	// walletAddress is the address of the wallet attached to the preparations,
+	// known once the store is started.
+	walletAddress string
 }
 
 func NewStore(o ...Option) (*Store, error) {
@@ -52,28 +83,52 @@ func NewStore(o ...Option) (*Store, error) {
 
 	cleanupSchedulerCfg := cleanupSchedulerConfig{
 		interval: opts.cleanupInterval,
+		listener: opts.cleanupListener,
+	}
+
+	index, err := openBlobIndex(filepath.Join(opts.storeDir, "index.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := newStoragePolicies(opts, index)
+	if err != nil {
+		_ = index.close()
+		return nil, fmt.Errorf("failed to init storage policies: %w", err)
 	}
 
 	store := &Store{
-		options:    opts,
-		local:      blob.NewLocalStore(opts.storeDir, blob.WithMinFreeSpace(opts.minFreeSpace)),
-		idMap:      newIDMap(opts.storeDir),
-		sourceName: "source",
-		toPack:     make(chan uint64, 1),
-		closing:    make(chan struct{}),
-		forcePack:  time.NewTicker(opts.forcePackAfter),
+		options:   opts,
+		local:     blob.NewLocalStore(opts.storeDir, blob.WithMinFreeSpace(opts.minFreeSpace)),
+		index:     index,
+		policies:  policies,
+		repairs:   newRepairLog(index, opts.storeDir),
+		renewals:  newRenewalLog(index, opts.storeDir),
+		holds:     newScheduleHolds(index, opts.storeDir),
+		toPack:    make(chan packRequest, 1),
+		closing:   make(chan struct{}),
+		forcePack: time.NewTicker(opts.forcePackAfter),
 	}
 
-	store.cleanupScheduler = newCleanupScheduler(cleanupSchedulerCfg, store.local, store.hasDealForAllProviders)
+	store.cleanupScheduler = newCleanupScheduler(cleanupSchedulerCfg, store.local, store.isReplicated)
+	store.dealCollector = dealCollector{store: store}
+	store.localCollector = store.local.Collector()
 
 	return store, nil
 }
 
-func (s *Store) initPreparation(ctx context.Context) (*models.ModelPreparation, error) {
+// packRequest is a request to prepare a file pushed to the preparation of a
+// storage policy for packing.
+type packRequest struct {
+	fileID uint64
+	policy *storagePolicy
+}
+
+func (s *Store) initPreparation(ctx context.Context, p *storagePolicy) (*models.ModelPreparation, error) {
 	createSourceStorageRes, err := s.singularityClient.Storage.CreateLocalStorage(&storage.CreateLocalStorageParams{
 		Context: ctx,
 		Request: &models.StorageCreateLocalStorageRequest{
-			Name: s.sourceName,
+			Name: p.sourceName,
 			Path: s.local.Dir(),
 		},
 	})
@@ -89,8 +144,8 @@ func (s *Store) initPreparation(ctx context.Context) (*models.ModelPreparation,
 		Context: ctx,
 		Request: &models.DataprepCreateRequest{
 			MaxSize:        &s.maxCarSize,
-			Name:           &s.preparationName,
-			SourceStorages: []string{s.sourceName},
+			Name:           &p.preparationName,
+			SourceStorages: []string{p.sourceName},
 		},
 	})
 	if err != nil {
@@ -102,7 +157,12 @@ func (s *Store) initPreparation(ctx context.Context) (*models.ModelPreparation,
 }
 
 func (s *Store) Start(ctx context.Context) error {
-	logger := logger.With("preparation", s.preparationName)
+	if err := s.migrateIDFiles(); err != nil {
+		return err
+	}
+	if err := s.checkIndex(ctx); err != nil {
+		return err
+	}
 
 	// Set the identity to Motion for tracking purpose
 	_, err := s.singularityClient.Admin.SetIdentity(&admin.SetIdentityParams{
@@ -115,7 +175,7 @@ func (s *Store) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to set motion identity: %w (are you using Singularity v0.5.4+?)", err)
 	}
 
-	// List out preparations and see if one with the configured name exists
+	// List out preparations to see which of the configured ones exist
 	listPreparationsRes, err := s.singularityClient.Preparation.ListPreparations(&preparation.ListPreparationsParams{
 		Context: ctx,
 	})
@@ -123,21 +183,6 @@ func (s *Store) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to list preparations: %w", err)
 	}
 
-	var preparation *models.ModelPreparation
-	for _, preparationCmp := range listPreparationsRes.Payload {
-		if preparationCmp.Name == s.preparationName {
-			preparation = preparationCmp
-			break
-		}
-	}
-	if preparation == nil {
-		// If no preparation was found, initialize it
-		_, err = s.initPreparation(ctx)
-		if err != nil {
-			return fmt.Errorf("first-time preparation initialization failed: %w", err)
-		}
-	}
-
 	// Ensure default wallet is imported to singularity
 	listWalletsRes, err := s.singularityClient.Wallet.ListWallets(&wallet.ListWalletsParams{
 		Context: ctx,
@@ -167,11 +212,112 @@ func (s *Store) Start(ctx context.Context) error {
 
 		wlt = importWalletRes.Payload
 	}
+	s.walletAddress = wlt.Address
+
+	if s.walletMonitor != nil {
+		if err := s.holds.read(); err != nil {
+			return err
+		}
+		// Check the wallet before schedules are created, so that they are held
+		// right away if the wallet is low on funds or DataCap.
+		if err := s.checkWallet(ctx); err != nil {
+			logger.Warnw("Failed to check wallet", "err", err)
+		}
+	}
+
+	for _, p := range s.policies {
+		if err := s.startPolicy(ctx, p, listPreparationsRes.Payload, wlt); err != nil {
+			return err
+		}
+	}
+	if err := s.pushPending(ctx); err != nil {
+		return err
+	}
+
+	if s.walletMonitor != nil {
+		if err := s.holdDeals(ctx); err != nil {
+			logger.Warnw("Failed to hold deal schedules", "err", err)
+		}
+		s.closed.Add(1)
+		go s.runWalletCheck()
+	}
+
+	s.cleanupScheduler.start(ctx)
+
+	if err := prometheus.Register(s.dealCollector); err != nil {
+		logger.Warnw("Failed to register deal metrics", "err", err)
+	}
+	if err := prometheus.Register(s.localCollector); err != nil {
+		logger.Warnw("Failed to register local store metrics", "err", err)
+	}
+
+	s.closed.Add(1)
+	go s.runPreparationJobs()
+
+	s.closed.Add(1)
+	go s.runPackCheck()
+
+	var replicatesSelectively, replicates bool
+	for _, p := range s.policies {
+		if p.replicatesSelectively() {
+			if err := p.replicas.read(); err != nil {
+				return err
+			}
+			logger.Infow("Replicating pieces to a subset of storage providers", "preparation", p.preparationName, "replicationFactor", p.replicationFactor)
+			replicatesSelectively = true
+		}
+		replicates = replicates || p.replicationFactor > 0
+	}
+	if replicatesSelectively {
+		s.closed.Add(1)
+		go s.runReplication()
+	}
+
+	if replicates {
+		if err := s.repairs.read(); err != nil {
+			return err
+		}
+		s.closed.Add(1)
+		go s.runRepair()
+	}
+
+	if replicates && s.renewalWindow > 0 {
+		if err := s.renewals.read(); err != nil {
+			return err
+		}
+		s.closed.Add(1)
+		go s.runRenewal()
+	}
+
+	return nil
+}
+
+// startPolicy makes sure that the preparation of the storage policy exists,
+// that the wallet is attached to it, and that deal schedules are set up for
+// the storage providers of the policy. The given preparations are the ones
+// that exist already.
+func (s *Store) startPolicy(ctx context.Context, p *storagePolicy, preparations []*models.ModelPreparation, wlt *models.ModelWallet) error {
+	logger := logger.With("preparation", p.preparationName)
+
+	var preparation *models.ModelPreparation
+	for _, preparationCmp := range preparations {
+		if preparationCmp.Name == p.preparationName {
+			preparation = preparationCmp
+			break
+		}
+	}
+	if preparation == nil {
+		// If no preparation was found, initialize it
+		_, err := s.initPreparation(ctx, p)
+		if err != nil {
+			return fmt.Errorf("first-time preparation initialization failed: %w", err)
+		}
+	}
 
 	// Ensure wallet is assigned to preparation
 	listAttachedWalletsRes, err := s.singularityClient.WalletAssociation.ListAttachedWallets(&wallet_association.ListAttachedWalletsParams{
 		Context: ctx,
-		ID:      s.preparationName,
+		ID:      p.preparationName,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to list attached wallets: %w", err)
@@ -188,7 +334,7 @@ func (s *Store) Start(ctx context.Context) error {
 		logger.Info("Wallet was not found. Creating wallet")
 		if attachWalletRes, err := s.singularityClient.WalletAssociation.AttachWallet(&wallet_association.AttachWalletParams{
 			Context: ctx,
-			ID:      s.preparationName,
+			ID:      p.preparationName,
 			Wallet:  wlt.Address,
 		}); err != nil {
 			return fmt.Errorf("failed to add wallet to preparation: %w", err)
@@ -198,91 +344,39 @@ func (s *Store) Start(ctx context.Context) error {
 	}
 	// Ensure schedules are created
 	// TODO: handle config changes for replication -- singularity currently has no modify schedule endpoint
-	listPreparationSchedulesRes, err := s.singularityClient.DealSchedule.ListPreparationSchedules(&deal_schedule.ListPreparationSchedulesParams{
-		Context: ctx,
-		ID:      s.preparationName,
-	})
-
-	switch {
-	case err == nil:
-		logger.Infow("Found existing schedules for preparation", "count", len(listPreparationSchedulesRes.Payload))
-	case strings.Contains(err.Error(), "404"):
-		logger.Info("Found no schedules for preparation")
-	default:
-		return fmt.Errorf("failed to list schedules for preparation: %w", err)
+	schedules, err := s.listSchedules(ctx, p)
+	if err != nil {
+		return err
 	}
+	logger.Infow("Found existing schedules for preparation", "count", len(schedules))
 
-	pricePerGBEpoch, _ := (new(big.Rat).SetFrac(s.pricePerGiBEpoch.Int, big.NewInt(int64(1e18)))).Float64()
-	pricePerGB, _ := (new(big.Rat).SetFrac(s.pricePerGiB.Int, big.NewInt(int64(1e18)))).Float64()
-	pricePerDeal, _ := (new(big.Rat).SetFrac(s.pricePerDeal.Int, big.NewInt(int64(1e18)))).Float64()
-
-	logger.Infof("Checking %v storage providers", len(s.storageProviders))
-	for _, sp := range s.storageProviders {
+	logger.Infof("Checking %v storage providers", len(p.storageProviders))
+	for _, sp := range p.storageProviders {
 		logger.Infof("Checking storage provider %s", sp)
-		var foundSchedule *models.ModelSchedule
 		logger := logger.With("provider", sp)
-		for _, schd := range listPreparationSchedulesRes.Payload {
-			scheduleAddr, err := address.NewFromString(schd.Provider)
-			if err == nil && sp == scheduleAddr {
-				foundSchedule = schd
-				break
-			}
-		}
-		if foundSchedule != nil {
+		if foundSchedule, ok := schedules[sp]; ok {
 			// If schedule was found, update it
 			logger.Infow("Schedule found for provider. Updating with latest settings", "id", foundSchedule.ID)
 			_, err := s.singularityClient.DealSchedule.UpdateSchedule(&deal_schedule.UpdateScheduleParams{
 				Context: ctx,
 				ID:      foundSchedule.ID,
-				Body: &models.ScheduleUpdateRequest{
-					PricePerGbEpoch:       pricePerGBEpoch,
-					PricePerGb:            pricePerGB,
-					PricePerDeal:          pricePerDeal,
-					Verified:              &s.verifiedDeal,
-					Ipni:                  &s.ipniAnnounce,
-					KeepUnsealed:          &s.keepUnsealed,
-					StartDelay:            ptr.String(strconv.Itoa(int(s.dealStartDelay)*builtin.EpochDurationSeconds) + "s"),
-					Duration:              ptr.String(strconv.Itoa(int(s.dealDuration)*builtin.EpochDurationSeconds) + "s"),
-					ScheduleCron:          s.scheduleCron,
-					ScheduleCronPerpetual: s.scheduleCronPerpetual,
-					ScheduleDealNumber:    int64(s.scheduleDealNumber),
-					TotalDealNumber:       int64(s.totalDealNumber),
-					ScheduleDealSize:      s.scheduleDealSize,
-					TotalDealSize:         s.totalDealSize,
-					MaxPendingDealSize:    s.maxPendingDealSize,
-					MaxPendingDealNumber:  int64(s.maxPendingDealNumber),
-					URLTemplate:           s.scheduleUrlTemplate,
-				},
+				Body:    s.newScheduleUpdateRequest(p),
 			})
 			if err != nil {
 				return fmt.Errorf("failed to update schedule for provider: %w", err)
 			}
+			if p.replicatesSelectively() && len(foundSchedule.AllowedPieceCids) == 0 {
+				logger.Warnw("Schedule for provider allows all pieces, and will make deals regardless of the replication factor", "id", foundSchedule.ID)
+			}
+		} else if p.replicatesSelectively() {
+			// Schedules are created once pieces are assigned to the provider.
+			logger.Info("Schedule not found for provider. Deferring creation until pieces are assigned to it")
 		} else {
 			// Otherwise, create it
 			logger.Info("Schedule not found for provider. Creating schedule")
 			if createScheduleRes, err := s.singularityClient.DealSchedule.CreateSchedule(&deal_schedule.CreateScheduleParams{
-				Context: ctx,
-				Schedule: &models.ScheduleCreateRequest{
-					Preparation:           s.preparationName,
-					Provider:              sp.String(),
-					PricePerGbEpoch:       pricePerGBEpoch,
-					PricePerGb:            pricePerGB,
-					PricePerDeal:          pricePerDeal,
-					Verified:              &s.verifiedDeal,
-					Ipni:                  &s.ipniAnnounce,
-					KeepUnsealed:          &s.keepUnsealed,
-					StartDelay:            ptr.String(strconv.Itoa(int(s.dealStartDelay)*builtin.EpochDurationSeconds) + "s"),
-					Duration:              ptr.String(strconv.Itoa(int(s.dealDuration)*builtin.EpochDurationSeconds) + "s"),
-					ScheduleCron:          s.scheduleCron,
-					ScheduleCronPerpetual: s.scheduleCronPerpetual,
-					ScheduleDealNumber:    int64(s.scheduleDealNumber),
-					TotalDealNumber:       int64(s.totalDealNumber),
-					ScheduleDealSize:      s.scheduleDealSize,
-					TotalDealSize:         s.totalDealSize,
-					MaxPendingDealSize:    s.maxPendingDealSize,
-					MaxPendingDealNumber:  int64(s.maxPendingDealNumber),
-					URLTemplate:           s.scheduleUrlTemplate,
-				},
+				Context:  ctx,
+				Schedule: s.newScheduleCreateRequest(p, sp, nil),
 			}); err != nil {
 				return fmt.Errorf("failed to create schedule for provider: %w", err)
 			} else {
@@ -290,12 +384,6 @@ func (s *Store) Start(ctx context.Context) error {
 			}
 		}
 	}
-
-	s.cleanupScheduler.start(ctx)
-
-	s.closed.Add(1)
-	go s.runPreparationJobs()
-
 	return nil
 }
 
@@ -313,45 +401,58 @@ func (s *Store) runPreparationJobs() {
 			return
 
 		// If a new file came in, prepare it for packing, and mark the source
-		// ready to pack if the threshold is reached. Also reset the timer.
-		case fileID := <-s.toPack:
+		// of its policy ready to pack if the threshold is reached. Also reset
+		// the timer.
+		case req := <-s.toPack:
+			metricPackQueueDepth.Dec()
+			fileID := req.fileID
+			spanCtx, span := tracer.Start(ctx, "singularity.PrepareToPackFile", trace.WithAttributes(attribute.Int64("singularity.file_id", int64(fileID))))
 			prepareToPackFileRes, err := s.singularityClient.File.PrepareToPackFile(&file.PrepareToPackFileParams{
-				Context: ctx,
+				Context: spanCtx,
 				ID:      int64(fileID),
 			})
+			tracing.End(span, err)
 			if err != nil {
 				logger.Errorw("Failed to prepare to pack file", "fileID", fileID, "error", err)
 				continue
 			}
 			logger.Infow("Prepared file for packing", "fileID", fileID)
 			if prepareToPackFileRes.Payload > s.packThreshold {
-				if err := s.prepareToPackSource(ctx); err != nil {
-					logger.Errorw("Failed to prepare to pack source", "error", err)
+				err := s.prepareToPackSource(ctx, req.policy)
+				metricPackTriggers.WithLabelValues("threshold", resultLabel(err)).Inc()
+				if err != nil {
+					logger.Errorw("Failed to prepare to pack source", "preparation", req.policy.preparationName, "error", err)
 					continue
 				}
 			}
 			s.resetForcePackTimer()
 
 		// If forced pack message comes through (e.g. from pack threshold max
-		// wait time being exceeded), prepare to pack source immediately
+		// wait time being exceeded), prepare to pack the sources of all
+		// policies immediately
 		case <-s.forcePack.C:
 			logger.Infof("Pack threshold not met after max wait time of %s, forcing pack of any pending data", s.forcePackAfter)
-			if err := s.prepareToPackSource(ctx); err != nil {
-				logger.Errorw("Failed to prepare to pack source (forced)", "error", err)
-				continue
+			for _, p := range s.policies {
+				err := s.prepareToPackSource(ctx, p)
+				metricPackTriggers.WithLabelValues("timeout", resultLabel(err)).Inc()
+				if err != nil {
+					logger.Errorw("Failed to prepare to pack source (forced)", "preparation", p.preparationName, "error", err)
+				}
 			}
 		}
 	}
 }
 
-// Marks outstanding pack jobs as ready to go so CAR files can be made, and
-// updates the last pack time
-func (s *Store) prepareToPackSource(ctx context.Context) error {
+// Marks outstanding pack jobs of the policy source as ready to go so CAR files
+// can be made, and updates the last pack time
+func (s *Store) prepareToPackSource(ctx context.Context, p *storagePolicy) error {
+	ctx, span := tracer.Start(ctx, "singularity.PrepareToPackSource", trace.WithAttributes(attribute.String("singularity.preparation", p.preparationName)))
 	_, err := s.singularityClient.Job.PrepareToPackSource(&job.PrepareToPackSourceParams{
 		Context: ctx,
-		ID:      s.preparationName,
-		Name:    s.sourceName,
+		ID:      p.preparationName,
+		Name:    p.sourceName,
 	})
+	tracing.End(span, err)
 
 	s.resetForcePackTimer()
 
@@ -364,6 +465,8 @@ func (s *Store) resetForcePackTimer() {
 
 func (s *Store) Shutdown(ctx context.Context) error {
 	close(s.closing)
+	prometheus.Unregister(s.dealCollector)
+	prometheus.Unregister(s.localCollector)
 
 	done := make(chan struct{})
 	go func() {
@@ -380,88 +483,178 @@ func (s *Store) Shutdown(ctx context.Context) error {
 
 	s.forcePack.Stop()
 
+	if err := s.index.close(); err != nil {
+		return fmt.Errorf("failed to close blob index: %w", err)
+	}
+
 	logger.Info("Singularity store shut down")
 
 	return nil
 }
 
-func (s *Store) Put(ctx context.Context, reader io.Reader) (*blob.Descriptor, error) {
-	desc, err := s.local.Put(ctx, reader)
+// Put stores the blob locally, and pushes it to the Singularity preparation of
+// the storage policy requested in its metadata, if any, or of the default
+// policy otherwise. Blobs requesting a policy that is not configured are
+// rejected with blob.ErrUnknownPolicy.
+func (s *Store) Put(ctx context.Context, reader io.Reader, o ...blob.PutOption) (*blob.Descriptor, error) {
+	p, ok := s.policy(blob.NewPutOptions(o...).Metadata.Policy)
+	if !ok {
+		return nil, blob.ErrUnknownPolicy
+	}
+	// The local store digests the content and verifies it against the expected
+	// size and digests, if any. The digest and metadata are then kept in the
+	// index, since the local copy is removed once deals are made.
+	desc, err := s.local.Put(ctx, reader, o...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to put file locally: %w", err)
 	}
-	filePath := desc.ID.String() + ".bin"
-	pushFileRes, err := s.singularityClient.File.PushFile(&file.PushFileParams{
-		Context: ctx,
-		File:    &models.FileInfo{Path: filePath},
-		ID:      s.preparationName,
-		Name:    s.sourceName,
-	})
+	// The blob is indexed as pending before it is pushed, so that it is pushed
+	// again on startup if Motion stops midway, rather than left untracked.
+	if err := s.index.put(desc.ID, blobInfo{
+		Metadata:  desc.Metadata,
+		SHA256:    desc.SHA256,
+		RootCID:   desc.RootCID,
+		Size:      desc.Size,
+		CreatedAt: desc.ModificationTime,
+		State:     blobStatePending,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to index blob: %w", err)
+	}
+	fileID, err := s.pushFile(ctx, desc.ID, p)
 	if err != nil {
-		return nil, fmt.Errorf("error creating singularity entry at %s: %w", filePath, err)
+		if err := s.index.remove(desc.ID); err != nil {
+			logger.Warnw("Failed to remove blob that could not be pushed from index", "id", desc.ID.String(), "err", err)
+		}
+		if err := s.local.Remove(ctx, desc.ID); err != nil {
+			logger.Warnw("Failed to remove local copy of blob that could not be pushed", "id", desc.ID.String(), "err", err)
+		}
+		return nil, err
 	}
+
+	// The send blocks while the preparation jobs are busy preparing the
+	// previously pushed files, hence traced separately. The blob is stored
+	// regardless of whether the send is cancelled, and packed at the latest
+	// once packing is forced.
+	_, span := tracer.Start(ctx, "singularity.EnqueuePack", trace.WithAttributes(attribute.Int64("singularity.file_id", fileID)))
+	metricPackQueueDepth.Inc()
 	select {
 	case <-ctx.Done():
+		metricPackQueueDepth.Dec()
+		tracing.End(span, ctx.Err())
 		return nil, ctx.Err()
-	case s.toPack <- uint64(pushFileRes.Payload.ID):
+	case s.toPack <- packRequest{fileID: uint64(fileID), policy: p}:
 	}
+	span.End()
 
-	s.idMap.insert(desc.ID, pushFileRes.Payload.ID)
-
-	logger.Infow("Stored blob successfully", "id", desc.ID.String(), "size", desc.Size, "singularityFileID", pushFileRes.Payload.ID)
+	logger.With(tracing.LogFields(ctx)...).Infow("Stored blob successfully", "id", desc.ID.String(), "size", desc.Size, "singularityFileID", fileID)
 
 	return desc, nil
 }
 
-func (s *Store) PassGet(w http.ResponseWriter, r *http.Request, id blob.ID) {
-
-	fileID, err := s.idMap.get(id)
+// pushFile pushes the local copy of the pending blob to the Singularity
+// preparation of the given storage policy, and records the Singularity file to
+// which it was pushed in the index, after which the blob is stored. The ID of
+// the Singularity file is returned.
+func (s *Store) pushFile(ctx context.Context, id blob.ID, p *storagePolicy) (int64, error) {
+	filePath := id.String() + ".bin"
+	spanCtx, span := tracer.Start(ctx, "singularity.PushFile", trace.WithAttributes(attribute.String("motion.blob.id", id.String())))
+	pushFileRes, err := s.singularityClient.File.PushFile(&file.PushFileParams{
+		Context: spanCtx,
+		File:    &models.FileInfo{Path: filePath},
+		ID:      p.preparationName,
+		Name:    p.sourceName,
+	})
 	if err != nil {
-		if errors.Is(err, blob.ErrBlobNotFound) {
-			http.Error(w, "", http.StatusNotFound)
-			return
-		}
+		tracing.End(span, err)
+		return 0, fmt.Errorf("error creating singularity entry at %s: %w", filePath, err)
+	}
+	fileID := pushFileRes.Payload.ID
+	span.SetAttributes(attribute.Int64("singularity.file_id", fileID))
+	tracing.End(span, nil)
 
-		logger.Errorw("Could not get singularity file ID", "err", err)
-		http.Error(w, "", http.StatusInternalServerError)
-		return
+	if err := s.index.update(id, func(info *blobInfo) {
+		info.FileID = fileID
+		info.State = blobStateStored
+	}); err != nil {
+		return 0, fmt.Errorf("failed to index blob: %w", err)
 	}
+	return fileID, nil
+}
 
-	params := &file.RetrieveFileParams{
-		Context: r.Context(),
-		ID:      int64(fileID),
+// pushPending pushes the blobs left pending by a previous run, i.e. whose push
+// to Singularity was interrupted, and removes those whose local copy is gone.
+// Blobs that fail to be pushed are left pending, since Singularity rejects
+// files that were already pushed without telling which file they are.
+func (s *Store) pushPending(ctx context.Context) error {
+	ids, err := s.index.pending()
+	if err != nil {
+		return err
 	}
-	rangeHeader := r.Header.Get("Range")
-	if rangeHeader != "" {
-		params.Range = ptr.String(rangeHeader)
+	for _, id := range ids {
+		logger := logger.With("id", id.String())
+		info, err := s.index.get(id)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(s.localPath(id)); errors.Is(err, os.ErrNotExist) {
+			logger.Warn("Removing pending blob without a local copy from index")
+			if err := s.index.remove(id); err != nil {
+				return err
+			}
+			continue
+		}
+		p, ok := s.policy(info.Policy)
+		if !ok {
+			logger.Warnw("Pending blob requests a storage policy that is no longer configured", "policy", info.Policy)
+			continue
+		}
+		fileID, err := s.pushFile(ctx, id, p)
+		if err != nil {
+			logger.Warnw("Failed to push pending blob", "err", err)
+			continue
+		}
+		logger.Infow("Pushed pending blob", "singularityFileID", fileID)
 	}
+	return nil
+}
 
-	_, _, err = s.singularityClient.File.RetrieveFile(params, w)
+// PassGet serves the blob directly from Singularity, as http.ServeContent
+// would: single and multiple byte ranges are served as partial content, and
+// conditional requests are evaluated against the modification time of the blob
+// and the ETag response header, if set by the caller. Each byte range is
+// retrieved from Singularity separately, and only once it is to be served.
+func (s *Store) PassGet(w http.ResponseWriter, r *http.Request, id blob.ID) {
+	logger := logger.With(tracing.LogFields(r.Context())...).With("id", id.String())
+	singularityFile, desc, err := s.describeFile(r.Context(), id)
 	if err != nil {
-		logger.Errorw("Failed to retrieve file slice", "err", err, "id", id.String(), "fileID", fileID)
-		if strings.Contains(err.Error(), "404") {
+		if errors.Is(err, blob.ErrBlobNotFound) {
 			http.Error(w, "", http.StatusNotFound)
 			return
 		}
+
+		logger.Errorw("Could not describe singularity file", "err", err)
 		http.Error(w, "", http.StatusInternalServerError)
 		return
 	}
-	logger.Infow("Retrieved file", "id", id.String())
+
+	fileID := singularityFile.ID
+	content := newRetrievalSeeker(r.Context(), s.singularityClient, fileID, int64(desc.Size))
+	defer content.Close()
+	http.ServeContent(w, r, "", desc.ModificationTime, content)
+	logger.Infow("Retrieved file", "fileID", fileID)
 }
 
 func (s *Store) Get(ctx context.Context, id blob.ID) (io.ReadSeekCloser, error) {
-	fileID, err := s.idMap.get(id)
+	info, err := s.storedInfo(id)
 	if err != nil {
 		if errors.Is(err, blob.ErrBlobNotFound) {
 			return nil, blob.ErrBlobNotFound
 		}
 		return nil, fmt.Errorf("could not get singularity file ID: %w", err)
 	}
+	fileID := info.FileID
 
-	getFileRes, err := s.singularityClient.File.GetFile(&file.GetFileParams{
-		Context: ctx,
-		ID:      int64(fileID),
-	})
+	getFileRes, err := s.getFile(ctx, fileID)
 	if err != nil {
 		if strings.Contains(err.Error(), "404") {
 			return nil, blob.ErrBlobNotFound
@@ -470,52 +663,61 @@ func (s *Store) Get(ctx context.Context, id blob.ID) (io.ReadSeekCloser, error)
 		return nil, fmt.Errorf("error loading singularity entry: %w", err)
 	}
 
-	return NewReader(s.singularityClient, uint64(fileID), getFileRes.Payload.Size), nil
+	reader := NewReader(s.singularityClient, uint64(fileID), getFileRes.Payload.Size)
+	// Retrievals by the reader outlive the request context, but are traced as
+	// part of the request regardless.
+	reader.traceCtx = trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+	return reader, nil
 }
 
+// Describe describes the blob, along with its packs and deals. The CARs into
+// which the blob was packed are described as recorded in the index, and are
+// unknown until recorded; see checkPacks.
 func (s *Store) Describe(ctx context.Context, id blob.ID) (*blob.Descriptor, error) {
-	fileID, err := s.idMap.get(id)
+	info, err := s.storedInfo(id)
 	if err != nil {
 		if errors.Is(err, blob.ErrBlobNotFound) {
 			return nil, blob.ErrBlobNotFound
 		}
 		return nil, fmt.Errorf("could not get Singularity file ID: %w", err)
 	}
-
-	getFileRes, err := s.singularityClient.File.GetFile(&file.GetFileParams{
-		Context: ctx,
-		ID:      int64(fileID),
-	})
-	if err != nil {
-		// TODO(@elijaharita): this is not very robust, but is there even a better way?
-		if strings.Contains(err.Error(), "404") {
-			return nil, blob.ErrBlobNotFound
-		}
-		return nil, fmt.Errorf("error loading singularity entry: %w", err)
-	}
-	var decoded blob.ID
-	err = decoded.Decode(strings.TrimSuffix(path.Base(getFileRes.Payload.Path), path.Ext(getFileRes.Payload.Path)))
+	singularityFile, descriptor, err := s.describeFile(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	descriptor := &blob.Descriptor{
-		ID:               id,
-		Size:             uint64(getFileRes.Payload.Size),
-		ModificationTime: time.Unix(0, getFileRes.Payload.LastModifiedNano),
+	// Blobs stored with a policy that is no longer configured are not
+	// replicated any further.
+	if p, ok := s.policy(descriptor.Metadata.Policy); ok {
+		descriptor.TargetReplicas = p.replicationFactor
 	}
-	getFileDealsRes, err := s.singularityClient.File.GetFileDeals(&file.GetFileDealsParams{
-		Context: ctx,
-		ID:      int64(fileID),
-	})
+	getFileDealsRes, err := s.getFileDeals(ctx, singularityFile.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(getFileDealsRes.Payload) == 0 {
-		return descriptor, nil
+	payloadCIDs := make(map[string]string)
+	for _, pack := range info.Packs {
+		payloadCIDs[pack.PieceCID] = pack.PayloadCID
+	}
+	for _, fileRange := range singularityFile.FileRanges {
+		if fileRange.JobID == 0 {
+			continue
+		}
+		pack := blob.Pack{
+			JobID:  fileRange.JobID,
+			Offset: uint64(fileRange.Offset),
+			Length: uint64(fileRange.Length),
+		}
+		if i := slices.IndexFunc(info.Packs, func(recorded packInfo) bool {
+			return recorded.JobID == pack.JobID && recorded.Offset == pack.Offset
+		}); i != -1 {
+			pack = blob.Pack(info.Packs[i])
+		}
+		descriptor.Packs = append(descriptor.Packs, pack)
 	}
 
-	replicas := make([]blob.Replica, 0, len(getFileDealsRes.Payload))
+	// Group the deals by provider, in the order in which providers first appear.
+	replicaIndex := make(map[string]int)
 	for _, deal := range getFileDealsRes.Payload {
 		updatedAt, err := time.Parse("2006-01-02 15:04:05-07:00", deal.LastVerifiedAt)
 		if err != nil {
@@ -526,49 +728,579 @@ func (s *Store) Describe(ctx context.Context, id blob.ID) (*blob.Descriptor, err
 			LastUpdated: updatedAt,
 			PieceCID:    deal.PieceCid,
 			Status:      string(deal.State),
+			PieceSize:   uint64(deal.PieceSize),
+			DealID:      uint64(deal.DealID),
+			ProposalID:  deal.ProposalID,
+			Client:      deal.ClientID,
+			Verified:    deal.Verified,
+			Price:       deal.Price,
+			StartEpoch:  deal.StartEpoch,
+			EndEpoch:    deal.EndEpoch,
 		}
-		replicas = append(replicas, blob.Replica{
-			Provider: deal.Provider,
-			Pieces:   []blob.Piece{piece},
-		})
+		if payloadCID, ok := payloadCIDs[deal.PieceCid]; ok {
+			piece.PayloadCID = payloadCID
+		} else {
+			// Singularity labels deals with the payload CID.
+			piece.PayloadCID = deal.Label
+		}
+		i, ok := replicaIndex[deal.Provider]
+		if !ok {
+			i = len(descriptor.Replicas)
+			replicaIndex[deal.Provider] = i
+			descriptor.Replicas = append(descriptor.Replicas, blob.Replica{Provider: deal.Provider})
+		}
+		descriptor.Replicas[i].Pieces = append(descriptor.Replicas[i].Pieces, piece)
 	}
-	descriptor.Replicas = replicas
+	descriptor.ActiveReplicas = activeReplicas(descriptor)
+	descriptor.Repairs = s.repairs.forBlob(id, pieceCIDsOf(descriptor))
 	return descriptor, nil
 }
 
-// Returns true if the file has at least 1 deal for every SP.
-func (s *Store) hasDealForAllProviders(ctx context.Context, blobID blob.ID) (bool, error) {
-	fileID, err := s.idMap.get(blobID)
+// storedInfo gets the information about the blob from the index. Returns
+// blob.ErrBlobNotFound if the blob is not indexed, still pending, or removed.
+func (s *Store) storedInfo(id blob.ID) (blobInfo, error) {
+	info, err := s.index.get(id)
+	if err == nil && (info.State == blobStatePending || info.RemovedAt != nil) {
+		return info, blob.ErrBlobNotFound
+	}
+	return info, err
+}
+
+// updateState records the state of the described blob in the index, if it
+// changed.
+func (s *Store) updateState(id blob.ID, desc *blob.Descriptor) {
+	state := blobStateStored
+	switch {
+	case desc.TargetReplicas != 0 && desc.ActiveReplicas >= desc.TargetReplicas:
+		state = blobStateReplicated
+	case len(desc.Packs) != 0 && !slices.ContainsFunc(desc.Packs, func(pack blob.Pack) bool { return pack.PieceCID == "" }):
+		state = blobStatePacked
+	}
+	info, err := s.index.get(id)
+	if err != nil || info.State == state {
+		return
+	}
+	if err := s.index.update(id, func(info *blobInfo) { info.State = state }); err != nil {
+		logger.Warnw("Failed to update blob state in index", "id", id.String(), "state", state, "err", err)
+	}
+}
+
+// pieceCIDsOf returns the CIDs of the pieces that contain the described blob,
+// as known from its packs and deals.
+func pieceCIDsOf(desc *blob.Descriptor) []string {
+	var pieceCIDs []string
+	for _, pack := range desc.Packs {
+		if pack.PieceCID != "" && !slices.Contains(pieceCIDs, pack.PieceCID) {
+			pieceCIDs = append(pieceCIDs, pack.PieceCID)
+		}
+	}
+	for _, replica := range desc.Replicas {
+		for _, piece := range replica.Pieces {
+			if !slices.Contains(pieceCIDs, piece.PieceCID) {
+				pieceCIDs = append(pieceCIDs, piece.PieceCID)
+			}
+		}
+	}
+	return pieceCIDs
+}
+
+// activeReplicas counts the replicas of the described blob with an active deal
+// for every piece that contains it. The pieces are those of the packs of the
+// blob, or of its deals if no pack is known to be complete.
+func activeReplicas(desc *blob.Descriptor) uint {
+	var pieceCIDs []string
+	for _, pack := range desc.Packs {
+		if pack.PieceCID == "" {
+			// The blob is not fully packed yet.
+			return 0
+		}
+		pieceCIDs = append(pieceCIDs, pack.PieceCID)
+	}
+	if len(pieceCIDs) == 0 {
+		for _, replica := range desc.Replicas {
+			for _, piece := range replica.Pieces {
+				if !slices.Contains(pieceCIDs, piece.PieceCID) {
+					pieceCIDs = append(pieceCIDs, piece.PieceCID)
+				}
+			}
+		}
+	}
+	if len(pieceCIDs) == 0 {
+		return 0
+	}
+	var active uint
+	for _, replica := range desc.Replicas {
+		replicated := true
+		for _, pieceCID := range pieceCIDs {
+			replicated = replicated && slices.ContainsFunc(replica.Pieces, func(piece blob.Piece) bool {
+				return piece.PieceCID == pieceCID && piece.Status == string(models.ModelDealStateActive)
+			})
+		}
+		if replicated {
+			active++
+		}
+	}
+	return active
+}
+
+// describeFile describes the blob using only the Singularity file that
+// corresponds to it, i.e. without any deal or pack information. The Singularity
+// file is returned along with the descriptor.
+func (s *Store) describeFile(ctx context.Context, id blob.ID) (*models.ModelFile, *blob.Descriptor, error) {
+	info, err := s.storedInfo(id)
 	if err != nil {
-		return false, fmt.Errorf("could not get Singularity file ID: %w", err)
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			return nil, nil, blob.ErrBlobNotFound
+		}
+		return nil, nil, fmt.Errorf("could not get Singularity file ID: %w", err)
 	}
 
-	getFileDealsRes, err := s.singularityClient.File.GetFileDeals(&file.GetFileDealsParams{
-		Context: ctx,
-		ID:      fileID,
-	})
+	getFileRes, err := s.getFile(ctx, info.FileID)
 	if err != nil {
-		return false, fmt.Errorf("failed to get file deals: %w", err)
+		// TODO(@elijaharita): this is not very robust, but is there even a better way?
+		if strings.Contains(err.Error(), "404") {
+			return nil, nil, blob.ErrBlobNotFound
+		}
+		return nil, nil, fmt.Errorf("error loading singularity entry: %w", err)
 	}
+	var decoded blob.ID
+	err = decoded.Decode(strings.TrimSuffix(path.Base(getFileRes.Payload.Path), path.Ext(getFileRes.Payload.Path)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return getFileRes.Payload, &blob.Descriptor{
+		ID:               id,
+		Size:             uint64(getFileRes.Payload.Size),
+		ModificationTime: time.Unix(0, getFileRes.Payload.LastModifiedNano),
+		Metadata:         info.Metadata,
+		SHA256:           info.SHA256,
+		RootCID:          info.RootCID,
+	}, nil
+}
 
-	// Make sure the file has at least 1 deal for every SP
-	for _, sp := range s.storageProviders {
-		foundDealForSP := false
-		for _, deal := range getFileDealsRes.Payload {
-			// Only check state for current provider
-			if deal.Provider != sp.String() {
+// ListBlobs lists a page of blobs known to Motion, in ascending order of ID.
+// Blobs are described from the index, paged through within a single read
+// transaction, and listed descriptors do not include replica information; see
+// Describe.
+func (s *Store) ListBlobs(ctx context.Context, options blob.ListOptions) (*blob.ListResult, error) {
+	after, err := options.CursorID()
+	if err != nil {
+		return nil, err
+	}
+	limit := options.PageLimit()
+	result := &blob.ListResult{}
+	// Blobs migrated from ID files without a local copy are described from
+	// their Singularity file once the transaction is done, until their size and
+	// creation time are backfilled.
+	var unknown []blob.ID
+	if err := s.index.scan(after, func(id blob.ID, info blobInfo) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		if info.State == blobStatePending || info.RemovedAt != nil {
+			return true, nil
+		}
+		if len(result.Descriptors) == limit {
+			result.NextCursor = result.Descriptors[limit-1].ID.String()
+			return false, nil
+		}
+		desc := &blob.Descriptor{
+			ID:               id,
+			Size:             info.Size,
+			ModificationTime: info.CreatedAt,
+			Metadata:         info.Metadata,
+			SHA256:           info.SHA256,
+			RootCID:          info.RootCID,
+		}
+		if info.CreatedAt.IsZero() {
+			unknown = append(unknown, id)
+		} else if !options.Match(desc) {
+			return true, nil
+		}
+		result.Descriptors = append(result.Descriptors, desc)
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(unknown) == 0 {
+		return result, nil
+	}
+	descriptors := result.Descriptors[:0]
+	for _, desc := range result.Descriptors {
+		if slices.Contains(unknown, desc.ID) {
+			var err error
+			if _, desc, err = s.describeFile(ctx, desc.ID); err != nil {
+				if errors.Is(err, blob.ErrBlobNotFound) {
+					continue
+				}
+				return nil, err
+			}
+			if !options.Match(desc) {
 				continue
 			}
+		}
+		descriptors = append(descriptors, desc)
+	}
+	result.Descriptors = descriptors
+	return result, nil
+}
+
+// Remove removes the blob from Motion. The blob is no longer listed, described
+// or retrieved, and is no longer retained, so that renewals and repairs of the
+// pieces into which it is packed stop once no other blob in them is retained.
+// Deals that are already scheduled may still be made, and deals that are
+// already on chain remain in effect until they expire.
+//
+// Singularity reads the locally staged copy of the blob to pack it, and the
+// copies of all the blobs in a piece to rebuild it for renewal and repair
+// deals. The local copy is therefore kept until the blob is fully packed, and
+// the index entry is kept while the pieces into which it is packed contain
+// blobs that are not removed, so that the copy can be restored from Filecoin
+// to rebuild them; see purgeRemoved.
+//
+// If no blob exists for the given ID, blob.ErrBlobNotFound is returned.
+func (s *Store) Remove(ctx context.Context, id blob.ID) error {
+	info, err := s.storedInfo(id)
+	if err != nil {
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			return blob.ErrBlobNotFound
+		}
+		return fmt.Errorf("could not get Singularity file ID: %w", err)
+	}
+
+	removedAt := time.Now()
+	if err := s.index.update(id, func(info *blobInfo) {
+		info.RemovedAt = &removedAt
+	}); err != nil {
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			return blob.ErrBlobNotFound
+		}
+		return fmt.Errorf("failed to record removal in index: %w", err)
+	}
+	if err := s.purgeRemoved(ctx, id); err != nil {
+		return err
+	}
 
-			if deal.State == models.ModelDealStatePublished || deal.State == models.ModelDealStateActive {
-				foundDealForSP = true
-				break
+	logger.Infow("Removed blob", "id", id.String(), "singularityFileID", info.FileID)
+	return nil
+}
+
+// purgeRemoved deletes what is no longer needed of the removed blob, once it
+// is fully packed. Its local copy is deleted, unless the pieces into which it
+// is packed are being renewed. Once none of those pieces contain blobs that
+// are not removed, the index entries of the removed blobs packed only into
+// such pieces are deleted too, along with the renewal and repair state of the
+// pieces. Replica assignments are kept, so that the pieces are not assigned
+// to providers anew.
+func (s *Store) purgeRemoved(ctx context.Context, id blob.ID) error {
+	info, err := s.index.get(id)
+	if err != nil {
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			return nil
+		}
+		return err
+	}
+	if info.RemovedAt == nil || !info.packed() {
+		return nil
+	}
+	pieceCIDs := info.pieceCIDs()
+	if !s.renewals.renewing(pieceCIDs...) {
+		// The local copy may have already been removed by the cleanup scheduler.
+		if err := s.local.Remove(ctx, id); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			return fmt.Errorf("failed to remove local copy: %w", err)
+		}
+	}
+
+	// Pieces are dead once all the blobs packed into them are removed.
+	dead := make(map[string]bool)
+	isDead := func(pieceCID string) (bool, error) {
+		if d, ok := dead[pieceCID]; ok {
+			return d, nil
+		}
+		packed, err := s.packedBlobs(pieceCID)
+		if err != nil {
+			return false, err
+		}
+		dead[pieceCID] = !slices.ContainsFunc(packed, func(pb *packedBlob) bool { return pb.info.RemovedAt == nil })
+		return dead[pieceCID], nil
+	}
+	var purgeable []*packedBlob
+	for _, pieceCID := range pieceCIDs {
+		d, err := isDead(pieceCID)
+		if err != nil {
+			return err
+		}
+		if !d {
+			continue
+		}
+		packed, err := s.packedBlobs(pieceCID)
+		if err != nil {
+			return err
+		}
+		for _, pb := range packed {
+			if !pb.info.packed() || slices.ContainsFunc(purgeable, func(p *packedBlob) bool { return p.id == pb.id }) {
+				continue
+			}
+			allDead := true
+			for _, other := range pb.info.pieceCIDs() {
+				d, err := isDead(other)
+				if err != nil {
+					return err
+				}
+				allDead = allDead && d
+			}
+			if allDead {
+				purgeable = append(purgeable, pb)
 			}
 		}
-		if !foundDealForSP {
-			return false, nil
+	}
+
+	var errs []error
+	for _, pb := range purgeable {
+		if err := s.local.Remove(ctx, pb.id); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			errs = append(errs, fmt.Errorf("failed to remove local copy of blob %s: %w", pb.id, err))
+			continue
+		}
+		if err := s.index.remove(pb.id); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			errs = append(errs, fmt.Errorf("failed to remove blob %s from index: %w", pb.id, err))
 		}
 	}
+	for pieceCID, d := range dead {
+		if !d {
+			continue
+		}
+		if err := s.renewals.finish(pieceCID); err != nil {
+			errs = append(errs, err)
+		}
+		if err := s.repairs.forget(pieceCID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(purgeable) != 0 && len(errs) == 0 {
+		logger.Infow("Purged removed blobs", "count", len(purgeable), "pieces", pieceCIDs)
+	}
+	return errors.Join(errs...)
+}
+
+// getFile gets the Singularity file with the given ID.
+func (s *Store) getFile(ctx context.Context, fileID int64) (_ *file.GetFileOK, err error) {
+	ctx, span := tracer.Start(ctx, "singularity.GetFile", trace.WithAttributes(attribute.Int64("singularity.file_id", fileID)))
+	defer func() { tracing.End(span, err) }()
+	return s.singularityClient.File.GetFile(&file.GetFileParams{
+		Context: ctx,
+		ID:      fileID,
+	})
+}
+
+// getFileDeals gets the deals made for the Singularity file with the given ID.
+func (s *Store) getFileDeals(ctx context.Context, fileID int64) (_ *file.GetFileDealsOK, err error) {
+	ctx, span := tracer.Start(ctx, "singularity.GetFileDeals", trace.WithAttributes(attribute.Int64("singularity.file_id", fileID)))
+	defer func() { tracing.End(span, err) }()
+	return s.singularityClient.File.GetFileDeals(&file.GetFileDealsParams{
+		Context: ctx,
+		ID:      fileID,
+	})
+}
+
+// listSchedules lists the deal schedules of the policy preparation by provider.
+func (s *Store) listSchedules(ctx context.Context, p *storagePolicy) (map[address.Address]*models.ModelSchedule, error) {
+	listPreparationSchedulesRes, err := s.singularityClient.DealSchedule.ListPreparationSchedules(&deal_schedule.ListPreparationSchedulesParams{
+		Context: ctx,
+		ID:      p.preparationName,
+	})
+	switch {
+	case err == nil:
+	case strings.Contains(err.Error(), "404"):
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("failed to list schedules for preparation: %w", err)
+	}
+	schedules := make(map[address.Address]*models.ModelSchedule)
+	for _, schedule := range listPreparationSchedulesRes.Payload {
+		if provider, err := address.NewFromString(schedule.Provider); err == nil {
+			schedules[provider] = schedule
+		}
+	}
+	return schedules, nil
+}
+
+// schedulePrices returns the deal prices of the policy in FIL, as expected by
+// Singularity schedules.
+func (s *Store) schedulePrices(p *storagePolicy) (perGBEpoch, perGB, perDeal float64) {
+	perGBEpoch, _ = (new(big.Rat).SetFrac(p.pricePerGiBEpoch.Int, big.NewInt(int64(1e18)))).Float64()
+	perGB, _ = (new(big.Rat).SetFrac(p.pricePerGiB.Int, big.NewInt(int64(1e18)))).Float64()
+	perDeal, _ = (new(big.Rat).SetFrac(p.pricePerDeal.Int, big.NewInt(int64(1e18)))).Float64()
+	return
+}
+
+// scheduleDurations returns the configured deal start delay and the deal
+// duration of the policy in seconds, as expected by Singularity schedules.
+func (s *Store) scheduleDurations(p *storagePolicy) (startDelay, duration string) {
+	startDelay = strconv.Itoa(int(s.dealStartDelay)*builtin.EpochDurationSeconds) + "s"
+	duration = strconv.Itoa(int(p.dealDuration)*builtin.EpochDurationSeconds) + "s"
+	return
+}
 
-	return true, nil
+// newScheduleCreateRequest instantiates a request to create a deal schedule
+// with the given provider for the policy preparation, using the policy
+// settings. Deals are made for every piece of the preparation unless
+// allowedPieceCIDs is non-empty.
+func (s *Store) newScheduleCreateRequest(p *storagePolicy, sp address.Address, allowedPieceCIDs []string) *models.ScheduleCreateRequest {
+	pricePerGBEpoch, pricePerGB, pricePerDeal := s.schedulePrices(p)
+	startDelay, duration := s.scheduleDurations(p)
+	return &models.ScheduleCreateRequest{
+		Preparation:           p.preparationName,
+		Provider:              sp.String(),
+		PricePerGbEpoch:       pricePerGBEpoch,
+		PricePerGb:            pricePerGB,
+		PricePerDeal:          pricePerDeal,
+		Verified:              &p.verifiedDeal,
+		Ipni:                  &s.ipniAnnounce,
+		KeepUnsealed:          &s.keepUnsealed,
+		StartDelay:            ptr.String(startDelay),
+		Duration:              ptr.String(duration),
+		ScheduleCron:          s.scheduleCron,
+		ScheduleCronPerpetual: s.scheduleCronPerpetual,
+		ScheduleDealNumber:    int64(s.scheduleDealNumber),
+		TotalDealNumber:       int64(s.totalDealNumber),
+		ScheduleDealSize:      s.scheduleDealSize,
+		TotalDealSize:         s.totalDealSize,
+		MaxPendingDealSize:    s.maxPendingDealSize,
+		MaxPendingDealNumber:  int64(s.maxPendingDealNumber),
+		URLTemplate:           s.scheduleUrlTemplate,
+		AllowedPieceCids:      allowedPieceCIDs,
+	}
+}
+
+// newScheduleUpdateRequest instantiates a request to update a deal schedule
+// with the policy settings. The pieces allowed by the schedule are left
+// unchanged.
+func (s *Store) newScheduleUpdateRequest(p *storagePolicy) *models.ScheduleUpdateRequest {
+	pricePerGBEpoch, pricePerGB, pricePerDeal := s.schedulePrices(p)
+	startDelay, duration := s.scheduleDurations(p)
+	return &models.ScheduleUpdateRequest{
+		PricePerGbEpoch:       pricePerGBEpoch,
+		PricePerGb:            pricePerGB,
+		PricePerDeal:          pricePerDeal,
+		Verified:              &p.verifiedDeal,
+		Ipni:                  &s.ipniAnnounce,
+		KeepUnsealed:          &s.keepUnsealed,
+		StartDelay:            ptr.String(startDelay),
+		Duration:              ptr.String(duration),
+		ScheduleCron:          s.scheduleCron,
+		ScheduleCronPerpetual: s.scheduleCronPerpetual,
+		ScheduleDealNumber:    int64(s.scheduleDealNumber),
+		TotalDealNumber:       int64(s.totalDealNumber),
+		ScheduleDealSize:      s.scheduleDealSize,
+		TotalDealSize:         s.totalDealSize,
+		MaxPendingDealSize:    s.maxPendingDealSize,
+		MaxPendingDealNumber:  int64(s.maxPendingDealNumber),
+		URLTemplate:           s.scheduleUrlTemplate,
+	}
+}
+
+// listCars lists the CARs packed for the policy preparation.
+func (s *Store) listCars(ctx context.Context, p *storagePolicy) (_ []*models.ModelCar, err error) {
+	ctx, span := tracer.Start(ctx, "singularity.ListPieces", trace.WithAttributes(attribute.String("singularity.preparation", p.preparationName)))
+	defer func() { tracing.End(span, err) }()
+	listPiecesRes, err := s.singularityClient.Piece.ListPieces(&piece.ListPiecesParams{
+		Context: ctx,
+		ID:      p.preparationName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pieces: %w", err)
+	}
+	var cars []*models.ModelCar
+	for _, pieceList := range listPiecesRes.Payload {
+		cars = append(cars, pieceList.Pieces...)
+	}
+	return cars, nil
+}
+
+// listAllCars lists the CARs packed for the preparations of all policies.
+func (s *Store) listAllCars(ctx context.Context) ([]policyCar, error) {
+	var all []policyCar
+	for _, p := range s.policies {
+		cars, err := s.listCars(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, car := range cars {
+			all = append(all, policyCar{ModelCar: car, policy: p})
+		}
+	}
+	return all, nil
+}
+
+// isReplicated reports whether the blob is safe, i.e. whether as many storage
+// providers as the replication factor hold active deals for it, and records
+// the state of the blob in the index along the way. Blobs packed
+// into pieces whose deals are being renewed are not, since their local copy is
+// needed to make the renewal deals.
+func (s *Store) isReplicated(ctx context.Context, blobID blob.ID) (bool, error) {
+	// The local copies of removed blobs are only needed to pack them, and to
+	// renew the deals of the pieces into which they are packed.
+	if info, err := s.index.get(blobID); err == nil && info.RemovedAt != nil {
+		return info.packed() && !s.renewals.renewing(info.pieceCIDs()...), nil
+	}
+	desc, err := s.Describe(ctx, blobID)
+	if err != nil {
+		return false, fmt.Errorf("failed to describe blob: %w", err)
+	}
+	s.updateState(blobID, desc)
+	if s.renewals.renewing(pieceCIDsOf(desc)...) {
+		return false, nil
+	}
+	return desc.TargetReplicas != 0 && desc.ActiveReplicas >= desc.TargetReplicas, nil
+}
+
+// CheckHealth checks that the Singularity API is reachable, that the
+// preparations of all policies and their attached wallet set up by Start still
+// exist, and that the local store in which blobs are staged is healthy.
+func (s *Store) CheckHealth(ctx context.Context) []blob.HealthCheck {
+	checks := s.local.CheckHealth(ctx)
+
+	api := blob.HealthCheck{Name: "singularity_api"}
+	listPreparationsRes, err := s.singularityClient.Preparation.ListPreparations(&preparation.ListPreparationsParams{
+		Context: ctx,
+	})
+	if err != nil {
+		api.Err = fmt.Errorf("failed to list preparations: %w", err)
+		return append(checks, api)
+	}
+	checks = append(checks, api)
+
+	var missing []error
+	for _, p := range s.policies {
+		if !slices.ContainsFunc(listPreparationsRes.Payload, func(existing *models.ModelPreparation) bool {
+			return existing.Name == p.preparationName
+		}) {
+			missing = append(missing, fmt.Errorf("preparation %s does not exist", p.preparationName))
+		}
+	}
+	checks = append(checks, blob.HealthCheck{Name: "singularity_preparation", Err: errors.Join(missing...)})
+
+	wlt := blob.HealthCheck{Name: "singularity_wallet"}
+	if s.walletAddress == "" {
+		wlt.Err = errors.New("store is not started")
+		return append(checks, wlt)
+	}
+	var errs []error
+	for _, p := range s.policies {
+		listAttachedWalletsRes, err := s.singularityClient.WalletAssociation.ListAttachedWallets(&wallet_association.ListAttachedWalletsParams{
+			Context: ctx,
+			ID:      p.preparationName,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list attached wallets: %w", err))
+			continue
+		}
+		if !slices.ContainsFunc(listAttachedWalletsRes.Payload, func(existing *models.ModelWallet) bool {
+			return existing.Address == s.walletAddress
+		}) {
+			errs = append(errs, fmt.Errorf("wallet %s is not attached to preparation %s", s.walletAddress, p.preparationName))
+		}
+	}
+	wlt.Err = errors.Join(errs...)
+	return append(checks, wlt)
 }
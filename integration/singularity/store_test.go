@@ -3,14 +3,20 @@ package singularity_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -89,17 +95,76 @@ func TestStorePut(t *testing.T) {
 	}
 	timer.Stop()
 
-	// Check reading file ID.
-	idStream, err := os.Open(filepath.Join(tmpDir, blobID.String()+".id"))
+	err = s.Shutdown(context.Background())
+	require.NoError(t, err)
+
+	// Check that blobs are indexed across restarts, and listed without
+	// Singularity.
+	require.NoFileExists(t, filepath.Join(tmpDir, blobID.String()+".id"))
+	s, err = singularity.NewStore(
+		singularity.WithStoreDir(tmpDir),
+		singularity.WithWalletKey("dummy"),
+	)
 	require.NoError(t, err)
-	fileIDString, err := io.ReadAll(idStream)
+	listed, err := s.ListBlobs(ctx, blob.ListOptions{Limit: 100})
 	require.NoError(t, err)
-	fileID, err := strconv.ParseUint(string(fileIDString), 10, 64)
+	require.Len(t, listed.Descriptors, 17)
+	i := slices.IndexFunc(listed.Descriptors, func(desc *blob.Descriptor) bool { return desc.ID == blobID })
+	require.NotEqual(t, -1, i)
+	require.Equal(t, uint64(len("Halló heimur!")), listed.Descriptors[i].Size)
+
+	// Check the indexed Singularity file ID.
+	fileID, err := singularity.IndexedFileID(s, blobID)
 	require.NoError(t, err)
 	require.Zero(t, fileID)
+	require.NoError(t, s.Shutdown(context.Background()))
+}
 
-	err = s.Shutdown(context.Background())
+func TestStoreRemove(t *testing.T) {
+	checkGoLeaks(t)
+
+	testServer := httptest.NewServer(http.HandlerFunc(testHandler))
+	t.Cleanup(func() {
+		testServer.Close()
+	})
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	singularityAPI := singularityclient.NewHTTPClientWithConfig(nil, cfg)
+
+	tmpDir := t.TempDir()
+	s, err := singularity.NewStore(
+		singularity.WithStoreDir(tmpDir),
+		singularity.WithWalletKey("dummy"),
+		singularity.WithSingularityClient(singularityAPI),
+	)
 	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, s.Start(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, s.Shutdown(context.Background()))
+	})
+
+	desc, err := s.Put(ctx, bytes.NewReader([]byte("Halló heimur!")))
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(tmpDir, desc.ID.String()+".bin"))
+	listed, err := s.ListBlobs(ctx, blob.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, listed.Descriptors, 1)
+
+	// The local copy is kept until Singularity packs the blob; see
+	// TestCheckPacksPurgesRemovedBlobs.
+	require.NoError(t, s.Remove(ctx, desc.ID))
+	require.FileExists(t, filepath.Join(tmpDir, desc.ID.String()+".bin"))
+	listed, err = s.ListBlobs(ctx, blob.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, listed.Descriptors)
+
+	_, err = s.Describe(ctx, desc.ID)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+	require.ErrorIs(t, s.Remove(ctx, desc.ID), blob.ErrBlobNotFound)
 }
 
 func TestReader(t *testing.T) {
@@ -181,3 +246,263 @@ func testHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 }
+
+func TestStoreDescribe(t *testing.T) {
+	checkGoLeaks(t)
+
+	var (
+		mu     sync.Mutex
+		blobID blob.ID
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/api/file/0":
+			mu.Lock()
+			path := blobID.String() + ".bin"
+			mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"path": path,
+				"size": len(testData),
+				"fileRanges": []map[string]any{
+					{"jobId": 7, "offset": 0, "length": 100},
+					{"jobId": 8, "offset": 100, "length": len(testData) - 100},
+				},
+			})
+		case "/api/file/0/deals":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"provider": "f01000", "pieceCid": "baga1", "dealId": 11, "state": "active", "verified": true, "clientId": "f0100", "price": "0", "startEpoch": 100, "endEpoch": 200, "proposalId": "bafyproposal1"},
+				{"provider": "f02000", "pieceCid": "baga1", "dealId": 12, "state": "published", "label": "bafylabel"},
+				{"provider": "f01000", "pieceCid": "baga2", "state": "proposed", "label": "bafypayload2"},
+			})
+		case "/api/preparation/MOTION_PREPARATION/piece":
+			_ = json.NewEncoder(w).Encode([]map[string]any{
+				{"pieces": []map[string]any{
+					{"jobId": 7, "pieceCid": "baga1", "pieceSize": 256, "rootCid": "bafypayload1", "fileSize": 200},
+				}},
+			})
+		default:
+			testHandler(w, req)
+		}
+	}))
+	t.Cleanup(func() {
+		testServer.Close()
+	})
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	singularityAPI := singularityclient.NewHTTPClientWithConfig(nil, cfg)
+
+	s, err := singularity.NewStore(
+		singularity.WithStoreDir(t.TempDir()),
+		singularity.WithWalletKey("dummy"),
+		singularity.WithSingularityClient(singularityAPI),
+		singularity.WithPackCheckInterval(10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, s.Start(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, s.Shutdown(context.Background()))
+	})
+	desc, err := s.Put(ctx, bytes.NewReader(testData))
+	require.NoError(t, err)
+	mu.Lock()
+	blobID = desc.ID
+	mu.Unlock()
+
+	// CARs are described once recorded by the periodic pack check.
+	var got *blob.Descriptor
+	require.Eventually(t, func() bool {
+		got, err = s.Describe(ctx, desc.ID)
+		require.NoError(t, err)
+		return got.Packs[0].PieceCID != ""
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []blob.Pack{
+		{JobID: 7, Offset: 0, Length: 100, PieceCID: "baga1", PieceSize: 256, PayloadCID: "bafypayload1", CARSize: 200},
+		{JobID: 8, Offset: 100, Length: uint64(len(testData) - 100)},
+	}, got.Packs)
+
+	require.Len(t, got.Replicas, 2)
+	require.Equal(t, "f01000", got.Replicas[0].Provider)
+	require.Len(t, got.Replicas[0].Pieces, 2)
+	active := got.Replicas[0].Pieces[0]
+	require.Equal(t, uint64(11), active.DealID)
+	require.Equal(t, "bafypayload1", active.PayloadCID)
+	require.Equal(t, "bafyproposal1", active.ProposalID)
+	require.Equal(t, "f0100", active.Client)
+	require.True(t, active.Verified)
+	require.Equal(t, int64(100), active.StartEpoch)
+	require.Equal(t, int64(200), active.EndEpoch)
+	require.Equal(t, "active", active.Status)
+	// Deals for pieces not found among the CARs fall back on the deal label.
+	require.Equal(t, "bafypayload2", got.Replicas[0].Pieces[1].PayloadCID)
+	require.Equal(t, "f02000", got.Replicas[1].Provider)
+	require.Len(t, got.Replicas[1].Pieces, 1)
+	require.Equal(t, uint64(12), got.Replicas[1].Pieces[0].DealID)
+	require.Equal(t, "bafypayload1", got.Replicas[1].Pieces[0].PayloadCID)
+}
+
+func TestStorePassGet(t *testing.T) {
+	checkGoLeaks(t)
+
+	var (
+		mu       sync.Mutex
+		blobID   blob.ID
+		modTime  = time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+		retrieve []string
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/file/0":
+			mu.Lock()
+			path := blobID.String() + ".bin"
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"path": path, "size": len(testData), "lastModifiedNano": modTime.UnixNano()})
+		case "/api/file/0/retrieve":
+			mu.Lock()
+			retrieve = append(retrieve, req.Header.Get("Range"))
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/octet-stream")
+			http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(testData))
+		default:
+			testHandler(w, req)
+		}
+	}))
+	t.Cleanup(func() {
+		testServer.Close()
+	})
+
+	cfg := singularityclient.DefaultTransportConfig()
+	u, _ := url.Parse(testServer.URL)
+	cfg.Host = u.Host
+	singularityAPI := singularityclient.NewHTTPClientWithConfig(nil, cfg)
+
+	s, err := singularity.NewStore(
+		singularity.WithStoreDir(t.TempDir()),
+		singularity.WithWalletKey("dummy"),
+		singularity.WithSingularityClient(singularityAPI),
+	)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, s.Start(ctx))
+	t.Cleanup(func() {
+		require.NoError(t, s.Shutdown(context.Background()))
+	})
+	desc, err := s.Put(ctx, bytes.NewReader(testData))
+	require.NoError(t, err)
+	mu.Lock()
+	blobID = desc.ID
+	mu.Unlock()
+
+	const etag = `"fish"`
+	passGet := func(header map[string]string) (*httptest.ResponseRecorder, []string) {
+		mu.Lock()
+		retrieve = nil
+		mu.Unlock()
+		r := httptest.NewRequest(http.MethodGet, "/v0/blob/"+desc.ID.String(), nil)
+		for key, value := range header {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "text/plain")
+		s.PassGet(w, r, desc.ID)
+		mu.Lock()
+		defer mu.Unlock()
+		return w, retrieve
+	}
+
+	t.Run("full content", func(t *testing.T) {
+		w, retrieved := passGet(nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+		require.Equal(t, strconv.Itoa(len(testData)), w.Header().Get("Content-Length"))
+		require.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+		require.Equal(t, modTime.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+		require.Equal(t, testData, w.Body.Bytes())
+		require.Equal(t, []string{fmt.Sprintf("bytes=0-%d", len(testData)-1)}, retrieved)
+	})
+
+	t.Run("single range", func(t *testing.T) {
+		w, retrieved := passGet(map[string]string{"Range": "bytes=10-19"})
+		require.Equal(t, http.StatusPartialContent, w.Code)
+		require.Equal(t, "bytes 10-19/"+strconv.Itoa(len(testData)), w.Header().Get("Content-Range"))
+		require.Equal(t, "10", w.Header().Get("Content-Length"))
+		require.Equal(t, testData[10:20], w.Body.Bytes())
+		require.Equal(t, []string{"bytes=10-19"}, retrieved)
+	})
+
+	t.Run("multiple ranges", func(t *testing.T) {
+		w, retrieved := passGet(map[string]string{"Range": "bytes=0-4,100-109,-5"})
+		require.Equal(t, http.StatusPartialContent, w.Code)
+		mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/byteranges", mediaType)
+		require.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+
+		size := len(testData)
+		wantRanges := [][2]int{{0, 4}, {100, 109}, {size - 5, size - 1}}
+		parts := multipart.NewReader(w.Body, params["boundary"])
+		for _, want := range wantRanges {
+			part, err := parts.NextPart()
+			require.NoError(t, err)
+			require.Equal(t, "text/plain", part.Header.Get("Content-Type"))
+			require.Equal(t, fmt.Sprintf("bytes %d-%d/%d", want[0], want[1], size), part.Header.Get("Content-Range"))
+			got, err := io.ReadAll(part)
+			require.NoError(t, err)
+			require.Equal(t, testData[want[0]:want[1]+1], got)
+		}
+		_, err = parts.NextPart()
+		require.ErrorIs(t, err, io.EOF)
+		require.Equal(t, []string{"bytes=0-4", "bytes=100-109", fmt.Sprintf("bytes=%d-%d", size-5, size-1)}, retrieved)
+	})
+
+	t.Run("unsatisfiable range", func(t *testing.T) {
+		w, retrieved := passGet(map[string]string{"Range": "bytes=100000-"})
+		require.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+		require.Equal(t, "bytes */"+strconv.Itoa(len(testData)), w.Header().Get("Content-Range"))
+		require.Empty(t, retrieved)
+	})
+
+	t.Run("if-none-match", func(t *testing.T) {
+		w, retrieved := passGet(map[string]string{"If-None-Match": etag})
+		require.Equal(t, http.StatusNotModified, w.Code)
+		require.Empty(t, w.Body.Bytes())
+		require.Empty(t, retrieved)
+	})
+
+	t.Run("if-modified-since", func(t *testing.T) {
+		w, retrieved := passGet(map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)})
+		require.Equal(t, http.StatusNotModified, w.Code)
+		require.Empty(t, retrieved)
+	})
+
+	t.Run("if-match", func(t *testing.T) {
+		w, retrieved := passGet(map[string]string{"If-Match": `"lobster"`})
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+		require.Empty(t, retrieved)
+	})
+
+	t.Run("if-range", func(t *testing.T) {
+		w, _ := passGet(map[string]string{"Range": "bytes=10-19", "If-Range": etag})
+		require.Equal(t, http.StatusPartialContent, w.Code)
+		require.Equal(t, testData[10:20], w.Body.Bytes())
+
+		w, retrieved := passGet(map[string]string{"Range": "bytes=10-19", "If-Range": `"lobster"`})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, testData, w.Body.Bytes())
+		require.Equal(t, []string{fmt.Sprintf("bytes=0-%d", len(testData)-1)}, retrieved)
+	})
+
+	t.Run("head", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodHead, "/v0/blob/"+desc.ID.String(), nil)
+		w := httptest.NewRecorder()
+		s.PassGet(w, r, desc.ID)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, strconv.Itoa(len(testData)), w.Header().Get("Content-Length"))
+		require.Empty(t, w.Body.Bytes())
+	})
+}
@@ -20,8 +20,10 @@ type testCase struct {
 	name          string
 	onMethod      string
 	onPath        string
+	onQuery       string
 	onBody        string
 	onContentType string
+	onHeader      map[string]string
 	expectStatus  int
 	expectBody    string // may be regex. optional (empty = not tested)
 
@@ -58,6 +60,40 @@ func TestApi(t *testing.T) {
 		resp.Body.Close()
 	}
 
+	// Prereq: create 2 upload sessions to test on
+	var testUploadResps [2]api.UploadResponse
+	for i := range testUploadResps {
+		resp, err := http.Post(
+			requireJoinUrlPath(t, env.MotionAPIEndpoint, "v0", "upload"),
+			"",
+			nil,
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&testUploadResps[i]))
+
+		resp.Body.Close()
+	}
+
+	// Prereq: create upload sessions that cannot be finalised, one with a
+	// declared length that is never reached and one with a declared digest that
+	// does not match its content
+	newUpload := func(key, value string) api.UploadResponse {
+		req, err := http.NewRequest(http.MethodPost, requireJoinUrlPath(t, env.MotionAPIEndpoint, "v0", "upload"), nil)
+		require.NoError(t, err)
+		req.Header.Set(key, value)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		var uploadResp api.UploadResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&uploadResp))
+
+		resp.Body.Close()
+		return uploadResp
+	}
+	testIncompleteUploadResp := newUpload("Upload-Length", "1")
+	testMismatchedUploadResp := newUpload("Content-Digest", "sha-256=:LCa0a2j/xo/5m0U8HTBBNBNCLXBkg7+g+YpeiGJm564=:")
+
 	// ---- Add test cases here ----
 	tests := []testCase{
 		{
@@ -69,6 +105,49 @@ func TestApi(t *testing.T) {
 			expectBody:    "{\"id\":\".*\"}",
 			expectStatus:  201,
 		},
+		{
+			name:         "GET /v0/blob is 200",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob",
+			expectBody:   "{\"blobs\":\\[.*\\].*}",
+			expectStatus: 200,
+		},
+		{
+			// not testable without query parameters
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob",
+			expectStatus: 400,
+			skip:         true,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob",
+			expectStatus: 405,
+			skip:         true,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob",
+			expectStatus: 500,
+			skip:         true,
+		},
+		{
+			name:         "POST /v0/blob without content type is 400",
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/blob",
+			onBody:       "fish",
+			expectStatus: 400,
+		},
+		{
+			name:         "POST /v0/blob with invalid retention time is 400",
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/blob",
+			onHeader:     map[string]string{"Content-Type": "text/plain", "X-Motion-Retain-Until": "tomorrow"},
+			onBody:       "fish",
+			expectStatus: 400,
+		},
 		{
 			// not reliably testable
 			onMethod:     http.MethodPost,
@@ -89,6 +168,48 @@ func TestApi(t *testing.T) {
 			onPath:       "/v0/blob/" + testBlobResp.ID,
 			expectStatus: 200,
 		},
+		{
+			name:         "GET /v0/blob/{id} as CAR is 200",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob/" + testBlobResp.ID,
+			onQuery:      "format=car",
+			expectStatus: 200,
+		},
+		{
+			name:         "GET /v0/blob/{id} with range is 206",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob/" + testBlobResp.ID,
+			onHeader:     map[string]string{"Range": "bytes=0-0"},
+			expectStatus: 206,
+		},
+		{
+			name:         "GET /v0/blob/{id} with matching If-None-Match is 304",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob/" + testBlobResp.ID,
+			onHeader:     map[string]string{"If-None-Match": "*"},
+			expectStatus: 304,
+		},
+		{
+			name:         "GET /v0/blob/{id} with mismatching If-Match is 412",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob/" + testBlobResp.ID,
+			onHeader:     map[string]string{"If-Match": `"fish"`},
+			expectStatus: 412,
+		},
+		{
+			name:         "GET /v0/blob/{id} with unsatisfiable range is 416",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob/" + testBlobResp.ID,
+			onHeader:     map[string]string{"Range": "bytes=10-"},
+			expectStatus: 416,
+		},
+		{
+			name:         "GET /v0/blob/{id} with unknown format is 400",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/blob/" + testBlobResp.ID,
+			onQuery:      "format=fish",
+			expectStatus: 400,
+		},
 		{
 			name:         "GET /v0/blob/{id} for unknown ID is 404",
 			onMethod:     http.MethodGet,
@@ -135,6 +256,278 @@ func TestApi(t *testing.T) {
 			expectStatus: 503,
 			skip:         true,
 		},
+		{
+			name:         "DELETE /v0/blob/{id} is 204",
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/blob/" + testBlobResp.ID,
+			expectStatus: 204,
+		},
+		{
+			name:         "DELETE /v0/blob/{id} for unknown ID is 404",
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/blob/00000000-0000-0000-0000-000000000000",
+			expectStatus: 404,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/blob/00000000-0000-0000-0000-000000000000",
+			expectStatus: 405,
+			skip:         true,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/blob/00000000-0000-0000-0000-000000000000",
+			expectStatus: 500,
+			skip:         true,
+		},
+		{
+			name:         "POST /v0/upload is 201",
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/upload",
+			expectBody:   "{\"id\":\".*\",\"offset\":0,.*}",
+			expectStatus: 201,
+		},
+		{
+			name:         "POST /v0/upload with invalid Upload-Length is 400",
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/upload",
+			onHeader:     map[string]string{"Upload-Length": "fish"},
+			expectStatus: 400,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/upload",
+			expectStatus: 500,
+			skip:         true,
+		},
+		{
+			name:         "GET /v0/upload/{id} is 200",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/upload/" + testUploadResps[0].ID,
+			expectStatus: 200,
+		},
+		{
+			name:         "GET /v0/upload/{id} for unknown ID is 404",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/upload/00000000-0000-0000-0000-000000000000",
+			expectStatus: 404,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/upload/00000000-0000-0000-0000-000000000000",
+			expectStatus: 500,
+			skip:         true,
+		},
+		{
+			name:          "PATCH /v0/upload/{id} is 204",
+			onMethod:      http.MethodPatch,
+			onPath:        "/v0/upload/" + testUploadResps[0].ID,
+			onContentType: "application/offset+octet-stream",
+			onHeader:      map[string]string{"Upload-Offset": "0"},
+			expectStatus:  204,
+		},
+		{
+			name:          "PATCH /v0/upload/{id} without Upload-Offset is 400",
+			onMethod:      http.MethodPatch,
+			onPath:        "/v0/upload/" + testUploadResps[0].ID,
+			onContentType: "application/offset+octet-stream",
+			expectStatus:  400,
+		},
+		{
+			name:          "PATCH /v0/upload/{id} for unknown ID is 404",
+			onMethod:      http.MethodPatch,
+			onPath:        "/v0/upload/00000000-0000-0000-0000-000000000000",
+			onContentType: "application/offset+octet-stream",
+			onHeader:      map[string]string{"Upload-Offset": "0"},
+			expectStatus:  404,
+		},
+		{
+			name:          "PATCH /v0/upload/{id} at mismatching Upload-Offset is 409",
+			onMethod:      http.MethodPatch,
+			onPath:        "/v0/upload/" + testUploadResps[0].ID,
+			onContentType: "application/offset+octet-stream",
+			onHeader:      map[string]string{"Upload-Offset": "1"},
+			expectStatus:  409,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodPatch,
+			onPath:       "/v0/upload/" + testUploadResps[0].ID,
+			expectStatus: 500,
+			skip:         true,
+		},
+		{
+			name:         "POST /v0/upload/{id} is 201",
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/upload/" + testUploadResps[0].ID,
+			expectBody:   "{\"id\":\".*\"}",
+			expectStatus: 201,
+		},
+		{
+			name:         "POST /v0/upload/{id} for unknown ID is 404",
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/upload/00000000-0000-0000-0000-000000000000",
+			expectStatus: 404,
+		},
+		{
+			name:         "POST /v0/upload/{id} with mismatching content digest is 400",
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/upload/" + testMismatchedUploadResp.ID,
+			expectStatus: 400,
+		},
+		{
+			name:         "POST /v0/upload/{id} for incomplete upload is 409",
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/upload/" + testIncompleteUploadResp.ID,
+			expectStatus: 409,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/upload/00000000-0000-0000-0000-000000000000",
+			expectStatus: 500,
+			skip:         true,
+		},
+		{
+			name:         "DELETE /v0/upload/{id} is 204",
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/upload/" + testUploadResps[1].ID,
+			expectStatus: 204,
+		},
+		{
+			name:         "DELETE /v0/upload/{id} for unknown ID is 404",
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/upload/00000000-0000-0000-0000-000000000000",
+			expectStatus: 404,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/upload/00000000-0000-0000-0000-000000000000",
+			expectStatus: 409,
+			skip:         true,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/upload/00000000-0000-0000-0000-000000000000",
+			expectStatus: 500,
+			skip:         true,
+		},
+		{
+			name:         "GET /v0/webhook is 200",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/webhook",
+			expectBody:   "{\"webhooks\":\\[.*\\]}",
+			expectStatus: 200,
+		},
+		{
+			// not testable without request body
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/webhook",
+			expectStatus: 201,
+			skip:         true,
+		},
+		{
+			name:          "POST /v0/webhook without body is 400",
+			onMethod:      http.MethodPost,
+			onPath:        "/v0/webhook",
+			onContentType: "application/json",
+			expectStatus:  400,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodPost,
+			onPath:       "/v0/webhook",
+			expectStatus: 500,
+			skip:         true,
+		},
+		{
+			// not testable without a subscribed endpoint
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/webhook/00000000-0000-0000-0000-000000000000",
+			expectStatus: 200,
+			skip:         true,
+		},
+		{
+			name:         "GET /v0/webhook/{id} with unknown ID is 404",
+			onMethod:     http.MethodGet,
+			onPath:       "/v0/webhook/00000000-0000-0000-0000-000000000000",
+			expectStatus: 404,
+		},
+		{
+			// not testable without a subscribed endpoint
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/webhook/00000000-0000-0000-0000-000000000000",
+			expectStatus: 204,
+			skip:         true,
+		},
+		{
+			name:         "DELETE /v0/webhook/{id} with unknown ID is 404",
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/webhook/00000000-0000-0000-0000-000000000000",
+			expectStatus: 404,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodDelete,
+			onPath:       "/v0/webhook/00000000-0000-0000-0000-000000000000",
+			expectStatus: 500,
+			skip:         true,
+		},
+		{
+			name:         "GET /healthz is 200",
+			onMethod:     http.MethodGet,
+			onPath:       "/healthz",
+			expectBody:   `{"status":"ok"}`,
+			expectStatus: 200,
+		},
+		{
+			name:         "GET /readyz is 200",
+			onMethod:     http.MethodGet,
+			onPath:       "/readyz",
+			expectBody:   `{"status":"ok".*}`,
+			expectStatus: 200,
+		},
+		{
+			// not reliably testable
+			onMethod:     http.MethodGet,
+			onPath:       "/readyz",
+			expectStatus: 503,
+			skip:         true,
+		},
+	}
+
+	// Authentication is not enabled in the test environment; see api/server for
+	// the coverage of 401 and 403 responses.
+	for _, operation := range []struct{ method, path string }{
+		{http.MethodGet, "/v0/blob"},
+		{http.MethodPost, "/v0/blob"},
+		{http.MethodGet, "/v0/blob/00000000-0000-0000-0000-000000000000"},
+		{http.MethodDelete, "/v0/blob/00000000-0000-0000-0000-000000000000"},
+		{http.MethodGet, "/v0/blob/00000000-0000-0000-0000-000000000000/status"},
+		{http.MethodPost, "/v0/upload"},
+		{http.MethodGet, "/v0/upload/00000000-0000-0000-0000-000000000000"},
+		{http.MethodPatch, "/v0/upload/00000000-0000-0000-0000-000000000000"},
+		{http.MethodPost, "/v0/upload/00000000-0000-0000-0000-000000000000"},
+		{http.MethodDelete, "/v0/upload/00000000-0000-0000-0000-000000000000"},
+		{http.MethodGet, "/v0/webhook"},
+		{http.MethodPost, "/v0/webhook"},
+		{http.MethodGet, "/v0/webhook/00000000-0000-0000-0000-000000000000"},
+		{http.MethodDelete, "/v0/webhook/00000000-0000-0000-0000-000000000000"},
+	} {
+		for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+			tests = append(tests, testCase{
+				onMethod:     operation.method,
+				onPath:       operation.path,
+				expectStatus: status,
+				skip:         true,
+			})
+		}
 	}
 
 	// Read and parse openapi.yaml for ensuring all paths, methods, and status
@@ -171,8 +564,12 @@ func TestApi(t *testing.T) {
 				requireJoinUrlPath(t, env.MotionAPIEndpoint, test.onPath),
 				bytes.NewReader([]byte(test.expectBody)),
 			)
-			req.Header.Set("Content-Type", test.onContentType)
 			require.NoError(t, err)
+			req.URL.RawQuery = test.onQuery
+			req.Header.Set("Content-Type", test.onContentType)
+			for key, value := range test.onHeader {
+				req.Header.Set(key, value)
+			}
 
 			resp, err := http.DefaultClient.Do(req)
 			require.NoError(t, err)
@@ -0,0 +1,47 @@
+package motion
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsServer serves the metrics in the default Prometheus registry at
+// /metrics, on a listener separate from the API so that it need not be exposed
+// publicly.
+type metricsServer struct {
+	listenAddr string
+	httpServer *http.Server
+}
+
+func newMetricsServer(listenAddr string) *metricsServer {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &metricsServer{
+		listenAddr: listenAddr,
+		httpServer: &http.Server{Handler: mux},
+	}
+}
+
+func (s *metricsServer) start() error {
+	listener, err := net.Listen("tcp", s.listenAddr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.httpServer.Serve(listener); errors.Is(err, http.ErrServerClosed) {
+			logger.Info("Metrics server stopped successfully.")
+		} else {
+			logger.Errorw("Metrics server stopped erroneously.", "err", err)
+		}
+	}()
+	logger.Infow("Metrics server started successfully.", "address", listener.Addr())
+	return nil
+}
+
+func (s *metricsServer) shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
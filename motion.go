@@ -3,6 +3,7 @@ package motion
 import (
 	"context"
 
+	"github.com/filecoin-project/motion/api/s3"
 	"github.com/filecoin-project/motion/api/server"
 	"github.com/ipfs/go-log/v2"
 )
@@ -15,6 +16,8 @@ var (
 type Motion struct {
 	*options
 	httpServer *server.HttpServer
+	s3Gateway  *s3.Gateway
+	metrics    *metricsServer
 }
 
 // New Instantiates a new Motion service.
@@ -23,23 +26,83 @@ func New(o ...Option) (*Motion, error) {
 	if err != nil {
 		return nil, err
 	}
-	httpServer, err := server.NewHttpServer(opts.blobStore)
+	serverOptions := opts.serverOptions
+	if opts.webhooks != nil {
+		serverOptions = append([]server.Option{server.WithWebhookNotifier(opts.webhooks)}, serverOptions...)
+	}
+	httpServer, err := server.NewHttpServer(opts.blobStore, serverOptions...)
 	if err != nil {
 		return nil, err
 	}
-	return &Motion{
+	m := &Motion{
 		options:    opts,
 		httpServer: httpServer,
-	}, nil
+	}
+	if opts.s3Enabled {
+		if m.s3Gateway, err = s3.NewGateway(opts.blobStore, opts.s3Options...); err != nil {
+			return nil, err
+		}
+	}
+	if opts.metricsAddr != "" {
+		m.metrics = newMetricsServer(opts.metricsAddr)
+	}
+	return m, nil
 }
 
-// Start starts the motion services.
-func (m *Motion) Start(ctx context.Context) error {
+// Start starts the motion services. If any of them fails to start, the ones
+// already started are shut down before the error is returned.
+func (m *Motion) Start(ctx context.Context) (err error) {
 	// TODO start other components like deal engine, wallets etc.
-	return m.httpServer.Start(ctx)
+	var started []func(context.Context) error
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(started) - 1; i >= 0; i-- {
+			if shutdownErr := started[i](ctx); shutdownErr != nil {
+				logger.Warnw("Failed to shut down partially started Motion", "err", shutdownErr)
+			}
+		}
+	}()
+	if m.webhooks != nil {
+		if err := m.webhooks.Start(ctx, m.blobStore); err != nil {
+			return err
+		}
+		started = append(started, m.webhooks.Shutdown)
+	}
+	if err := m.httpServer.Start(ctx); err != nil {
+		return err
+	}
+	started = append(started, m.httpServer.Shutdown)
+	if m.s3Gateway != nil {
+		if err := m.s3Gateway.Start(ctx); err != nil {
+			return err
+		}
+		started = append(started, m.s3Gateway.Shutdown)
+	}
+	if m.metrics != nil {
+		return m.metrics.start()
+	}
+	return nil
 }
 
 // Shutdown Start shuts down the motion services.
 func (m *Motion) Shutdown(ctx context.Context) error {
-	return m.httpServer.Shutdown(ctx)
+	err := m.httpServer.Shutdown(ctx)
+	if m.s3Gateway != nil {
+		if s3Err := m.s3Gateway.Shutdown(ctx); err == nil {
+			err = s3Err
+		}
+	}
+	if m.metrics != nil {
+		if metricsErr := m.metrics.shutdown(ctx); err == nil {
+			err = metricsErr
+		}
+	}
+	if m.webhooks != nil {
+		if webhooksErr := m.webhooks.Shutdown(ctx); err == nil {
+			err = webhooksErr
+		}
+	}
+	return err
 }
@@ -0,0 +1,35 @@
+package motion
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/filecoin-project/motion/api/server"
+	"github.com/filecoin-project/motion/blob"
+	"github.com/filecoin-project/motion/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartShutsDownStartedServicesOnFailure(t *testing.T) {
+	// The HTTP server fails to start since its address is taken.
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer taken.Close()
+
+	webhookDir := t.TempDir()
+	notifier, err := webhook.NewNotifier(webhook.WithDir(webhookDir))
+	require.NoError(t, err)
+	subject, err := New(
+		WithBlobStore(blob.NewLocalStore(t.TempDir())),
+		WithWebhookNotifier(notifier),
+		WithServerOptions(server.WithHttpListenAddr(taken.Addr().String()), server.WithUploadDir(t.TempDir())),
+	)
+	require.NoError(t, err)
+	require.Error(t, subject.Start(context.Background()))
+
+	// The notifier was shut down, releasing its directory to other notifiers.
+	notifier, err = webhook.NewNotifier(webhook.WithDir(webhookDir))
+	require.NoError(t, err)
+	require.NoError(t, notifier.Shutdown(context.Background()))
+}
@@ -3,8 +3,10 @@ package motion
 import (
 	"os"
 
+	"github.com/filecoin-project/motion/api/s3"
 	"github.com/filecoin-project/motion/api/server"
 	"github.com/filecoin-project/motion/blob"
+	"github.com/filecoin-project/motion/webhook"
 )
 
 type (
@@ -13,6 +15,10 @@ type (
 	options struct {
 		serverOptions []server.Option
 		blobStore     blob.Store
+		s3Enabled     bool
+		s3Options     []s3.Option
+		metricsAddr   string
+		webhooks      *webhook.Notifier
 	}
 )
 
@@ -49,3 +55,36 @@ func WithBlobStore(s blob.Store) Option {
 		return nil
 	}
 }
+
+// WithS3Gateway enables the S3 compatible gateway, instantiated with the given
+// options, which serves on its own listener in front of the blob.Store.
+// Defaults to disabled.
+// See: s3.NewGateway.
+func WithS3Gateway(s3Options ...s3.Option) Option {
+	return func(o *options) error {
+		o.s3Enabled = true
+		o.s3Options = s3Options
+		return nil
+	}
+}
+
+// WithMetricsListenAddr enables the Prometheus metrics endpoint, served at
+// /metrics on the given listen address.
+// Defaults to disabled.
+func WithMetricsListenAddr(addr string) Option {
+	return func(o *options) error {
+		o.metricsAddr = addr
+		return nil
+	}
+}
+
+// WithWebhookNotifier enables webhook notifications of blob lifecycle events
+// via the given notifier, which is started and shut down along with Motion.
+// Defaults to disabled.
+// See: server.WithWebhookNotifier.
+func WithWebhookNotifier(n *webhook.Notifier) Option {
+	return func(o *options) error {
+		o.webhooks = n
+		return nil
+	}
+}
@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Exporter is the destination to which spans are exported.
+type Exporter string
+
+const (
+	// ExporterNone disables the export of spans.
+	ExporterNone Exporter = "none"
+	// ExporterStdout writes spans as JSON to a writer, stdout by default.
+	ExporterStdout Exporter = "stdout"
+	// ExporterOTLP sends spans to an OTLP collector over HTTP.
+	ExporterOTLP Exporter = "otlp"
+)
+
+type (
+	// Option is a configurable parameter in Provider.
+	Option  func(*options) error
+	options struct {
+		exporter     Exporter
+		serviceName  string
+		sampleRatio  float64
+		stdout       io.Writer
+		otlpEndpoint string
+		otlpHeaders  map[string]string
+		otlpTimeout  time.Duration
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		exporter:     ExporterNone,
+		serviceName:  "motion",
+		sampleRatio:  1,
+		stdout:       os.Stdout,
+		otlpEndpoint: "http://localhost:4318/v1/traces",
+		otlpTimeout:  10 * time.Second,
+	}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithExporter sets the destination to which spans are exported.
+// Defaults to ExporterNone.
+func WithExporter(e Exporter) Option {
+	return func(o *options) error {
+		switch e {
+		case ExporterNone, ExporterStdout, ExporterOTLP:
+			o.exporter = e
+			return nil
+		default:
+			return fmt.Errorf("unknown tracing exporter: %q", e)
+		}
+	}
+}
+
+// WithServiceName sets the service name with which exported spans are tagged.
+// Defaults to "motion".
+func WithServiceName(name string) Option {
+	return func(o *options) error {
+		o.serviceName = name
+		return nil
+	}
+}
+
+// WithSampleRatio sets the ratio of traces to sample, between 0 and 1. Traces
+// started by a sampled remote parent are always sampled.
+// Defaults to 1, i.e. all traces are sampled.
+func WithSampleRatio(r float64) Option {
+	return func(o *options) error {
+		if r < 0 || r > 1 {
+			return errors.New("sample ratio must be between 0 and 1")
+		}
+		o.sampleRatio = r
+		return nil
+	}
+}
+
+// WithStdoutWriter sets the writer to which ExporterStdout writes spans.
+// Defaults to os.Stdout.
+func WithStdoutWriter(w io.Writer) Option {
+	return func(o *options) error {
+		o.stdout = w
+		return nil
+	}
+}
+
+// WithOTLPEndpoint sets the URL to which ExporterOTLP sends spans.
+// Defaults to "http://localhost:4318/v1/traces".
+func WithOTLPEndpoint(endpoint string) Option {
+	return func(o *options) error {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid OTLP endpoint: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid OTLP endpoint: unsupported scheme %q", u.Scheme)
+		}
+		o.otlpEndpoint = endpoint
+		return nil
+	}
+}
+
+// WithOTLPHeaders sets the headers sent along with every request to the OTLP
+// endpoint, e.g. for authentication.
+// Defaults to none.
+func WithOTLPHeaders(headers map[string]string) Option {
+	return func(o *options) error {
+		o.otlpHeaders = headers
+		return nil
+	}
+}
+
+// WithOTLPTimeout sets the timeout of each request to the OTLP endpoint.
+// Defaults to 10 seconds.
+func WithOTLPTimeout(t time.Duration) Option {
+	return func(o *options) error {
+		o.otlpTimeout = t
+		return nil
+	}
+}
@@ -0,0 +1,30 @@
+package tracing
+
+import (
+	"context"
+	"net/url"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+)
+
+// newOTLPExporter instantiates an exporter that sends spans to the OTLP
+// endpoint over HTTP, retrying transient failures. See:
+// https://opentelemetry.io/docs/specs/otlp/#otlphttp
+func newOTLPExporter(opts *options) (*otlptrace.Exporter, error) {
+	// The endpoint is validated by WithOTLPEndpoint.
+	endpoint, err := url.Parse(opts.otlpEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	exporterOpts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint.Host),
+		otlptracehttp.WithURLPath(endpoint.Path),
+		otlptracehttp.WithHeaders(opts.otlpHeaders),
+		otlptracehttp.WithTimeout(opts.otlpTimeout),
+	}
+	if endpoint.Scheme == "http" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	}
+	return otlptracehttp.New(context.Background(), exporterOpts...)
+}
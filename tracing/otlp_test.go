@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var body coltracepb.ExportTraceServiceRequest
+		if err := proto.Unmarshal(data, &body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests <- r
+		bodies <- &body
+	}))
+	defer collector.Close()
+
+	opts, err := newOptions(
+		WithOTLPEndpoint(collector.URL+"/v1/traces"),
+		WithOTLPHeaders(map[string]string{"Authorization": "Bearer fish"}),
+	)
+	require.NoError(t, err)
+	exporter, err := newOTLPExporter(opts)
+	require.NoError(t, err)
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	_, span := provider.Tracer("test").Start(context.Background(), "lobster")
+	span.SetAttributes(attribute.String("fish", "cod"), attribute.Int64("size", 42))
+	End(span, errors.New("boiled"))
+
+	r := <-requests
+	require.Equal(t, "/v1/traces", r.URL.Path)
+	require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+	require.Equal(t, "Bearer fish", r.Header.Get("Authorization"))
+
+	body := <-bodies
+	require.Len(t, body.ResourceSpans, 1)
+	scopeSpans := body.ResourceSpans[0].ScopeSpans
+	require.Len(t, scopeSpans, 1)
+	require.Equal(t, "test", scopeSpans[0].Scope.Name)
+	require.Len(t, scopeSpans[0].Spans, 1)
+	got := scopeSpans[0].Spans[0]
+	require.Equal(t, "lobster", got.Name)
+	require.Equal(t, span.SpanContext().TraceID().String(), hex.EncodeToString(got.TraceId))
+	require.Equal(t, span.SpanContext().SpanID().String(), hex.EncodeToString(got.SpanId))
+	require.Equal(t, tracepb.Status_STATUS_CODE_ERROR, got.Status.Code)
+	require.Equal(t, "boiled", got.Status.Message)
+	attributes := make(map[string]*commonpb.AnyValue)
+	for _, kv := range got.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	require.Equal(t, "cod", attributes["fish"].GetStringValue())
+	require.Equal(t, int64(42), attributes["size"].GetIntValue())
+	require.Len(t, got.Events, 1)
+}
+
+func TestOTLPExporter_ErrorStatus(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "", http.StatusBadRequest)
+	}))
+	defer collector.Close()
+
+	opts, err := newOptions(WithOTLPEndpoint(collector.URL))
+	require.NoError(t, err)
+	exporter, err := newOTLPExporter(opts)
+	require.NoError(t, err)
+	provider := sdktrace.NewTracerProvider()
+	_, span := provider.Tracer("test").Start(context.Background(), "lobster")
+	span.End()
+	err = exporter.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)})
+	require.ErrorContains(t, err, "400")
+}
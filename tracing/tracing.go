@@ -0,0 +1,116 @@
+// Package tracing configures OpenTelemetry tracing across Motion, and carries
+// the ID of the API request being served through context.Context so that it
+// can be attached to logs.
+//
+// Spans are created via the global tracer provider, e.g. otel.Tracer, which is
+// set by NewProvider. Until then, or if export is disabled, spans are not
+// recorded.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-log/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logger = log.Logger("motion/tracing")
+
+// Provider exports the spans recorded across Motion.
+type Provider struct {
+	*options
+	tracerProvider *sdktrace.TracerProvider
+}
+
+// NewProvider instantiates a new Provider, and sets it as the global tracer
+// provider along with the W3C trace context and baggage propagators, so that
+// traces started by clients of the Motion API are continued.
+// See Option.
+func NewProvider(o ...Option) (*Provider, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	p := &Provider{options: opts}
+	if opts.exporter == ExporterNone {
+		return p, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch opts.exporter {
+	case ExporterStdout:
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(opts.stdout)); err != nil {
+			return nil, fmt.Errorf("failed to instantiate stdout span exporter: %w", err)
+		}
+	case ExporterOTLP:
+		if exporter, err = newOTLPExporter(opts); err != nil {
+			return nil, fmt.Errorf("failed to instantiate OTLP span exporter: %w", err)
+		}
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(opts.serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	p.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.sampleRatio))),
+	)
+	otel.SetTracerProvider(p.tracerProvider)
+	logger.Infow("Tracing enabled", "exporter", opts.exporter, "sampleRatio", opts.sampleRatio)
+	return p, nil
+}
+
+// Shutdown exports any spans not yet exported, and stops exporting spans.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.tracerProvider == nil {
+		return nil
+	}
+	return p.tracerProvider.Shutdown(ctx)
+}
+
+// End ends the given span, marking it as failed with the given error, if any.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the context that carries the given ID of the
+// API request being served.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the API request carried by the context, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// LogFields returns the key-value pairs with which to correlate logs to the API
+// request and trace carried by the context, if any, e.g.
+// logger.With(tracing.LogFields(ctx)...).
+func LogFields(ctx context.Context) []any {
+	var fields []any
+	if id := RequestID(ctx); id != "" {
+		fields = append(fields, "requestID", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, "traceID", sc.TraceID().String())
+	}
+	return fields
+}
@@ -0,0 +1,95 @@
+package wallet
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+var _ ChainClient = (*FakeChainClient)(nil)
+
+// FakeChainClient is an in-memory ChainClient, for testing and local
+// development without access to a chain. Wallets are reported as having no
+// funds nor DataCap unless set otherwise.
+type FakeChainClient struct {
+	mu       sync.Mutex
+	balances map[address.Address]abi.TokenAmount
+	markets  map[address.Address]MarketBalance
+	dataCaps map[address.Address]abi.StoragePower
+	err      error
+}
+
+// NewFakeChainClient instantiates a new FakeChainClient.
+func NewFakeChainClient() *FakeChainClient {
+	return &FakeChainClient{
+		balances: make(map[address.Address]abi.TokenAmount),
+		markets:  make(map[address.Address]MarketBalance),
+		dataCaps: make(map[address.Address]abi.StoragePower),
+	}
+}
+
+// SetBalance sets the balance of the wallet.
+func (f *FakeChainClient) SetBalance(addr address.Address, balance abi.TokenAmount) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balances[addr] = balance
+}
+
+// SetMarketBalance sets the storage market escrow of the wallet.
+func (f *FakeChainClient) SetMarketBalance(addr address.Address, balance MarketBalance) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.markets[addr] = balance
+}
+
+// SetDataCap sets the DataCap of the wallet.
+func (f *FakeChainClient) SetDataCap(addr address.Address, dataCap abi.StoragePower) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dataCaps[addr] = dataCap
+}
+
+// SetError sets the error returned by all queries, or clears it if nil.
+func (f *FakeChainClient) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *FakeChainClient) WalletBalance(_ context.Context, addr address.Address) (abi.TokenAmount, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return abi.TokenAmount{}, f.err
+	}
+	if balance, ok := f.balances[addr]; ok {
+		return balance, nil
+	}
+	return abi.NewTokenAmount(0), nil
+}
+
+func (f *FakeChainClient) MarketBalance(_ context.Context, addr address.Address) (MarketBalance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return MarketBalance{}, f.err
+	}
+	if balance, ok := f.markets[addr]; ok {
+		return balance, nil
+	}
+	return MarketBalance{Escrow: abi.NewTokenAmount(0), Locked: abi.NewTokenAmount(0)}, nil
+}
+
+func (f *FakeChainClient) DataCap(_ context.Context, addr address.Address) (abi.StoragePower, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return abi.StoragePower{}, f.err
+	}
+	if dataCap, ok := f.dataCaps[addr]; ok {
+		return dataCap, nil
+	}
+	return abi.NewStoragePower(0), nil
+}
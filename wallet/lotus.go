@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+var _ ChainClient = (*LotusClient)(nil)
+
+// lotusRequestTimeout bounds each request to the Lotus API, so that checks do
+// not hang on an unresponsive endpoint.
+const lotusRequestTimeout = 30 * time.Second
+
+// LotusClient is a ChainClient that queries the chain via the JSON-RPC API of
+// a Lotus node, or of any API compatible with it such as Glif.
+type LotusClient struct {
+	endpoint   string
+	token      string
+	httpClient *http.Client
+	nextID     atomic.Int64
+}
+
+type (
+	rpcRequest struct {
+		JSONRPC string `json:"jsonrpc"`
+		ID      int64  `json:"id"`
+		Method  string `json:"method"`
+		Params  []any  `json:"params"`
+	}
+	rpcResponse struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	rpcError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+// NewLotusClient instantiates a new client of the Lotus JSON-RPC API at the
+// given endpoint, e.g. https://api.node.glif.io/rpc/v1, authenticated with the
+// given token if non-empty. Each request times out after 30 seconds.
+func NewLotusClient(endpoint, token string) *LotusClient {
+	return &LotusClient{
+		endpoint:   endpoint,
+		token:      token,
+		httpClient: &http.Client{Timeout: lotusRequestTimeout},
+	}
+}
+
+func (c *LotusClient) WalletBalance(ctx context.Context, addr address.Address) (abi.TokenAmount, error) {
+	var balance abi.TokenAmount
+	if err := c.call(ctx, "Filecoin.WalletBalance", &balance, addr); err != nil {
+		return abi.TokenAmount{}, err
+	}
+	return balance, nil
+}
+
+func (c *LotusClient) MarketBalance(ctx context.Context, addr address.Address) (MarketBalance, error) {
+	var balance MarketBalance
+	if err := c.call(ctx, "Filecoin.StateMarketBalance", &balance, addr, nil); err != nil {
+		return MarketBalance{}, err
+	}
+	return balance, nil
+}
+
+func (c *LotusClient) DataCap(ctx context.Context, addr address.Address) (abi.StoragePower, error) {
+	// The result is null if the address is not a verified client.
+	var dataCap *abi.StoragePower
+	if err := c.call(ctx, "Filecoin.StateVerifiedClientStatus", &dataCap, addr, nil); err != nil {
+		return abi.StoragePower{}, err
+	}
+	if dataCap == nil {
+		return abi.NewStoragePower(0), nil
+	}
+	return *dataCap, nil
+}
+
+// call calls the given JSON-RPC method with the given params, and decodes its
+// result into result.
+func (c *LotusClient) call(ctx context.Context, method string, result any, params ...any) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      c.nextID.Add(1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to call %s: unexpected response status %d", method, resp.StatusCode)
+	}
+	var response rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("failed to call %s: %s (%d)", method, response.Error.Message, response.Error.Code)
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
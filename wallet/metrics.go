@@ -0,0 +1,80 @@
+package wallet
+
+import (
+	stdbig "math/big"
+
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "wallet",
+		Name:      "checks_total",
+		Help:      `Number of wallet checks against the chain, by result ("success" or "failure").`,
+	}, []string{"result"})
+	metricBalance = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "motion",
+		Subsystem: "wallet",
+		Name:      "balance_attofil",
+		Help:      "Balance of the wallet in attoFIL, as of the last successful check.",
+	})
+	metricMarketEscrow = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "motion",
+		Subsystem: "wallet",
+		Name:      "market_escrow_attofil",
+		Help:      "Storage market escrow of the wallet in attoFIL, as of the last successful check.",
+	})
+	metricMarketLocked = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "motion",
+		Subsystem: "wallet",
+		Name:      "market_locked_attofil",
+		Help:      "Storage market escrow of the wallet locked by deals in attoFIL, as of the last successful check.",
+	})
+	metricDataCap = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "motion",
+		Subsystem: "wallet",
+		Name:      "datacap_bytes",
+		Help:      "Remaining DataCap of the wallet in bytes, as of the last successful check.",
+	})
+	metricLow = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "motion",
+		Subsystem: "wallet",
+		Name:      "low",
+		Help:      `Whether the wallet "funds" or "datacap" are below the configured minimum (1) or not (0).`,
+	}, []string{"resource"})
+)
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+func reportStatus(status *Status) {
+	metricBalance.Set(toFloat(status.Balance))
+	metricMarketEscrow.Set(toFloat(status.MarketEscrow))
+	metricMarketLocked.Set(toFloat(status.MarketLocked))
+	metricDataCap.Set(toFloat(status.DataCap))
+	metricLow.WithLabelValues("funds").Set(boolFloat(status.FundsLow))
+	metricLow.WithLabelValues("datacap").Set(boolFloat(status.DataCapLow))
+}
+
+// toFloat approximates the given amount as a float, for reporting as a metric.
+func toFloat(v big.Int) float64 {
+	if v.Nil() {
+		return 0
+	}
+	f, _ := new(stdbig.Float).SetInt(v.Int).Float64()
+	return f
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+// Package wallet reports the funds and DataCap of the wallet with which Motion
+// makes deals.
+//
+// The wallet balance, its storage market escrow and its remaining DataCap are
+// queried from the chain via a ChainClient, and compared to configurable
+// thresholds below which deal making should be held, since deals would
+// otherwise stall.
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-log/v2"
+)
+
+var logger = log.Logger("motion/wallet")
+
+var (
+	// ErrInsufficientFunds signals that the wallet balance or its available
+	// market escrow is below the configured minimum.
+	ErrInsufficientFunds = errors.New("wallet funds are below the configured minimum")
+	// ErrInsufficientDataCap signals that the DataCap of the wallet is below
+	// the configured minimum.
+	ErrInsufficientDataCap = errors.New("wallet DataCap is below the configured minimum")
+)
+
+type (
+	// ChainClient queries the state of wallets from the Filecoin chain.
+	ChainClient interface {
+		// WalletBalance returns the balance of the wallet.
+		WalletBalance(context.Context, address.Address) (abi.TokenAmount, error)
+		// MarketBalance returns the funds of the wallet in the storage market
+		// escrow.
+		MarketBalance(context.Context, address.Address) (MarketBalance, error)
+		// DataCap returns the remaining DataCap of the wallet, which is zero if
+		// the wallet is not a verified client.
+		DataCap(context.Context, address.Address) (abi.StoragePower, error)
+	}
+	// MarketBalance is the storage market escrow of a wallet.
+	MarketBalance struct {
+		// Escrow is the total amount in escrow.
+		Escrow abi.TokenAmount
+		// Locked is the amount in escrow locked by deals.
+		Locked abi.TokenAmount
+	}
+	// Status is the state of a wallet as of its last check.
+	Status struct {
+		Address      address.Address
+		Balance      abi.TokenAmount
+		MarketEscrow abi.TokenAmount
+		MarketLocked abi.TokenAmount
+		DataCap      abi.StoragePower
+		// CheckedAt is the time of the last successful check.
+		CheckedAt time.Time
+		// Err is the error that occurred during the last check, if any, in
+		// which case the rest of the status is as of the last successful one.
+		Err error
+		// FundsLow is whether the balance or the available market escrow is
+		// below the configured minimum.
+		FundsLow bool
+		// DataCapLow is whether the DataCap is below the configured minimum.
+		DataCapLow bool
+	}
+)
+
+// MarketAvailable returns the amount in market escrow that is not locked by
+// deals, i.e. available to pay for new deals.
+func (s *Status) MarketAvailable() abi.TokenAmount {
+	if s.MarketEscrow.Nil() || s.MarketLocked.Nil() {
+		return abi.NewTokenAmount(0)
+	}
+	return big.Sub(s.MarketEscrow, s.MarketLocked)
+}
+
+// AllowsDeals checks whether new deals may be made with the wallet, given
+// whether they are verified. It returns ErrInsufficientFunds or
+// ErrInsufficientDataCap otherwise.
+func (s *Status) AllowsDeals(verified bool) error {
+	switch {
+	case s.FundsLow:
+		return ErrInsufficientFunds
+	case verified && s.DataCapLow:
+		return ErrInsufficientDataCap
+	default:
+		return nil
+	}
+}
+
+// Monitor checks the status of a wallet against the configured thresholds, and
+// keeps the status as of the last check.
+type Monitor struct {
+	*options
+	client ChainClient
+
+	mu     sync.RWMutex
+	status *Status
+}
+
+// NewMonitor instantiates a new Monitor that queries the chain via the given
+// client.
+// See Option.
+func NewMonitor(client ChainClient, o ...Option) (*Monitor, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	return &Monitor{
+		options: opts,
+		client:  client,
+	}, nil
+}
+
+// Check queries the chain for the status of the wallet at the given address,
+// and records it as the latest status. If the query fails, the returned
+// status is the last successful one for the address, if any, with Err set.
+func (m *Monitor) Check(ctx context.Context, addr address.Address) Status {
+	status, err := m.query(ctx, addr)
+	metricChecks.WithLabelValues(resultLabel(err)).Inc()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		logger.Warnw("Failed to check wallet", "address", addr, "err", err)
+		if m.status != nil && m.status.Address == addr {
+			status = *m.status
+		}
+		status.Err = err
+	} else {
+		reportStatus(&status)
+		if previous := m.status; previous == nil || previous.FundsLow != status.FundsLow || previous.DataCapLow != status.DataCapLow {
+			logger.Infow("Checked wallet", "address", addr, "balance", status.Balance, "marketAvailable", status.MarketAvailable(), "dataCap", status.DataCap, "fundsLow", status.FundsLow, "dataCapLow", status.DataCapLow)
+		}
+	}
+	m.status = &status
+	return status
+}
+
+// Status returns the status of the wallet as of the last check, if any.
+func (m *Monitor) Status() (Status, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.status == nil {
+		return Status{}, false
+	}
+	return *m.status, true
+}
+
+func (m *Monitor) query(ctx context.Context, addr address.Address) (Status, error) {
+	status := Status{Address: addr}
+	var err error
+	if status.Balance, err = m.client.WalletBalance(ctx, addr); err != nil {
+		return status, fmt.Errorf("failed to get wallet balance: %w", err)
+	}
+	market, err := m.client.MarketBalance(ctx, addr)
+	if err != nil {
+		return status, fmt.Errorf("failed to get market balance: %w", err)
+	}
+	status.MarketEscrow, status.MarketLocked = market.Escrow, market.Locked
+	if status.DataCap, err = m.client.DataCap(ctx, addr); err != nil {
+		return status, fmt.Errorf("failed to get DataCap: %w", err)
+	}
+	if status.DataCap.Nil() {
+		status.DataCap = abi.NewStoragePower(0)
+	}
+	status.CheckedAt = time.Now()
+	status.FundsLow = status.Balance.LessThan(m.minBalance) || status.MarketAvailable().LessThan(m.minMarketEscrow)
+	status.DataCapLow = status.DataCap.LessThan(m.minDataCap)
+	return status, nil
+}
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor(t *testing.T) {
+	addr, err := address.NewFromString("f01234")
+	require.NoError(t, err)
+	chain := NewFakeChainClient()
+	subject, err := NewMonitor(chain,
+		WithMinBalance(abi.NewTokenAmount(10)),
+		WithMinMarketEscrow(abi.NewTokenAmount(100)),
+		WithMinDataCap(abi.NewStoragePower(1<<30)),
+	)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, ok := subject.Status()
+	require.False(t, ok)
+
+	// An empty wallet is low on funds and DataCap.
+	status := subject.Check(ctx, addr)
+	require.NoError(t, status.Err)
+	require.True(t, status.FundsLow)
+	require.True(t, status.DataCapLow)
+	require.ErrorIs(t, status.AllowsDeals(false), ErrInsufficientFunds)
+
+	// Locked escrow is not available to new deals.
+	chain.SetBalance(addr, abi.NewTokenAmount(10))
+	chain.SetMarketBalance(addr, MarketBalance{Escrow: abi.NewTokenAmount(150), Locked: abi.NewTokenAmount(60)})
+	status = subject.Check(ctx, addr)
+	require.Equal(t, abi.NewTokenAmount(90), status.MarketAvailable())
+	require.True(t, status.FundsLow)
+
+	chain.SetMarketBalance(addr, MarketBalance{Escrow: abi.NewTokenAmount(160), Locked: abi.NewTokenAmount(60)})
+	status = subject.Check(ctx, addr)
+	require.False(t, status.FundsLow)
+	require.NoError(t, status.AllowsDeals(false))
+	require.ErrorIs(t, status.AllowsDeals(true), ErrInsufficientDataCap)
+
+	chain.SetDataCap(addr, abi.NewStoragePower(1<<30))
+	status = subject.Check(ctx, addr)
+	require.NoError(t, status.AllowsDeals(true))
+
+	// Failed checks keep the last known status.
+	chain.SetError(errors.New("fish"))
+	status = subject.Check(ctx, addr)
+	require.ErrorContains(t, status.Err, "fish")
+	require.Equal(t, abi.NewTokenAmount(10), status.Balance)
+	require.NoError(t, status.AllowsDeals(true))
+	latest, ok := subject.Status()
+	require.True(t, ok)
+	require.Equal(t, status, latest)
+}
+
+func TestLotusClient(t *testing.T) {
+	addr, err := address.NewFromString("f01234")
+	require.NoError(t, err)
+	var methods []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer lobster", r.Header.Get("Authorization"))
+		var request rpcRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		require.Equal(t, addr.String(), request.Params[0])
+		methods = append(methods, request.Method)
+		var result any
+		switch request.Method {
+		case "Filecoin.WalletBalance":
+			result = "42"
+		case "Filecoin.StateMarketBalance":
+			result = map[string]string{"Escrow": "20", "Locked": "5"}
+		case "Filecoin.StateVerifiedClientStatus":
+			result = nil
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": 1, "message": "unknown method"}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"result": result})
+	}))
+	t.Cleanup(testServer.Close)
+
+	subject := NewLotusClient(testServer.URL, "lobster")
+	ctx := context.Background()
+	balance, err := subject.WalletBalance(ctx, addr)
+	require.NoError(t, err)
+	require.Equal(t, abi.NewTokenAmount(42), balance)
+	market, err := subject.MarketBalance(ctx, addr)
+	require.NoError(t, err)
+	require.Equal(t, MarketBalance{Escrow: abi.NewTokenAmount(20), Locked: abi.NewTokenAmount(5)}, market)
+	dataCap, err := subject.DataCap(ctx, addr)
+	require.NoError(t, err)
+	require.Equal(t, abi.NewStoragePower(0), dataCap)
+	require.Equal(t, []string{"Filecoin.WalletBalance", "Filecoin.StateMarketBalance", "Filecoin.StateVerifiedClientStatus"}, methods)
+
+	require.ErrorContains(t, subject.call(ctx, "Filecoin.Fish", nil, addr), "unknown method")
+}
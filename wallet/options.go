@@ -0,0 +1,71 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+type (
+	// Option is a configurable parameter in Monitor.
+	Option  func(*options) error
+	options struct {
+		minBalance      abi.TokenAmount
+		minMarketEscrow abi.TokenAmount
+		minDataCap      abi.StoragePower
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		minBalance:      abi.NewTokenAmount(0),
+		minMarketEscrow: abi.NewTokenAmount(0),
+		minDataCap:      abi.NewStoragePower(0),
+	}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithMinBalance sets the wallet balance, in attoFIL, below which the wallet
+// funds are reported as low.
+// Defaults to zero, i.e. never low.
+func WithMinBalance(v abi.TokenAmount) Option {
+	return func(o *options) error {
+		if v.Nil() || v.Sign() < 0 {
+			return errors.New("minimum balance must be a non-negative amount")
+		}
+		o.minBalance = v
+		return nil
+	}
+}
+
+// WithMinMarketEscrow sets the available storage market escrow, i.e. not
+// locked by deals, in attoFIL, below which the wallet funds are reported as
+// low.
+// Defaults to zero, i.e. never low.
+func WithMinMarketEscrow(v abi.TokenAmount) Option {
+	return func(o *options) error {
+		if v.Nil() || v.Sign() < 0 {
+			return errors.New("minimum market escrow must be a non-negative amount")
+		}
+		o.minMarketEscrow = v
+		return nil
+	}
+}
+
+// WithMinDataCap sets the DataCap, in bytes, below which the wallet DataCap is
+// reported as low. Low DataCap only holds verified deals.
+// Defaults to zero, i.e. never low.
+func WithMinDataCap(v abi.StoragePower) Option {
+	return func(o *options) error {
+		if v.Nil() || v.Sign() < 0 {
+			return errors.New("minimum DataCap must be a non-negative amount")
+		}
+		o.minDataCap = v
+		return nil
+	}
+}
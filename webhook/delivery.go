@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderEvent is the HTTP header that carries the type of delivered event.
+	HeaderEvent = "X-Motion-Event"
+	// HeaderDelivery is the HTTP header that uniquely identifies a delivery
+	// attempt of an event to an endpoint.
+	HeaderDelivery = "X-Motion-Delivery"
+	// HeaderTimestamp is the HTTP header that carries the Unix time in seconds
+	// at which a delivery was signed.
+	HeaderTimestamp = "X-Motion-Timestamp"
+	// HeaderSignature is the HTTP header that carries the delivery signature.
+	// See: Sign.
+	HeaderSignature = "X-Motion-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+// Sign computes the signature of an event delivery, as set in HeaderSignature.
+// The signature is the hex encoded HMAC-SHA256, keyed by the subscription
+// secret, of the delivery timestamp in decimal, followed by '.' and the request
+// body. It is prefixed by "sha256=".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks whether the given signature, timestamp and body are of a
+// delivery signed with the given secret, and that the delivery was signed
+// within the given tolerance of now. Zero tolerance disables the timestamp
+// check.
+func Verify(secret, signature, timestamp string, body []byte, tolerance time.Duration) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if tolerance > 0 {
+		skew := time.Since(time.Unix(ts, 0))
+		if skew > tolerance || skew < -tolerance {
+			return false
+		}
+	}
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+type (
+	// delivery is an event pending delivery to a subscription.
+	delivery struct {
+		ID             string    `json:"id"`
+		SubscriptionID string    `json:"subscriptionId"`
+		Event          *Event    `json:"event"`
+		Attempts       int       `json:"attempts"`
+		NextAttempt    time.Time `json:"nextAttempt"`
+	}
+	// outbox holds the pending deliveries, each of which is persisted as a
+	// JSON file until it succeeds or is discarded.
+	outbox struct {
+		dir string
+
+		mu      sync.Mutex
+		pending map[string]*delivery
+	}
+)
+
+func openOutbox(dir string) (*outbox, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create webhook outbox directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook outbox directory: %w", err)
+	}
+	o := &outbox{
+		dir:     dir,
+		pending: make(map[string]*delivery),
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pending webhook delivery: %w", err)
+		}
+		var d delivery
+		if err := json.Unmarshal(data, &d); err != nil || d.Event == nil {
+			logger.Warnw("Discarding corrupt pending webhook delivery", "path", path, "err", err)
+			_ = os.Remove(path)
+			continue
+		}
+		o.pending[d.ID] = &d
+	}
+	return o, nil
+}
+
+func (o *outbox) put(d *delivery) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := writeJsonFile(o.path(d.ID), d); err != nil {
+		return err
+	}
+	o.pending[d.ID] = d
+	return nil
+}
+
+func (o *outbox) remove(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.pending, id)
+	if err := os.Remove(o.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// due returns the deliveries that are due at the given time, in ascending order
+// of next attempt, along with the time at which the next delivery that is not
+// yet due becomes due. The returned time is zero if there is no such delivery.
+func (o *outbox) due(now time.Time) ([]*delivery, time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var due []*delivery
+	var next time.Time
+	for _, d := range o.pending {
+		switch {
+		case !d.NextAttempt.After(now):
+			due = append(due, d)
+		case next.IsZero() || d.NextAttempt.Before(next):
+			next = d.NextAttempt
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttempt.Before(due[j].NextAttempt) })
+	return due, next
+}
+
+func (o *outbox) path(id string) string {
+	return filepath.Join(o.dir, id+".json")
+}
+
+// attempt makes a single attempt to deliver the given delivery. Successful and
+// exhausted deliveries are removed from the outbox, and failed ones are
+// rescheduled with exponential backoff.
+func (n *Notifier) attempt(ctx context.Context, d *delivery) {
+	logger := logger.With("delivery", d.ID, "event", d.Event.ID, "type", d.Event.Type, "subscription", d.SubscriptionID)
+	sub, ok := n.subscriptions.get(d.SubscriptionID)
+	if !ok {
+		logger.Debug("Discarding delivery to removed subscription")
+		n.removeDelivery(d)
+		return
+	}
+	err := n.send(ctx, sub, d)
+	if err == nil {
+		metricDeliveries.WithLabelValues(resultSuccess).Inc()
+		logger.Debug("Delivered webhook event successfully")
+		n.removeDelivery(d)
+		return
+	}
+	if ctx.Err() != nil {
+		// Shutting down; the delivery is retried after restart.
+		return
+	}
+	d.Attempts++
+	if d.Attempts >= n.maxAttempts {
+		metricDeliveries.WithLabelValues(resultDiscarded).Inc()
+		logger.Errorw("Discarding webhook delivery after exhausting attempts", "attempts", d.Attempts, "err", err)
+		n.removeDelivery(d)
+		return
+	}
+	metricDeliveries.WithLabelValues(resultRetry).Inc()
+	backoff := n.minRetryBackoff << (d.Attempts - 1)
+	if backoff > n.maxRetryBackoff || backoff <= 0 {
+		backoff = n.maxRetryBackoff
+	}
+	d.NextAttempt = time.Now().Add(backoff)
+	logger.Warnw("Failed to deliver webhook event; retrying later", "attempts", d.Attempts, "nextAttempt", d.NextAttempt, "err", err)
+	if err := n.outbox.put(d); err != nil {
+		logger.Errorw("Failed to persist webhook delivery retry", "err", err)
+	}
+}
+
+func (n *Notifier) removeDelivery(d *delivery) {
+	if err := n.outbox.remove(d.ID); err != nil {
+		logger.Errorw("Failed to remove webhook delivery", "delivery", d.ID, "err", err)
+	}
+}
+
+// send posts the delivery event to the subscription endpoint, and returns an
+// error if the endpoint does not respond with a 2XX status code.
+func (n *Notifier) send(ctx context.Context, sub Subscription, d *delivery) error {
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, n.deliveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "motion-webhook")
+	req.Header.Set(HeaderEvent, string(d.Event.Type))
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, body))
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with status %s", strings.TrimSpace(resp.Status))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/filecoin-project/motion/blob"
+	"github.com/google/uuid"
+)
+
+// EventType identifies a blob lifecycle transition.
+type EventType string
+
+const (
+	// EventBlobStored is emitted once a blob is stored.
+	EventBlobStored EventType = "blob.stored"
+	// EventBlobPacked is emitted the first time a blob is observed as part of
+	// a piece.
+	EventBlobPacked EventType = "blob.packed"
+	// EventDealProposed is emitted when a deal for a piece containing a blob
+	// is proposed to a storage provider.
+	EventDealProposed EventType = "deal.proposed"
+	// EventDealPublished is emitted when a deal for a piece containing a blob
+	// is published on chain.
+	EventDealPublished EventType = "deal.published"
+	// EventDealActive is emitted when a deal for a piece containing a blob
+	// becomes active, i.e. the storage provider has proven the piece is sealed.
+	EventDealActive EventType = "deal.active"
+	// EventDealExpired is emitted when a deal, or a deal proposal, for a
+	// piece containing a blob expires.
+	EventDealExpired EventType = "deal.expired"
+	// EventDealSlashed is emitted when a deal for a piece containing a blob is
+	// slashed.
+	EventDealSlashed EventType = "deal.slashed"
+	// EventDealFailed is emitted when a deal for a piece containing a blob is
+	// rejected by the storage provider or otherwise fails.
+	EventDealFailed EventType = "deal.failed"
+	// EventLocalCleaned is emitted when the local copy of a blob is removed
+	// because it is replicated onto all storage providers.
+	EventLocalCleaned EventType = "local.cleaned"
+)
+
+// EventTypes lists all the event types emitted by Notifier.
+var EventTypes = []EventType{
+	EventBlobStored,
+	EventBlobPacked,
+	EventDealProposed,
+	EventDealPublished,
+	EventDealActive,
+	EventDealExpired,
+	EventDealSlashed,
+	EventDealFailed,
+	EventLocalCleaned,
+}
+
+type (
+	// Event is the payload delivered to webhook endpoints.
+	Event struct {
+		// ID uniquely identifies the event. Deliveries retried after failure
+		// carry the same ID, which endpoints may use to discard duplicates.
+		ID string `json:"id"`
+		// Type is the type of the event.
+		Type EventType `json:"type"`
+		// Time is the time at which the transition was observed.
+		Time time.Time `json:"time"`
+		// BlobID is the ID of the blob to which the event relates.
+		BlobID string `json:"blobId"`
+		// Size is the blob size in bytes, only set for EventBlobStored.
+		Size uint64 `json:"size,omitempty"`
+		// SHA256 is the hex encoded SHA-256 digest of the blob content, only set
+		// for EventBlobStored if known.
+		SHA256 string `json:"sha256,omitempty"`
+		// PieceCID is the CID of the piece containing the blob, set for
+		// EventBlobPacked and deal events.
+		PieceCID string `json:"pieceCid,omitempty"`
+		// Deal describes the deal, set for deal events only.
+		Deal *EventDeal `json:"deal,omitempty"`
+	}
+	// EventDeal describes the deal to which an event relates.
+	EventDeal struct {
+		// Provider is the address of the storage provider.
+		Provider string `json:"provider"`
+		// Status is the deal status as reported by the blob store.
+		Status string `json:"status"`
+		// Expiration is the time at which the deal expires.
+		Expiration time.Time `json:"expiration,omitempty"`
+	}
+)
+
+// dealEventTypes maps the deal statuses reported by blob stores to the events
+// emitted on transitioning to them.
+var dealEventTypes = map[string]EventType{
+	"proposed":         EventDealProposed,
+	"published":        EventDealPublished,
+	"active":           EventDealActive,
+	"expired":          EventDealExpired,
+	"proposal_expired": EventDealExpired,
+	"slashed":          EventDealSlashed,
+	"rejected":         EventDealFailed,
+	"error":            EventDealFailed,
+}
+
+// isTerminalDealStatus checks whether a deal with the given status can no
+// longer transition. Rejected and failed deals are not considered terminal, as
+// the blob store may retry them.
+func isTerminalDealStatus(status string) bool {
+	switch status {
+	case "expired", "proposal_expired", "slashed":
+		return true
+	default:
+		return false
+	}
+}
+
+func newEvent(typ EventType, id blob.ID) *Event {
+	return &Event{
+		ID:     uuid.NewString(),
+		Type:   typ,
+		Time:   time.Now().UTC(),
+		BlobID: id.String(),
+	}
+}
+
+func isKnownEventType(typ EventType) bool {
+	for _, known := range EventTypes {
+		if typ == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	resultSuccess   = "success"
+	resultRetry     = "retry"
+	resultDiscarded = "discarded"
+)
+
+var (
+	metricEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "webhook",
+		Name:      "events_total",
+		Help:      "Number of blob lifecycle events emitted, by event type.",
+	}, []string{"type"})
+	metricDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "motion",
+		Subsystem: "webhook",
+		Name:      "delivery_attempts_total",
+		Help:      `Number of attempts to deliver events to endpoints, by result ("success", "retry" or "discarded").`,
+	}, []string{"result"})
+)
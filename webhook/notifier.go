@@ -0,0 +1,271 @@
+// Package webhook notifies HTTP endpoints of blob lifecycle transitions.
+//
+// Events are emitted when blobs are stored and when their local copy is cleaned
+// up, and by periodically describing the blobs that are yet to be replicated
+// to observe when they are packed and their deals change status. Each
+// event is delivered to every subscribed endpoint as a JSON HTTP POST request,
+// signed with the subscription secret. Deliveries are persisted until they
+// succeed, and are retried with exponential backoff.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/motion/blob"
+	"github.com/google/uuid"
+	"github.com/ipfs/go-log/v2"
+)
+
+var logger = log.Logger("motion/webhook")
+
+// Notifier delivers blob lifecycle events to subscribed endpoints.
+type Notifier struct {
+	*options
+	subscriptions *subscriptions
+	outbox        *outbox
+	watched       *watchList
+	store         blob.Store
+
+	wake      chan struct{}
+	closing   chan struct{}
+	closeOnce sync.Once
+	closed    sync.WaitGroup
+}
+
+// NewNotifier instantiates a new Notifier, loading any subscriptions, pending
+// deliveries and watched blobs persisted by a previous instance.
+// Events may be emitted before the notifier is started, in which case they are
+// delivered once it starts.
+// See Option.
+func NewNotifier(o ...Option) (*Notifier, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(opts.dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create webhook directory: %w", err)
+	}
+	subs, err := openSubscriptions(filepath.Join(opts.dir, "subscriptions.json"), opts.endpoints)
+	if err != nil {
+		return nil, err
+	}
+	outbox, err := openOutbox(filepath.Join(opts.dir, "outbox"))
+	if err != nil {
+		return nil, err
+	}
+	watched, err := openWatchList(filepath.Join(opts.dir, "watched.db"), filepath.Join(opts.dir, "watched.json"))
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{
+		options:       opts,
+		subscriptions: subs,
+		outbox:        outbox,
+		watched:       watched,
+		wake:          make(chan struct{}, 1),
+		closing:       make(chan struct{}),
+	}, nil
+}
+
+// Start starts delivering events, and periodically describing the watched blobs
+// via the given store to observe their lifecycle transitions.
+// See Shutdown.
+func (n *Notifier) Start(_ context.Context, store blob.Store) error {
+	n.store = store
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-n.closing
+		cancel()
+	}()
+	n.closed.Add(2)
+	go n.deliverLoop(ctx)
+	go n.watchLoop(ctx)
+	logger.Infow("Webhook notifier started successfully.", "pollInterval", n.pollInterval)
+	return nil
+}
+
+func (n *Notifier) deliverLoop(ctx context.Context) {
+	defer n.closed.Done()
+	for {
+		due, next := n.outbox.due(time.Now())
+		for _, d := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			n.attempt(ctx, d)
+		}
+		if len(due) != 0 {
+			// Attempts take time; check again for deliveries that became due
+			// in the meantime.
+			continue
+		}
+		wait := n.maxRetryBackoff
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-n.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (n *Notifier) watchLoop(ctx context.Context) {
+	defer n.closed.Done()
+	ticker := time.NewTicker(n.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// There is no point in describing blobs if no one is listening.
+			if !n.subscriptions.isEmpty() {
+				n.poll(ctx)
+			}
+		}
+	}
+}
+
+// Shutdown stops delivering events and watching blobs. Pending deliveries are
+// resumed once a notifier is started with the same directory. Shutting down
+// more than once has no further effect.
+func (n *Notifier) Shutdown(ctx context.Context) error {
+	n.closeOnce.Do(func() { close(n.closing) })
+	done := make(chan struct{})
+	go func() {
+		n.closed.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return n.watched.close()
+	}
+}
+
+// Subscribe registers an endpoint to which events of the given types are
+// delivered, or all events if no types are given. Deliveries are signed with
+// the given secret, or a randomly generated one if empty. The subscription is
+// persisted, and remains in effect until unsubscribed.
+// ErrInvalidEndpoint or ErrUnknownEventType is returned if the endpoint is not
+// an absolute HTTP(S) URL, or any of the types is unknown respectively.
+func (n *Notifier) Subscribe(endpoint, secret string, types ...EventType) (*Subscription, error) {
+	if err := validateEndpoint(endpoint, types); err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		var random [32]byte
+		if _, err := rand.Read(random[:]); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(random[:])
+	}
+	sub := Subscription{
+		ID:        uuid.NewString(),
+		URL:       endpoint,
+		Events:    types,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := n.subscriptions.add(sub); err != nil {
+		return nil, err
+	}
+	logger.Infow("Webhook subscription registered", "id", sub.ID, "url", sub.URL, "events", sub.Events)
+	return &sub, nil
+}
+
+// Unsubscribe removes the subscription with the given ID, discarding any of
+// its pending deliveries. ErrSubscriptionNotFound is returned if no such
+// subscription was registered via Subscribe.
+func (n *Notifier) Unsubscribe(id string) error {
+	if err := n.subscriptions.remove(id); err != nil {
+		return err
+	}
+	logger.Infow("Webhook subscription removed", "id", id)
+	return nil
+}
+
+// Subscription returns the subscription with the given ID, registered via
+// Subscribe. ErrSubscriptionNotFound is returned if there is no such
+// subscription.
+func (n *Notifier) Subscription(id string) (*Subscription, error) {
+	for _, sub := range n.subscriptions.list() {
+		if sub.ID == id {
+			return &sub, nil
+		}
+	}
+	return nil, ErrSubscriptionNotFound
+}
+
+// Subscriptions lists the subscriptions registered via Subscribe, in ascending
+// order of creation. Statically configured endpoints are not listed.
+func (n *Notifier) Subscriptions() []Subscription {
+	return n.subscriptions.list()
+}
+
+// BlobStored emits EventBlobStored for the blob with the given descriptor, and
+// starts watching the blob for further transitions, unless there are no
+// subscriptions to notify of them.
+func (n *Notifier) BlobStored(desc *blob.Descriptor) {
+	if !n.subscriptions.isEmpty() {
+		if err := n.watched.watch(desc.ID); err != nil {
+			logger.Errorw("Failed to watch stored blob", "id", desc.ID, "err", err)
+		}
+	}
+	event := newEvent(EventBlobStored, desc.ID)
+	event.Size = desc.Size
+	event.SHA256 = hex.EncodeToString(desc.SHA256)
+	if err := n.emit(event); err != nil {
+		logger.Errorw("Failed to emit webhook event", "type", event.Type, "id", desc.ID, "err", err)
+	}
+}
+
+// LocalCleaned emits EventLocalCleaned for the blob with the given ID.
+func (n *Notifier) LocalCleaned(id blob.ID) {
+	event := newEvent(EventLocalCleaned, id)
+	if err := n.emit(event); err != nil {
+		logger.Errorw("Failed to emit webhook event", "type", event.Type, "id", id, "err", err)
+	}
+}
+
+// emit queues the given events for delivery to every subscription that matches
+// their type.
+func (n *Notifier) emit(events ...*Event) error {
+	var errs []error
+	for _, event := range events {
+		metricEvents.WithLabelValues(string(event.Type)).Inc()
+		for _, sub := range n.subscriptions.matching(event.Type) {
+			d := &delivery{
+				ID:             uuid.NewString(),
+				SubscriptionID: sub.ID,
+				Event:          event,
+				NextAttempt:    event.Time,
+			}
+			if err := n.outbox.put(d); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(events) != 0 {
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+	}
+	return errors.Join(errs...)
+}
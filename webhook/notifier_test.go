@@ -0,0 +1,213 @@
+package webhook
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/motion/blob"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingEndpoint is a webhook endpoint that records the events it receives,
+// and fails the first given number of deliveries.
+type recordingEndpoint struct {
+	t      *testing.T
+	secret string
+	fail   int
+
+	mu       sync.Mutex
+	attempts int
+	events   []Event
+}
+
+func (e *recordingEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(e.t, err)
+	require.True(e.t, Verify(e.secret, r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), body, time.Minute))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.attempts++
+	if e.attempts <= e.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var event Event
+	require.NoError(e.t, json.Unmarshal(body, &event))
+	require.Equal(e.t, string(event.Type), r.Header.Get(HeaderEvent))
+	e.events = append(e.events, event)
+}
+
+func (e *recordingEndpoint) received() []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Event(nil), e.events...)
+}
+
+func TestNotifierDeliversSignedEvents(t *testing.T) {
+	endpoint := &recordingEndpoint{t: t, secret: "fish", fail: 2}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	store := blob.NewLocalStore(t.TempDir())
+	subject, err := NewNotifier(WithDir(t.TempDir()), WithRetryBackoff(10*time.Millisecond, 50*time.Millisecond))
+	require.NoError(t, err)
+	sub, err := subject.Subscribe(server.URL, endpoint.secret, EventBlobStored)
+	require.NoError(t, err)
+	require.Equal(t, []Subscription{*sub}, subject.Subscriptions())
+	require.NoError(t, subject.Start(context.Background(), store))
+	defer func() { require.NoError(t, subject.Shutdown(context.Background())) }()
+
+	desc, err := store.Put(context.Background(), strings.NewReader("fish"))
+	require.NoError(t, err)
+	subject.BlobStored(desc)
+	// Not subscribed to, therefore never delivered.
+	subject.LocalCleaned(desc.ID)
+
+	require.Eventually(t, func() bool { return len(endpoint.received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	event := endpoint.received()[0]
+	require.Equal(t, EventBlobStored, event.Type)
+	require.Equal(t, desc.ID.String(), event.BlobID)
+	require.Equal(t, uint64(4), event.Size)
+	require.Equal(t, hex.EncodeToString(desc.SHA256), event.SHA256)
+	require.Equal(t, 3, endpoint.attempts, "delivery must be retried until it succeeds")
+
+	require.NoError(t, subject.Unsubscribe(sub.ID))
+	require.ErrorIs(t, subject.Unsubscribe(sub.ID), ErrSubscriptionNotFound)
+	require.Empty(t, subject.Subscriptions())
+}
+
+func TestNotifierResumesPendingDeliveries(t *testing.T) {
+	dir := t.TempDir()
+	endpoint := &recordingEndpoint{t: t, secret: "lobster"}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	id, err := blob.NewID()
+	require.NoError(t, err)
+	subject, err := NewNotifier(WithDir(dir), WithEndpoint(server.URL, endpoint.secret))
+	require.NoError(t, err)
+	// Emitted before start, and never delivered by this instance.
+	subject.LocalCleaned(*id)
+	require.NoError(t, subject.Shutdown(context.Background()))
+	// Shutting down again has no further effect.
+	require.NoError(t, subject.Shutdown(context.Background()))
+
+	subject, err = NewNotifier(WithDir(dir), WithEndpoint(server.URL, endpoint.secret))
+	require.NoError(t, err)
+	require.Empty(t, subject.Subscriptions())
+	require.NoError(t, subject.Start(context.Background(), blob.NewLocalStore(t.TempDir())))
+	defer func() { require.NoError(t, subject.Shutdown(context.Background())) }()
+
+	require.Eventually(t, func() bool { return len(endpoint.received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, EventLocalCleaned, endpoint.received()[0].Type)
+	require.Equal(t, id.String(), endpoint.received()[0].BlobID)
+}
+
+func TestSubscribeValidates(t *testing.T) {
+	subject, err := NewNotifier(WithDir(t.TempDir()))
+	require.NoError(t, err)
+	_, err = subject.Subscribe("ftp://example.com", "")
+	require.ErrorIs(t, err, ErrInvalidEndpoint)
+	_, err = subject.Subscribe("/relative", "")
+	require.ErrorIs(t, err, ErrInvalidEndpoint)
+	_, err = subject.Subscribe("https://example.com", "", "blob.eaten")
+	require.ErrorIs(t, err, ErrUnknownEventType)
+	sub, err := subject.Subscribe("https://example.com", "")
+	require.NoError(t, err)
+	require.Len(t, sub.Secret, 64)
+}
+
+func TestObserve(t *testing.T) {
+	id, err := blob.NewID()
+	require.NoError(t, err)
+	describe := func(statuses ...string) *blob.Descriptor {
+		desc := &blob.Descriptor{ID: *id}
+		for i, status := range statuses {
+			desc.Replicas = append(desc.Replicas, blob.Replica{
+				Provider: []string{"f01", "f02"}[i],
+				Pieces:   []blob.Piece{{PieceCID: "baga", Status: status}},
+			})
+		}
+		return desc
+	}
+	types := func(events []*Event) []EventType {
+		var types []EventType
+		for _, event := range events {
+			types = append(types, event.Type)
+		}
+		return types
+	}
+
+	events, state := observe(&blobState{}, describe())
+	require.Empty(t, events)
+	require.Equal(t, &blobState{}, state)
+
+	events, state = observe(state, describe("proposed"))
+	require.Equal(t, []EventType{EventBlobPacked, EventDealProposed}, types(events))
+	require.Equal(t, "f01", events[1].Deal.Provider)
+
+	events, state = observe(state, describe("proposed", "published"))
+	require.Equal(t, []EventType{EventDealPublished}, types(events))
+	require.Equal(t, "f02", events[0].Deal.Provider)
+
+	events, state = observe(state, describe("active", "active"))
+	require.Equal(t, []EventType{EventDealActive, EventDealActive}, types(events))
+
+	events, state = observe(state, describe("active", "active"))
+	require.Empty(t, events)
+	require.NotNil(t, state)
+
+	events, state = observe(state, describe("expired", "slashed"))
+	require.Equal(t, []EventType{EventDealExpired, EventDealSlashed}, types(events))
+	require.Nil(t, state, "blob with only terminal deals must no longer be watched")
+
+	replicated := describe("active", "published")
+	replicated.TargetReplicas, replicated.ActiveReplicas = 1, 1
+	events, state = observe(&blobState{}, replicated)
+	require.Equal(t, []EventType{EventBlobPacked, EventDealActive, EventDealPublished}, types(events))
+	require.Nil(t, state, "blob with as many active replicas as targeted must no longer be watched")
+}
+
+func TestWatchList(t *testing.T) {
+	dir := t.TempDir()
+	watched, err := blob.NewID()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "watched.json"), []byte(`{"`+watched.String()+`":{"packed":true}}`), 0600))
+
+	// Blobs watched by earlier versions are migrated from the JSON file.
+	subject, err := NewNotifier(WithDir(dir))
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(dir, "watched.json"))
+	state, ok := subject.watched.get(watched.String())
+	require.True(t, ok)
+	require.True(t, state.Packed)
+
+	// Stored blobs are not watched unless there are subscriptions.
+	unwatched, err := blob.NewID()
+	require.NoError(t, err)
+	subject.BlobStored(&blob.Descriptor{ID: *unwatched})
+	require.ElementsMatch(t, []string{watched.String()}, subject.watched.ids())
+	_, err = subject.Subscribe("https://example.com", "")
+	require.NoError(t, err)
+	stored, err := blob.NewID()
+	require.NoError(t, err)
+	subject.BlobStored(&blob.Descriptor{ID: *stored})
+	require.NoError(t, subject.watched.update(map[string]*blobState{watched.String(): nil}))
+	require.NoError(t, subject.Shutdown(context.Background()))
+
+	// Changes are persisted across restarts.
+	subject, err = NewNotifier(WithDir(dir))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{stored.String()}, subject.watched.ids())
+	require.NoError(t, subject.Shutdown(context.Background()))
+}
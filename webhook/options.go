@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type (
+	// Option is a configurable parameter in Notifier.
+	Option  func(*options) error
+	options struct {
+		dir             string
+		endpoints       []Subscription
+		pollInterval    time.Duration
+		maxAttempts     int
+		minRetryBackoff time.Duration
+		maxRetryBackoff time.Duration
+		deliveryTimeout time.Duration
+		httpClient      *http.Client
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		dir:             filepath.Join(os.TempDir(), "motion-webhooks"),
+		pollInterval:    5 * time.Minute,
+		maxAttempts:     10,
+		minRetryBackoff: 10 * time.Second,
+		maxRetryBackoff: time.Hour,
+		deliveryTimeout: 10 * time.Second,
+		httpClient:      http.DefaultClient,
+	}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithDir sets the local directory at which subscriptions, pending deliveries
+// and the last observed state of watched blobs are stored.
+// Defaults to "motion-webhooks" under the OS temporary directory.
+// See: os.TempDir.
+func WithDir(dir string) Option {
+	return func(o *options) error {
+		o.dir = dir
+		return nil
+	}
+}
+
+// WithEndpoint adds a statically configured endpoint to which events of the
+// given types are delivered, signed with the given secret. All events are
+// delivered if no types are specified. Multiple endpoints may be added by
+// applying this option more than once. Unlike subscriptions registered via
+// Notifier.Subscribe, static endpoints are not persisted and cannot be
+// unsubscribed.
+func WithEndpoint(endpoint, secret string, types ...EventType) Option {
+	return func(o *options) error {
+		if err := validateEndpoint(endpoint, types); err != nil {
+			return err
+		}
+		if secret == "" {
+			return errors.New("webhook endpoint secret must not be empty")
+		}
+		o.endpoints = append(o.endpoints, Subscription{
+			ID:     staticSubscriptionID(endpoint),
+			URL:    endpoint,
+			Events: types,
+			Secret: secret,
+		})
+		return nil
+	}
+}
+
+// WithPollInterval sets the interval at which blobs are described to observe
+// their lifecycle transitions. Blobs are only polled while there is at least
+// one subscription.
+// Defaults to 5 minutes.
+func WithPollInterval(i time.Duration) Option {
+	return func(o *options) error {
+		if i <= 0 {
+			return errors.New("poll interval must be larger than zero")
+		}
+		o.pollInterval = i
+		return nil
+	}
+}
+
+// WithMaxAttempts sets the maximum number of attempts to deliver an event to
+// an endpoint, after which the delivery is discarded.
+// Defaults to 10.
+func WithMaxAttempts(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return errors.New("max attempts must be at least 1")
+		}
+		o.maxAttempts = n
+		return nil
+	}
+}
+
+// WithRetryBackoff sets the minimum and maximum delay between delivery
+// attempts. The delay doubles after each failed attempt, starting from min and
+// capped at max.
+// Defaults to 10 seconds and 1 hour respectively.
+func WithRetryBackoff(min, max time.Duration) Option {
+	return func(o *options) error {
+		if min <= 0 || max < min {
+			return errors.New("retry backoff must be positive with max at least min")
+		}
+		o.minRetryBackoff = min
+		o.maxRetryBackoff = max
+		return nil
+	}
+}
+
+// WithDeliveryTimeout sets the timeout of each attempt to deliver an event.
+// Defaults to 10 seconds.
+func WithDeliveryTimeout(t time.Duration) Option {
+	return func(o *options) error {
+		o.deliveryTimeout = t
+		return nil
+	}
+}
+
+// WithHttpClient sets the client with which events are delivered.
+// Defaults to http.DefaultClient.
+func WithHttpClient(c *http.Client) Option {
+	return func(o *options) error {
+		o.httpClient = c
+		return nil
+	}
+}
+
+func validateEndpoint(endpoint string, types []EventType) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidEndpoint
+	}
+	for _, typ := range types {
+		if !isKnownEventType(typ) {
+			return ErrUnknownEventType
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidEndpoint      = errors.New("webhook endpoint must be an absolute http or https URL")
+	ErrUnknownEventType     = errors.New("unknown webhook event type")
+	ErrSubscriptionNotFound = errors.New("no webhook subscription is found with given ID")
+)
+
+// Subscription is an endpoint to which events are delivered.
+type Subscription struct {
+	// ID uniquely identifies the subscription.
+	ID string `json:"id"`
+	// URL is the endpoint to which events are delivered as HTTP POST requests.
+	URL string `json:"url"`
+	// Events are the types of event delivered to the endpoint. All events are
+	// delivered if empty.
+	Events []EventType `json:"events,omitempty"`
+	// Secret is the key with which deliveries are signed. See: Sign.
+	Secret string `json:"secret"`
+	// CreatedAt is the time at which the subscription was registered. Zero for
+	// statically configured endpoints.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Matches checks whether events of the given type are delivered to the
+// subscription.
+func (s *Subscription) Matches(typ EventType) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, event := range s.Events {
+		if event == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptions holds the statically configured endpoints along with the
+// subscriptions registered at runtime, the latter of which are persisted as a
+// JSON file.
+type subscriptions struct {
+	path   string
+	static []Subscription
+
+	mu         sync.RWMutex
+	registered map[string]Subscription
+}
+
+func openSubscriptions(path string, static []Subscription) (*subscriptions, error) {
+	s := &subscriptions{
+		path:       path,
+		static:     static,
+		registered: make(map[string]Subscription),
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	default:
+		return nil, fmt.Errorf("failed to read webhook subscriptions: %w", err)
+	}
+	var registered []Subscription
+	if err := json.Unmarshal(data, &registered); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook subscriptions: %w", err)
+	}
+	for _, sub := range registered {
+		s.registered[sub.ID] = sub
+	}
+	return s, nil
+}
+
+func (s *subscriptions) add(sub Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registered[sub.ID] = sub
+	if err := s.persist(); err != nil {
+		delete(s.registered, sub.ID)
+		return err
+	}
+	return nil
+}
+
+func (s *subscriptions) remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.registered[id]
+	if !ok {
+		return ErrSubscriptionNotFound
+	}
+	delete(s.registered, id)
+	if err := s.persist(); err != nil {
+		s.registered[id] = sub
+		return err
+	}
+	return nil
+}
+
+// persist writes the registered subscriptions to file. The caller must hold the
+// write lock.
+func (s *subscriptions) persist() error {
+	return writeJsonFile(s.path, s.sortedRegistered())
+}
+
+// list returns the registered subscriptions in ascending order of creation.
+func (s *subscriptions) list() []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sortedRegistered()
+}
+
+func (s *subscriptions) sortedRegistered() []Subscription {
+	subs := make([]Subscription, 0, len(s.registered))
+	for _, sub := range s.registered {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].CreatedAt.Equal(subs[j].CreatedAt) {
+			return subs[i].ID < subs[j].ID
+		}
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs
+}
+
+// get returns the static or registered subscription with the given ID.
+func (s *subscriptions) get(id string) (Subscription, bool) {
+	for _, sub := range s.static {
+		if sub.ID == id {
+			return sub, true
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub, ok := s.registered[id]
+	return sub, ok
+}
+
+// matching returns the static and registered subscriptions to which events of
+// the given type are delivered.
+func (s *subscriptions) matching(typ EventType) []Subscription {
+	var subs []Subscription
+	for _, sub := range s.static {
+		if sub.Matches(typ) {
+			subs = append(subs, sub)
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sub := range s.registered {
+		if sub.Matches(typ) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+func (s *subscriptions) isEmpty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.static) == 0 && len(s.registered) == 0
+}
+
+// staticSubscriptionID derives a stable ID for a statically configured
+// endpoint, so that its pending deliveries survive restarts.
+func staticSubscriptionID(endpoint string) string {
+	digest := sha256.Sum256([]byte(endpoint))
+	return "static-" + hex.EncodeToString(digest[:8])
+}
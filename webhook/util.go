@@ -0,0 +1,24 @@
+package webhook
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// writeJsonFile atomically replaces the file at the given path with the JSON
+// encoding of v.
+func writeJsonFile(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
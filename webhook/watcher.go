@@ -0,0 +1,289 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/motion/blob"
+	bolt "go.etcd.io/bbolt"
+)
+
+// watchedBucket is the bucket of the watch list database that maps the IDs of
+// watched blobs to their last observed blobState.
+var watchedBucket = []byte("watched")
+
+type (
+	// blobState is the last observed lifecycle state of a watched blob.
+	blobState struct {
+		// Packed is whether the blob has been observed as part of a piece.
+		Packed bool `json:"packed,omitempty"`
+		// Deals maps the provider and piece CID of each observed deal to its
+		// last observed status.
+		Deals map[string]string `json:"deals,omitempty"`
+	}
+	// watchList holds the last observed state of the blobs that are yet to
+	// be replicated, persisted in an embedded bbolt database one blob at a
+	// time.
+	watchList struct {
+		db *bolt.DB
+
+		mu    sync.Mutex
+		blobs map[string]*blobState
+	}
+)
+
+// openWatchList opens the watch list database at the given path, creating it
+// if it does not exist. Blobs watched by earlier versions of Motion, which
+// kept the watch list as a JSON file at legacyPath, are moved into the
+// database, after which the file is removed.
+func openWatchList(path, legacyPath string) (*watchList, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open webhook watch list: %w", err)
+	}
+	w := &watchList{
+		db:    db,
+		blobs: make(map[string]*blobState),
+	}
+	if err := w.load(legacyPath); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *watchList) load(legacyPath string) error {
+	data, err := os.ReadFile(legacyPath)
+	switch {
+	case err == nil:
+		legacy := make(map[string]*blobState)
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return fmt.Errorf("failed to decode webhook watch list: %w", err)
+		}
+		if err := w.db.Update(func(tx *bolt.Tx) error {
+			for id, state := range legacy {
+				if err := putBlobState(tx, id, state); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to migrate webhook watch list: %w", err)
+		}
+		if err := os.Remove(legacyPath); err != nil {
+			return fmt.Errorf("failed to remove migrated webhook watch list: %w", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("failed to read webhook watch list: %w", err)
+	}
+	return w.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(watchedBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			state := &blobState{}
+			if err := json.Unmarshal(v, state); err != nil {
+				return fmt.Errorf("failed to decode webhook watch list entry '%s': %w", k, err)
+			}
+			w.blobs[string(k)] = state
+			return nil
+		})
+	})
+}
+
+func (w *watchList) close() error {
+	return w.db.Close()
+}
+
+func (w *watchList) watch(id blob.ID) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := id.String()
+	if _, ok := w.blobs[key]; ok {
+		return nil
+	}
+	state := &blobState{}
+	if err := w.db.Update(func(tx *bolt.Tx) error {
+		return putBlobState(tx, key, state)
+	}); err != nil {
+		return err
+	}
+	w.blobs[key] = state
+	return nil
+}
+
+// ids returns the IDs of watched blobs.
+func (w *watchList) ids() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ids := make([]string, 0, len(w.blobs))
+	for id := range w.blobs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (w *watchList) get(id string) (*blobState, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, ok := w.blobs[id]
+	return state, ok
+}
+
+// update sets the state of the given blobs, and stops watching those with nil
+// state. Blobs that are no longer watched are ignored. Only the given blobs
+// are persisted, in a single transaction.
+func (w *watchList) update(states map[string]*blobState) error {
+	if len(states) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.db.Update(func(tx *bolt.Tx) error {
+		for id, state := range states {
+			if _, ok := w.blobs[id]; !ok {
+				continue
+			}
+			if state == nil {
+				if bucket := tx.Bucket(watchedBucket); bucket != nil {
+					if err := bucket.Delete([]byte(id)); err != nil {
+						return err
+					}
+				}
+			} else if err := putBlobState(tx, id, state); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for id, state := range states {
+		if _, ok := w.blobs[id]; !ok {
+			continue
+		}
+		if state == nil {
+			delete(w.blobs, id)
+		} else {
+			w.blobs[id] = state
+		}
+	}
+	return nil
+}
+
+func putBlobState(tx *bolt.Tx, id string, state *blobState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	bucket, err := tx.CreateBucketIfNotExists(watchedBucket)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(id), data)
+}
+
+// observe compares the given descriptor of a blob to its previously observed
+// state, and returns the events for the transitions since, along with the new
+// state. Nil state is returned once the blob has as many active replicas as
+// targeted, or all the deals observed for it are terminal, after which the
+// blob need no longer be watched.
+func observe(prev *blobState, desc *blob.Descriptor) ([]*Event, *blobState) {
+	next := &blobState{
+		Packed: prev.Packed,
+		Deals:  make(map[string]string),
+	}
+	var events []*Event
+	terminal := true
+	for _, replica := range desc.Replicas {
+		for _, piece := range replica.Pieces {
+			if !next.Packed && piece.PieceCID != "" {
+				next.Packed = true
+				event := newEvent(EventBlobPacked, desc.ID)
+				event.PieceCID = piece.PieceCID
+				events = append(events, event)
+			}
+			key := replica.Provider + "/" + piece.PieceCID
+			next.Deals[key] = piece.Status
+			terminal = terminal && isTerminalDealStatus(piece.Status)
+			if prev.Deals[key] == piece.Status {
+				continue
+			}
+			typ, ok := dealEventTypes[piece.Status]
+			if !ok {
+				continue
+			}
+			event := newEvent(typ, desc.ID)
+			event.PieceCID = piece.PieceCID
+			event.Deal = &EventDeal{
+				Provider:   replica.Provider,
+				Status:     piece.Status,
+				Expiration: piece.Expiration,
+			}
+			events = append(events, event)
+		}
+	}
+	if desc.TargetReplicas != 0 && desc.ActiveReplicas >= desc.TargetReplicas {
+		return events, nil
+	}
+	if len(next.Deals) == 0 {
+		next.Deals = nil
+	} else if terminal {
+		return events, nil
+	}
+	return events, next
+}
+
+// poll describes each watched blob, and emits the events for its transitions
+// since it was last observed.
+func (n *Notifier) poll(ctx context.Context) {
+	updates := make(map[string]*blobState)
+	defer func() {
+		if err := n.watched.update(updates); err != nil {
+			logger.Errorw("Failed to persist webhook watch list", "err", err)
+		}
+	}()
+	for _, key := range n.watched.ids() {
+		if ctx.Err() != nil {
+			return
+		}
+		var id blob.ID
+		if err := id.Decode(key); err != nil {
+			logger.Warnw("Ignoring invalid blob ID in webhook watch list", "id", key, "err", err)
+			updates[key] = nil
+			continue
+		}
+		desc, err := n.store.Describe(ctx, id)
+		switch {
+		case err == nil:
+		case errors.Is(err, blob.ErrBlobNotFound):
+			logger.Debugw("Watched blob no longer exists; no longer watching", "id", key)
+			updates[key] = nil
+			continue
+		default:
+			logger.Warnw("Failed to describe watched blob; retrying at next poll", "id", key, "err", err)
+			continue
+		}
+		prev, ok := n.watched.get(key)
+		if !ok {
+			continue
+		}
+		events, next := observe(prev, desc)
+		if err := n.emit(events...); err != nil {
+			// Leave the state as it was so that the events are emitted again at
+			// next poll.
+			logger.Errorw("Failed to emit webhook events", "id", key, "err", err)
+			continue
+		}
+		if next == nil || !reflect.DeepEqual(prev, next) {
+			updates[key] = next
+		}
+	}
+}